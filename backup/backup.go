@@ -0,0 +1,143 @@
+// Package backup implements the backup and restore CLI subcommands. Postgres
+// tables are dumped to the backup directory as JSON lines; ClickHouse tables
+// are written by the server itself with BACKUP ... TO Disk(...) or S3(...).
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mabletask/api/database"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Options struct {
+	// Dir receives the Postgres dumps and the manifest.
+	Dir string
+	// S3URL, when set, is the ClickHouse backup destination. Otherwise the
+	// backup goes to Disk on the ClickHouse server, which must be listed in
+	// its backups.allowed_disk setting.
+	S3URL string
+	Disk  string
+}
+
+func Backup(ctx context.Context, db *sql.DB, ch *database.ClickHouseClient, opts Options) error {
+	if err := os.MkdirAll(filepath.Join(opts.Dir, "postgres"), 0o700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	manifest := &Manifest{CreatedAt: time.Now().UTC()}
+	name := "mable-" + manifest.CreatedAt.Format("20060102-150405")
+	if opts.S3URL != "" {
+		manifest.ClickHouse.S3URL = strings.TrimSuffix(opts.S3URL, "/") + "/" + name + "/"
+	} else {
+		manifest.ClickHouse.Disk, manifest.ClickHouse.Path = opts.Disk, name
+	}
+
+	tables, err := postgresTables(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		n, err := dumpPostgresTable(ctx, db, table, postgresDumpPath(opts.Dir, table))
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("Backup: dumped %d rows from Postgres table %s", n, table)
+		manifest.Postgres = append(manifest.Postgres, PostgresTable{Table: table, Rows: n})
+	}
+
+	manifest.ClickHouseTables, err = clickHouseTables(ctx, ch)
+	if err != nil {
+		return err
+	}
+	if len(manifest.ClickHouseTables) > 0 {
+		statement := fmt.Sprintf("BACKUP %s TO %s", tableList(manifest.ClickHouseTables), manifest.ClickHouse.sql())
+		if err := ch.Conn.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("ClickHouse backup failed: %w", err)
+		}
+		log.Info().Msgf("Backup: ClickHouse tables %s written to %s", strings.Join(manifest.ClickHouseTables, ", "), manifest.ClickHouse.location())
+	}
+
+	return writeManifest(opts.Dir, manifest)
+}
+
+// Restore loads a backup into the current environment. Postgres tables must
+// already exist (run the migrations first); rows whose keys exist are kept.
+// ClickHouse tables are created by RESTORE when missing and must be empty
+// otherwise.
+func Restore(ctx context.Context, db *sql.DB, ch *database.ClickHouseClient, dir string) error {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, t := range manifest.Postgres {
+		n, err := loadPostgresTable(ctx, tx, t.Table, postgresDumpPath(dir, t.Table))
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("Restore: loaded %d of %d rows into Postgres table %s", n, t.Rows, t.Table)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit Postgres restore: %w", err)
+	}
+
+	if len(manifest.ClickHouseTables) > 0 {
+		statement := fmt.Sprintf("RESTORE %s FROM %s", tableList(manifest.ClickHouseTables), manifest.ClickHouse.sql())
+		if err := ch.Conn.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("ClickHouse restore failed: %w", err)
+		}
+		log.Info().Msgf("Restore: ClickHouse tables %s restored from %s", strings.Join(manifest.ClickHouseTables, ", "), manifest.ClickHouse.location())
+	}
+	return nil
+}
+
+func clickHouseTables(ctx context.Context, ch *database.ClickHouseClient) ([]string, error) {
+	rows, err := ch.Conn.Query(ctx, `
+		SELECT name
+		FROM system.tables
+		WHERE database = currentDatabase() AND engine LIKE '%MergeTree'
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ClickHouse tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan ClickHouse table name: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+func tableList(tables []string) string {
+	parts := make([]string, len(tables))
+	for i, table := range tables {
+		parts[i] = "TABLE `" + strings.ReplaceAll(table, "`", "") + "`"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (t ClickHouseTarget) location() string {
+	if t.S3URL != "" {
+		return t.S3URL
+	}
+	return fmt.Sprintf("disk %s:%s", t.Disk, t.Path)
+}
@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const manifestFile = "manifest.json"
+
+type PostgresTable struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// ClickHouseTarget is where the ClickHouse server wrote its BACKUP: a backup
+// disk and path, or an S3 URL. Credentials are never stored in the manifest.
+type ClickHouseTarget struct {
+	Disk  string `json:"disk,omitempty"`
+	Path  string `json:"path,omitempty"`
+	S3URL string `json:"s3Url,omitempty"`
+}
+
+// Manifest describes a backup directory. Postgres tables are listed in an
+// order that satisfies their foreign keys, so restore loads them as listed.
+type Manifest struct {
+	CreatedAt        time.Time        `json:"createdAt"`
+	Postgres         []PostgresTable  `json:"postgres"`
+	ClickHouseTables []string         `json:"clickhouseTables"`
+	ClickHouse       ClickHouseTarget `json:"clickhouse"`
+}
+
+// sql renders the target as a BACKUP/RESTORE destination. S3 credentials come
+// from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY when set.
+func (t ClickHouseTarget) sql() string {
+	if t.S3URL != "" {
+		key, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if key != "" {
+			return fmt.Sprintf("S3(%s, %s, %s)", quote(t.S3URL), quote(key), quote(secret))
+		}
+		return fmt.Sprintf("S3(%s)", quote(t.S3URL))
+	}
+	return fmt.Sprintf("Disk(%s, %s)", quote(t.Disk), quote(t.Path))
+}
+
+func quote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &m, nil
+}
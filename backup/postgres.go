@@ -0,0 +1,170 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// postgresTables returns the public tables ordered so that every table comes
+// after the tables its foreign keys reference.
+func postgresTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT tablename FROM pg_tables WHERE schemaname = 'public' ORDER BY tablename;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tables: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT conrelid::regclass::text, confrelid::regclass::text
+		FROM pg_constraint
+		WHERE contype = 'f' AND connamespace = 'public'::regnamespace;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foreign keys: %w", err)
+	}
+	deps := map[string][]string{}
+	for rows.Next() {
+		var table, references string
+		if err := rows.Scan(&table, &references); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan foreign key: %w", err)
+		}
+		if table != references {
+			deps[table] = append(deps[table], references)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating foreign keys: %w", err)
+	}
+
+	ordered := make([]string, 0, len(tables))
+	visited := map[string]bool{}
+	var visit func(string)
+	visit = func(table string) {
+		if visited[table] {
+			return
+		}
+		visited[table] = true
+		refs := deps[table]
+		sort.Strings(refs)
+		for _, ref := range refs {
+			visit(ref)
+		}
+		ordered = append(ordered, table)
+	}
+	for _, table := range tables {
+		visit(table)
+	}
+	return ordered, nil
+}
+
+// dumpPostgresTable writes every row of the table as one JSON object per line.
+func dumpPostgresTable(ctx context.Context, db *sql.DB, table, path string) (int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT row_to_json(t)::text FROM %s t;`, pq.QuoteIdentifier(table)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var n int64
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return n, fmt.Errorf("failed to scan row of %s: %w", table, err)
+		}
+		w.WriteString(line)
+		w.WriteByte('\n')
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("error iterating %s: %w", table, err)
+	}
+	if err := w.Flush(); err != nil {
+		return n, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return n, f.Close()
+}
+
+// loadPostgresTable inserts the dumped rows into an existing table, skipping
+// rows whose key already exists, then moves serial sequences past the
+// restored IDs.
+func loadPostgresTable(ctx context.Context, tx *sql.Tx, table, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	insert := fmt.Sprintf(`INSERT INTO %[1]s SELECT * FROM json_populate_record(NULL::%[1]s, $1) ON CONFLICT DO NOTHING;`, pq.QuoteIdentifier(table))
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var n int64
+	for scanner.Scan() {
+		if _, err := tx.ExecContext(ctx, insert, scanner.Text()); err != nil {
+			return n, fmt.Errorf("failed to restore row %d of %s: %w", n+1, table, err)
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1 AND column_default LIKE 'nextval(%';
+	`, table)
+	if err != nil {
+		return n, fmt.Errorf("failed to find sequences of %s: %w", table, err)
+	}
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return n, fmt.Errorf("failed to scan sequence column: %w", err)
+		}
+		columns = append(columns, column)
+	}
+	rows.Close()
+	for _, column := range columns {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			SELECT setval(pg_get_serial_sequence($1, $2), COALESCE(MAX(%s), 0) + 1, false) FROM %s;
+		`, pq.QuoteIdentifier(column), pq.QuoteIdentifier(table)), table, column)
+		if err != nil {
+			return n, fmt.Errorf("failed to reset sequence of %s.%s: %w", table, column, err)
+		}
+	}
+	return n, nil
+}
+
+func postgresDumpPath(dir, table string) string {
+	return filepath.Join(dir, "postgres", table+".ndjson")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"mabletask/api/backup"
+	"mabletask/api/database"
+	"mabletask/api/importer"
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runCommand executes a CLI subcommand instead of starting the server.
+func runCommand(name string, args []string, dbClient *database.DBClient, chClient *database.ClickHouseClient) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch name {
+	case "backup":
+		fs := flag.NewFlagSet("backup", flag.ExitOnError)
+		opts := backup.Options{}
+		fs.StringVar(&opts.Dir, "dir", "", "directory for the Postgres dumps and manifest (required)")
+		fs.StringVar(&opts.S3URL, "s3", "", "S3 URL for the ClickHouse backup, e.g. https://bucket.s3.amazonaws.com/backups")
+		fs.StringVar(&opts.Disk, "disk", "backups", "ClickHouse backup disk, used when -s3 is not set")
+		fs.Parse(args)
+		if opts.Dir == "" {
+			return fmt.Errorf("backup: -dir is required")
+		}
+		return backup.Backup(ctx, dbClient.DB, chClient, opts)
+	case "restore":
+		fs := flag.NewFlagSet("restore", flag.ExitOnError)
+		dir := fs.String("dir", "", "backup directory containing manifest.json (required)")
+		fs.Parse(args)
+		if *dir == "" {
+			return fmt.Errorf("restore: -dir is required")
+		}
+		return backup.Restore(ctx, dbClient.DB, chClient, *dir)
+	case "import":
+		return runImport(ctx, args, chClient, dbClient)
+	default:
+		return fmt.Errorf("unknown command %q (expected backup, restore or import)", name)
+	}
+}
+
+func runImport(ctx context.Context, args []string, chClient *database.ClickHouseClient, dbClient *database.DBClient) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "", "CSV or TSV file to import (required)")
+	mappingPath := fs.String("mapping", "", "JSON column-mapping spec (required)")
+	format := fs.String("format", "", "csv or tsv (default: from the file extension)")
+	projectID := fs.String("project", models.DefaultProjectID, "project (site) the events belong to")
+	batchSize := fs.Int("batch", 5000, "events per insert batch")
+	fs.Parse(args)
+	if *file == "" || *mappingPath == "" {
+		return fmt.Errorf("import: -file and -mapping are required")
+	}
+	if !utils.IsValidProjectID(*projectID) || *batchSize <= 0 {
+		return fmt.Errorf("import: invalid -project or -batch")
+	}
+
+	mapping, err := importer.LoadMapping(*mappingPath)
+	if err != nil {
+		return err
+	}
+	delimiter := ','
+	if *format == "tsv" || (*format == "" && strings.EqualFold(filepath.Ext(*file), ".tsv")) {
+		delimiter = '\t'
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	defer f.Close()
+
+	suppressionStore := store.NewSuppressionStore(dbClient.DB, chClient)
+	if err := suppressionStore.Refresh(ctx); err != nil {
+		return fmt.Errorf("import: failed to load suppression list: %w", err)
+	}
+
+	ipAnonymizer, err := utils.IPAnonymizerFromEnv()
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	result, err := importer.ImportCSV(ctx, f, mapping, importer.Options{
+		ProjectID:    *projectID,
+		Delimiter:    delimiter,
+		BatchSize:    *batchSize,
+		IPAnonymizer: ipAnonymizer,
+	}, store.NewAnalyticsStore(chClient), suppressionStore)
+	if result != nil {
+		log.Info().Msgf("Import finished: %d rows, %d imported, %d skipped, %d suppressed", result.Rows, result.Imported, result.Skipped, result.Suppressed)
+	}
+	return err
+}
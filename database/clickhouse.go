@@ -3,12 +3,12 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/rs/zerolog/log"
 )
 
 type ClickHouseClient struct {
@@ -62,13 +62,13 @@ func NewClickHouseDB() (*ClickHouseClient, error) {
 		return nil, fmt.Errorf("failed to ping ClickHouse: %w", err)
 	}
 
-	log.Println("Successfully connected to ClickHouse database via Native TCP (direct options)!")
-	return &ClickHouseClient{Conn: conn}, nil
+	log.Info().Msg("Successfully connected to ClickHouse database via Native TCP (direct options)!")
+	return &ClickHouseClient{Conn: tracedConn{conn}}, nil
 }
 
 func (c *ClickHouseClient) Close() {
 	if c.Conn != nil {
 		c.Conn.Close()
-		log.Println("ClickHouse connection closed.")
+		log.Info().Msg("ClickHouse connection closed.")
 	}
 }
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	"mabletask/api/tracing"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxTracedQuery is how much of a query's text is kept on its span. Queries
+// take their values as arguments, so the text holds no event data.
+const maxTracedQuery = 4096
+
+// tracedConn is a ClickHouse connection that records a client span for every
+// query. A span is named after the method that ran the query, such as
+// "AnalyticsStore.GetStats", so slow stats queries show up by name in a trace.
+type tracedConn struct {
+	driver.Conn
+}
+
+func (c tracedConn) start(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	ctx, span := tracing.Tracer().Start(ctx, "clickhouse."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameClickHouse,
+			semconv.DBOperationName(queryOperation(query)),
+			semconv.DBQueryText(truncateQuery(query)),
+		),
+	)
+	// Walking the stack is only worth it for spans that are exported.
+	if span.IsRecording() {
+		if caller := queryCaller(); caller != "" {
+			span.SetName(shortFunctionName(caller))
+			span.SetAttributes(semconv.CodeFunctionName(caller))
+		}
+	}
+	return ctx, span
+}
+
+func (c tracedConn) Select(ctx context.Context, dest any, query string, args ...any) error {
+	ctx, span := c.start(ctx, "select", query)
+	err := c.Conn.Select(ctx, dest, query, args...)
+	tracing.End(span, err)
+	return err
+}
+
+// Query's span ends when the rows are closed, so it covers reading them.
+func (c tracedConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	ctx, span := c.start(ctx, "query", query)
+	rows, err := c.Conn.Query(ctx, query, args...)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+// QueryRow's span ends when the row is scanned.
+func (c tracedConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	ctx, span := c.start(ctx, "query_row", query)
+	row := c.Conn.QueryRow(ctx, query, args...)
+	if err := row.Err(); err != nil {
+		tracing.End(span, err)
+		return row
+	}
+	return &tracedRow{Row: row, span: span}
+}
+
+func (c tracedConn) Exec(ctx context.Context, query string, args ...any) error {
+	ctx, span := c.start(ctx, "exec", query)
+	err := c.Conn.Exec(ctx, query, args...)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedConn) AsyncInsert(ctx context.Context, query string, wait bool, args ...any) error {
+	ctx, span := c.start(ctx, "async_insert", query)
+	err := c.Conn.AsyncInsert(ctx, query, wait, args...)
+	tracing.End(span, err)
+	return err
+}
+
+// PrepareBatch's span ends when the batch is sent, aborted or closed, so it
+// covers appending the rows as well as the insert.
+func (c tracedConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	ctx, span := c.start(ctx, "batch", query)
+	batch, err := c.Conn.PrepareBatch(ctx, query, opts...)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	return &tracedBatch{Batch: batch, span: span}, nil
+}
+
+type tracedRows struct {
+	driver.Rows
+	span trace.Span
+	read int
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		r.read++
+		return true
+	}
+	return false
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	if r.span != nil {
+		r.span.SetAttributes(semconv.DBResponseReturnedRows(r.read))
+		tracing.End(r.span, r.Rows.Err())
+		r.span = nil
+	}
+	return err
+}
+
+type tracedRow struct {
+	driver.Row
+	span trace.Span
+}
+
+func (r *tracedRow) end(err error) error {
+	if r.span != nil {
+		tracing.End(r.span, err)
+		r.span = nil
+	}
+	return err
+}
+
+func (r *tracedRow) Scan(dest ...any) error {
+	return r.end(r.Row.Scan(dest...))
+}
+
+func (r *tracedRow) ScanStruct(dest any) error {
+	return r.end(r.Row.ScanStruct(dest))
+}
+
+type tracedBatch struct {
+	driver.Batch
+	span trace.Span
+}
+
+func (b *tracedBatch) end(err error) error {
+	if b.span != nil {
+		b.span.SetAttributes(attribute.Int("db.operation.batch.rows", b.Batch.Rows()))
+		tracing.End(b.span, err)
+		b.span = nil
+	}
+	return err
+}
+
+func (b *tracedBatch) Send() error {
+	return b.end(b.Batch.Send())
+}
+
+func (b *tracedBatch) Abort() error {
+	return b.end(b.Batch.Abort())
+}
+
+func (b *tracedBatch) Close() error {
+	return b.end(b.Batch.Close())
+}
+
+// queryOperation returns the first keyword of query, e.g. "SELECT".
+func queryOperation(query string) string {
+	if fields := strings.Fields(query); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return ""
+}
+
+func truncateQuery(query string) string {
+	query = strings.TrimSpace(query)
+	if len(query) > maxTracedQuery {
+		return query[:maxTracedQuery] + "..."
+	}
+	return query
+}
+
+// queryCaller returns the full name of the function that ran the query,
+// skipping this package and the store's query and queryRow helpers, which
+// only wrap the connection.
+func queryCaller() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		name := frame.Function
+		switch {
+		case strings.HasPrefix(name, "mabletask/api/database."),
+			strings.HasSuffix(name, ").query"), strings.HasSuffix(name, ").queryRow"):
+		default:
+			return name
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// shortFunctionName turns "mabletask/api/store.(*AnalyticsStore).GetStats"
+// into "AnalyticsStore.GetStats".
+func shortFunctionName(name string) string {
+	name = name[strings.LastIndex(name, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.NewReplacer("(*", "", ")", "").Replace(name)
+}
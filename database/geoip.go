@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+	"github.com/rs/zerolog/log"
+)
+
+// GeoLocation is where an IP address is located. Country is an ISO 3166-1
+// alpha-2 code; Region is the ISO 3166-2 subdivision code without the country
+// prefix, e.g. "BY" for Bavaria. Fields the database does not know are empty.
+type GeoLocation struct {
+	Country string
+	Region  string
+	City    string
+}
+
+// GeoIP resolves IP addresses with a MaxMind GeoIP2 or GeoLite2 City (or
+// Country) database. A nil *GeoIP resolves nothing.
+type GeoIP struct {
+	reader *geoip2.Reader
+	// city is false for Country databases, which have no region or city.
+	city bool
+}
+
+// NewGeoIP opens the mmdb database at GEOIP_DB_PATH. It returns nil without an
+// error when the variable is not set, disabling lookups.
+func NewGeoIP() (*GeoIP, error) {
+	path := os.Getenv("GEOIP_DB_PATH")
+	if path == "" {
+		log.Info().Msg("GEOIP_DB_PATH not set, events will not be geolocated")
+		return nil, nil
+	}
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database %s: %w", path, err)
+	}
+	meta := reader.Metadata()
+	log.Info().Msgf("GeoIP database %s (%s) loaded", path, meta.DatabaseType)
+	return &GeoIP{reader: reader, city: strings.Contains(meta.DatabaseType, "City")}, nil
+}
+
+// Lookup returns the location of ip, and false when it cannot be resolved.
+func (g *GeoIP) Lookup(ip string) (GeoLocation, bool) {
+	var loc GeoLocation
+	if g == nil {
+		return loc, false
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.IsPrivate() || parsed.IsLoopback() {
+		return loc, false
+	}
+	if !g.city {
+		record, err := g.reader.Country(parsed)
+		if err != nil {
+			return loc, false
+		}
+		loc.Country = record.Country.IsoCode
+		return loc, loc.Country != ""
+	}
+	record, err := g.reader.City(parsed)
+	if err != nil {
+		return loc, false
+	}
+	loc.Country = record.Country.IsoCode
+	if len(record.Subdivisions) > 0 {
+		loc.Region = record.Subdivisions[0].IsoCode
+	}
+	loc.City = record.City.Names["en"]
+	return loc, loc.Country != ""
+}
+
+func (g *GeoIP) Close() {
+	if g != nil {
+		g.reader.Close()
+		log.Info().Msg("GeoIP database closed.")
+	}
+}
@@ -0,0 +1,13 @@
+-- Daily ad spend per project (site) and campaign, uploaded via /api/ad-spend.
+-- campaign matches the utm_campaign of the traffic the ads bought.
+CREATE TABLE IF NOT EXISTS ad_spend (
+    project_id VARCHAR(64) NOT NULL,
+    campaign VARCHAR(255) NOT NULL,
+    spend_date DATE NOT NULL,
+    source VARCHAR(64) NOT NULL DEFAULT '', -- Ad network, e.g. google_ads
+    spend NUMERIC(14, 2) NOT NULL CHECK (spend >= 0),
+    currency CHAR(3) NOT NULL, -- ISO 4217
+    uploaded_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, campaign, spend_date, source)
+);
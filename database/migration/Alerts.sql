@@ -0,0 +1,43 @@
+-- Threshold alerts on event metrics, evaluated periodically by the
+-- alert_evaluation scheduled task.
+CREATE TABLE IF NOT EXISTS alerts (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    event_type VARCHAR(128) NOT NULL,
+    metric VARCHAR(16) NOT NULL DEFAULT 'count' CHECK (metric IN ('count', 'unique_users')),
+    condition VARCHAR(16) NOT NULL CHECK (condition IN ('below', 'above', 'drop_pct', 'rise_pct')),
+    threshold DOUBLE PRECISION NOT NULL,
+    window_seconds INTEGER NOT NULL,
+    compare_offset_seconds INTEGER NOT NULL DEFAULT 0,
+    webhook_url VARCHAR(2048) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    state VARCHAR(16) NOT NULL DEFAULT 'ok' CHECK (state IN ('ok', 'firing')),
+    last_value DOUBLE PRECISION,
+    last_evaluated_at TIMESTAMP WITH TIME ZONE,
+    last_triggered_at TIMESTAMP WITH TIME ZONE,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_project ON alerts (project_id);
+
+-- State transitions of each alert and the outcome of their notification.
+CREATE TABLE IF NOT EXISTS alert_events (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id INTEGER NOT NULL REFERENCES alerts (id) ON DELETE CASCADE,
+    state VARCHAR(16) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    baseline DOUBLE PRECISION,
+    notified BOOLEAN NOT NULL DEFAULT FALSE,
+    notify_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_alert ON alert_events (alert_id, id);
+
+-- Existing deployments created before projects, keeping the alerts of the
+-- default project:
+-- ALTER TABLE alerts ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE alerts ALTER COLUMN project_id DROP DEFAULT;
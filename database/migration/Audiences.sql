@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS audiences (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    definition JSONB NOT NULL,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audiences_project ON audiences (project_id);
+
+-- Existing deployments created before projects, keeping the audiences of the
+-- default project:
+-- ALTER TABLE audiences ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE audiences ALTER COLUMN project_id DROP DEFAULT;
@@ -0,0 +1,14 @@
+-- Per-project ingestion blocklist. Events matching a rule are dropped before
+-- they are stored; hits counts them so the filtering stays visible.
+CREATE TABLE IF NOT EXISTS blocklist_rules (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    rule_type VARCHAR(16) NOT NULL CHECK (rule_type IN ('ip', 'user_agent', 'referrer')),
+    value TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    hits BIGINT NOT NULL DEFAULT 0,
+    last_hit_at TIMESTAMP WITH TIME ZONE,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (project_id, rule_type, value)
+);
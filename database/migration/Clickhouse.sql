@@ -4,21 +4,245 @@ CREATE TABLE analytics_events (
     event_type String,
     user_id String,
     session_id String,
-    timestamp DateTime64(3),
+    timestamp DateTime64(3, 'UTC'), -- Millisecond precision, always stored in UTC
     page_path String,
     referrer String,
     user_agent String,
     ip_address String,
     duration_ms Int64,
-    products String, -- To store json.RawMessage as a string
+    products Nested( -- Product line items, one array element per item
+        id String,
+        sku String,
+        name String,
+        price Float64, -- Unit price
+        quantity UInt32,
+        currency LowCardinality(String) -- ISO 4217
+    ),
+    experiments Nested( -- A/B experiment variants the visitor was assigned
+        id LowCardinality(String),
+        variant LowCardinality(String)
+    ),
+    web_vital_name LowCardinality(String), -- LCP, INP, CLS, TTFB or FCP for web_vital events
+    web_vital_value Float64, -- Milliseconds, except CLS
+    web_vital_rating LowCardinality(String), -- good, needs-improvement or poor
+    destination_url String, -- Link target of outbound_click events
+    form_id String, -- Form of form_start, form_submit and form_abandon events
+    revenue Float64, -- Order total of purchase events, in currency
+    currency LowCardinality(String), -- ISO 4217 currency of revenue
     location String, -- For timezone
-    event_data JSON -- For flexible arbitrary data (JSON type requires ClickHouse v21.10+ or Cloud)
+    event_data JSON, -- For flexible arbitrary data (JSON type requires ClickHouse v21.10+ or Cloud)
     -- If JSON type is not supported by your ClickHouse version, use String:
     -- event_data String
+    client_timestamp Nullable(DateTime64(3, 'UTC')), -- Event time reported by the SDK, before skew correction
+    group_id String, -- Account/company the event belongs to, if known
+    anonymous_id String, -- Visitor identifier assigned by the SDK before login
+    project_id LowCardinality(String) DEFAULT 'default', -- Site the event was tracked for
+    country LowCardinality(String), -- ISO 3166-1 alpha-2 country code
+    device_type LowCardinality(String), -- desktop, mobile, tablet, bot
+    browser LowCardinality(String), -- Browser family, e.g. Chrome
+    region LowCardinality(String), -- ISO 3166-2 subdivision code, from GeoIP
+    city String, -- City name, from GeoIP
+    browser_version LowCardinality(String), -- Major version of browser
+    os LowCardinality(String), -- Operating system family, e.g. Windows
+    utm_source LowCardinality(String), -- UTM campaign parameters, sent or parsed from page_path
+    utm_medium LowCardinality(String),
+    utm_campaign String,
+    utm_term String,
+    utm_content String,
+    channel LowCardinality(String), -- direct, search, social or referral, from referrer
+    schema_errors Array(String), -- How event_data fails the schema of a lenient event type; empty if valid
+    is_bot Bool DEFAULT false, -- Sent by a known bot or crawler, from user_agent and ip_address
+    sample_rate Float64 DEFAULT 1 -- Share of events kept by ingestion sampling; stats count each as 1 / sample_rate
 )
 ENGINE = MergeTree()
+PARTITION BY toYYYYMM(timestamp)
 ORDER BY (timestamp, event_type);
 
+-- Existing deployments created before timestamps were pinned to UTC:
+-- ALTER TABLE analytics_events MODIFY COLUMN timestamp DateTime64(3, 'UTC');
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS client_timestamp Nullable(DateTime64(3, 'UTC'));
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS group_id String;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS anonymous_id String;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS project_id LowCardinality(String) DEFAULT 'default';
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS country LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS device_type LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS browser LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS experiments Nested(id LowCardinality(String), variant LowCardinality(String));
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS web_vital_name LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS web_vital_value Float64;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS web_vital_rating LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS destination_url String;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS form_id String;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS revenue Float64;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS currency LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS region LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS city String;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS browser_version LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS os LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS utm_source LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS utm_medium LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS utm_campaign String;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS utm_term String;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS utm_content String;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS channel LowCardinality(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS schema_errors Array(String);
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS is_bot Bool DEFAULT false;
+-- ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS sample_rate Float64 DEFAULT 1;
+-- Parse the user agents of earlier events with a reprocess job using the useragent enricher,
+-- their UTM parameters with the utm enricher and their channel with the channel enricher.
+-- Flag the bots among them with the bot enricher (after the useragent enricher).
+-- Backfill purchase revenue recorded before the typed columns:
+-- ALTER TABLE analytics_events UPDATE
+--     revenue = JSONExtractFloat(toString(event_data), 'revenue'),
+--     currency = JSONExtractString(toString(event_data), 'currency')
+-- WHERE event_type = 'purchase';
+-- Products used to be a JSON string column; move it aside and add the Nested columns:
+-- ALTER TABLE analytics_events RENAME COLUMN products TO products_json;
+-- ALTER TABLE analytics_events ADD COLUMN products Nested(id String, sku String, name String, price Float64, quantity UInt32, currency LowCardinality(String));
+-- Backfill existing rows, then DROP COLUMN products_json once the mutation has finished:
+-- ALTER TABLE analytics_events UPDATE
+--     `products.id` = arrayMap(p -> JSONExtractString(p, 'id'), JSONExtractArrayRaw(products_json)),
+--     `products.sku` = arrayMap(p -> JSONExtractString(p, 'sku'), JSONExtractArrayRaw(products_json)),
+--     `products.name` = arrayMap(p -> JSONExtractString(p, 'name'), JSONExtractArrayRaw(products_json)),
+--     `products.price` = arrayMap(p -> JSONExtractFloat(p, 'price'), JSONExtractArrayRaw(products_json)),
+--     `products.quantity` = arrayMap(p -> greatest(toUInt32(JSONExtractUInt(p, 'quantity')), 1), JSONExtractArrayRaw(products_json)),
+--     `products.currency` = arrayMap(p -> JSONExtractString(p, 'currency'), JSONExtractArrayRaw(products_json))
+-- WHERE products_json != '';
+-- The monthly partition key cannot be added in place; to partition an existing table,
+-- create analytics_events_new with the statement above, then
+-- INSERT INTO analytics_events_new SELECT * FROM analytics_events and swap with EXCHANGE TABLES.
+
+-- Where each visitor originally came from: the referrer, landing page and UTM
+-- parameters of their first page-bearing event, per project. Filled at ingest by
+-- first_touch_mv; read with the argMinMerge combinators, grouped by visitor.
+-- visitor_id follows visitorExpr in store/analytics_store.go.
+CREATE TABLE IF NOT EXISTS first_touch (
+    project_id LowCardinality(String),
+    visitor_id String,
+    first_seen SimpleAggregateFunction(min, DateTime64(3, 'UTC')),
+    referrer AggregateFunction(argMin, String, DateTime64(3, 'UTC')),
+    landing_page AggregateFunction(argMin, String, DateTime64(3, 'UTC')),
+    utm_source AggregateFunction(argMin, String, DateTime64(3, 'UTC')),
+    utm_medium AggregateFunction(argMin, String, DateTime64(3, 'UTC')),
+    utm_campaign AggregateFunction(argMin, String, DateTime64(3, 'UTC')),
+    utm_term AggregateFunction(argMin, String, DateTime64(3, 'UTC')),
+    utm_content AggregateFunction(argMin, String, DateTime64(3, 'UTC'))
+)
+ENGINE = AggregatingMergeTree()
+ORDER BY (project_id, visitor_id);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS first_touch_mv TO first_touch AS
+SELECT
+    project_id,
+    if(user_id != '', user_id, if(anonymous_id != '', anonymous_id, session_id)) AS visitor_id,
+    min(timestamp) AS first_seen,
+    argMinState(referrer, timestamp) AS referrer,
+    argMinState(cutQueryString(page_path), timestamp) AS landing_page,
+    argMinState(utm_source, timestamp) AS utm_source,
+    argMinState(utm_medium, timestamp) AS utm_medium,
+    argMinState(utm_campaign, timestamp) AS utm_campaign,
+    argMinState(utm_term, timestamp) AS utm_term,
+    argMinState(utm_content, timestamp) AS utm_content
+FROM analytics_events
+WHERE page_path != ''
+GROUP BY project_id, visitor_id;
+
+-- Views created before the utm_* columns parse page_path instead; once the columns
+-- exist, DROP VIEW first_touch_mv and re-create it with the statement above.
+
+-- Backfill first touches of events ingested before the view existed:
+-- INSERT INTO first_touch SELECT ... FROM analytics_events WHERE page_path != '' GROUP BY project_id, visitor_id;
+-- using the SELECT of first_touch_mv.
+
+-- Traits of the users of a project, populated via POST /api/identify. Each
+-- trait is a row of its own: email, plan and company, or a custom trait with its
+-- JSON-encoded value. ReplacingMergeTree keeps the newest value of every trait,
+-- so concurrent identify calls merge instead of overwriting each other; read it
+-- with FINAL, grouped by project_id and user_id. A row with an empty name
+-- records an identify call without traits.
+CREATE TABLE IF NOT EXISTS user_traits (
+    project_id LowCardinality(String),
+    user_id String,
+    custom Bool,
+    name String,
+    value String,
+    updated_at DateTime64(3, 'UTC')
+)
+ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (project_id, user_id, custom, name);
+
+-- User to account associations of a project, populated via POST /api/group.
+CREATE TABLE IF NOT EXISTS user_groups (
+    project_id LowCardinality(String),
+    user_id String,
+    group_id String,
+    group_name String,
+    traits String, -- Group traits as a JSON object
+    updated_at DateTime64(3, 'UTC')
+)
+ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (project_id, user_id, group_id);
+
+-- The sorting key of user_groups cannot gain project_id in place. Existing
+-- deployments created before projects keep their rows in the default project:
+-- RENAME TABLE user_groups TO user_groups_old; create it with the statement above, then
+-- INSERT INTO user_groups SELECT 'default' AS project_id, * FROM user_groups_old;
+
+-- Deployments with one user_traits row per user (email, plan, company and a
+-- traits JSON column) split it into per-trait rows:
+-- RENAME TABLE user_traits TO user_traits_old; create it with the statement above, then
+-- INSERT INTO user_traits SELECT project_id, user_id, false, '', '', updated_at FROM user_traits_old FINAL;
+-- INSERT INTO user_traits SELECT project_id, user_id, false, f.1, f.2, updated_at FROM user_traits_old FINAL
+--     ARRAY JOIN [('email', email), ('plan', plan), ('company', company)] AS f WHERE f.2 != '';
+-- INSERT INTO user_traits SELECT project_id, user_id, true, t.1, t.2, updated_at FROM user_traits_old FINAL
+--     ARRAY JOIN JSONExtractKeysAndValuesRaw(traits) AS t;
+-- Tables created before projects have no project_id: use 'default' AS project_id instead.
+
+-- Materialized audience membership. Each refresh appends a full snapshot tagged
+-- with computed_at, so older snapshots double as membership history.
+CREATE TABLE IF NOT EXISTS audience_members (
+    audience_id UInt64,
+    user_id String,
+    computed_at DateTime64(3, 'UTC')
+)
+ENGINE = MergeTree()
+ORDER BY (audience_id, computed_at, user_id)
+TTL toDateTime(computed_at) + INTERVAL 90 DAY;
+
+-- Mirror of the active PostgreSQL suppressions list, used to exclude opted-out
+-- subjects from the queries of their project. The latest row per subject wins.
+-- Every suppression refresh (once a minute) reconciles it with PostgreSQL.
+CREATE TABLE IF NOT EXISTS suppressed_ids (
+    project_id LowCardinality(String),
+    subject_type LowCardinality(String), -- 'user' or 'anonymous'
+    subject_id String,
+    active UInt8,
+    updated_at DateTime64(3, 'UTC')
+)
+ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (project_id, subject_type, subject_id);
+
+-- Existing deployments created before projects keep their rows in the default project:
+-- RENAME TABLE suppressed_ids TO suppressed_ids_old; create it with the statement above, then
+-- INSERT INTO suppressed_ids SELECT 'default' AS project_id, * FROM suppressed_ids_old;
+
+-- Audit trail of /api/stats queries, written by the QueryLog middleware.
+CREATE TABLE IF NOT EXISTS query_log (
+    timestamp DateTime64(3, 'UTC'),
+    user_id Int64,
+    user_email String,
+    endpoint LowCardinality(String), -- Route pattern, e.g. /api/stats/funnel/:id
+    params String, -- Raw query string
+    status UInt16,
+    duration_ms Int64,
+    rows_returned UInt64,
+    ip_address String
+)
+ENGINE = MergeTree()
+PARTITION BY toYYYYMM(timestamp)
+ORDER BY (timestamp, user_id)
+TTL toDateTime(timestamp) + INTERVAL 1 YEAR;
+
 
 
 
@@ -0,0 +1,18 @@
+-- Tiered storage for analytics_events: parts whose newest event is older than
+-- 90 days move from the "hot" to the "cold" volume of the "tiered" storage policy
+-- defined in clickhouse-config/storage.xml, which must be installed first.
+-- Change the interval and re-run the MODIFY TTL statement to use another cutoff.
+-- Moves happen in the background; GET /api/admin/clickhouse/storage shows how
+-- much data lives in each tier.
+
+-- The new policy must contain the table's current disk ("default") for the
+-- change to be accepted.
+ALTER TABLE analytics_events MODIFY SETTING storage_policy = 'tiered';
+
+ALTER TABLE analytics_events MODIFY TTL toDateTime(timestamp) + INTERVAL 90 DAY TO VOLUME 'cold';
+
+-- query_log is rarely read once it is a few weeks old; it keeps its one-year
+-- deletion TTL in addition to the move:
+-- ALTER TABLE query_log MODIFY SETTING storage_policy = 'tiered';
+-- ALTER TABLE query_log MODIFY TTL toDateTime(timestamp) + INTERVAL 30 DAY TO VOLUME 'cold',
+--     toDateTime(timestamp) + INTERVAL 1 YEAR;
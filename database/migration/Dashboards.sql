@@ -0,0 +1,31 @@
+-- Dashboards and their widgets. Each widget stores the stats query it renders.
+CREATE TABLE IF NOT EXISTS dashboards (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dashboards_project ON dashboards (project_id);
+
+CREATE TABLE IF NOT EXISTS dashboard_widgets (
+    id SERIAL PRIMARY KEY,
+    dashboard_id INTEGER NOT NULL REFERENCES dashboards (id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('metric', 'series', 'funnel', 'table')),
+    title VARCHAR(255) NOT NULL,
+    query JSONB NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    layout JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dashboard_widgets_dashboard ON dashboard_widgets (dashboard_id, position);
+
+-- Existing deployments created before projects, keeping the dashboards of the
+-- default project:
+-- ALTER TABLE dashboards ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE dashboards ALTER COLUMN project_id DROP DEFAULT;
@@ -0,0 +1,22 @@
+-- Erasure requests for the analytics events of a project, each backed by a
+-- ClickHouse mutation.
+CREATE TABLE IF NOT EXISTS data_deletions (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    subject_type VARCHAR(16) NOT NULL CHECK (subject_type IN ('user', 'anonymous', 'session')),
+    subject_id VARCHAR(255) NOT NULL,
+    mutation_id VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'running',
+    parts_to_do BIGINT NOT NULL DEFAULT 0,
+    fail_reason TEXT NOT NULL DEFAULT '',
+    requested_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_deletions_project ON data_deletions (project_id);
+
+-- Existing deployments created before projects, keeping the requests of the
+-- default project:
+-- ALTER TABLE data_deletions ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE data_deletions ALTER COLUMN project_id DROP DEFAULT;
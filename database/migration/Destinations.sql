@@ -0,0 +1,21 @@
+-- Third-party tools a project's recorded events are forwarded to. config holds
+-- the settings of kind, such as a Segment write key or GA4 API secret; an
+-- empty event_types forwards all events.
+CREATE TABLE IF NOT EXISTS destinations (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL CHECK (kind IN ('webhook', 'segment', 'ga4')),
+    config JSONB NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    events_forwarded BIGINT NOT NULL DEFAULT 0,
+    last_success_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_error_at TIMESTAMP WITH TIME ZONE,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_destinations_project ON destinations (project_id);
@@ -0,0 +1,14 @@
+-- Pending email changes (/api/account/email). The new address is only set on
+-- the user once the token emailed to it is redeemed; only a SHA-256 hash of
+-- each token is stored.
+CREATE TABLE IF NOT EXISTS email_changes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user ON email_changes (user_id);
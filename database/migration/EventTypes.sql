@@ -0,0 +1,27 @@
+-- Registry of the event types of each project and the properties they are
+-- expected to carry.
+CREATE TABLE IF NOT EXISTS event_types (
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    display_name VARCHAR(255) NOT NULL DEFAULT '',
+    category VARCHAR(64) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    expected_properties JSONB NOT NULL DEFAULT '[]',
+    deprecated BOOLEAN NOT NULL DEFAULT FALSE,
+    deprecation_note TEXT NOT NULL DEFAULT '',
+    deprecated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, name)
+);
+
+-- JSON Schema eventData of the type is validated against at ingestion, and
+-- whether invalid events are rejected (strict) or recorded flagged (lenient).
+ALTER TABLE event_types ADD COLUMN IF NOT EXISTS schema JSONB;
+ALTER TABLE event_types ADD COLUMN IF NOT EXISTS schema_mode VARCHAR(16) NOT NULL DEFAULT 'lenient';
+
+-- Existing deployments created before projects, keeping the event types of the
+-- default project:
+-- ALTER TABLE event_types ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE event_types ALTER COLUMN project_id DROP DEFAULT;
+-- ALTER TABLE event_types DROP CONSTRAINT event_types_pkey, ADD PRIMARY KEY (project_id, name);
@@ -0,0 +1,11 @@
+-- Daily exchange rates as units of currency per 1 EUR, the convention of the
+-- ECB reference rates. Rows are uploaded by admins (source 'static') or
+-- fetched daily when EXCHANGE_RATES_URL is set (source 'ecb').
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    rate_date DATE NOT NULL,
+    currency CHAR(3) NOT NULL, -- ISO 4217
+    per_eur NUMERIC(18, 8) NOT NULL CHECK (per_eur > 0),
+    source VARCHAR(32) NOT NULL DEFAULT 'static',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (currency, rate_date)
+);
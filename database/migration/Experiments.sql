@@ -0,0 +1,24 @@
+-- A/B experiment definitions of a project. id is the experiment id sent on
+-- events in experiments[].id or in experiment_exposure eventData.
+CREATE TABLE IF NOT EXISTS experiments (
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    id VARCHAR(128) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    variants JSONB NOT NULL DEFAULT '[]',
+    control VARCHAR(128) NOT NULL DEFAULT '',
+    goal_id INTEGER REFERENCES goals (id) ON DELETE SET NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'running', 'completed')),
+    started_at TIMESTAMP WITH TIME ZONE,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, id)
+);
+
+-- Existing deployments created before projects, keeping the experiments of the
+-- default project:
+-- ALTER TABLE experiments ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE experiments ALTER COLUMN project_id DROP DEFAULT;
+-- ALTER TABLE experiments DROP CONSTRAINT experiments_pkey, ADD PRIMARY KEY (project_id, id);
@@ -0,0 +1,15 @@
+-- Asynchronous export jobs. Workers claim pending rows with FOR UPDATE SKIP LOCKED.
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    error TEXT NOT NULL DEFAULT '',
+    file_path TEXT NOT NULL DEFAULT '',
+    requested_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_pending ON export_jobs (created_at) WHERE status = 'pending';
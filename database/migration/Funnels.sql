@@ -0,0 +1,17 @@
+-- Saved funnel definitions (ordered steps, conversion window, filters).
+CREATE TABLE IF NOT EXISTS funnels (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    definition JSONB NOT NULL,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_funnels_project ON funnels (project_id);
+
+-- Existing deployments created before projects, keeping the funnels of the
+-- default project:
+-- ALTER TABLE funnels ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE funnels ALTER COLUMN project_id DROP DEFAULT;
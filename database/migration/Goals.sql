@@ -0,0 +1,25 @@
+-- Named conversion goals matched against analytics events.
+CREATE TABLE IF NOT EXISTS goals (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    event_type VARCHAR(128) NOT NULL DEFAULT '',
+    page_path VARCHAR(2048) NOT NULL DEFAULT '',
+    path_match VARCHAR(16) NOT NULL DEFAULT 'exact' CHECK (path_match IN ('exact', 'prefix', 'regex')),
+    property VARCHAR(128) NOT NULL DEFAULT '', -- eventData property the event must have
+    property_value VARCHAR(1024) NOT NULL DEFAULT '', -- and its value, if set
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_goals_project ON goals (project_id);
+
+-- Existing deployments created before property conditions:
+-- ALTER TABLE goals ADD COLUMN IF NOT EXISTS property VARCHAR(128) NOT NULL DEFAULT '';
+-- ALTER TABLE goals ADD COLUMN IF NOT EXISTS property_value VARCHAR(1024) NOT NULL DEFAULT '';
+
+-- Existing deployments created before projects, keeping the goals of the
+-- default project:
+-- ALTER TABLE goals ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE goals ALTER COLUMN project_id DROP DEFAULT;
@@ -0,0 +1,32 @@
+-- Persistent background job queue. Workers claim due rows with FOR UPDATE SKIP
+-- LOCKED and hold them for a lease; a running job whose lease expired (the
+-- worker died) is claimed again. Failed attempts are retried with backoff until
+-- max_attempts is reached.
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT NOT NULL DEFAULT '',
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs (run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_jobs_kind_status ON jobs (kind, status);
+
+-- Exports used to be polled from export_jobs directly; queue the ones still
+-- waiting. Erasure requests now start as 'pending' until a worker issues the
+-- mutation.
+INSERT INTO jobs (id, kind, payload)
+SELECT gen_random_uuid(), 'export', jsonb_build_object('exportId', e.id)
+FROM export_jobs e
+WHERE e.status IN ('pending', 'running')
+  AND NOT EXISTS (SELECT 1 FROM jobs j WHERE j.kind = 'export' AND j.payload->>'exportId' = e.id::text);
+
+ALTER TABLE data_deletions ALTER COLUMN status SET DEFAULT 'pending';
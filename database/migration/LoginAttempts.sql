@@ -0,0 +1,10 @@
+-- Failed login counts per client address and per account, used to throttle
+-- /api/login when Redis is not configured. Each row counts the attempts of a
+-- window ending at expires_at.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    count INTEGER NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_expires ON login_attempts (expires_at);
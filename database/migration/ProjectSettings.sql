@@ -0,0 +1,25 @@
+-- Per-project (site) settings. retention_days is chosen by the site and must not
+-- exceed max_retention_days, the plan limit set by admins; NULL means no choice
+-- (keep events up to the plan limit) or, for the limit, the server default.
+CREATE TABLE IF NOT EXISTS project_settings (
+    project_id VARCHAR(64) PRIMARY KEY,
+    retention_days INTEGER CHECK (retention_days > 0),
+    max_retention_days INTEGER CHECK (max_retention_days > 0),
+    base_currency CHAR(3), -- ISO 4217 currency revenue is reported in; NULL = server default
+    updated_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Existing deployments created before base currencies:
+-- ALTER TABLE project_settings ADD COLUMN IF NOT EXISTS base_currency CHAR(3);
+
+-- Retention of single event types within a project, e.g. to purge web_vital
+-- events sooner than the rest. It can only shorten the project's retention.
+CREATE TABLE IF NOT EXISTS event_type_retention (
+    project_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(128) NOT NULL,
+    retention_days INTEGER NOT NULL CHECK (retention_days > 0),
+    updated_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, event_type)
+);
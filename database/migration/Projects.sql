@@ -0,0 +1,29 @@
+-- Projects (sites) whose events are kept apart. id is the project_id stored on
+-- events and sent in the X-Project-ID header.
+CREATE TABLE IF NOT EXISTS projects (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    domain VARCHAR(255) NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Users with access to a project's data. Owners may also manage the project
+-- and its members; admins have access to every project.
+CREATE TABLE IF NOT EXISTS project_members (
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'member')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_members_user ON project_members (user_id);
+
+-- Events tracked before projects existed belong to the default project.
+INSERT INTO projects (id, name) VALUES ('default', 'Default') ON CONFLICT (id) DO NOTHING;
+
+-- Existing deployments created before projects, to keep every user's access
+-- to the events tracked so far:
+-- INSERT INTO project_members (project_id, user_id) SELECT 'default', id FROM users ON CONFLICT DO NOTHING;
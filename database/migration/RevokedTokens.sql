@@ -0,0 +1,17 @@
+-- Access tokens revoked before they expire, checked on every authenticated
+-- request when Redis is not configured: single tokens by their jti claim
+-- (e.g. at logout), and all tokens of a user issued before revoked_before
+-- (e.g. after a password change). Rows are deleted once the tokens they
+-- revoke have expired.
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_token_revocations (
+    user_id INTEGER PRIMARY KEY,
+    revoked_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires ON revoked_tokens (expires_at);
@@ -0,0 +1,12 @@
+-- Per-project ingestion sampling. Events are kept with probability sample_rate,
+-- decided per visitor so their sessions stay whole, and stats scale event counts
+-- back up by 1 / sample_rate. An empty event_type applies to the project's
+-- event types without a rule of their own.
+CREATE TABLE IF NOT EXISTS sampling_rules (
+    project_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(128) NOT NULL DEFAULT '',
+    sample_rate DOUBLE PRECISION NOT NULL CHECK (sample_rate > 0 AND sample_rate <= 1),
+    updated_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, event_type)
+);
@@ -0,0 +1,13 @@
+-- Last run of each scheduled task. Rows are shared by all replicas so any of
+-- them can report the status of tasks that only run on the leader.
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    name VARCHAR(64) PRIMARY KEY,
+    instance VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'running',
+    error TEXT NOT NULL DEFAULT '',
+    runs BIGINT NOT NULL DEFAULT 0,
+    failures BIGINT NOT NULL DEFAULT 0,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
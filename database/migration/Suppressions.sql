@@ -0,0 +1,23 @@
+-- Opt-out suppression list of each project. Rows are never deleted: removing a
+-- suppression sets removed_at/removed_by so every change stays auditable.
+CREATE TABLE IF NOT EXISTS suppressions (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    subject_type VARCHAR(16) NOT NULL CHECK (subject_type IN ('user', 'anonymous')),
+    subject_id VARCHAR(255) NOT NULL,
+    mode VARCHAR(16) NOT NULL DEFAULT 'drop' CHECK (mode IN ('drop', 'anonymize')),
+    reason TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    removed_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    removed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_suppressions_active_subject
+    ON suppressions (project_id, subject_type, subject_id) WHERE removed_at IS NULL;
+
+-- Existing deployments created before projects, keeping the suppressions of the
+-- default project:
+-- ALTER TABLE suppressions ADD COLUMN IF NOT EXISTS project_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES projects (id) ON DELETE CASCADE;
+-- ALTER TABLE suppressions ALTER COLUMN project_id DROP DEFAULT;
+-- DROP INDEX idx_suppressions_active_subject; then re-create it with the statement above.
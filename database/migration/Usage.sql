@@ -0,0 +1,17 @@
+-- Monthly usage per project (site): ingested events and stats queries.
+CREATE TABLE IF NOT EXISTS usage_counters (
+    project_id VARCHAR(64) NOT NULL,
+    period DATE NOT NULL, -- First day of the month (UTC)
+    events_ingested BIGINT NOT NULL DEFAULT 0,
+    queries BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, period)
+);
+
+-- Per-project overrides of the default monthly ingestion quota (0 = unlimited).
+CREATE TABLE IF NOT EXISTS project_quotas (
+    project_id VARCHAR(64) PRIMARY KEY,
+    monthly_event_quota BIGINT NOT NULL CHECK (monthly_event_quota >= 0),
+    updated_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,16 @@
+-- Per-project write keys authenticating ingestion (/api/track, /api/identify,
+-- /api/group). Only a SHA-256 hash of each key is stored; prefix is kept to
+-- tell keys apart.
+CREATE TABLE IF NOT EXISTS write_keys (
+    id SERIAL PRIMARY KEY,
+    project_id VARCHAR(64) NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE, -- set on the old key when a key is rotated with a grace period
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_write_keys_project ON write_keys (project_id);
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+type RedisClient struct {
+	Client *redis.Client
+}
+
+// NewRedisClient connects to the Redis at REDIS_URL (e.g.
+// redis://:password@localhost:6379/0). Redis is optional: without REDIS_URL
+// the returned client has a nil Client, and state that could live in Redis
+// stays in process memory.
+func NewRedisClient() (*RedisClient, error) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		log.Info().Msg("REDIS_URL environment variable not set. Sessions are kept in memory.")
+		return &RedisClient{}, nil
+	}
+
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("error connecting to Redis (ping failed): %w", err)
+	}
+
+	log.Info().Msg("Successfully connected to Redis!")
+	return &RedisClient{Client: client}, nil
+}
+
+func (c *RedisClient) Close() {
+	if c.Client == nil {
+		return
+	}
+	if err := c.Client.Close(); err != nil {
+		log.Error().Err(err).Msg("Error closing Redis connection")
+	} else {
+		log.Info().Msg("Redis connection closed.")
+	}
+}
@@ -0,0 +1,145 @@
+// Package destinations forwards ingested events to third-party tools set up
+// per project: a generic webhook, Segment or the GA4 Measurement Protocol.
+// Recorded events are handed to a Forwarder, which sends them in batches in
+// the background, so a slow or failing destination never holds up ingestion.
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// Destination sends batches of events to an external tool.
+type Destination interface {
+	Send(ctx context.Context, events []models.AnalyticsEvent) error
+}
+
+// kind builds destinations of one type from their JSON config.
+type kind struct {
+	new func(config json.RawMessage) (Destination, error)
+	// secrets are the config fields hidden when destinations are listed.
+	secrets []string
+}
+
+var kinds = map[string]kind{}
+
+// redacted replaces the secret config fields of listed destinations.
+const redacted = "********"
+
+// httpClient sends the requests of all destinations. Their URLs come from
+// users, so it only connects to public addresses.
+var httpClient = utils.OutboundClient
+
+func register(name string, k kind) {
+	kinds[name] = k
+}
+
+// Kinds lists the destination types.
+func Kinds() []string {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds a destination of kind from its config, reporting an unknown
+// kind or an invalid config.
+func New(kindName string, config json.RawMessage) (Destination, error) {
+	k, ok := kinds[kindName]
+	if !ok {
+		return nil, fmt.Errorf("unknown destination kind %q (available: %v)", kindName, Kinds())
+	}
+	return k.new(config)
+}
+
+// Redact returns config with the values of its secret fields replaced, for
+// showing it back to users.
+func Redact(kindName string, config json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return config
+	}
+	for _, name := range kinds[kindName].secrets {
+		if _, ok := fields[name]; ok {
+			fields[name] = json.RawMessage(`"` + redacted + `"`)
+		}
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return config
+	}
+	return out
+}
+
+// decodeConfig decodes config into v, rejecting unknown fields so that typos
+// are caught when a destination is saved.
+func decodeConfig(config json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(config))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// statusError is a non-2xx answer of a destination.
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("destination answered %d", e.status)
+	}
+	return fmt.Sprintf("destination answered %d: %s", e.status, e.body)
+}
+
+// retryable reports whether sending again may succeed: anything but a
+// client error other than 408 or 429, which would fail the same way.
+func retryable(err error) bool {
+	se, ok := err.(*statusError)
+	if !ok {
+		return true
+	}
+	return se.status >= 500 || se.status == http.StatusRequestTimeout || se.status == http.StatusTooManyRequests
+}
+
+// post sends req and turns a non-2xx answer into a statusError.
+func post(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &statusError{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+}
+
+// eventProperties returns the eventData object of an event, or an empty map
+// when it has none or it is not an object.
+func eventProperties(event *models.AnalyticsEvent) map[string]interface{} {
+	props := map[string]interface{}{}
+	if len(event.EventData) > 0 {
+		json.Unmarshal(event.EventData, &props)
+	}
+	if props == nil {
+		props = map[string]interface{}{}
+	}
+	return props
+}
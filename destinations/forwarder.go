@@ -0,0 +1,226 @@
+package destinations
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	sendTimeout = 30 * time.Second
+	sendRetries = 3
+)
+
+// active is an enabled destination ready to send.
+type active struct {
+	id         int
+	name       string
+	eventTypes map[string]bool // nil for all
+	dest       Destination
+}
+
+func (a *active) matches(event *models.AnalyticsEvent) bool {
+	return a.eventTypes == nil || a.eventTypes[event.EventType]
+}
+
+// Forwarder fans recorded events out to the enabled destinations of their
+// project. Enqueue only buffers them; a background goroutine sends them in
+// batches of batchSize, or every flushInterval, to all destinations at once.
+// Events are dropped when the buffer is full, and buffered events are lost if
+// the process dies, so destinations are best effort rather than a copy of
+// the stored events.
+type Forwarder struct {
+	store         *store.DestinationStore
+	batchSize     int
+	flushInterval time.Duration
+
+	events  chan models.AnalyticsEvent
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	active map[string][]*active // project_id -> destinations
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewForwarder buffers at most capacity events. Refresh loads the
+// destinations and Start must be called for events to be sent.
+func NewForwarder(s *store.DestinationStore, batchSize, capacity int, flushInterval time.Duration) *Forwarder {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	return &Forwarder{
+		store:         s,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		events:        make(chan models.AnalyticsEvent, max(capacity, batchSize)),
+		active:        map[string][]*active{},
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Refresh reloads the enabled destinations. A destination whose config no
+// longer builds is skipped and logged, so it cannot keep the others from
+// loading.
+func (f *Forwarder) Refresh(ctx context.Context) error {
+	list, err := f.store.ListEnabledDestinations(ctx)
+	if err != nil {
+		return err
+	}
+	byProject := map[string][]*active{}
+	for _, d := range list {
+		dest, err := New(d.Kind, d.Config)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Destination %d (%s)", d.ID, d.Name)
+			continue
+		}
+		a := &active{id: d.ID, name: d.Name, dest: dest}
+		if len(d.EventTypes) > 0 {
+			a.eventTypes = make(map[string]bool, len(d.EventTypes))
+			for _, eventType := range d.EventTypes {
+				a.eventTypes[eventType] = true
+			}
+		}
+		byProject[d.ProjectID] = append(byProject[d.ProjectID], a)
+	}
+
+	f.mu.Lock()
+	f.active = byProject
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *Forwarder) destinations(projectID string) []*active {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.active[projectID]
+}
+
+// Enqueue buffers the events of projects with destinations for sending. It
+// never blocks: events that do not fit are dropped and counted.
+func (f *Forwarder) Enqueue(events []models.AnalyticsEvent) {
+	dropped := 0
+	for i := range events {
+		if len(f.destinations(events[i].ProjectID)) == 0 {
+			continue
+		}
+		select {
+		case f.events <- events[i]:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		total := f.dropped.Add(int64(dropped))
+		log.Warn().Msgf("Destination forwarding buffer full: dropped %d events (%d in total)", dropped, total)
+	}
+}
+
+// Start runs the background sender until Close.
+func (f *Forwarder) Start() {
+	go f.run()
+	log.Info().Msgf("Destination forwarder started (batches of %d, every %s, buffer of %d)", f.batchSize, f.flushInterval, cap(f.events))
+}
+
+// Close stops the sender once the buffered events are sent or ctx is done,
+// in which case the events still buffered are lost. Enqueue must not be
+// called after Close.
+func (f *Forwarder) Close(ctx context.Context) error {
+	close(f.stop)
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d buffered events not forwarded: %w", len(f.events), ctx.Err())
+	}
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AnalyticsEvent, 0, f.batchSize)
+	for {
+		select {
+		case event := <-f.events:
+			batch = append(batch, event)
+			if len(batch) < f.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-f.stop:
+			for len(f.events) > 0 {
+				batch = append(batch, <-f.events)
+				if len(batch) == f.batchSize {
+					f.flush(batch)
+					batch = batch[:0]
+				}
+			}
+			f.flush(batch)
+			return
+		}
+		f.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush sends a batch to every destination of its events' projects, all
+// destinations at once, and waits for them.
+func (f *Forwarder) flush(batch []models.AnalyticsEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	byDestination := map[*active][]models.AnalyticsEvent{}
+	for i := range batch {
+		for _, a := range f.destinations(batch[i].ProjectID) {
+			if a.matches(&batch[i]) {
+				byDestination[a] = append(byDestination[a], batch[i])
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for a, events := range byDestination {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.send(a, events)
+		}()
+	}
+	wg.Wait()
+}
+
+// send delivers events to a destination, retrying with backoff errors that
+// may pass, and records the outcome.
+func (f *Forwarder) send(a *active, events []models.AnalyticsEvent) {
+	var err error
+	for attempt := 0; attempt < sendRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = a.dest.Send(ctx, events)
+		cancel()
+		if err == nil || !retryable(err) {
+			break
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to forward %d events to destination %d (%s)", len(events), a.id, a.name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := f.store.RecordForwarding(ctx, a.id, len(events), err); err != nil {
+		log.Error().Err(err).Msgf("Failed to record forwarding to destination %d", a.id)
+	}
+}
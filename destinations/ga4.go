@@ -0,0 +1,203 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+func init() {
+	register(models.DestinationKindGA4, kind{new: newGA4, secrets: []string{"apiSecret"}})
+}
+
+const (
+	ga4Endpoint = "https://www.google-analytics.com/mp/collect"
+	// Limits of the Measurement Protocol.
+	ga4MaxEvents      = 25
+	ga4MaxParams      = 25
+	ga4MaxNameLength  = 40
+	ga4MaxValueLength = 100
+)
+
+// ga4Config configures a GA4 web data stream:
+// {"measurementId": "G-XXXXXXX", "apiSecret": "..."}. Endpoint overrides the
+// collection URL, e.g. with the /debug/mp/collect validation server.
+type ga4Config struct {
+	MeasurementID string `json:"measurementId"`
+	APISecret     string `json:"apiSecret"`
+	Endpoint      string `json:"endpoint"`
+}
+
+// ga4Destination sends events through the GA4 Measurement Protocol. Events
+// keep their type as name, sanitized to GA4's rules; page_view, add_to_cart,
+// purchase and search carry the parameters GA4 reports expect.
+type ga4Destination struct {
+	endpoint string
+}
+
+func newGA4(raw json.RawMessage) (Destination, error) {
+	var config ga4Config
+	if err := decodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.MeasurementID == "" || config.APISecret == "" {
+		return nil, errors.New("invalid config: measurementId and apiSecret are required")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = ga4Endpoint
+	}
+	if err := utils.CheckOutboundURL(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid config: endpoint: %w", err)
+	}
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: endpoint: %w", err)
+	}
+	query := u.Query()
+	query.Set("measurement_id", config.MeasurementID)
+	query.Set("api_secret", config.APISecret)
+	u.RawQuery = query.Encode()
+	return &ga4Destination{endpoint: u.String()}, nil
+}
+
+type ga4Event struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params"`
+}
+
+type ga4Payload struct {
+	ClientID        string     `json:"client_id"`
+	UserID          string     `json:"user_id,omitempty"`
+	TimestampMicros int64      `json:"timestamp_micros"`
+	Events          []ga4Event `json:"events"`
+}
+
+// Send posts one request per visitor and up to 25 events, as a request
+// carries the events of a single client_id.
+func (d *ga4Destination) Send(ctx context.Context, events []models.AnalyticsEvent) error {
+	var (
+		payloads []*ga4Payload
+		open     = map[string]*ga4Payload{}
+	)
+	for i := range events {
+		event := &events[i]
+		clientID := ga4ClientID(event)
+		p := open[clientID]
+		if p == nil || len(p.Events) == ga4MaxEvents || p.UserID != event.UserID {
+			p = &ga4Payload{ClientID: clientID, UserID: event.UserID, TimestampMicros: event.Timestamp.UnixMicro()}
+			open[clientID] = p
+			payloads = append(payloads, p)
+		}
+		p.Events = append(p.Events, ga4EventOf(event))
+	}
+
+	for _, p := range payloads {
+		body, err := json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("failed to encode events: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if err := post(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ga4ClientID identifies the visitor of an event to GA4.
+func ga4ClientID(event *models.AnalyticsEvent) string {
+	for _, id := range []string{event.AnonymousID, event.SessionID, event.UserID} {
+		if id != "" {
+			return id
+		}
+	}
+	return event.EventID
+}
+
+var ga4InvalidName = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// ga4Name makes s a valid GA4 event or parameter name: letters, digits and
+// underscores, starting with a letter, at most 40 characters.
+func ga4Name(s string) string {
+	s = ga4InvalidName.ReplaceAllString(s, "_")
+	if s == "" || !(s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z') {
+		s = "e_" + s
+	}
+	if len(s) > ga4MaxNameLength {
+		s = s[:ga4MaxNameLength]
+	}
+	return s
+}
+
+// ga4Renames maps eventData fields of first-class event types to the GA4
+// parameters of the same meaning.
+var ga4Renames = map[string]string{
+	"orderId": "transaction_id",
+	"revenue": "value",
+	"query":   "search_term",
+	"title":   "page_title",
+}
+
+func ga4EventOf(event *models.AnalyticsEvent) ga4Event {
+	params := map[string]interface{}{}
+	if event.SessionID != "" {
+		params["session_id"] = event.SessionID
+	}
+	if event.DurationMs > 0 {
+		params["engagement_time_msec"] = event.DurationMs
+	}
+	if event.PagePath != "" {
+		if strings.HasPrefix(event.PagePath, "http://") || strings.HasPrefix(event.PagePath, "https://") {
+			params["page_location"] = event.PagePath
+		} else {
+			params["page_path"] = event.PagePath
+		}
+	}
+	if event.Referrer != "" {
+		params["page_referrer"] = event.Referrer
+	}
+	if len(event.Products) > 0 {
+		items := make([]map[string]interface{}, 0, len(event.Products))
+		for _, p := range event.Products {
+			item := map[string]interface{}{"item_id": p.ID, "item_name": p.Name, "price": p.Price, "quantity": p.Quantity}
+			if item["item_id"] == "" {
+				item["item_id"] = p.SKU
+			}
+			items = append(items, item)
+		}
+		params["items"] = items
+	}
+
+	for key, value := range eventProperties(event) {
+		if len(params) >= ga4MaxParams {
+			break
+		}
+		name, ok := ga4Renames[key]
+		if !ok {
+			name = ga4Name(key)
+		}
+		switch v := value.(type) {
+		case string:
+			if len(v) > ga4MaxValueLength {
+				v = v[:ga4MaxValueLength]
+			}
+			params[name] = v
+		case float64, bool:
+			params[name] = v
+		}
+	}
+	return ga4Event{Name: ga4Name(event.EventType), Params: params}
+}
@@ -0,0 +1,132 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+func init() {
+	register(models.DestinationKindSegment, kind{new: newSegment, secrets: []string{"writeKey"}})
+}
+
+const segmentEndpoint = "https://api.segment.io/v1/batch"
+
+// segmentConfig configures a Segment source: {"writeKey": "..."}. Endpoint
+// overrides the HTTP Tracking API batch URL, e.g. for the EU region.
+type segmentConfig struct {
+	WriteKey string `json:"writeKey"`
+	Endpoint string `json:"endpoint"`
+}
+
+// segmentDestination sends events to the Segment HTTP Tracking API: page_view
+// events as page calls, all others as track calls named after their type.
+type segmentDestination struct {
+	config segmentConfig
+}
+
+func newSegment(raw json.RawMessage) (Destination, error) {
+	var config segmentConfig
+	if err := decodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if config.WriteKey == "" {
+		return nil, errors.New("invalid config: writeKey is required")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = segmentEndpoint
+	}
+	if err := utils.CheckOutboundURL(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid config: endpoint: %w", err)
+	}
+	return &segmentDestination{config: config}, nil
+}
+
+type segmentMessage struct {
+	Type        string                 `json:"type"`
+	Event       string                 `json:"event,omitempty"`
+	Name        string                 `json:"name,omitempty"`
+	MessageID   string                 `json:"messageId"`
+	UserID      string                 `json:"userId,omitempty"`
+	AnonymousID string                 `json:"anonymousId,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Properties  map[string]interface{} `json:"properties"`
+	Context     map[string]interface{} `json:"context"`
+}
+
+func (d *segmentDestination) Send(ctx context.Context, events []models.AnalyticsEvent) error {
+	batch := make([]segmentMessage, 0, len(events))
+	for i := range events {
+		batch = append(batch, segmentMessageOf(&events[i]))
+	}
+	payload, err := json.Marshal(map[string]interface{}{"batch": batch})
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.config.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.config.WriteKey, "")
+	req.Header.Set("Content-Type", "application/json")
+	return post(req)
+}
+
+func segmentMessageOf(event *models.AnalyticsEvent) segmentMessage {
+	msg := segmentMessage{
+		Type:        "track",
+		Event:       event.EventType,
+		MessageID:   event.EventID,
+		UserID:      event.UserID,
+		AnonymousID: event.AnonymousID,
+		Timestamp:   event.Timestamp,
+		Properties:  eventProperties(event),
+	}
+	// Segment needs one of the two IDs.
+	if msg.UserID == "" && msg.AnonymousID == "" {
+		msg.AnonymousID = event.SessionID
+		if msg.AnonymousID == "" {
+			msg.AnonymousID = event.EventID
+		}
+	}
+	if event.EventType == models.EventTypePageView {
+		msg.Type, msg.Event = "page", ""
+		msg.Properties["path"] = event.PagePath
+		msg.Properties["referrer"] = event.Referrer
+		if title, ok := msg.Properties["title"].(string); ok {
+			msg.Name = title
+		}
+	}
+	if len(event.Products) > 0 {
+		products := make([]map[string]interface{}, 0, len(event.Products))
+		for _, p := range event.Products {
+			products = append(products, map[string]interface{}{
+				"product_id": p.ID, "sku": p.SKU, "name": p.Name, "price": p.Price, "quantity": p.Quantity,
+			})
+		}
+		msg.Properties["products"] = products
+	}
+
+	msg.Context = map[string]interface{}{
+		"ip":        event.IPAddress,
+		"userAgent": event.UserAgent,
+		"page":      map[string]interface{}{"path": event.PagePath, "referrer": event.Referrer},
+	}
+	if event.UTMSource != "" || event.UTMCampaign != "" {
+		msg.Context["campaign"] = map[string]interface{}{
+			"source": event.UTMSource, "medium": event.UTMMedium, "name": event.UTMCampaign,
+			"term": event.UTMTerm, "content": event.UTMContent,
+		}
+	}
+	if event.GroupID != "" {
+		msg.Context["groupId"] = event.GroupID
+	}
+	return msg
+}
@@ -0,0 +1,90 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+func init() {
+	register(models.DestinationKindWebhook, kind{new: newWebhook, secrets: []string{"secret", "headers"}})
+}
+
+// webhookConfig configures a generic webhook destination:
+//
+//	{"url": "https://example.com/events", "secret": "...", "headers": {"Authorization": "Bearer ..."}}
+type webhookConfig struct {
+	URL string `json:"url"`
+	// Secret, when set, signs each batch like the API's own webhooks.
+	Secret  string            `json:"secret"`
+	Headers map[string]string `json:"headers"`
+}
+
+// webhookDestination POSTs batches of events as {"events": [...]}.
+type webhookDestination struct {
+	config webhookConfig
+}
+
+func newWebhook(raw json.RawMessage) (Destination, error) {
+	var config webhookConfig
+	if err := decodeConfig(raw, &config); err != nil {
+		return nil, err
+	}
+	if err := utils.CheckOutboundURL(config.URL); err != nil {
+		return nil, fmt.Errorf("invalid config: url must be an http or https URL of a public host: %w", err)
+	}
+	for name := range config.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || reservedHeader(name) {
+			return nil, fmt.Errorf("invalid config: header %q cannot be set", name)
+		}
+	}
+	return &webhookDestination{config: config}, nil
+}
+
+// reservedHeader reports whether name is a header the webhook request sets
+// itself or that controls the connection or routing rather than the payload,
+// which configured headers may not override.
+func reservedHeader(name string) bool {
+	switch name = http.CanonicalHeaderKey(name); name {
+	case "Host", "Connection", "Content-Length", "Content-Type", "Transfer-Encoding", "Te", "Trailer",
+		"Upgrade", "Keep-Alive", "Expect", "Forwarded", "X-Webhook-Signature":
+		return true
+	}
+	return strings.HasPrefix(name, "Proxy-") || strings.HasPrefix(name, "X-Forwarded-")
+}
+
+func (d *webhookDestination) Send(ctx context.Context, events []models.AnalyticsEvent) error {
+	payload, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for name, value := range d.config.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.config.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(d.config.Secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(payload)
+		req.Header.Set("X-Webhook-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return post(req)
+}
@@ -0,0 +1,91 @@
+package enrich
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"mabletask/api/models"
+)
+
+func init() {
+	Register("bot", func() Enricher { return botDetector{} })
+}
+
+// botTokens are user agent substrings, in lower case, of crawlers, monitors
+// and HTTP libraries that uap does not classify as spiders.
+var botTokens = []string{
+	"bot", "crawl", "spider", "slurp", "scrape", "headless", "lighthouse",
+	"pingdom", "uptime", "monitor", "preview", "curl/", "wget/",
+	"python-requests", "python-urllib", "go-http-client", "java/",
+	"okhttp", "axios/", "node-fetch", "libwww-perl", "httpclient",
+}
+
+// botNetworks holds the IP ranges set with SetBotIPRanges.
+var botNetworks atomic.Pointer[[]netip.Prefix]
+
+// SetBotIPRanges makes events from the comma-separated CIDR ranges count as
+// bots, e.g. the ranges of a monitoring service or a data center.
+func SetBotIPRanges(ranges string) error {
+	var prefixes []netip.Prefix
+	for _, r := range strings.Split(ranges, ",") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			return fmt.Errorf("invalid bot IP range %q: %w", r, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	botNetworks.Store(&prefixes)
+	return nil
+}
+
+// botDetector sets IsBot on events from known bots and crawlers: those the
+// useragent enricher classified as bot, those whose user agent names a
+// crawler or HTTP library, and those from the ranges of SetBotIPRanges. It
+// runs after the useragent enricher.
+type botDetector struct{}
+
+func (botDetector) Enrich(event *models.AnalyticsEvent) bool {
+	if event.IsBot {
+		return false
+	}
+	event.IsBot = event.DeviceType == models.DeviceTypeBot ||
+		isBotUserAgent(event.UserAgent) ||
+		inBotNetwork(event.IPAddress)
+	return event.IsBot
+}
+
+func isBotUserAgent(ua string) bool {
+	if ua == "" {
+		return false
+	}
+	ua = strings.ToLower(ua)
+	for _, token := range botTokens {
+		if strings.Contains(ua, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func inBotNetwork(ip string) bool {
+	networks := botNetworks.Load()
+	if networks == nil || len(*networks) == 0 || ip == "" {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, network := range *networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package enrich
+
+import (
+	"net/url"
+	"strings"
+
+	"mabletask/api/models"
+)
+
+func init() {
+	Register("channel", func() Enricher { return channelClassifier{} })
+}
+
+// channelRules maps referring domains to their channel. A rule matches the
+// domain and its subdomains. Search engines are matched by name in
+// searchEngines instead, since they run a domain per country.
+var channelRules = map[string]string{
+	"facebook.com":         models.ChannelSocial,
+	"fb.com":               models.ChannelSocial,
+	"fb.me":                models.ChannelSocial,
+	"messenger.com":        models.ChannelSocial,
+	"instagram.com":        models.ChannelSocial,
+	"threads.net":          models.ChannelSocial,
+	"twitter.com":          models.ChannelSocial,
+	"x.com":                models.ChannelSocial,
+	"t.co":                 models.ChannelSocial,
+	"linkedin.com":         models.ChannelSocial,
+	"lnkd.in":              models.ChannelSocial,
+	"reddit.com":           models.ChannelSocial,
+	"pinterest.com":        models.ChannelSocial,
+	"youtube.com":          models.ChannelSocial,
+	"youtu.be":             models.ChannelSocial,
+	"tiktok.com":           models.ChannelSocial,
+	"snapchat.com":         models.ChannelSocial,
+	"tumblr.com":           models.ChannelSocial,
+	"vk.com":               models.ChannelSocial,
+	"weibo.com":            models.ChannelSocial,
+	"bsky.app":             models.ChannelSocial,
+	"mastodon.social":      models.ChannelSocial,
+	"news.ycombinator.com": models.ChannelSocial,
+	"search.brave.com":     models.ChannelSearch,
+	"startpage.com":        models.ChannelSearch,
+}
+
+// searchEngines are matched on the label before the public suffix, e.g.
+// google in www.google.co.uk.
+var searchEngines = map[string]bool{
+	"google":     true,
+	"bing":       true,
+	"yahoo":      true,
+	"duckduckgo": true,
+	"baidu":      true,
+	"yandex":     true,
+	"ecosia":     true,
+	"qwant":      true,
+	"naver":      true,
+	"seznam":     true,
+}
+
+// channelClassifier sets the acquisition channel of events from their
+// referrer: direct without one, search or social by channelRules and
+// searchEngines, and referral otherwise.
+type channelClassifier struct{}
+
+func (channelClassifier) Enrich(event *models.AnalyticsEvent) bool {
+	channel := classifyReferrer(event.Referrer)
+	if channel == event.Channel {
+		return false
+	}
+	event.Channel = channel
+	return true
+}
+
+func classifyReferrer(referrer string) string {
+	referrer = strings.TrimSpace(referrer)
+	if referrer == "" {
+		return models.ChannelDirect
+	}
+	if !strings.Contains(referrer, "://") {
+		referrer = "//" + referrer
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Hostname() == "" {
+		return models.ChannelReferral
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	for domain := host; domain != ""; {
+		if channel, ok := channelRules[domain]; ok {
+			return channel
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+
+	// The engine's name is the last label before a public suffix of one or
+	// two short labels: google.com, google.de, google.co.uk.
+	labels := strings.Split(host, ".")
+	for i := len(labels) - 2; i >= 0 && i >= len(labels)-3; i-- {
+		if searchEngines[labels[i]] {
+			return models.ChannelSearch
+		}
+	}
+	return models.ChannelReferral
+}
@@ -0,0 +1,98 @@
+// Package enrich derives event fields from raw event data. Enrichers are
+// registered by name so the same steps can run at ingestion and when replaying
+// stored events.
+package enrich
+
+import (
+	"fmt"
+	"sort"
+
+	"mabletask/api/models"
+)
+
+// Enricher updates an event in place and reports whether it changed it.
+// Enrichers registered with registerStateful keep state between events and
+// need a fresh instance per run; the others must be safe for concurrent use.
+type Enricher interface {
+	Enrich(event *models.AnalyticsEvent) bool
+}
+
+var registry = map[string]func() Enricher{}
+
+// stateful holds the enrichers that keep state between events.
+var stateful = map[string]bool{}
+
+// Ingest lists the enrichers applied to every event at ingestion unless a
+// deployment configures its own. ipanonymize comes last so that the others
+// see the full client IP.
+var Ingest = []string{"geoip", "useragent", "bot", "utm", "channel", "ipanonymize"}
+
+// Register makes an enricher available under name.
+func Register(name string, factory func() Enricher) {
+	registry[name] = factory
+}
+
+// registerStateful is Register for enrichers keeping state between events,
+// which cannot be part of a shared pipeline.
+func registerStateful(name string, factory func() Enricher) {
+	Register(name, factory)
+	stateful[name] = true
+}
+
+// Names lists the registered enrichers.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pipeline runs enrichers in order.
+type Pipeline []Enricher
+
+// New builds a pipeline of freshly created enrichers.
+func New(names []string) (Pipeline, error) {
+	p := make(Pipeline, 0, len(names))
+	for _, name := range names {
+		factory, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown enricher %q (available: %v)", name, Names())
+		}
+		p = append(p, factory())
+	}
+	return p, nil
+}
+
+// NewShared builds a pipeline that may run on several goroutines at once, such
+// as the ingestion pipeline shared by all requests. Stateful enrichers are
+// rejected.
+func NewShared(names []string) (Pipeline, error) {
+	for _, name := range names {
+		if stateful[name] {
+			return nil, fmt.Errorf("enricher %q keeps state between events and only runs in reprocess jobs", name)
+		}
+	}
+	return New(names)
+}
+
+// MustNewShared is NewShared for fixed lists such as Ingest; it panics if
+// names are invalid.
+func MustNewShared(names []string) Pipeline {
+	p, err := NewShared(names)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p Pipeline) Enrich(event *models.AnalyticsEvent) bool {
+	changed := false
+	for _, e := range p {
+		if e.Enrich(event) {
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,36 @@
+package enrich
+
+import (
+	"sync/atomic"
+
+	"mabletask/api/database"
+	"mabletask/api/models"
+)
+
+func init() {
+	Register("geoip", func() Enricher { return geoLocator{} })
+}
+
+// geoIP is the database set with SetGeoIP.
+var geoIP atomic.Pointer[database.GeoIP]
+
+// SetGeoIP sets the database the geoip enricher resolves client IPs with; nil
+// disables it.
+func SetGeoIP(g *database.GeoIP) {
+	geoIP.Store(g)
+}
+
+// geoLocator sets the country, region and city of events from their IP
+// address. Events whose IP cannot be resolved keep the location they were
+// sent with.
+type geoLocator struct{}
+
+func (geoLocator) Enrich(event *models.AnalyticsEvent) bool {
+	loc, ok := geoIP.Load().Lookup(event.IPAddress)
+	if !ok {
+		return false
+	}
+	before := [3]string{event.Country, event.Region, event.City}
+	event.Country, event.Region, event.City = loc.Country, loc.Region, loc.City
+	return before != [3]string{event.Country, event.Region, event.City}
+}
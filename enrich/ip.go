@@ -0,0 +1,35 @@
+package enrich
+
+import (
+	"sync/atomic"
+
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+func init() {
+	Register("ipanonymize", func() Enricher { return ipAnonymizer{} })
+}
+
+// anonymizer is the IPAnonymizer set with SetIPAnonymizer.
+var anonymizer atomic.Pointer[utils.IPAnonymizer]
+
+// SetIPAnonymizer sets how the ipanonymize enricher rewrites client IPs; nil
+// keeps them.
+func SetIPAnonymizer(a *utils.IPAnonymizer) {
+	anonymizer.Store(a)
+}
+
+// ipAnonymizer truncates or hashes the IP address of events as configured by
+// IP_ANONYMIZE. It runs after the enrichers that read the full address. Over
+// stored events, hashing an already hashed address hashes it again.
+type ipAnonymizer struct{}
+
+func (ipAnonymizer) Enrich(event *models.AnalyticsEvent) bool {
+	ip := anonymizer.Load().Anonymize(event.IPAddress)
+	if ip == event.IPAddress {
+		return false
+	}
+	event.IPAddress = ip
+	return true
+}
@@ -0,0 +1,94 @@
+package enrich
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"mabletask/api/models"
+)
+
+func init() {
+	Register("pii", func() Enricher { return piiScrubber{} })
+}
+
+// redacted replaces scrubbed values.
+const redacted = "[redacted]"
+
+// emailPattern matches email addresses within text.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// sensitiveParams are query parameters, in lower case, whose values are
+// redacted from URLs.
+var sensitiveParams = map[string]bool{
+	"email": true, "e-mail": true, "mail": true, "phone": true, "tel": true,
+	"name": true, "firstname": true, "lastname": true, "first_name": true, "last_name": true,
+	"password": true, "pass": true, "pwd": true, "token": true, "access_token": true,
+	"id_token": true, "auth": true, "code": true, "session": true, "ssn": true,
+}
+
+// piiScrubber redacts personal data that pages leak into events: email
+// addresses anywhere in the page path, referrer and string values of
+// eventData, and the values of query parameters such as email or token.
+type piiScrubber struct{}
+
+func (piiScrubber) Enrich(event *models.AnalyticsEvent) bool {
+	changed := false
+	for _, field := range []*string{&event.PagePath, &event.Referrer} {
+		if scrubbed := scrubURL(*field); scrubbed != *field {
+			*field = scrubbed
+			changed = true
+		}
+	}
+	if len(event.EventData) > 0 && emailPattern.Match(event.EventData) {
+		var data interface{}
+		if err := json.Unmarshal(event.EventData, &data); err == nil {
+			if raw, err := json.Marshal(scrubValue(data)); err == nil {
+				event.EventData = raw
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// scrubURL redacts sensitive query parameters and email addresses of a URL
+// or path. Values that do not parse as URLs only have emails redacted.
+func scrubURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	if u, err := url.Parse(raw); err == nil && u.RawQuery != "" {
+		query := u.Query()
+		touched := false
+		for param := range query {
+			if sensitiveParams[strings.ToLower(param)] {
+				query.Set(param, redacted)
+				touched = true
+			}
+		}
+		if touched {
+			u.RawQuery = query.Encode()
+			raw = u.String()
+		}
+	}
+	return emailPattern.ReplaceAllString(raw, redacted)
+}
+
+// scrubValue redacts email addresses in the strings of a decoded JSON value.
+func scrubValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return emailPattern.ReplaceAllString(v, redacted)
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = scrubValue(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = scrubValue(value)
+		}
+	}
+	return v
+}
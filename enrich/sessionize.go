@@ -0,0 +1,51 @@
+package enrich
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mabletask/api/models"
+)
+
+// SessionTimeout is the inactivity gap after which a visitor starts a new session.
+const SessionTimeout = 30 * time.Minute
+
+var sessionNamespace = uuid.MustParse("6f1c3c1e-93c4-4c1f-a6a4-3c9f3f0b6e52")
+
+func init() {
+	registerStateful("sessionize", func() Enricher { return &sessionizer{} })
+}
+
+// sessionizer assigns session IDs to events that arrived without one. It
+// expects events ordered by visitor and then by time. Generated IDs are
+// derived from the visitor and the session start, so replays are repeatable.
+type sessionizer struct {
+	visitor string
+	last    time.Time
+	session string
+}
+
+func (s *sessionizer) Enrich(event *models.AnalyticsEvent) bool {
+	visitor := event.UserID
+	if visitor == "" {
+		visitor = event.AnonymousID
+	}
+	if visitor == "" {
+		return false
+	}
+
+	continues := visitor == s.visitor && s.session != "" && event.Timestamp.Sub(s.last) <= SessionTimeout
+	s.visitor, s.last = visitor, event.Timestamp
+
+	if event.SessionID != "" {
+		s.session = event.SessionID
+		return false
+	}
+	if !continues {
+		s.session = uuid.NewSHA1(sessionNamespace, []byte(visitor+"|"+strconv.FormatInt(event.Timestamp.UnixMilli(), 10))).String()
+	}
+	event.SessionID = s.session
+	return true
+}
@@ -0,0 +1,60 @@
+package enrich
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/ua-parser/uap-go/uaparser"
+
+	"mabletask/api/models"
+)
+
+func init() {
+	Register("useragent", func() Enricher { return userAgentParser{} })
+}
+
+// uaParser is shared by all runs; it is safe for concurrent use and caches
+// recent user agents.
+var uaParser = sync.OnceValue(func() *uaparser.Parser {
+	return uaparser.NewFromSaved()
+})
+
+// userAgentParser sets the browser, browser version, OS and device type of
+// events from their user agent. Fields the user agent does not reveal are
+// left as sent.
+type userAgentParser struct{}
+
+func (userAgentParser) Enrich(event *models.AnalyticsEvent) bool {
+	if event.UserAgent == "" {
+		return false
+	}
+	client := uaParser().Parse(event.UserAgent)
+	before := [4]string{event.Browser, event.BrowserVersion, event.OS, event.DeviceType}
+
+	if family := client.UserAgent.Family; family != "" && family != "Other" {
+		event.Browser = family
+		event.BrowserVersion = client.UserAgent.Major
+	}
+	if family := client.Os.Family; family != "" && family != "Other" {
+		event.OS = family
+	}
+	event.DeviceType = deviceType(client, event.UserAgent)
+
+	return before != [4]string{event.Browser, event.BrowserVersion, event.OS, event.DeviceType}
+}
+
+// deviceType classifies a client as bot, tablet, mobile or desktop. uap only
+// names devices, so form factors are inferred from the OS and common tokens.
+func deviceType(client *uaparser.Client, ua string) string {
+	switch {
+	case client.Device.Family == "Spider":
+		return models.DeviceTypeBot
+	case client.Device.Family == "iPad" || strings.Contains(ua, "Tablet") ||
+		(client.Os.Family == "Android" && !strings.Contains(ua, "Mobile")):
+		return models.DeviceTypeTablet
+	case strings.Contains(ua, "Mobi") || client.Os.Family == "iOS" || client.Os.Family == "Android":
+		return models.DeviceTypeMobile
+	default:
+		return models.DeviceTypeDesktop
+	}
+}
@@ -0,0 +1,47 @@
+package enrich
+
+import (
+	"net/url"
+
+	"mabletask/api/models"
+)
+
+func init() {
+	Register("utm", func() Enricher { return utmParser{} })
+}
+
+// utmParser sets the UTM campaign parameters of events from the query string
+// of their page path. Parameters sent with the event are kept.
+type utmParser struct{}
+
+func (utmParser) Enrich(event *models.AnalyticsEvent) bool {
+	if event.PagePath == "" {
+		return false
+	}
+	u, err := url.Parse(event.PagePath)
+	if err != nil || u.RawQuery == "" {
+		return false
+	}
+	query := u.Query()
+
+	changed := false
+	for _, field := range []struct {
+		param string
+		value *string
+	}{
+		{"utm_source", &event.UTMSource},
+		{"utm_medium", &event.UTMMedium},
+		{"utm_campaign", &event.UTMCampaign},
+		{"utm_term", &event.UTMTerm},
+		{"utm_content", &event.UTMContent},
+	} {
+		if *field.value != "" {
+			continue
+		}
+		if v := query.Get(field.param); v != "" {
+			*field.value = v
+			changed = true
+		}
+	}
+	return changed
+}
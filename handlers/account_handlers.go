@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"mabletask/api/mailer"
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+)
+
+// AccountHandlers let users manage their own account.
+type AccountHandlers struct {
+	Auth             *AuthHandlers
+	EmailChangeStore *store.EmailChangeStore
+	AuditStore       *store.AuditStore
+	// VerifyURL is the page of the frontend that email confirmation links
+	// point to; the token is appended as the token query parameter.
+	VerifyURL string
+}
+
+func NewAccountHandlers(auth *AuthHandlers, emailChanges *store.EmailChangeStore, auditStore *store.AuditStore, verifyURL string) *AccountHandlers {
+	return &AccountHandlers{Auth: auth, EmailChangeStore: emailChanges, AuditStore: auditStore, VerifyURL: verifyURL}
+}
+
+// currentUser loads the caller and checks their current password, which
+// accounts created through social login do not have. On failure it writes the
+// response and returns false.
+func (h *AccountHandlers) currentUser(c *gin.Context, currentPassword string) (*models.User, bool) {
+	user, err := h.Auth.UserStore.GetUserByID(c.Request.Context(), c.GetInt("user_id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to get user %d", c.GetInt("user_id"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+	if len(user.HashedPassword) > 0 && bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(currentPassword)) != nil {
+		requestLog(c).Warn().Msgf("Account change refused for user %d: password mismatch", user.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return nil, false
+	}
+	return user, true
+}
+
+// ChangeEmail sends a confirmation link to the new address. The email of the
+// account only changes once the link is followed (see VerifyEmail).
+func (h *AccountHandlers) ChangeEmail(c *gin.Context) {
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	user, ok := h.currentUser(c, req.CurrentPassword)
+	if !ok {
+		return
+	}
+	if req.Email == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The new email is the current email"})
+		return
+	}
+	_, err := h.Auth.UserStore.GetUserByEmail(c.Request.Context(), req.Email)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		requestLog(c).Error().Err(err).Msg("Database error during email change check")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	token, expiresAt, err := h.EmailChangeStore.CreateToken(c.Request.Context(), user.ID, req.Email)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to create email change for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if err := h.Auth.Mailer.Send(c.Request.Context(), h.verifyMessage(req.Email, token, expiresAt)); err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to send email confirmation to user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation email"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "account.email_change_requested", strconv.Itoa(user.ID), gin.H{"email": req.Email})
+	c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new email"})
+}
+
+func (h *AccountHandlers) verifyMessage(email, token string, expiresAt time.Time) mailer.Message {
+	link := token
+	if h.VerifyURL != "" {
+		link = h.VerifyURL + "?token=" + url.QueryEscape(token)
+	}
+	return mailer.Message{
+		To:      email,
+		Subject: "Confirm your new email",
+		Body: "This address was entered as the new email of your account. Use the link below to confirm it:\n\n" +
+			link + "\n\n" +
+			"The link can be used once and expires at " + expiresAt.UTC().Format(time.RFC1123) + ".\n" +
+			"If you did not ask for this change, you can ignore this email.\n",
+	}
+}
+
+// VerifyEmail applies the email change confirmed by a token. The user's
+// sessions are revoked, since their tokens carry the old email.
+func (h *AccountHandlers) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	userID, email, err := h.EmailChangeStore.Redeem(c.Request.Context(), req.Token)
+	if errors.Is(err, store.ErrRevoked) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Failed to redeem email change")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	err = h.Auth.UserStore.UpdateEmail(c.Request.Context(), userID, email)
+	switch {
+	case errors.Is(err, store.ErrAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Failed to change email of user %d", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+	h.Auth.revokeSessions(c, userID, "email change")
+	clearSessionCookies(c)
+
+	recordAuditAs(c, h.AuditStore, userID, "account.email_change", strconv.Itoa(userID), gin.H{"email": email})
+	requestLog(c).Info().Int("user_id", userID).Msg("Email changed; sessions revoked")
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed. Please log in with your new email", "user_email": email})
+}
+
+// ChangePassword sets a new password. The user's other sessions are revoked
+// and the caller gets a fresh session.
+func (h *AccountHandlers) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	user, ok := h.currentUser(c, req.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to hash password for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		return
+	}
+	if err := h.Auth.UserStore.UpdatePassword(c.Request.Context(), user.ID, hashedPassword); err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to change password of user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	h.Auth.revokeSessions(c, user.ID, "password change")
+	recordAudit(c, h.AuditStore, "account.password_change", strconv.Itoa(user.ID), nil)
+
+	tokenString, refresh, ok := h.Auth.startSession(c, user)
+	if !ok {
+		return
+	}
+	requestLog(c).Info().Msgf("Password of user %d changed; other sessions revoked", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Password changed",
+		"token":         tokenString,
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"refresh_token": refresh.Token,
+	})
+}
+
+// DeleteAccount deletes the caller's account and ends their sessions.
+func (h *AccountHandlers) DeleteAccount(c *gin.Context) {
+	var req models.DeleteAccountRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+	user, ok := h.currentUser(c, req.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	err := h.Auth.UserStore.DeleteUser(c.Request.Context(), user.ID)
+	switch {
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transfer ownership of your projects before deleting your account", "details": err.Error()})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Failed to delete user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	h.Auth.revokeSessions(c, user.ID, "account deletion")
+	clearSessionCookies(c)
+
+	// The actor no longer exists, so the entry is recorded without one.
+	recordAuditAs(c, h.AuditStore, 0, "account.delete", strconv.Itoa(user.ID), gin.H{"email": user.Email})
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
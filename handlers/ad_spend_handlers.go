@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdSpendHandlers accepts ad spend uploads and reports campaign ROI.
+type AdSpendHandlers struct {
+	AdSpendStore *store.AdSpendStore
+	AuditStore   *store.AuditStore
+}
+
+func NewAdSpendHandlers(s *store.AdSpendStore, audit *store.AuditStore) *AdSpendHandlers {
+	return &AdSpendHandlers{AdSpendStore: s, AuditStore: audit}
+}
+
+// UploadSpend stores spend entries for the caller's project, either as JSON
+// ({"entries": [...]}) or, with Content-Type text/csv, as CSV with the header
+// campaign,date,spend,currency and an optional source column.
+func (h *AdSpendHandlers) UploadSpend(c *gin.Context) {
+	var entries []models.AdSpendEntry
+	if c.ContentType() == mimeCSV {
+		var err error
+		entries, err = parseAdSpendCSV(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV", "details": err.Error()})
+			return
+		}
+	} else {
+		var req models.AdSpendRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		entries = req.Entries
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No ad spend entries"})
+		return
+	}
+	for i := range entries {
+		entries[i].Currency = strings.ToUpper(entries[i].Currency)
+		if err := entries[i].Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ad spend entry", "details": err.Error(), "index": i})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	projectID := c.GetString("project_id")
+	if err := h.AdSpendStore.UpsertSpend(ctx, projectID, entries, c.GetInt("user_id")); err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error uploading ad spend for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store ad spend"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "ad_spend.upload", projectID, gin.H{"entries": len(entries)})
+
+	c.JSON(http.StatusOK, gin.H{"stored": len(entries)})
+}
+
+// parseAdSpendCSV reads spend entries from CSV with a header row naming the
+// campaign, date, spend, currency and optionally source columns.
+func parseAdSpendCSV(r io.Reader) ([]models.AdSpendEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"campaign", "date", "spend", "currency"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %q column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var entries []models.AdSpendEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		spend, err := strconv.ParseFloat(field(record, "spend"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid spend %q", line, field(record, "spend"))
+		}
+		entries = append(entries, models.AdSpendEntry{
+			Campaign: field(record, "campaign"),
+			Date:     field(record, "date"),
+			Source:   field(record, "source"),
+			Spend:    spend,
+			Currency: field(record, "currency"),
+		})
+	}
+}
+
+// ListSpend returns the caller's project spend between start and end.
+func (h *AdSpendHandlers) ListSpend(c *gin.Context) {
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	projectID := c.GetString("project_id")
+	spend, err := h.AdSpendStore.ListSpend(ctx, projectID, start, end)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing ad spend for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list ad spend"})
+		return
+	}
+
+	respond(c, http.StatusOK, spend)
+}
+
+// GetCampaignROI reports spend, first-touch attributed revenue and ROAS per
+// utm_campaign and interval (Day, Week or Month).
+func (h *AdSpendHandlers) GetCampaignROI(c *gin.Context) {
+	interval := c.DefaultQuery("interval", "Day")
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AdSpendStore.GetCampaignROI(ctx, c.GetString("project_id"), interval, start, end, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting campaign ROI")
+		statsQueryFailed(c, err, "Failed to retrieve campaign ROI")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultImpersonationTTL is how long an impersonation token lasts when the
+// request does not say.
+const defaultImpersonationTTL = 15 * time.Minute
+
+// AdminHandlers serves admin listings of users and the audit log, and support
+// impersonation.
+type AdminHandlers struct {
+	UserStore  *store.UserStore
+	AuditStore *store.AuditStore
+}
+
+func NewAdminHandlers(users *store.UserStore, audit *store.AuditStore) *AdminHandlers {
+	return &AdminHandlers{UserStore: users, AuditStore: audit}
+}
+
+// ListUsers returns a page of users, newest first.
+func (h *AdminHandlers) ListUsers(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	users, err := h.UserStore.ListUsers(ctx, page)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing users")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// ListAuditLog returns a page of audit entries, newest first, optionally
+// filtered by action and actor.
+func (h *AdminHandlers) ListAuditLog(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	filter := store.AuditFilter{Action: c.Query("action"), Target: c.Query("target")}
+	if c.Query("start") != "" || c.Query("end") != "" || c.Query("range") != "" {
+		start, end, ok := parseTimeRange(c)
+		if !ok {
+			return
+		}
+		filter.Since, filter.Until = start, end
+	}
+	if raw := c.Query("actorId"); raw != "" {
+		actorID, err := strconv.Atoi(raw)
+		if err != nil || actorID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'actorId' parameter"})
+			return
+		}
+		filter.ActorID = actorID
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	entries, err := h.AuditStore.ListEntries(ctx, filter, page)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing audit log")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// ImpersonateUser issues a short-lived token acting as the user, for support
+// staff to reproduce what the customer sees. The token is flagged with the
+// issuing admin, never has admin rights and is read-only. It is returned in
+// the body only, so the admin's own session cookie is left alone.
+func (h *AdminHandlers) ImpersonateUser(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req models.ImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	adminID := c.GetInt("user_id")
+	if adminID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Impersonation requires a user session"})
+		return
+	}
+	if userID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot impersonate yourself"})
+		return
+	}
+	ttl := defaultImpersonationTTL
+	if req.TTLMinutes > 0 {
+		ttl = time.Duration(req.TTLMinutes) * time.Minute
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.UserStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		requestLog(c).Error().Err(err).Msgf("Error getting user %d for impersonation", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	token, expiresAt, err := utils.GenerateImpersonationJWT(user, adminID, ttl)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to generate impersonation JWT for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate impersonation token"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "user.impersonate", strconv.Itoa(user.ID), gin.H{
+		"email":     user.Email,
+		"reason":    req.Reason,
+		"expiresAt": expiresAt,
+	})
+	requestLog(c).Info().Msgf("Admin %d impersonating user %d until %s", adminID, user.ID, expiresAt.Format(time.RFC3339))
+
+	c.JSON(http.StatusCreated, models.ImpersonationToken{
+		Token:          token,
+		UserID:         user.ID,
+		UserEmail:      user.Email,
+		ImpersonatorID: adminID,
+		Impersonation:  true,
+		ExpiresAt:      expiresAt,
+	})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AlertHandlers struct {
+	AlertStore *store.AlertStore
+}
+
+func NewAlertHandlers(s *store.AlertStore) *AlertHandlers {
+	return &AlertHandlers{AlertStore: s}
+}
+
+func (h *AlertHandlers) CreateAlert(c *gin.Context) {
+	var req models.AlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.WebhookURL != "" {
+		if err := utils.CheckOutboundURL(req.WebhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook URL must point to a public host", "details": err.Error()})
+			return
+		}
+	}
+
+	alert, err := h.AlertStore.CreateAlert(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error creating alert")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, alert)
+}
+
+func (h *AlertHandlers) ListAlerts(c *gin.Context) {
+	alerts, err := h.AlertStore.ListAlerts(c.Request.Context(), c.GetString("project_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing alerts")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+func (h *AlertHandlers) GetAlert(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	alert, err := h.AlertStore.GetAlert(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting alert %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+func (h *AlertHandlers) UpdateAlert(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.AlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.WebhookURL != "" {
+		if err := utils.CheckOutboundURL(req.WebhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook URL must point to a public host", "details": err.Error()})
+			return
+		}
+	}
+
+	alert, err := h.AlertStore.UpdateAlert(c.Request.Context(), c.GetString("project_id"), id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating alert %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+func (h *AlertHandlers) DeleteAlert(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	err := h.AlertStore.DeleteAlert(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting alert %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetAlertHistory returns the alert's state transitions, newest first.
+func (h *AlertHandlers) GetAlertHistory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 50)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := h.AlertStore.GetAlert(ctx, c.GetString("project_id"), id); errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	} else if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting alert %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alert"})
+		return
+	}
+
+	events, err := h.AlertStore.ListAlertEvents(ctx, id, limit)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting history of alert %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alert history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AudienceHandlers struct {
+	AudienceStore *store.AudienceStore
+	HTTPClient    *http.Client
+}
+
+func NewAudienceHandlers(s *store.AudienceStore) *AudienceHandlers {
+	return &AudienceHandlers{
+		AudienceStore: s,
+		HTTPClient:    utils.OutboundClient,
+	}
+}
+
+func (h *AudienceHandlers) CreateAudience(c *gin.Context) {
+	var req models.AudienceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	audience, err := h.AudienceStore.CreateAudience(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error creating audience")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create audience"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, audience)
+}
+
+func (h *AudienceHandlers) ListAudiences(c *gin.Context) {
+	audiences, err := h.AudienceStore.ListAudiences(c.Request.Context(), c.GetString("project_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing audiences")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audiences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, audiences)
+}
+
+// loadAudience resolves the :id path parameter, writing the error response itself.
+func (h *AudienceHandlers) loadAudience(c *gin.Context) (*models.Audience, bool) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	audience, err := h.AudienceStore.GetAudience(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audience not found"})
+		return nil, false
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting audience %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audience"})
+		return nil, false
+	}
+	return audience, true
+}
+
+func (h *AudienceHandlers) GetAudience(c *gin.Context) {
+	audience, ok := h.loadAudience(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, audience)
+}
+
+func (h *AudienceHandlers) RefreshAudience(c *gin.Context) {
+	audience, ok := h.loadAudience(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	size, err := h.AudienceStore.Materialize(ctx, audience)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error refreshing audience %d", audience.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh audience"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"audienceId": audience.ID, "size": size})
+}
+
+func (h *AudienceHandlers) GetAudienceMembers(c *gin.Context) {
+	audience, ok := h.loadAudience(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	members, err := h.AudienceStore.GetLatestMembers(ctx, audience.ID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting members of audience %d", audience.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audience members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func (h *AudienceHandlers) GetAudienceHistory(c *gin.Context) {
+	audience, ok := h.loadAudience(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 30)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	history, err := h.AudienceStore.GetMembershipHistory(ctx, audience.ID, c.Query("userId"), limit)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting history of audience %d", audience.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audience history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// ExportAudience downloads the latest membership snapshot as CSV (default) or JSON.
+func (h *AudienceHandlers) ExportAudience(c *gin.Context) {
+	audience, ok := h.loadAudience(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	members, err := h.AudienceStore.GetLatestMembers(ctx, audience.ID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error exporting audience %d", audience.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audience"})
+		return
+	}
+
+	filename := fmt.Sprintf("audience-%d", audience.ID)
+	if c.DefaultQuery("format", "csv") == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		c.JSON(http.StatusOK, members)
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"user_id"})
+	for _, userID := range members.UserIDs {
+		w.Write([]string{userID})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error encoding audience %d export", audience.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audience"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// ExportAudienceWebhook POSTs the latest membership snapshot as JSON to the
+// given URL, e.g. an ad platform's custom audience ingestion endpoint.
+func (h *AudienceHandlers) ExportAudienceWebhook(c *gin.Context) {
+	audience, ok := h.loadAudience(c)
+	if !ok {
+		return
+	}
+
+	var req models.AudienceWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	members, err := h.AudienceStore.GetLatestMembers(ctx, audience.ID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error exporting audience %d", audience.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audience"})
+		return
+	}
+
+	payload, err := json.Marshal(gin.H{
+		"audienceId": audience.ID,
+		"name":       audience.Name,
+		"computedAt": members.ComputedAt,
+		"userIds":    members.UserIDs,
+	})
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error encoding audience %d export", audience.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audience"})
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(payload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook URL"})
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// Delivery failures and rejections are answered alike, so the endpoint
+	// does not tell which hosts and ports answer.
+	resp, err := h.HTTPClient.Do(httpReq)
+	if errors.Is(err, utils.ErrForbiddenAddress) {
+		requestLog(c).Warn().Err(err).Msgf("Refused to deliver audience %d to a non-public webhook address", audience.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook URL must resolve to a public address"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error delivering audience %d to webhook", audience.ID)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to deliver audience to webhook"})
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		requestLog(c).Info().Msgf("Webhook rejected audience %d export with status %d", audience.ID, resp.StatusCode)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to deliver audience to webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "delivered": len(members.UserIDs)})
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"context"
+
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit writes an audit log entry for the current request. Failures are
+// logged rather than surfaced so auditing never blocks the audited operation.
+func recordAudit(c *gin.Context, audit *store.AuditStore, action, target string, details interface{}) {
+	recordAuditAs(c, audit, c.GetInt("user_id"), action, target, details)
+}
+
+// recordAuditAs is recordAudit for requests without an authenticated user,
+// such as logins, attributing the entry to actorID (0 for none).
+func recordAuditAs(c *gin.Context, audit *store.AuditStore, actorID int, action, target string, details interface{}) {
+	err := audit.Record(context.WithoutCancel(c.Request.Context()), actorID, action, target, details, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to record audit entry %s for %s", action, target)
+	}
+}
@@ -1,25 +1,124 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 
+	"mabletask/api/logging"
+	"mabletask/api/mailer"
 	"mabletask/api/models"
 	"mabletask/api/store"
 	"mabletask/api/utils"
 )
 
 type AuthHandlers struct {
-	UserStore *store.UserStore
+	UserStore          *store.UserStore
+	RefreshTokenStore  *store.RefreshTokenStore
+	PasswordResetStore *store.PasswordResetStore
+	LoginLimiter       *store.LoginLimiter
+	TokenRevocations   store.TokenRevocationStore
+	AuditStore         *store.AuditStore
+	Mailer             mailer.Mailer
+	// ResetURL is the page of the frontend that reset links point to; the
+	// token is appended as the token query parameter.
+	ResetURL string
 }
 
-func NewAuthHandlers(userStore *store.UserStore) *AuthHandlers {
-	return &AuthHandlers{UserStore: userStore}
+func NewAuthHandlers(userStore *store.UserStore, refreshTokens *store.RefreshTokenStore, passwordResets *store.PasswordResetStore, loginLimiter *store.LoginLimiter, tokenRevocations store.TokenRevocationStore, auditStore *store.AuditStore, mail mailer.Mailer, resetURL string) *AuthHandlers {
+	return &AuthHandlers{
+		UserStore:          userStore,
+		RefreshTokenStore:  refreshTokens,
+		PasswordResetStore: passwordResets,
+		LoginLimiter:       loginLimiter,
+		TokenRevocations:   tokenRevocations,
+		AuditStore:         auditStore,
+		Mailer:             mail,
+		ResetURL:           resetURL,
+	}
+}
+
+const (
+	accessTokenCookie  = "jwt_token"
+	refreshTokenCookie = "refresh_token"
+	// refreshTokenCookiePath keeps the refresh token cookie away from all but
+	// the API, which reads it at /api/refresh and /api/logout.
+	refreshTokenCookiePath = "/api"
+)
+
+// setSessionCookies sets the access and refresh token cookies, each expiring
+// with its token.
+func setSessionCookies(c *gin.Context, accessToken string, refresh *models.RefreshToken) {
+	c.SetCookie(accessTokenCookie, accessToken, int(utils.AccessTokenTTL.Seconds()), "/", "", true, true)
+	c.SetCookie(refreshTokenCookie, refresh.Token, int(time.Until(refresh.ExpiresAt).Seconds()), refreshTokenCookiePath, "", true, true)
+}
+
+func clearSessionCookies(c *gin.Context) {
+	c.SetCookie(accessTokenCookie, "", -1, "/", "", true, true)
+	c.SetCookie(refreshTokenCookie, "", -1, refreshTokenCookiePath, "", true, true)
+}
+
+// startSession issues the access token and a new refresh token family for the
+// user and sets their cookies. On failure it writes the response and returns
+// false.
+func (h *AuthHandlers) startSession(c *gin.Context, user *models.User) (string, *models.RefreshToken, bool) {
+	tokenString, err := utils.GenerateJWT(user)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to generate JWT for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return "", nil, false
+	}
+	refresh, err := h.RefreshTokenStore.Issue(c.Request.Context(), user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to issue refresh token for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return "", nil, false
+	}
+	setSessionCookies(c, tokenString, refresh)
+	return tokenString, refresh, true
+}
+
+// refreshTokenFromRequest reads the refresh token from its cookie or, failing
+// that, the JSON body.
+func refreshTokenFromRequest(c *gin.Context) string {
+	if token, err := c.Cookie(refreshTokenCookie); err == nil && token != "" {
+		return token
+	}
+	var req models.RefreshRequest
+	if c.Request.ContentLength > 0 {
+		_ = c.ShouldBindJSON(&req)
+	}
+	return req.RefreshToken
+}
+
+// accessTokenFromRequest returns the access token from its cookie or the
+// Authorization header, as AuthRequired reads it.
+func accessTokenFromRequest(c *gin.Context) string {
+	if token, err := c.Cookie(accessTokenCookie); err == nil && token != "" {
+		return token
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+// revokeSessions ends every session of the user: its refresh tokens and the
+// access tokens issued so far. Failures are logged, since the change that
+// prompted them has already been made.
+func (h *AuthHandlers) revokeSessions(c *gin.Context, userID int, reason string) {
+	ctx := c.Request.Context()
+	if err := h.RefreshTokenStore.RevokeUser(ctx, userID); err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to revoke sessions of user %d after %s", userID, reason)
+	}
+	if err := h.TokenRevocations.RevokeUser(ctx, userID, time.Now()); err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to revoke access tokens of user %d after %s", userID, reason)
+	}
 }
 
 func (h *AuthHandlers) Signup(c *gin.Context) {
@@ -34,22 +133,22 @@ func (h *AuthHandlers) Signup(c *gin.Context) {
 		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
 		return
 	}
-	if err.Error() != fmt.Sprintf("user with email '%s' not found", req.Email) {
-		log.Printf("ERROR: Database error during signup email check: %v", err)
+	if !errors.Is(err, store.ErrNotFound) {
+		requestLog(c).Error().Err(err).Msg("Database error during signup email check")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user existence"})
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("ERROR: Failed to hash password for %s: %v", req.Email, err)
+		requestLog(c).Error().Err(err).Msg("Failed to hash password during signup")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
 		return
 	}
 
 	user, err := h.UserStore.CreateUser(c.Request.Context(), req.Email, hashedPassword)
 	if err != nil {
-		log.Printf("ERROR: Failed to create user in DB for email %s: %v", req.Email, err)
+		requestLog(c).Error().Err(err).Msg("Failed to create user during signup")
 		if err.Error() == fmt.Sprintf("user with email '%s' already exists", req.Email) {
 			c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
 		} else {
@@ -58,25 +157,19 @@ func (h *AuthHandlers) Signup(c *gin.Context) {
 		return
 	}
 
-	log.Printf("User registered successfully via DB: ID=%d, Email=%s", user.ID, user.Email)
-	tokenString, err := utils.GenerateJWT(user)
-	if err != nil {
-		log.Printf("ERROR: Failed to generate JWT for user %d: %v", user.ID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+	requestLog(c).Info().Int("user_id", user.ID).Msg("User registered")
+	recordAuditAs(c, h.AuditStore, user.ID, "auth.signup", strconv.Itoa(user.ID), gin.H{"email": user.Email})
+	tokenString, refresh, ok := h.startSession(c, user)
+	if !ok {
 		return
 	}
-	c.SetCookie(
-		"jwt_token",
-		tokenString,
-		int(24*time.Hour),
-		"/",
-		"",
-		true,
-		true,
-	)
-	log.Printf("User registered and JWT issued: ID=%d, Email=%s", user.ID, user.Email)
-
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "user_email": user.Email, "token": tokenString})
+	c.JSON(http.StatusCreated, gin.H{
+		"message":       "User registered successfully",
+		"user_email":    user.Email,
+		"token":         tokenString,
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"refresh_token": refresh.Token,
+	})
 }
 
 func (h *AuthHandlers) Login(c *gin.Context) {
@@ -86,70 +179,262 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 		return
 	}
 
+	if err := h.LoginLimiter.Check(c.Request.Context(), c.ClientIP(), req.Email); err != nil && h.loginBlocked(c, 0, req.Email, err) {
+		return
+	}
+
 	user, err := h.UserStore.GetUserByEmail(c.Request.Context(), req.Email)
 	if err != nil {
-		log.Printf("Login failed for email %s: %v", req.Email, err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		if errors.Is(err, store.ErrNotFound) {
+			requestLog(c).Warn().Msg("Login failed: unknown email")
+		} else {
+			requestLog(c).Error().Err(err).Msg("Login failed: error getting user")
+		}
+		h.loginFailed(c, 0, req.Email, "unknown_email")
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(req.Password))
 	if err != nil {
-		log.Printf("Login failed for email %s: password mismatch", req.Email)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		requestLog(c).Warn().Int("user_id", user.ID).Msg("Login failed: password mismatch")
+		h.loginFailed(c, user.ID, req.Email, "wrong_password")
+		return
+	}
+	if err := h.LoginLimiter.Succeed(c.Request.Context(), req.Email); err != nil {
+		requestLog(c).Error().Err(err).Int("user_id", user.ID).Msg("Failed to reset login failures")
+	}
+
+	tokenString, refresh, ok := h.startSession(c, user)
+	if !ok {
+		return
+	}
+
+	requestLog(c).Info().Int("user_id", user.ID).Msg("User logged in")
+	recordAuditAs(c, h.AuditStore, user.ID, "auth.login", strconv.Itoa(user.ID), gin.H{"method": "password"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Login successful",
+		"user_email":    user.Email,
+		"token":         tokenString,
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"refresh_token": refresh.Token,
+	})
+}
+
+// loginFailed records and counts a failed login and answers 401, or 423 when
+// the failure locks the account. userID is 0 for unknown emails.
+func (h *AuthHandlers) loginFailed(c *gin.Context, userID int, email, reason string) {
+	recordAuditAs(c, h.AuditStore, userID, "auth.login_failed", email, gin.H{"reason": reason})
+	if err := h.LoginLimiter.Fail(c.Request.Context(), c.ClientIP(), email); err != nil && h.loginBlocked(c, userID, email, err) {
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
+// loginBlocked answers a login refused by the limiter: 429 for a throttled
+// client address and 423 for a locked account, with Retry-After. Other
+// errors are only logged, so an unavailable counter store does not stop
+// logins; it then returns false and writes no response.
+func (h *AuthHandlers) loginBlocked(c *gin.Context, userID int, email string, err error) bool {
+	var blockedErr *store.LoginBlockedError
+	if !errors.As(err, &blockedErr) {
+		requestLog(c).Error().Err(err).Msg("Login limiter failed")
+		return false
+	}
+	retryAfter := int(blockedErr.RetryAfter.Round(time.Second).Seconds())
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	requestLog(c).Warn().Err(err).Int("user_id", userID).Msg("Login refused by limiter")
+	locked := errors.Is(err, store.ErrAccountLocked)
+	recordAuditAs(c, h.AuditStore, userID, "auth.login_blocked", email, gin.H{"locked": locked, "retryAfterSeconds": retryAfter})
+	if locked {
+		c.JSON(http.StatusLocked, gin.H{"error": "Account temporarily locked after too many failed logins", "retry_after": retryAfter})
+		return true
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts", "retry_after": retryAfter})
+	return true
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token, which replaces the one presented.
+func (h *AuthHandlers) Refresh(c *gin.Context) {
+	token := refreshTokenFromRequest(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No refresh token provided"})
+		return
+	}
+
+	userID, refresh, err := h.RefreshTokenStore.Rotate(c.Request.Context(), token, c.Request.UserAgent(), c.ClientIP())
+	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrRevoked) {
+		requestLog(c).Warn().Err(err).Msg("Refresh failed")
+		if userID != 0 {
+			recordAuditAs(c, h.AuditStore, userID, "auth.refresh_token_reused", strconv.Itoa(userID), nil)
+		}
+		clearSessionCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Failed to rotate refresh token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
 		return
 	}
 
+	user, err := h.UserStore.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		requestLog(c).Warn().Err(err).Int("user_id", userID).Msg("Refresh failed")
+		clearSessionCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid or expired refresh token"})
+		return
+	}
 	tokenString, err := utils.GenerateJWT(user)
 	if err != nil {
-		log.Printf("ERROR: Failed to generate JWT for user %d: %v", user.ID, err)
+		requestLog(c).Error().Err(err).Msgf("Failed to generate JWT for user %d", user.ID)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
 		return
 	}
 
-	c.SetCookie(
-		"jwt_token",
-		tokenString,
-		int(24*time.Hour),
-		"/",
-		"",
-		true,
-		true,
-	)
-
-	log.Printf("User logged in: ID=%d, Email=%s. JWT issued.", user.ID, user.Email)
+	setSessionCookies(c, tokenString, refresh)
 	c.JSON(http.StatusOK, gin.H{
-		"message":    "Login successful",
-		"user_email": user.Email,
-		"token":      tokenString,
+		"token":         tokenString,
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"refresh_token": refresh.Token,
 	})
 }
 
+// Logout revokes the session's refresh token, if one is sent, and clears the
+// session cookies.
 func (h *AuthHandlers) Logout(c *gin.Context) {
-	c.SetCookie(
-		"jwt_token",
-		"",
-		-1,
-		"/",
-		"",
-		true,
-		true,
-	)
-
-	log.Println("User logged out (JWT cookie cleared).")
+	if token := refreshTokenFromRequest(c); token != "" {
+		if err := h.RefreshTokenStore.Revoke(c.Request.Context(), token); err != nil {
+			requestLog(c).Error().Err(err).Msg("Failed to revoke refresh token on logout")
+		}
+	}
+	// Expired or otherwise invalid access tokens need no revoking.
+	if claims, err := utils.ValidateJWT(accessTokenFromRequest(c)); err == nil && claims.ID != "" && claims.ExpiresAt != nil {
+		if err := h.TokenRevocations.Revoke(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+			requestLog(c).Error().Err(err).Msgf("Failed to revoke access token of user %d on logout", claims.UserID)
+		}
+	}
+	clearSessionCookies(c)
+
+	requestLog(c).Info().Msg("User logged out (session cookies cleared, tokens revoked)")
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
 func (h *AuthHandlers) GetUserByToken(c *gin.Context) {
-	email := c.GetString("user_email")
-	user, err := h.UserStore.GetUserByEmail(c.Request.Context(), email)
+	user, err := h.UserStore.GetUserByID(c.Request.Context(), c.GetInt("user_id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"user_id":    user.ID,
 		"user_email": user.Email,
-	})
+	}
+	if impersonatorID := c.GetInt("impersonator_id"); impersonatorID != 0 {
+		response["impersonated_by"] = impersonatorID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// passwordResetTimeout bounds looking up the user, creating the token and
+// sending the email of a password reset request.
+const passwordResetTimeout = 30 * time.Second
+
+// ForgotPassword emails a password reset link to the user. It answers the
+// same whether or not the email belongs to a user, so it cannot be used to
+// find out which addresses have accounts: the reset itself runs after the
+// response, so neither its outcome nor its duration show.
+func (h *AuthHandlers) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	go h.sendPasswordReset(context.WithoutCancel(c.Request.Context()), req.Email, c.ClientIP(), c.Request.UserAgent())
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+// sendPasswordReset emails a reset link to the user with email, if any.
+// Failures are only logged, as the request has already been answered.
+func (h *AuthHandlers) sendPasswordReset(ctx context.Context, email, clientIP, userAgent string) {
+	ctx, cancel := context.WithTimeout(ctx, passwordResetTimeout)
+	defer cancel()
+	logger := logging.Ctx(ctx)
+
+	user, err := h.UserStore.GetUserByEmail(ctx, email)
+	if errors.Is(err, store.ErrNotFound) {
+		logger.Info().Msg("Password reset requested for unknown email")
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Database error during password reset request")
+		return
+	}
+
+	token, expiresAt, err := h.PasswordResetStore.CreateToken(ctx, user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to create password reset token for user %d", user.ID)
+		return
+	}
+	if err := h.Mailer.Send(ctx, h.resetMessage(user.Email, token, expiresAt)); err != nil {
+		logger.Error().Err(err).Msgf("Failed to send password reset email to user %d", user.ID)
+		return
+	}
+
+	logger.Info().Int("user_id", user.ID).Msg("Password reset link sent")
+	if err := h.AuditStore.Record(ctx, user.ID, "auth.password_reset_requested", strconv.Itoa(user.ID), nil, clientIP, userAgent); err != nil {
+		logger.Error().Err(err).Msgf("Failed to record audit entry auth.password_reset_requested for %d", user.ID)
+	}
+}
+
+func (h *AuthHandlers) resetMessage(email, token string, expiresAt time.Time) mailer.Message {
+	link := token
+	if h.ResetURL != "" {
+		link = h.ResetURL + "?token=" + url.QueryEscape(token)
+	}
+	return mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: "A password reset was requested for your account. Use the link below to choose a new password:\n\n" +
+			link + "\n\n" +
+			"The link can be used once and expires at " + expiresAt.UTC().Format(time.RFC1123) + ".\n" +
+			"If you did not request a reset, you can ignore this email.\n",
+	}
+}
+
+// ResetPassword sets a new password with a reset token and ends all of the
+// user's sessions.
+func (h *AuthHandlers) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Failed to hash password during reset")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		return
+	}
+
+	userID, err := h.PasswordResetStore.ResetPassword(c.Request.Context(), req.Token, hashedPassword)
+	if errors.Is(err, store.ErrRevoked) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired password reset token"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Failed to reset password")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	h.revokeSessions(c, userID, "password reset")
+	clearSessionCookies(c)
+
+	requestLog(c).Info().Int("user_id", userID).Msg("Password reset; sessions revoked")
+	recordAuditAs(c, h.AuditStore, userID, "auth.password_reset", strconv.Itoa(userID), nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset. Please log in with your new password"})
 }
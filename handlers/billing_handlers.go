@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BillingHandlers struct {
+	UsageStore  *store.UsageStore
+	ExportStore *store.ExportStore
+}
+
+func NewBillingHandlers(usage *store.UsageStore, exports *store.ExportStore) *BillingHandlers {
+	return &BillingHandlers{UsageStore: usage, ExportStore: exports}
+}
+
+// GetBillingUsage returns the billing report for ?period=YYYY-MM (default: the
+// previous month) as JSON, or as a CSV download with format=csv.
+func (h *BillingHandlers) GetBillingUsage(c *gin.Context) {
+	periodParam := c.Query("period")
+	if periodParam == "" {
+		now := time.Now().UTC()
+		periodParam = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0).Format("2006-01")
+	}
+	period, err := store.ParseUsagePeriod(periodParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'period' parameter. Use YYYY-MM."})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	lines, err := h.UsageStore.GetBillingUsage(ctx, period)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting billing usage for %s", periodParam)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve billing usage"})
+		return
+	}
+
+	if c.DefaultQuery("format", "json") != "csv" {
+		c.JSON(http.StatusOK, lines)
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(models.BillingUsageCSVHeader)
+	for _, line := range lines {
+		w.Write(line.CSVRecord())
+	}
+	w.Flush()
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="billing-usage-%s.csv"`, periodParam))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// CreateBillingExport queues a billing report job; the result is downloaded
+// through /api/exports/:id/download.
+func (h *BillingHandlers) CreateBillingExport(c *gin.Context) {
+	var req models.BillingExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if _, err := store.ParseUsagePeriod(req.Period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'period'. Use YYYY-MM."})
+		return
+	}
+
+	kind := models.ExportKindBillingCSV
+	if req.Format == "json" {
+		kind = models.ExportKindBillingJSON
+	}
+	job, err := h.ExportStore.CreateJob(c.Request.Context(), kind, models.BillingExportParams{Period: req.Period}, c.GetInt("user_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error queueing billing export for %s", req.Period)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue billing export"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListBillingExports lists queued and generated billing reports, including the
+// ones produced by the monthly scheduler.
+func (h *BillingHandlers) ListBillingExports(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.ExportStore.ListJobs(c.Request.Context(), []string{models.ExportKindBillingCSV, models.ExportKindBillingJSON}, page)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing billing exports")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list billing exports"})
+		return
+	}
+	for i := range jobs.Items {
+		withDownloadURL(&jobs.Items[i])
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
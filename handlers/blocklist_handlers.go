@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BlocklistHandlers struct {
+	BlocklistStore *store.BlocklistStore
+	AuditStore     *store.AuditStore
+}
+
+func NewBlocklistHandlers(s *store.BlocklistStore, audit *store.AuditStore) *BlocklistHandlers {
+	return &BlocklistHandlers{BlocklistStore: s, AuditStore: audit}
+}
+
+// ListRules returns the blocklist of the request's project with hit counts.
+func (h *BlocklistHandlers) ListRules(c *gin.Context) {
+	projectID := c.GetString("project_id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rules, err := h.BlocklistStore.ListRules(ctx, projectID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing blocklist for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list blocklist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *BlocklistHandlers) CreateRule(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	var req models.BlocklistRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rule, err := h.BlocklistStore.CreateRule(ctx, projectID, c.GetInt("user_id"), req)
+	switch {
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocklist rule", "details": err.Error()})
+		return
+	case errors.Is(err, store.ErrAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Blocklist rule already exists"})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Error creating blocklist rule for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create blocklist rule"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "blocklist.create", strconv.Itoa(rule.ID), rule)
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *BlocklistHandlers) DeleteRule(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	err := h.BlocklistStore.DeleteRule(ctx, projectID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blocklist rule not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting blocklist rule %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete blocklist rule"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "blocklist.delete", strconv.Itoa(id), gin.H{"projectId": projectID})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
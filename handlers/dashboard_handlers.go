@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardHandlers struct {
+	DashboardStore *store.DashboardStore
+	FunnelStore    *store.FunnelStore
+}
+
+func NewDashboardHandlers(ds *store.DashboardStore, fs *store.FunnelStore) *DashboardHandlers {
+	return &DashboardHandlers{DashboardStore: ds, FunnelStore: fs}
+}
+
+func (h *DashboardHandlers) CreateDashboard(c *gin.Context) {
+	var req models.DashboardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	dashboard, err := h.DashboardStore.CreateDashboard(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error creating dashboard")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dashboard"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dashboard)
+}
+
+func (h *DashboardHandlers) ListDashboards(c *gin.Context) {
+	dashboards, err := h.DashboardStore.ListDashboards(c.Request.Context(), c.GetString("project_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing dashboards")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list dashboards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboards)
+}
+
+func (h *DashboardHandlers) GetDashboard(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	dashboard, err := h.DashboardStore.GetDashboard(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dashboard not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting dashboard %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dashboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+func (h *DashboardHandlers) UpdateDashboard(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.DashboardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	dashboard, err := h.DashboardStore.UpdateDashboard(c.Request.Context(), c.GetString("project_id"), id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dashboard not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating dashboard %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dashboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+func (h *DashboardHandlers) DeleteDashboard(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	err := h.DashboardStore.DeleteDashboard(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dashboard not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting dashboard %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete dashboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h *DashboardHandlers) AddWidget(c *gin.Context) {
+	dashboardID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	req, ok := h.bindWidget(c)
+	if !ok {
+		return
+	}
+
+	widget, err := h.DashboardStore.AddWidget(c.Request.Context(), c.GetString("project_id"), dashboardID, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dashboard not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error adding widget to dashboard %d", dashboardID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add widget"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, widget)
+}
+
+func (h *DashboardHandlers) UpdateWidget(c *gin.Context) {
+	dashboardID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	widgetID, ok := parseIDParam(c, "widgetId")
+	if !ok {
+		return
+	}
+	req, ok := h.bindWidget(c)
+	if !ok {
+		return
+	}
+
+	widget, err := h.DashboardStore.UpdateWidget(c.Request.Context(), c.GetString("project_id"), dashboardID, widgetID, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Widget not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating widget %d on dashboard %d", widgetID, dashboardID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update widget"})
+		return
+	}
+
+	c.JSON(http.StatusOK, widget)
+}
+
+func (h *DashboardHandlers) DeleteWidget(c *gin.Context) {
+	dashboardID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	widgetID, ok := parseIDParam(c, "widgetId")
+	if !ok {
+		return
+	}
+
+	err := h.DashboardStore.DeleteWidget(c.Request.Context(), c.GetString("project_id"), dashboardID, widgetID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Widget not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting widget %d on dashboard %d", widgetID, dashboardID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete widget"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h *DashboardHandlers) ReorderWidgets(c *gin.Context) {
+	dashboardID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.ReorderWidgetsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	err := h.DashboardStore.ReorderWidgets(c.Request.Context(), c.GetString("project_id"), dashboardID, req.WidgetIDs)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dashboard not found"})
+		return
+	}
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "widgetIds must list every widget of the dashboard exactly once", "details": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error reordering widgets on dashboard %d", dashboardID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder widgets"})
+		return
+	}
+
+	dashboard, err := h.DashboardStore.GetDashboard(c.Request.Context(), c.GetString("project_id"), dashboardID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting dashboard %d", dashboardID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dashboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+// bindWidget binds a widget request and checks that its query fits the widget
+// type: funnel widgets must reference an existing saved funnel, other widgets
+// a stats endpoint. On invalid input it writes the response and returns false.
+func (h *DashboardHandlers) bindWidget(c *gin.Context) (models.WidgetRequest, bool) {
+	var req models.WidgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return req, false
+	}
+
+	if req.Type != models.WidgetTypeFunnel {
+		if req.Query.Endpoint == "funnel" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only funnel widgets can use the 'funnel' endpoint"})
+			return req, false
+		}
+		req.Query.FunnelID = 0
+		return req, true
+	}
+
+	if req.Query.Endpoint != "funnel" || req.Query.FunnelID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Funnel widgets require query.endpoint 'funnel' and a query.funnelId"})
+		return req, false
+	}
+	_, err := h.FunnelStore.GetFunnel(c.Request.Context(), c.GetString("project_id"), req.Query.FunnelID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Referenced funnel does not exist"})
+		return req, false
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting funnel %d", req.Query.FunnelID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve funnel"})
+		return req, false
+	}
+	return req, true
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeletionHandlers struct {
+	DeletionStore *store.DeletionStore
+	AuditStore    *store.AuditStore
+}
+
+func NewDeletionHandlers(s *store.DeletionStore, audit *store.AuditStore) *DeletionHandlers {
+	return &DeletionHandlers{DeletionStore: s, AuditStore: audit}
+}
+
+func (h *DeletionHandlers) CreateDeletion(c *gin.Context) {
+	var req models.DeletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	req.ProjectID = c.GetString("project_id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	deletion, err := h.DeletionStore.CreateDeletion(ctx, c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error queueing deletion for %s '%s'", req.Type, req.SubjectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start event deletion"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "analytics.delete", req.Type+":"+req.SubjectID, gin.H{
+		"deletionId": deletion.ID,
+	})
+
+	c.JSON(http.StatusAccepted, deletion)
+}
+
+func (h *DeletionHandlers) ListDeletions(c *gin.Context) {
+	deletions, err := h.DeletionStore.ListDeletions(c.Request.Context(), c.GetString("project_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing deletions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list deletions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletions)
+}
+
+func (h *DeletionHandlers) GetDeletion(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	deletion, err := h.DeletionStore.GetDeletion(ctx, c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deletion not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting deletion %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deletion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
+}
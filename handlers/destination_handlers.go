@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/destinations"
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DestinationHandlers struct {
+	DestinationStore *store.DestinationStore
+	// Forwarder is reloaded after changes so they apply on this instance
+	// right away; other instances pick them up at their next refresh.
+	Forwarder  *destinations.Forwarder
+	AuditStore *store.AuditStore
+}
+
+func NewDestinationHandlers(s *store.DestinationStore, forwarder *destinations.Forwarder, audit *store.AuditStore) *DestinationHandlers {
+	return &DestinationHandlers{DestinationStore: s, Forwarder: forwarder, AuditStore: audit}
+}
+
+// ListKinds returns the types of destination that can be set up.
+func (h *DestinationHandlers) ListKinds(c *gin.Context) {
+	c.JSON(http.StatusOK, destinations.Kinds())
+}
+
+// bindDestination binds a destination request and checks its config builds.
+func bindDestination(c *gin.Context) (models.DestinationRequest, bool) {
+	var req models.DestinationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return req, false
+	}
+	if _, err := destinations.New(req.Kind, req.Config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid destination config", "details": err.Error()})
+		return req, false
+	}
+	return req, true
+}
+
+// redact hides the secret config fields of a destination before it is
+// returned.
+func redact(d *models.Destination) *models.Destination {
+	d.Config = destinations.Redact(d.Kind, d.Config)
+	return d
+}
+
+func (h *DestinationHandlers) reload(ctx context.Context) {
+	if err := h.Forwarder.Refresh(ctx); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Msg("Error reloading destinations")
+	}
+}
+
+func (h *DestinationHandlers) CreateDestination(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	req, ok := bindDestination(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	d, err := h.DestinationStore.CreateDestination(ctx, projectID, c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error creating destination for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create destination"})
+		return
+	}
+	h.reload(ctx)
+
+	recordAudit(c, h.AuditStore, "destination.create", strconv.Itoa(d.ID), gin.H{"projectId": projectID, "name": d.Name, "kind": d.Kind})
+	c.JSON(http.StatusCreated, redact(d))
+}
+
+func (h *DestinationHandlers) ListDestinations(c *gin.Context) {
+	projectID := c.GetString("project_id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	list, err := h.DestinationStore.ListDestinations(ctx, projectID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing destinations for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list destinations"})
+		return
+	}
+	for i := range list {
+		redact(&list[i])
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *DestinationHandlers) GetDestination(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	d, err := h.DestinationStore.GetDestination(ctx, projectID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Destination not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting destination %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve destination"})
+		return
+	}
+
+	c.JSON(http.StatusOK, redact(d))
+}
+
+// UpdateDestination replaces a destination's definition. Its config is
+// replaced as a whole, secrets included.
+func (h *DestinationHandlers) UpdateDestination(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	req, ok := bindDestination(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	d, err := h.DestinationStore.UpdateDestination(ctx, projectID, id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Destination not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating destination %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update destination"})
+		return
+	}
+	h.reload(ctx)
+
+	recordAudit(c, h.AuditStore, "destination.update", strconv.Itoa(id), gin.H{"projectId": projectID, "name": d.Name, "kind": d.Kind, "enabled": d.Enabled})
+	c.JSON(http.StatusOK, redact(d))
+}
+
+func (h *DestinationHandlers) DeleteDestination(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	err := h.DestinationStore.DeleteDestination(ctx, projectID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Destination not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting destination %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete destination"})
+		return
+	}
+	h.reload(ctx)
+
+	recordAudit(c, h.AuditStore, "destination.delete", strconv.Itoa(id), gin.H{"projectId": projectID})
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListEvents returns a page of the project's raw events, newest first, as
+// stored: for checking what the tracker actually sent. Besides the stats
+// segmentation filters and time range, userId, anonymousId and sessionId
+// select one subject's events. Pages continue with the returned next_cursor.
+func (h *AnalyticsHandlers) ListEvents(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	if page.After != nil && !utils.IsEventID(page.After.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'cursor' parameter"})
+		return
+	}
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	q := store.EventQuery{
+		Filters:     parseEventFilters(c),
+		UserID:      c.Query("userId"),
+		AnonymousID: c.Query("anonymousId"),
+		SessionID:   c.Query("sessionId"),
+		Start:       start,
+		End:         end,
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	events, err := h.AnalyticsStore.ListEvents(ctx, c.GetString("project_id"), q, page)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing events")
+		statsQueryFailed(c, err, "Failed to list events")
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EventTypeHandlers struct {
+	EventTypeStore *store.EventTypeStore
+}
+
+func NewEventTypeHandlers(s *store.EventTypeStore) *EventTypeHandlers {
+	return &EventTypeHandlers{EventTypeStore: s}
+}
+
+func (h *EventTypeHandlers) CreateEventType(c *gin.Context) {
+	var req models.EventTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	et, err := h.EventTypeStore.CreateEventType(c.Request.Context(), c.GetString("project_id"), req)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schema", "details": err.Error()})
+		return
+	}
+	if errors.Is(err, store.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Event type already registered"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error creating event type %s", req.Name)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register event type"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, et)
+}
+
+func (h *EventTypeHandlers) ListEventTypes(c *gin.Context) {
+	eventTypes, err := h.EventTypeStore.ListEventTypes(c.Request.Context(), c.GetString("project_id"), c.Query("includeDeprecated") == "true")
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing event types")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list event types"})
+		return
+	}
+
+	c.JSON(http.StatusOK, eventTypes)
+}
+
+func (h *EventTypeHandlers) GetEventType(c *gin.Context) {
+	et, err := h.EventTypeStore.GetEventType(c.Request.Context(), c.GetString("project_id"), c.Param("name"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event type not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting event type %s", c.Param("name"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, et)
+}
+
+func (h *EventTypeHandlers) UpdateEventType(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.EventTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.Name != name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event type name cannot be changed"})
+		return
+	}
+
+	et, err := h.EventTypeStore.UpdateEventType(c.Request.Context(), c.GetString("project_id"), name, req)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schema", "details": err.Error()})
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event type not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating event type %s", name)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, et)
+}
+
+func (h *EventTypeHandlers) DeprecateEventType(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.DeprecateEventTypeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	et, err := h.EventTypeStore.DeprecateEventType(c.Request.Context(), c.GetString("project_id"), name, req.Note)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event type not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deprecating event type %s", name)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deprecate event type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, et)
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExperimentHandlers manages A/B experiment definitions and reports their
+// results from the experiment assignments recorded on events.
+type ExperimentHandlers struct {
+	AnalyticsStore  *store.AnalyticsStore
+	GoalStore       *store.GoalStore
+	ExperimentStore *store.ExperimentStore
+}
+
+func NewExperimentHandlers(s *store.AnalyticsStore, goals *store.GoalStore, experiments *store.ExperimentStore) *ExperimentHandlers {
+	return &ExperimentHandlers{AnalyticsStore: s, GoalStore: goals, ExperimentStore: experiments}
+}
+
+func (h *ExperimentHandlers) CreateExperiment(c *gin.Context) {
+	var req models.ExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment", "details": err.Error()})
+		return
+	}
+
+	exp, err := h.ExperimentStore.CreateExperiment(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), req)
+	switch {
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment", "details": err.Error()})
+		return
+	case errors.Is(err, store.ErrAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Experiment already exists"})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Error creating experiment %s", req.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create experiment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, exp)
+}
+
+func (h *ExperimentHandlers) ListExperiments(c *gin.Context) {
+	experiments, err := h.ExperimentStore.ListExperiments(c.Request.Context(), c.GetString("project_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing experiments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list experiments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, experiments)
+}
+
+func (h *ExperimentHandlers) GetExperiment(c *gin.Context) {
+	exp, err := h.ExperimentStore.GetExperiment(c.Request.Context(), c.GetString("project_id"), c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting experiment %s", c.Param("id"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve experiment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, exp)
+}
+
+func (h *ExperimentHandlers) UpdateExperiment(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.ExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.ID != id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Experiment id cannot be changed"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment", "details": err.Error()})
+		return
+	}
+
+	exp, err := h.ExperimentStore.UpdateExperiment(c.Request.Context(), c.GetString("project_id"), id, req)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment", "details": err.Error()})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Error updating experiment %s", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update experiment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, exp)
+}
+
+func (h *ExperimentHandlers) DeleteExperiment(c *gin.Context) {
+	id := c.Param("id")
+
+	err := h.ExperimentStore.DeleteExperiment(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting experiment %s", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete experiment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// defaultControlVariant is the control when the request names none and the
+// experiment has a variant of that name; otherwise the first variant is used.
+const defaultControlVariant = "control"
+
+// GetExperimentResults returns, per variant of the experiment, the exposed
+// users and their conversion rate on goalId, each non-control variant tested
+// against the control with a two-proportion z-test. goalId and control
+// default to those of the experiment's definition, if it has one.
+func (h *ExperimentHandlers) GetExperimentResults(c *gin.Context) {
+	experimentID := c.Param("id")
+	goalID := 0
+	if goalIDParam := c.Query("goalId"); goalIDParam != "" {
+		id, err := strconv.Atoi(goalIDParam)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'goalId' parameter"})
+			return
+		}
+		goalID = id
+	}
+	confidence := 0.95
+	if confidenceParam := c.Query("confidence"); confidenceParam != "" {
+		var err error
+		confidence, err = strconv.ParseFloat(confidenceParam, 64)
+		if err != nil || confidence < 0.5 || confidence >= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'confidence' parameter. Must be at least 0.5 and below 1, e.g. 0.95."})
+			return
+		}
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	control := c.Query("control")
+	if goalID == 0 || control == "" {
+		exp, err := h.ExperimentStore.GetExperiment(ctx, filters.ProjectID, experimentID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			requestLog(c).Error().Err(err).Msgf("Error getting experiment %s", experimentID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve experiment"})
+			return
+		}
+		if exp != nil {
+			if goalID == 0 && exp.GoalID != nil {
+				goalID = *exp.GoalID
+			}
+			if control == "" {
+				control = exp.Control
+			}
+		}
+	}
+	if goalID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "goalId query parameter is required unless the experiment is defined with a goal"})
+		return
+	}
+
+	goal, err := h.GoalStore.GetGoal(ctx, filters.ProjectID, goalID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting goal %d", goalID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve goal"})
+		return
+	}
+
+	variants, err := h.AnalyticsStore.GetExperimentVariants(ctx, experimentID, goal, start, end, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting results of experiment %s", experimentID)
+		statsQueryFailed(c, err, "Failed to retrieve experiment results")
+		return
+	}
+
+	results := models.ExperimentResults{
+		ExperimentID: experimentID,
+		GoalID:       goal.ID,
+		GoalName:     goal.Name,
+		Control:      control,
+		Confidence:   confidence,
+		Variants:     variants,
+	}
+	if !compareVariants(&results) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Control variant not found in experiment", "control": results.Control})
+		return
+	}
+
+	c.Set("rows_returned", len(variants))
+	respondTable(c, http.StatusOK, results, results.Variants)
+}
+
+// compareVariants picks the control, defaulting as described for
+// defaultControlVariant, and fills in each other variant's lift and
+// significance. It reports false when a requested control has no exposures.
+func compareVariants(results *models.ExperimentResults) bool {
+	if len(results.Variants) == 0 {
+		return true
+	}
+	if results.Control == "" {
+		results.Control = results.Variants[0].Variant
+		for _, v := range results.Variants {
+			if v.Variant == defaultControlVariant {
+				results.Control = v.Variant
+			}
+		}
+	}
+
+	var control *models.VariantResult
+	for i := range results.Variants {
+		if results.Variants[i].Variant == results.Control {
+			control = &results.Variants[i]
+		}
+	}
+	if control == nil {
+		return false
+	}
+
+	for i := range results.Variants {
+		v := &results.Variants[i]
+		if v == control {
+			continue
+		}
+		if control.ConversionRate > 0 {
+			lift := (v.ConversionRate - control.ConversionRate) / control.ConversionRate
+			v.Lift = &lift
+		}
+		if z, p, ok := utils.TwoProportionZTest(control.SampledConversions, control.SampledUsers, v.SampledConversions, v.SampledUsers); ok {
+			v.ZScore, v.PValue = &z, &p
+			v.Significant = p < 1-results.Confidence
+		}
+	}
+	return true
+}
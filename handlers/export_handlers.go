@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandlers struct {
+	ExportStore *store.ExportStore
+	// LinkSecret signs download links that work without authentication for
+	// LinkTTL; without it no signed links are issued.
+	LinkSecret []byte
+	LinkTTL    time.Duration
+}
+
+func NewExportHandlers(s *store.ExportStore) *ExportHandlers {
+	return &ExportHandlers{ExportStore: s}
+}
+
+// withDownloadURL fills in the download link for completed jobs.
+func withDownloadURL(job *models.ExportJob) *models.ExportJob {
+	if job.Status == models.ExportStatusCompleted {
+		job.DownloadURL = "/api/exports/" + job.ID + "/download"
+	}
+	return job
+}
+
+// loadExportJob resolves the :id path parameter and checks that the caller
+// requested the job or is an admin, writing the error response itself.
+func (h *ExportHandlers) loadExportJob(c *gin.Context) (*models.ExportJob, bool) {
+	job, err := h.ExportStore.GetJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export job not found"})
+		return nil, false
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting export job %s", c.Param("id"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve export job"})
+		return nil, false
+	}
+
+	isRequester := job.RequestedBy != nil && *job.RequestedBy == c.GetInt("user_id")
+	if !isRequester && !c.GetBool("is_admin") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export job not found"})
+		return nil, false
+	}
+	return job, true
+}
+
+// withSignedDownloadURL fills in a signed download link for completed jobs.
+func (h *ExportHandlers) withSignedDownloadURL(job *models.ExportJob) *models.ExportJob {
+	if job.Status != models.ExportStatusCompleted || len(h.LinkSecret) == 0 {
+		return job
+	}
+	expires := time.Now().Add(h.LinkTTL).Truncate(time.Second).UTC()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {utils.SignLink(h.LinkSecret, "exports/"+job.ID, expires)},
+	}
+	job.SignedDownloadURL = "/api/exports/" + job.ID + "/file?" + query.Encode()
+	job.SignedDownloadExpiresAt = &expires
+	return job
+}
+
+func (h *ExportHandlers) GetExport(c *gin.Context) {
+	job, ok := h.loadExportJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.withSignedDownloadURL(withDownloadURL(job)))
+}
+
+func (h *ExportHandlers) DownloadExport(c *gin.Context) {
+	job, ok := h.loadExportJob(c)
+	if !ok {
+		return
+	}
+	serveExportFile(c, job)
+}
+
+// DownloadSignedExport serves an export through a signed link from
+// GetExport, without authentication. Invalid and expired links get 403.
+func (h *ExportHandlers) DownloadSignedExport(c *gin.Context) {
+	unix, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !utils.VerifyLink(h.LinkSecret, "exports/"+c.Param("id"), time.Unix(unix, 0), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired download link"})
+		return
+	}
+
+	job, err := h.ExportStore.GetJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export job not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting export job %s", c.Param("id"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve export job"})
+		return
+	}
+	serveExportFile(c, job)
+}
+
+// serveExportFile sends the file of a completed export as an attachment.
+func serveExportFile(c *gin.Context, job *models.ExportJob) {
+	if job.Status == models.ExportStatusExpired {
+		c.JSON(http.StatusGone, gin.H{"error": "Export file has expired", "status": job.Status})
+		return
+	}
+	if job.Status != models.ExportStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is not ready", "status": job.Status})
+		return
+	}
+
+	c.FileAttachment(job.FilePath, job.Kind+"-"+job.ID+filepath.Ext(job.FilePath))
+}
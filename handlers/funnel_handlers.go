@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FunnelHandlers struct {
+	FunnelStore    *store.FunnelStore
+	AnalyticsStore *store.AnalyticsStore
+}
+
+func NewFunnelHandlers(fs *store.FunnelStore, as *store.AnalyticsStore) *FunnelHandlers {
+	return &FunnelHandlers{FunnelStore: fs, AnalyticsStore: as}
+}
+
+func (h *FunnelHandlers) CreateFunnel(c *gin.Context) {
+	var req models.FunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	funnel, err := h.FunnelStore.CreateFunnel(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error creating funnel")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create funnel"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, funnel)
+}
+
+func (h *FunnelHandlers) ListFunnels(c *gin.Context) {
+	funnels, err := h.FunnelStore.ListFunnels(c.Request.Context(), c.GetString("project_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing funnels")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list funnels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, funnels)
+}
+
+func (h *FunnelHandlers) GetFunnel(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	funnel, err := h.FunnelStore.GetFunnel(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Funnel not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting funnel %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve funnel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, funnel)
+}
+
+func (h *FunnelHandlers) UpdateFunnel(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.FunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	funnel, err := h.FunnelStore.UpdateFunnel(c.Request.Context(), c.GetString("project_id"), id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Funnel not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating funnel %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update funnel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, funnel)
+}
+
+func (h *FunnelHandlers) DeleteFunnel(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	err := h.FunnelStore.DeleteFunnel(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Funnel not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting funnel %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete funnel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// maxFunnelSteps matches the step limit of saved funnels.
+const maxFunnelSteps = 10
+
+// GetFunnelReport runs an ad-hoc funnel over the comma-separated event types
+// in steps, e.g. steps=page_view,add_to_cart,checkout,purchase, within
+// windowSeconds (default 24h) of the first step.
+func (h *FunnelHandlers) GetFunnelReport(c *gin.Context) {
+	var steps []models.FunnelStepDefinition
+	for _, eventType := range strings.Split(c.Query("steps"), ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			steps = append(steps, models.FunnelStepDefinition{EventType: eventType})
+		}
+	}
+	if len(steps) < 2 || len(steps) > maxFunnelSteps {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'steps' parameter. Give 2 to 10 comma-separated event types."})
+		return
+	}
+	window := store.DefaultFunnelWindow
+	if raw := c.Query("windowSeconds"); raw != "" {
+		seconds, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || seconds <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'windowSeconds' parameter. Must be a positive integer."})
+			return
+		}
+		window = time.Duration(seconds) * time.Second
+	}
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetFunnel(ctx, steps, window, start, end, parseEventFilters(c))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error executing funnel %v", steps)
+		statsQueryFailed(c, err, "Failed to retrieve funnel statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respondTable(c, http.StatusOK, models.FunnelResult{
+		WindowSeconds: int64(window.Seconds()),
+		StartDate:     start.Format(time.RFC3339),
+		EndDate:       end.Format(time.RFC3339),
+		Steps:         results,
+	}, results)
+}
+
+// GetSavedFunnel executes a saved funnel over the requested time range. Trait
+// filters given in the query are combined with the funnel's own, taking
+// precedence on conflicts.
+func (h *FunnelHandlers) GetSavedFunnel(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	funnel, err := h.FunnelStore.GetFunnel(ctx, c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Funnel not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting funnel %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve funnel"})
+		return
+	}
+
+	filters := parseEventFilters(c)
+	traits := make(map[string]string, len(funnel.Definition.Traits)+len(filters.Traits))
+	for k, v := range funnel.Definition.Traits {
+		traits[k] = v
+	}
+	for k, v := range filters.Traits {
+		traits[k] = v
+	}
+	filters.Traits = traits
+
+	window := time.Duration(funnel.Definition.WindowSeconds) * time.Second
+	if window <= 0 {
+		window = store.DefaultFunnelWindow
+	}
+
+	steps, err := h.AnalyticsStore.GetFunnel(ctx, funnel.Definition.Steps, window, start, end, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error executing funnel %d", id)
+		statsQueryFailed(c, err, "Failed to retrieve funnel statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(steps))
+	respondTable(c, http.StatusOK, models.FunnelResult{
+		FunnelID:      &funnel.ID,
+		WindowSeconds: int64(window.Seconds()),
+		StartDate:     start.Format(time.RFC3339),
+		EndDate:       end.Format(time.RFC3339),
+		Steps:         steps,
+	}, steps)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GoalHandlers struct {
+	GoalStore *store.GoalStore
+}
+
+func NewGoalHandlers(s *store.GoalStore) *GoalHandlers {
+	return &GoalHandlers{GoalStore: s}
+}
+
+func (h *GoalHandlers) CreateGoal(c *gin.Context) {
+	var req models.GoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal", "details": err.Error()})
+		return
+	}
+
+	goal, err := h.GoalStore.CreateGoal(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error creating goal")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create goal"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, goal)
+}
+
+func (h *GoalHandlers) ListGoals(c *gin.Context) {
+	goals, err := h.GoalStore.ListGoals(c.Request.Context(), c.GetString("project_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing goals")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list goals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, goals)
+}
+
+func (h *GoalHandlers) GetGoal(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	goal, err := h.GoalStore.GetGoal(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting goal %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, goal)
+}
+
+func (h *GoalHandlers) UpdateGoal(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.GoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal", "details": err.Error()})
+		return
+	}
+
+	goal, err := h.GoalStore.UpdateGoal(c.Request.Context(), c.GetString("project_id"), id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating goal %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, goal)
+}
+
+func (h *GoalHandlers) DeleteGoal(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	err := h.GoalStore.DeleteGoal(c.Request.Context(), c.GetString("project_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting goal %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetGoalsReport returns conversions and conversion rate over time for every
+// goal, or only for the goal given by goalId.
+func (h *GoalHandlers) GetGoalsReport(c *gin.Context) {
+	interval := c.Query("interval")
+	if interval == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval query parameter is required (e.g., 'Day', 'Hour')"})
+		return
+	}
+	filters := parseEventFilters(c)
+
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var goals []models.Goal
+	if goalIDParam := c.Query("goalId"); goalIDParam != "" {
+		id, err := strconv.Atoi(goalIDParam)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'goalId' parameter"})
+			return
+		}
+		goal, err := h.GoalStore.GetGoal(ctx, filters.ProjectID, id)
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		}
+		if err != nil {
+			requestLog(c).Error().Err(err).Msgf("Error getting goal %d", id)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve goal"})
+			return
+		}
+		goals = []models.Goal{*goal}
+	} else {
+		var err error
+		goals, err = h.GoalStore.ListGoals(ctx, filters.ProjectID)
+		if err != nil {
+			requestLog(c).Error().Err(err).Msg("Error listing goals")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list goals"})
+			return
+		}
+	}
+
+	reports := make([]models.GoalReport, 0, len(goals))
+	rowsReturned := 0
+	for i := range goals {
+		series, err := h.GoalStore.GetGoalConversionsOverTime(ctx, &goals[i], interval, start, end, filters)
+		if err != nil {
+			requestLog(c).Error().Err(err).Msgf("Error getting conversions for goal %d", goals[i].ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve goal statistics"})
+			return
+		}
+		reports = append(reports, models.GoalReport{GoalID: goals[i].ID, Name: goals[i].Name, Series: series})
+		rowsReturned += len(series)
+	}
+
+	c.Set("rows_returned", rowsReturned)
+	respond(c, http.StatusOK, reports)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GroupHandlers struct {
+	GroupStore *store.GroupStore
+}
+
+func NewGroupHandlers(s *store.GroupStore) *GroupHandlers {
+	return &GroupHandlers{GroupStore: s}
+}
+
+func (h *GroupHandlers) Group(c *gin.Context) {
+	var req models.GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.GroupStore.AssociateUser(ctx, c.GetString("project_id"), req); err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error associating user %s with group %s", req.UserID, req.GroupID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to associate user with group"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h *GroupHandlers) GetActiveAccountsOverTime(c *gin.Context) {
+	interval := c.Query("interval")
+	if interval == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval query parameter is required (e.g., 'Day', 'Hour')"})
+		return
+	}
+	filters := parseEventFilters(c)
+
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.GroupStore.GetActiveAccountsOverTime(ctx, interval, start, end, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting active accounts over time")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve active account statistics"})
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+func (h *GroupHandlers) GetEventsPerAccount(c *gin.Context) {
+	filters := parseEventFilters(c)
+
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.GroupStore.GetEventsPerAccount(ctx, start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting events per account")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events per account statistics"})
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
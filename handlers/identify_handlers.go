@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IdentifyHandlers struct {
+	TraitsStore *store.TraitsStore
+}
+
+func NewIdentifyHandlers(s *store.TraitsStore) *IdentifyHandlers {
+	return &IdentifyHandlers{TraitsStore: s}
+}
+
+func (h *IdentifyHandlers) Identify(c *gin.Context) {
+	var req models.IdentifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	traits, err := h.TraitsStore.UpsertUserTraits(ctx, c.GetString("project_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error storing traits for user %s", req.UserID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store user traits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, traits)
+}
+
+func (h *IdentifyHandlers) GetUserTraits(c *gin.Context) {
+	userID := c.Param("userId")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	traits, err := h.TraitsStore.GetUserTraits(ctx, c.GetString("project_id"), userID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting traits for user %s", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user traits"})
+		return
+	}
+	if traits == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User traits not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, traits)
+}
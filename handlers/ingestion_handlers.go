@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IngestionHandlers struct {
+	AnalyticsStore *store.AnalyticsStore
+}
+
+func NewIngestionHandlers(s *store.AnalyticsStore) *IngestionHandlers {
+	return &IngestionHandlers{AnalyticsStore: s}
+}
+
+// GetIngestionStats reports this instance's ingestion counters. Each replica
+// keeps its own counters, so behind a load balancer the numbers are per node.
+func (h *IngestionHandlers) GetIngestionStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.AnalyticsStore.Ingest.Snapshot())
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandlers struct {
+	JobStore   *store.JobStore
+	AuditStore *store.AuditStore
+}
+
+func NewJobHandlers(s *store.JobStore, audit *store.AuditStore) *JobHandlers {
+	return &JobHandlers{JobStore: s, AuditStore: audit}
+}
+
+var jobStatuses = map[string]bool{
+	models.JobStatusPending:   true,
+	models.JobStatusRunning:   true,
+	models.JobStatusCompleted: true,
+	models.JobStatusFailed:    true,
+}
+
+// ListJobs returns queued jobs, newest first, optionally filtered by kind and status.
+func (h *JobHandlers) ListJobs(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	filter := store.JobFilter{Kind: c.Query("kind"), Status: c.Query("status")}
+	if filter.Status != "" && !jobStatuses[filter.Status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'status'. Use pending, running, completed or failed."})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	jobs, err := h.JobStore.ListJobs(ctx, filter, page)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetJobSummary returns the number of jobs per kind and status.
+func (h *JobHandlers) GetJobSummary(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	counts, err := h.JobStore.CountJobs(ctx)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error counting jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, counts)
+}
+
+func (h *JobHandlers) GetJob(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	job, err := h.JobStore.GetJob(ctx, c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting job %s", c.Param("id"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJob requeues a failed job with a fresh set of attempts.
+func (h *JobHandlers) RetryJob(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	job, err := h.JobStore.Requeue(ctx, c.Param("id"))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed jobs can be retried"})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Error retrying job %s", c.Param("id"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "jobs.retry", job.ID, gin.H{"kind": job.Kind})
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"mabletask/api/utils"
+)
+
+// JWKS publishes the public keys access tokens are signed with, so other
+// services can verify tokens without the HS256 secret. The set is empty while
+// tokens are signed with HS256 only.
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": utils.JWKS()})
+}
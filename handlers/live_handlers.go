@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// LiveHandlers serves real-time dashboard summaries, pushed over WebSocket or
+// polled.
+type LiveHandlers struct {
+	AnalyticsStore *store.AnalyticsStore
+	// Interval is how often each connection receives a fresh summary.
+	// Connections with the same filters share one query per interval.
+	Interval time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedSummary
+}
+
+type cachedSummary struct {
+	summary *models.LiveSummary
+	at      time.Time
+}
+
+func NewLiveHandlers(s *store.AnalyticsStore, interval time.Duration) *LiveHandlers {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &LiveHandlers{AnalyticsStore: s, Interval: interval, cache: map[string]cachedSummary{}}
+}
+
+// Dashboard upgrades the request to a WebSocket and sends a models.LiveSummary
+// right away and then every Interval, until the client disconnects. The
+// segmentation filters of the stats endpoints apply, given as query parameters
+// of the connection URL.
+func (h *LiveHandlers) Dashboard(c *gin.Context) {
+	filters := parseEventFilters(c)
+	server := websocket.Server{
+		Handshake: checkWebSocketOrigin,
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			h.stream(ws, filters)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// GetRealtime returns the current models.LiveSummary: visitors active in the
+// last 5 minutes, events per second over the last minute, top pages of the
+// last half hour and the latest purchases. Results are shared with the
+// WebSocket streams and recomputed at most once per Interval.
+func (h *LiveHandlers) GetRealtime(c *gin.Context) {
+	summary, err := h.summary(c.Request.Context(), parseEventFilters(c))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting realtime summary")
+		statsQueryFailed(c, err, "Failed to retrieve realtime statistics")
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(h.Interval.Seconds())))
+	c.Set("rows_returned", 1)
+	respond(c, http.StatusOK, summary)
+}
+
+func (h *LiveHandlers) stream(ws *websocket.Conn, filters store.EventFilters) {
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+	defer cancel()
+
+	// Clients do not send anything; reading only detects the disconnect.
+	go func() {
+		defer cancel()
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+	for {
+		summary, err := h.summary(ctx, filters)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logging.Ctx(ctx).Error().Err(err).Msg("Error getting live dashboard summary")
+		} else {
+			ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := websocket.JSON.Send(ws, summary); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// summary returns the summary for filters, reusing one computed less than an
+// interval ago.
+func (h *LiveHandlers) summary(ctx context.Context, filters store.EventFilters) (*models.LiveSummary, error) {
+	key := fmt.Sprintf("%+v", filters)
+	now := time.Now().UTC()
+
+	h.mu.Lock()
+	cached, ok := h.cache[key]
+	h.mu.Unlock()
+	if ok && now.Sub(cached.at) < h.Interval {
+		return cached.summary, nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	summary, err := h.AnalyticsStore.GetLiveSummary(queryCtx, now, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k, entry := range h.cache {
+		if now.Sub(entry.at) >= h.Interval {
+			delete(h.cache, k)
+		}
+	}
+	h.cache[key] = cachedSummary{summary: summary, at: now}
+	return summary, nil
+}
+
+// checkWebSocketOrigin rejects cross-site upgrades, which browsers would
+// otherwise send with the user's session cookie. Without FE_ORIGIN, only
+// same-host origins and non-browser clients (no Origin) are accepted.
+func checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if allowed := os.Getenv("FE_ORIGIN"); allowed != "" && origin == allowed {
+		return nil
+	}
+	if u, err := websocket.Origin(config, r); err == nil && u.Host == r.Host {
+		return nil
+	}
+	return fmt.Errorf("websocket origin %q not allowed", origin)
+}
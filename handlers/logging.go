@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"mabletask/api/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// requestLog returns the logger of the request, carrying its request ID,
+// route, user and project. It must not be used after the handler returns, as
+// gin reuses the context; goroutines take logging.Ctx(c.Request.Context())
+// beforehand.
+func requestLog(c *gin.Context) *zerolog.Logger {
+	return logging.Ctx(c.Request.Context())
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+
+	"mabletask/api/oauth"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+)
+
+type OAuthHandlers struct {
+	Auth      *AuthHandlers
+	Providers oauth.Providers
+	// SuccessURL is where the browser is sent after signing in; empty, the
+	// callback answers with the tokens like /api/login.
+	SuccessURL string
+}
+
+func NewOAuthHandlers(auth *AuthHandlers, providers oauth.Providers, successURL string) *OAuthHandlers {
+	return &OAuthHandlers{Auth: auth, Providers: providers, SuccessURL: successURL}
+}
+
+const (
+	oauthStateCookie    = "oauth_state"
+	oauthVerifierCookie = "oauth_verifier"
+	// oauthCookieMaxAge bounds how long the user may take at the provider.
+	oauthCookieMaxAge = 10 * 60
+)
+
+func (h *OAuthHandlers) provider(c *gin.Context) (*oauth.Provider, bool) {
+	provider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown OAuth provider", "available": h.Providers.Names()})
+		return nil, false
+	}
+	return provider, true
+}
+
+// Start redirects to the provider's consent page. The state and PKCE
+// verifier the callback checks are kept in short-lived cookies scoped to the
+// callback.
+func (h *OAuthHandlers) Start(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	state, err := utils.NewToken("")
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Failed to generate OAuth state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	path := "/api/auth/" + provider.Name + "/callback"
+	c.SetCookie(oauthStateCookie, state, oauthCookieMaxAge, path, "", true, true)
+	c.SetCookie(oauthVerifierCookie, verifier, oauthCookieMaxAge, path, "", true, true)
+	c.Redirect(http.StatusFound, provider.Config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)))
+}
+
+// Callback completes the sign-in: it exchanges the code, creates or links the
+// user by the verified email of the provider account and starts a session as
+// /api/login does.
+func (h *OAuthHandlers) Callback(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	path := "/api/auth/" + provider.Name + "/callback"
+	state, _ := c.Cookie(oauthStateCookie)
+	verifier, _ := c.Cookie(oauthVerifierCookie)
+	c.SetCookie(oauthStateCookie, "", -1, path, "", true, true)
+	c.SetCookie(oauthVerifierCookie, "", -1, path, "", true, true)
+
+	if reason := c.Query("error"); reason != "" {
+		requestLog(c).Warn().Msgf("OAuth sign-in with %s denied: %s", provider.Name, reason)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in was not authorized", "details": reason})
+		return
+	}
+	if state == "" || verifier == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired OAuth state"})
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization code"})
+		return
+	}
+
+	identity, err := provider.Identity(c.Request.Context(), code, verifier)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("OAuth sign-in with %s failed", provider.Name)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to sign in with " + provider.Name})
+		return
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The " + provider.Name + " account has no verified email"})
+		return
+	}
+
+	user, err := h.Auth.UserStore.GetOrCreateOAuthUser(c.Request.Context(), provider.Name, identity.Subject, identity.Email)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This " + provider.Name + " account was just linked by another request; please retry"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Failed to sign in %s account %s", provider.Name, identity.Subject)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	tokenString, refresh, ok := h.Auth.startSession(c, user)
+	if !ok {
+		return
+	}
+	requestLog(c).Info().Int("user_id", user.ID).Str("provider", provider.Name).Msg("User logged in via OAuth")
+	recordAuditAs(c, h.Auth.AuditStore, user.ID, "auth.login", strconv.Itoa(user.ID), gin.H{"method": provider.Name})
+
+	if h.SuccessURL != "" {
+		c.Redirect(http.StatusFound, h.SuccessURL)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Login successful",
+		"user_email":    user.Email,
+		"token":         tokenString,
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"refresh_token": refresh.Token,
+	})
+}
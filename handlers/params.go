@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseEventFilters reads the optional segmentation parameters shared by all
+// stats endpoints, e.g. ?trait[plan]=pro&trait[company]=Acme&country=DE&device=mobile.
+func parseEventFilters(c *gin.Context) store.EventFilters {
+	return store.EventFilters{
+		ProjectID:      c.GetString("project_id"),
+		Traits:         c.QueryMap("trait"),
+		EventTypes:     parseEventTypes(c),
+		Country:        strings.ToUpper(c.Query("country")),
+		Region:         strings.ToUpper(c.Query("region")),
+		City:           c.Query("city"),
+		DeviceType:     strings.ToLower(c.Query("device")),
+		Browser:        c.Query("browser"),
+		OS:             c.Query("os"),
+		PagePathPrefix: c.Query("pagePath"),
+		ReferrerDomain: strings.TrimPrefix(strings.ToLower(c.Query("referrerDomain")), "www."),
+		Channel:        strings.ToLower(c.Query("channel")),
+		UTMSource:      c.Query("utm_source"),
+		UTMMedium:      c.Query("utm_medium"),
+		UTMCampaign:    c.Query("utm_campaign"),
+		IncludeBots:    c.Query("includeBots") == "true",
+	}
+}
+
+// parseEventTypes reads the eventType parameter, which may be repeated or hold
+// a comma-separated list, e.g. ?eventType=page_view,purchase.
+func parseEventTypes(c *gin.Context) []string {
+	var types []string
+	for _, param := range c.QueryArray("eventType") {
+		for _, t := range strings.Split(param, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+	}
+	return types
+}
+
+// parseTimeRange reads either a relative range preset (?range=last_30d&tz=Europe/Berlin)
+// or the optional start/end query parameters, defaulting to the last 7 days.
+// Plain dates in start/end are taken in tz as well, and end=YYYY-MM-DD includes
+// that whole day. On invalid input it writes a 400 response and returns false.
+func parseTimeRange(c *gin.Context) (time.Time, time.Time, bool) {
+	var start, end time.Time
+	var err error
+
+	loc := time.UTC
+	if tz := c.Query("tz"); tz != "" {
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'tz' parameter. Use an IANA timezone name (e.g., Europe/Berlin)"})
+			return start, end, false
+		}
+	}
+
+	if preset := c.Query("range"); preset != "" {
+		if c.Query("start") != "" || c.Query("end") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Use either 'range' or 'start'/'end', not both"})
+			return start, end, false
+		}
+		start, end, ok := utils.RangePreset(preset, time.Now().In(loc))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'range' parameter. Must be one of: " + strings.Join(utils.RangePresets, ", ")})
+		}
+		return start, end, ok
+	}
+
+	startParam := c.Query("start")
+	if startParam != "" {
+		start, err = utils.ParseTimeParam(startParam, loc, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'start' parameter", "details": err.Error()})
+			return start, end, false
+		}
+	} else {
+		start = time.Now().UTC().Add(-7 * 24 * time.Hour)
+	}
+
+	endParam := c.Query("end")
+	if endParam != "" {
+		end, err = utils.ParseTimeParam(endParam, loc, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'end' parameter", "details": err.Error()})
+			return start, end, false
+		}
+	} else {
+		end = time.Now().UTC()
+	}
+
+	return start, end, true
+}
+
+// parseLimit reads the optional positive integer limit parameter. On invalid
+// input it writes a 400 response and returns false.
+func parseLimit(c *gin.Context, def uint64) (uint64, bool) {
+	limitParam := c.Query("limit")
+	if limitParam == "" {
+		return def, true
+	}
+	limit, err := strconv.ParseUint(limitParam, 10, 64)
+	if err != nil || limit == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit' parameter. Must be a positive integer."})
+		return 0, false
+	}
+	return limit, true
+}
+
+// parsePage reads the limit and cursor parameters of a paginated listing,
+// capping limit at store.MaxPageLimit. On invalid input it writes a 400
+// response and returns false.
+func parsePage(c *gin.Context) (store.PageRequest, bool) {
+	limit, ok := parseLimit(c, store.DefaultPageLimit)
+	if !ok {
+		return store.PageRequest{}, false
+	}
+	page := store.PageRequest{Limit: min(limit, store.MaxPageLimit)}
+	if raw := c.Query("cursor"); raw != "" {
+		after, err := store.DecodeCursor(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'cursor' parameter"})
+			return store.PageRequest{}, false
+		}
+		page.After = after
+	}
+	return page, true
+}
+
+// parseComparison reads the optional compare parameter of time-series
+// endpoints (previous_period or previous_year), returning nil without it. On
+// invalid input it writes a 400 response and returns false.
+func parseComparison(c *gin.Context, start, end time.Time) (*store.Comparison, bool) {
+	mode := c.Query("compare")
+	if mode == "" {
+		return nil, true
+	}
+	cmp, err := store.NewComparison(mode, start, end)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'compare' parameter. Must be 'previous_period' or 'previous_year'."})
+		return nil, false
+	}
+	return cmp, true
+}
+
+// comparedSeries wraps a series and its comparison for the response.
+func comparedSeries[T any](cmp *store.Comparison, current, previous []T) models.ComparedSeries[T] {
+	if current == nil {
+		current = []T{}
+	}
+	if previous == nil {
+		previous = []T{}
+	}
+	return models.ComparedSeries[T]{
+		Compare:             cmp.Mode,
+		ComparisonStartDate: cmp.Start.Format(time.RFC3339),
+		ComparisonEndDate:   cmp.End.Format(time.RFC3339),
+		Current:             current,
+		Comparison:          previous,
+	}
+}
+
+// parseIDParam reads a positive integer path parameter. On invalid input it
+// writes a 400 response and returns false.
+func parseIDParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid '" + name + "' path parameter"})
+		return 0, false
+	}
+	return id, true
+}
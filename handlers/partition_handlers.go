@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PartitionHandlers struct {
+	PartitionStore *store.PartitionStore
+	AuditStore     *store.AuditStore
+}
+
+func NewPartitionHandlers(s *store.PartitionStore, audit *store.AuditStore) *PartitionHandlers {
+	return &PartitionHandlers{PartitionStore: s, AuditStore: audit}
+}
+
+// writePartitionError maps store errors to responses for the :table routes.
+func writePartitionError(c *gin.Context, table string, err error, message string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Table not available for maintenance"})
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance request", "details": err.Error()})
+	default:
+		requestLog(c).Error().Err(err).Msgf("Error maintaining partitions of %s", table)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (h *PartitionHandlers) ListPartitions(c *gin.Context) {
+	table := c.Param("table")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	partitions, err := h.PartitionStore.ListPartitions(ctx, table)
+	if err != nil {
+		writePartitionError(c, table, err, "Failed to list partitions")
+		return
+	}
+
+	c.JSON(http.StatusOK, partitions)
+}
+
+func (h *PartitionHandlers) OptimizePartitions(c *gin.Context) {
+	table := c.Param("table")
+	var req models.OptimizePartitionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Minute)
+	defer cancel()
+
+	if err := h.PartitionStore.Optimize(ctx, table, req.PartitionIDs); err != nil {
+		writePartitionError(c, table, err, "Failed to optimize partitions")
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "clickhouse.optimize", table, gin.H{"partitionIds": req.PartitionIDs})
+	c.JSON(http.StatusOK, gin.H{"success": true, "table": table, "partitionIds": req.PartitionIDs})
+}
+
+// DropPartitions drops partitions older than a date. Without a confirmation it
+// only reports which partitions would be dropped.
+func (h *PartitionHandlers) DropPartitions(c *gin.Context) {
+	table := c.Param("table")
+	var req models.DropPartitionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	cutoff, err := time.Parse(time.RFC3339, req.OlderThan)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'olderThan' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Minute)
+	defer cancel()
+
+	partitionIDs, err := h.PartitionStore.PartitionsOlderThan(ctx, table, cutoff)
+	if err != nil {
+		writePartitionError(c, table, err, "Failed to find partitions to drop")
+		return
+	}
+	if req.Confirm != table {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":        "Confirmation required: set 'confirm' to the table name to drop these partitions",
+			"partitionIds": partitionIDs,
+		})
+		return
+	}
+
+	dropped, err := h.PartitionStore.DropPartitions(ctx, table, partitionIDs)
+	if len(dropped) > 0 {
+		recordAudit(c, h.AuditStore, "clickhouse.drop_partitions", table, gin.H{"olderThan": req.OlderThan, "partitionIds": dropped})
+	}
+	if err != nil {
+		writePartitionError(c, table, err, "Failed to drop partitions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "table": table, "droppedPartitionIds": dropped})
+}
+
+func (h *PartitionHandlers) DetachPartition(c *gin.Context) {
+	h.movePartition(c, "detach")
+}
+
+func (h *PartitionHandlers) AttachPartition(c *gin.Context) {
+	h.movePartition(c, "attach")
+}
+
+func (h *PartitionHandlers) movePartition(c *gin.Context, op string) {
+	table := c.Param("table")
+	var req models.PartitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.Confirm != table {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Confirmation required: set 'confirm' to the table name to " + op + " this partition"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Minute)
+	defer cancel()
+
+	var err error
+	if op == "detach" {
+		err = h.PartitionStore.DetachPartition(ctx, table, req.PartitionID)
+	} else {
+		err = h.PartitionStore.AttachPartition(ctx, table, req.PartitionID)
+	}
+	if err != nil {
+		writePartitionError(c, table, err, "Failed to "+op+" partition")
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "clickhouse."+op+"_partition", table, gin.H{"partitionId": req.PartitionID})
+	c.JSON(http.StatusOK, gin.H{"success": true, "table": table, "partitionId": req.PartitionID})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"mabletask/api/models"
+)
+
+// transparentGIF is a 1x1 transparent GIF.
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// TrackPixel records a page_view from query parameters and answers with a
+// transparent GIF, for email opens and pages without JavaScript. The page is
+// the pagePath parameter, or the page embedding the pixel. The GIF is served
+// even when the event is not recorded, so a broken image never shows; the
+// reason is logged instead.
+func (h *AnalyticsHandlers) TrackPixel(c *gin.Context) {
+	defer servePixel(c)
+
+	projectID := c.GetString("project_id")
+	if !h.ProjectStore.Exists(projectID) {
+		requestLog(c).Info().Msgf("Pixel for unknown project %s ignored", projectID)
+		return
+	}
+
+	event := models.AnalyticsEvent{
+		EventType:   models.EventTypePageView,
+		EventID:     c.Query("eventId"),
+		DedupeID:    c.Query("dedupeId"),
+		PagePath:    c.DefaultQuery("pagePath", c.Request.Referer()),
+		Referrer:    c.Query("referrer"),
+		UserID:      c.Query("userId"),
+		AnonymousID: c.Query("anonymousId"),
+		SessionID:   c.Query("sessionId"),
+		GroupID:     c.Query("groupId"),
+		UTMSource:   c.Query("utmSource"),
+		UTMMedium:   c.Query("utmMedium"),
+		UTMCampaign: c.Query("utmCampaign"),
+		UTMTerm:     c.Query("utmTerm"),
+		UTMContent:  c.Query("utmContent"),
+	}
+	if title := c.Query("title"); title != "" {
+		event.EventData, _ = json.Marshal(models.PageViewPayload{Title: title})
+	}
+	if err := event.Validate(); err != nil {
+		requestLog(c).Warn().Err(err).Msgf("Invalid pixel event for project %s ignored", projectID)
+		return
+	}
+	events, validationErrors := h.checkSchemas(projectID, []models.AnalyticsEvent{event})
+	if len(events) == 0 {
+		requestLog(c).Warn().Msgf("Pixel event for project %s rejected by the page_view schema: %v", projectID, validationErrors[0].Errors)
+		return
+	}
+
+	if status, body := h.recordEvents(c, projectID, events); status != http.StatusOK {
+		requestLog(c).Warn().Msgf("Pixel event for project %s not recorded (%d): %v", projectID, status, body["error"])
+	}
+}
+
+func servePixel(c *gin.Context) {
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+	c.Header("Pragma", "no-cache")
+	c.Header("Expires", "0")
+	c.Data(http.StatusOK, "image/gif", transparentGIF)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrivacyHandlers struct {
+	ExportStore *store.ExportStore
+	AuditStore  *store.AuditStore
+}
+
+func NewPrivacyHandlers(s *store.ExportStore, auditStore *store.AuditStore) *PrivacyHandlers {
+	return &PrivacyHandlers{ExportStore: s, AuditStore: auditStore}
+}
+
+// privacyExportKinds maps the format parameter to the export kind.
+var privacyExportKinds = map[string]string{
+	"json": models.ExportKindPrivacy,
+	"csv":  models.ExportKindPrivacyCSV,
+}
+
+// RequestExport queues a data-subject access export for the given user of the
+// request's project, as one JSON document or, with format=csv, a zip of
+// profile.json and events.csv. Analytics user IDs are chosen by the tracking
+// client and say nothing about API accounts, so the route is admin-only and
+// the account record is only included when accountId names it. Being
+// admin-only, it stays closed to impersonation sessions although a GET.
+func (h *PrivacyHandlers) RequestExport(c *gin.Context) {
+	userID := c.Query("userId")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "userId query parameter is required"})
+		return
+	}
+	params := models.PrivacyExportParams{ProjectID: c.GetString("project_id"), UserID: userID}
+	if raw := c.Query("accountId"); raw != "" {
+		accountID, err := strconv.Atoi(raw)
+		if err != nil || accountID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'accountId' parameter"})
+			return
+		}
+		params.AccountID = accountID
+	}
+	kind, ok := privacyExportKinds[strings.ToLower(c.DefaultQuery("format", "json"))]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'format' parameter. Must be 'json' or 'csv'."})
+		return
+	}
+
+	job, err := h.ExportStore.CreateJob(c.Request.Context(), kind, params, c.GetInt("user_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error creating privacy export for user %s", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create privacy export"})
+		return
+	}
+
+	requestLog(c).Info().Msgf("Privacy export %s queued for user %s by user %d", job.ID, userID, c.GetInt("user_id"))
+	recordAudit(c, h.AuditStore, "privacy.export", userID, gin.H{"jobId": job.ID, "kind": kind})
+	c.Header("Location", "/api/exports/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectHandlers manages projects (sites), who may access their data and
+// the write keys their events are tracked with. Members may read a project;
+// owners and admins may change it and manage its keys.
+type ProjectHandlers struct {
+	ProjectStore  *store.ProjectStore
+	WriteKeyStore *store.WriteKeyStore
+	AuditStore    *store.AuditStore
+}
+
+func NewProjectHandlers(s *store.ProjectStore, keys *store.WriteKeyStore, audit *store.AuditStore) *ProjectHandlers {
+	return &ProjectHandlers{ProjectStore: s, WriteKeyStore: keys, AuditStore: audit}
+}
+
+// authorizeProject loads the project of the :id parameter and checks the
+// caller's access to it, requiring ownership when owner is set. On failure it
+// writes the response and returns nil.
+func (h *ProjectHandlers) authorizeProject(c *gin.Context, owner bool) *models.Project {
+	id := c.Param("id")
+	project, err := h.ProjectStore.GetProject(c.Request.Context(), id, c.GetInt("user_id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting project %s", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve project"})
+		return nil
+	}
+	if c.GetBool("is_admin") {
+		return project
+	}
+	if project.Role == "" {
+		// Hide the existence of projects the caller cannot see.
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil
+	}
+	if owner && project.Role != models.ProjectRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Project owner access required"})
+		return nil
+	}
+	return project
+}
+
+func (h *ProjectHandlers) CreateProject(c *gin.Context) {
+	var req models.ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if !utils.IsValidProjectID(req.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID", "details": "use letters, digits, '-' and '_'"})
+		return
+	}
+
+	project, err := h.ProjectStore.CreateProject(c.Request.Context(), c.GetInt("user_id"), req)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project already exists"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error creating project %s", req.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "project.create", project.ID, project)
+	c.JSON(http.StatusCreated, project)
+}
+
+// ListProjects returns the caller's projects, or every project for admins.
+func (h *ProjectHandlers) ListProjects(c *gin.Context) {
+	projects, err := h.ProjectStore.ListProjects(c.Request.Context(), c.GetInt("user_id"), c.GetBool("is_admin"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing projects")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list projects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+func (h *ProjectHandlers) GetProject(c *gin.Context) {
+	project := h.authorizeProject(c, false)
+	if project == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func (h *ProjectHandlers) UpdateProject(c *gin.Context) {
+	var req models.ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.ID != c.Param("id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project ID cannot be changed"})
+		return
+	}
+	if h.authorizeProject(c, true) == nil {
+		return
+	}
+
+	project, err := h.ProjectStore.UpdateProject(c.Request.Context(), req.ID, c.GetInt("user_id"), req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating project %s", req.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "project.update", project.ID, req)
+	c.JSON(http.StatusOK, project)
+}
+
+func (h *ProjectHandlers) DeleteProject(c *gin.Context) {
+	project := h.authorizeProject(c, true)
+	if project == nil {
+		return
+	}
+
+	err := h.ProjectStore.DeleteProject(c.Request.Context(), project.ID)
+	switch {
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project cannot be deleted", "details": err.Error()})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Error deleting project %s", project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "project.delete", project.ID, nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h *ProjectHandlers) ListMembers(c *gin.Context) {
+	project := h.authorizeProject(c, false)
+	if project == nil {
+		return
+	}
+
+	members, err := h.ProjectStore.ListMembers(c.Request.Context(), project.ID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing members of project %s", project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list project members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// SetMember adds a user to the project or changes their role.
+func (h *ProjectHandlers) SetMember(c *gin.Context) {
+	var req models.ProjectMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	project := h.authorizeProject(c, true)
+	if project == nil {
+		return
+	}
+
+	member, err := h.ProjectStore.SetMember(c.Request.Context(), project.ID, req)
+	switch {
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project member", "details": err.Error()})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Error setting member %d of project %s", req.UserID, project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set project member"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "project.member.set", project.ID, member)
+	c.JSON(http.StatusOK, member)
+}
+
+func (h *ProjectHandlers) RemoveMember(c *gin.Context) {
+	userID, ok := parseIDParam(c, "userId")
+	if !ok {
+		return
+	}
+	project := h.authorizeProject(c, true)
+	if project == nil {
+		return
+	}
+
+	err := h.ProjectStore.RemoveMember(c.Request.Context(), project.ID, userID)
+	switch {
+	case errors.Is(err, store.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project member cannot be removed", "details": err.Error()})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project member not found"})
+		return
+	case err != nil:
+		requestLog(c).Error().Err(err).Msgf("Error removing member %d of project %s", userID, project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove project member"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "project.member.remove", project.ID, gin.H{"userId": userID})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h *ProjectHandlers) ListWriteKeys(c *gin.Context) {
+	project := h.authorizeProject(c, true)
+	if project == nil {
+		return
+	}
+
+	keys, err := h.WriteKeyStore.ListKeys(c.Request.Context(), project.ID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing write keys of project %s", project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list write keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateWriteKey issues a write key for the project. The response is the only
+// time the key itself is shown.
+func (h *ProjectHandlers) CreateWriteKey(c *gin.Context) {
+	var req models.WriteKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+	project := h.authorizeProject(c, true)
+	if project == nil {
+		return
+	}
+
+	key, err := h.WriteKeyStore.CreateKey(c.Request.Context(), project.ID, c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error creating write key for project %s", project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create write key"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "write_key.create", strconv.Itoa(key.ID), gin.H{"projectId": key.ProjectID, "name": key.Name, "prefix": key.Prefix})
+	c.JSON(http.StatusCreated, key)
+}
+
+// RotateWriteKey replaces a write key by a new one, keeping the old key valid
+// for graceSeconds.
+func (h *ProjectHandlers) RotateWriteKey(c *gin.Context) {
+	keyID, ok := parseIDParam(c, "keyId")
+	if !ok {
+		return
+	}
+	var req models.RotateWriteKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+	project := h.authorizeProject(c, true)
+	if project == nil {
+		return
+	}
+
+	grace := time.Duration(req.GraceSeconds) * time.Second
+	key, err := h.WriteKeyStore.RotateKey(c.Request.Context(), project.ID, keyID, grace, c.GetInt("user_id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Write key not found or no longer valid"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error rotating write key %d of project %s", keyID, project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate write key"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "write_key.rotate", strconv.Itoa(keyID), gin.H{"projectId": key.ProjectID, "newKeyId": key.ID, "graceSeconds": req.GraceSeconds})
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *ProjectHandlers) RevokeWriteKey(c *gin.Context) {
+	keyID, ok := parseIDParam(c, "keyId")
+	if !ok {
+		return
+	}
+	project := h.authorizeProject(c, true)
+	if project == nil {
+		return
+	}
+
+	key, err := h.WriteKeyStore.RevokeKey(c.Request.Context(), project.ID, keyID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Write key not found or already revoked"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error revoking write key %d of project %s", keyID, project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke write key"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "write_key.revoke", strconv.Itoa(keyID), gin.H{"projectId": key.ProjectID})
+	c.JSON(http.StatusOK, key)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QueryLogHandlers struct {
+	QueryLogStore *store.QueryLogStore
+}
+
+func NewQueryLogHandlers(s *store.QueryLogStore) *QueryLogHandlers {
+	return &QueryLogHandlers{QueryLogStore: s}
+}
+
+// ListQueries returns logged stats queries, newest first, optionally for a
+// single userId or endpoint (e.g. /api/stats/top-paths).
+func (h *QueryLogHandlers) ListQueries(c *gin.Context) {
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 100)
+	if !ok {
+		return
+	}
+	filter := store.QueryLogFilter{
+		Endpoint: c.Query("endpoint"),
+		Start:    start,
+		End:      end,
+		Limit:    limit,
+	}
+	if userIDParam := c.Query("userId"); userIDParam != "" {
+		userID, err := strconv.Atoi(userIDParam)
+		if err != nil || userID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'userId' parameter"})
+			return
+		}
+		filter.UserID = userID
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	entries, err := h.QueryLogStore.ListQueries(ctx, filter)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing query log")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve query log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// SummarizeQueries ranks users and endpoints by total query time.
+func (h *QueryLogHandlers) SummarizeQueries(c *gin.Context) {
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 20)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	summary, err := h.QueryLogStore.SummarizeQueries(ctx, start, end, limit)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error summarizing query log")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize query log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
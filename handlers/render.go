@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	mimeCSV    = "text/csv"
+	mimeNDJSON = "application/x-ndjson"
+)
+
+// reportFormats maps the format query parameter to the MIME type it selects.
+var reportFormats = map[string]string{
+	"json":   binding.MIMEJSON,
+	"csv":    mimeCSV,
+	"ndjson": mimeNDJSON,
+}
+
+// respond writes a report in the format given by the format query parameter
+// (json, csv or ndjson) or else requested by the Accept header: JSON (the
+// default), CSV with one row per result, or newline-delimited JSON. A single
+// object is rendered as one row. Nested values become JSON in CSV cells. CSV
+// and NDJSON are sent as attachments named after the report.
+func respond(c *gin.Context, status int, data interface{}) {
+	respondTable(c, status, data, data)
+}
+
+// respondTable is respond for reports whose JSON form wraps the result rows,
+// e.g. in an envelope with the query parameters: CSV and NDJSON render rows.
+func respondTable(c *gin.Context, status int, data, table interface{}) {
+	format := c.NegotiateFormat(binding.MIMEJSON, mimeCSV, mimeNDJSON)
+	if raw := c.Query("format"); raw != "" {
+		var ok bool
+		if format, ok = reportFormats[strings.ToLower(raw)]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'format' parameter. Must be 'json', 'csv' or 'ndjson'."})
+			return
+		}
+	}
+
+	switch format {
+	case mimeCSV:
+		rows, err := reportRows(table)
+		if err != nil {
+			requestLog(c).Error().Err(err).Msg("Error encoding report as CSV")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode report"})
+			return
+		}
+		c.Header("Content-Disposition", reportDisposition(c, "csv"))
+		c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+		c.Status(status)
+		// Headers are sent; a failure now can only cut the download short.
+		if err := writeCSV(c.Writer, rows); err != nil {
+			requestLog(c).Error().Err(err).Msg("Error writing CSV report")
+		}
+	case mimeNDJSON:
+		rows, err := reportRows(table)
+		if err != nil {
+			requestLog(c).Error().Err(err).Msg("Error encoding report as NDJSON")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode report"})
+			return
+		}
+		var buf bytes.Buffer
+		for _, row := range rows {
+			buf.Write(row)
+			buf.WriteByte('\n')
+		}
+		c.Header("Content-Disposition", reportDisposition(c, "ndjson"))
+		c.Data(status, mimeNDJSON, buf.Bytes())
+	default:
+		c.JSON(status, data)
+	}
+}
+
+// statsQueryFailed answers a failed stats query: 503 with Retry-After when the
+// query was shed by the concurrency limiter, 500 with message otherwise.
+func statsQueryFailed(c *gin.Context, err error, message string) {
+	if errors.Is(err, store.ErrQueryBusy) {
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many concurrent queries, retry shortly"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// reportRows marshals data and splits it into one JSON document per row.
+func reportRows(data interface{}) ([]json.RawMessage, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var rows []json.RawMessage
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	return []json.RawMessage{raw}, nil
+}
+
+// reportDisposition names a downloaded report after its path below the API
+// prefix, e.g. stats-top-paths.csv.
+func reportDisposition(c *gin.Context, ext string) string {
+	name := strings.Trim(strings.TrimPrefix(path.Clean(c.Request.URL.Path), "/api"), "/")
+	name = strings.NewReplacer("/", "-", `"`, "").Replace(name)
+	if name == "" {
+		name = "report"
+	}
+	return fmt.Sprintf(`attachment; filename="%s.%s"`, name, ext)
+}
+
+// writeCSV writes rows of JSON objects as CSV. The header is the union of
+// the object keys in the order they first appear.
+func writeCSV(out io.Writer, rows []json.RawMessage) error {
+	var header []string
+	seen := map[string]bool{}
+	records := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		keys, values, err := flattenObject(row)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+		records = append(records, values)
+	}
+
+	w := csv.NewWriter(out)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for _, values := range records {
+		for i, k := range header {
+			record[i] = values[k]
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// flattenObject returns the keys of a JSON object in document order and each
+// value as a CSV cell. A row that is not an object becomes a single "value".
+func flattenObject(raw json.RawMessage) ([]string, map[string]string, error) {
+	if len(raw) == 0 || raw[0] != '{' {
+		return []string{"value"}, map[string]string{"value": csvCell(raw)}, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	var keys []string
+	values := map[string]string{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("unexpected JSON token %v", tok)
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		values[key] = csvCell(value)
+	}
+	return keys, values, nil
+}
+
+// csvCell renders a JSON value as text: strings unquoted, null as empty, and
+// numbers, booleans, objects and arrays as their JSON encoding.
+func csvCell(value json.RawMessage) string {
+	s := strings.TrimSpace(string(value))
+	if s == "null" {
+		return ""
+	}
+	if strings.HasPrefix(s, `"`) {
+		var str string
+		if err := json.Unmarshal(value, &str); err == nil {
+			return str
+		}
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportHandlers manage the caller's saved report definitions.
+type ReportHandlers struct {
+	ReportStore *store.ReportStore
+}
+
+func NewReportHandlers(rs *store.ReportStore) *ReportHandlers {
+	return &ReportHandlers{ReportStore: rs}
+}
+
+// bindReportRequest binds and validates a report body, answering 400 itself
+// when it is invalid.
+func bindReportRequest(c *gin.Context) (models.ReportRequest, bool) {
+	var req models.ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return req, false
+	}
+	if err := req.Definition.Validate(utils.RangePresets); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report definition", "details": err.Error()})
+		return req, false
+	}
+	return req, true
+}
+
+func (h *ReportHandlers) CreateReport(c *gin.Context) {
+	req, ok := bindReportRequest(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.ReportStore.CreateReport(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error creating report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+func (h *ReportHandlers) ListReports(c *gin.Context) {
+	reports, err := h.ReportStore.ListReports(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing reports")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+func (h *ReportHandlers) GetReport(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	report, err := h.ReportStore.GetReport(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting report %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *ReportHandlers) UpdateReport(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	req, ok := bindReportRequest(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.ReportStore.UpdateReport(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating report %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *ReportHandlers) DeleteReport(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	err := h.ReportStore.DeleteReport(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting report %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
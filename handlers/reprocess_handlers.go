@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"mabletask/api/enrich"
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReprocessHandlers struct {
+	ExportStore *store.ExportStore
+	AuditStore  *store.AuditStore
+}
+
+func NewReprocessHandlers(exports *store.ExportStore, audit *store.AuditStore) *ReprocessHandlers {
+	return &ReprocessHandlers{ExportStore: exports, AuditStore: audit}
+}
+
+// ListEnrichers returns the enrichers a reprocess job can apply.
+func (h *ReprocessHandlers) ListEnrichers(c *gin.Context) {
+	c.JSON(http.StatusOK, enrich.Names())
+}
+
+// CreateReprocessJob queues a job that re-runs enrichment over a range of
+// stored events. Progress and the final report are available through
+// /api/exports/:id.
+func (h *ReprocessHandlers) CreateReprocessJob(c *gin.Context) {
+	var req models.ReprocessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, req.Start)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'start' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+		return
+	}
+	end, err := time.Parse(time.RFC3339, req.End)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'end' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+		return
+	}
+	if !end.After(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'end' must be after 'start'"})
+		return
+	}
+	if req.ProjectID != "" && !utils.IsValidProjectID(req.ProjectID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'projectId'"})
+		return
+	}
+	if _, err := enrich.New(req.Enrichers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'enrichers'", "details": err.Error()})
+		return
+	}
+
+	params := models.ReprocessParams{
+		Start:     start.UTC(),
+		End:       end.UTC(),
+		ProjectID: req.ProjectID,
+		Enrichers: req.Enrichers,
+		Mode:      req.Mode,
+	}
+	switch req.Mode {
+	case models.ReprocessModeTable:
+		if !store.ValidEventTable(req.TargetTable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'targetTable' must match analytics_events_[a-z0-9_]+"})
+			return
+		}
+		params.TargetTable = req.TargetTable
+	default:
+		params.Mode = models.ReprocessModeReplace
+		if req.Confirm != "analytics_events" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Replacing events requires 'confirm' set to \"analytics_events\""})
+			return
+		}
+	}
+
+	job, err := h.ExportStore.CreateJob(c.Request.Context(), models.ExportKindReprocess, params, c.GetInt("user_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error queueing reprocess job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue reprocess job"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "events.reprocess", job.ID, params)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListReprocessJobs lists queued and finished reprocess jobs.
+func (h *ReprocessHandlers) ListReprocessJobs(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.ExportStore.ListJobs(c.Request.Context(), []string{models.ExportKindReprocess}, page)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing reprocess jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list reprocess jobs"})
+		return
+	}
+	for i := range jobs.Items {
+		withDownloadURL(&jobs.Items[i])
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
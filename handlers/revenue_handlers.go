@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevenueHandlers reports revenue in each project's base currency and manages
+// the exchange rates used for the conversion.
+type RevenueHandlers struct {
+	AnalyticsStore *store.AnalyticsStore
+	SettingsStore  *store.ProjectSettingsStore
+	RateStore      *store.ExchangeRateStore
+	AuditStore     *store.AuditStore
+}
+
+func NewRevenueHandlers(s *store.AnalyticsStore, settings *store.ProjectSettingsStore, rates *store.ExchangeRateStore, audit *store.AuditStore) *RevenueHandlers {
+	return &RevenueHandlers{AnalyticsStore: s, SettingsStore: settings, RateStore: rates, AuditStore: audit}
+}
+
+// GetRevenue reports purchase revenue per interval (Day, Week or Month) in the
+// project's base currency, converted at each purchase day's exchange rate,
+// alongside the original amounts per currency.
+func (h *RevenueHandlers) GetRevenue(c *gin.Context) {
+	interval := c.DefaultQuery("interval", "Day")
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	projectID := c.GetString("project_id")
+	settings, err := h.SettingsStore.GetSettings(ctx, projectID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting settings for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve settings"})
+		return
+	}
+	base := strings.ToUpper(c.DefaultQuery("currency", settings.BaseCurrency))
+	if len(base) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'currency' parameter. Must be an ISO 4217 code."})
+		return
+	}
+
+	daily, err := h.AnalyticsStore.GetDailyRevenue(ctx, start, end, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting daily revenue")
+		statsQueryFailed(c, err, "Failed to retrieve revenue statistics")
+		return
+	}
+	rates, err := h.RateStore.LoadRates(ctx, append(store.Currencies(daily), base), start, end)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error loading exchange rates")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load exchange rates"})
+		return
+	}
+	report, err := store.RevenueReport(daily, rates, base, interval)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error building revenue report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revenue statistics"})
+		return
+	}
+
+	c.Set("rows_returned", len(report.Series))
+	respondTable(c, http.StatusOK, report, report.Series)
+}
+
+// ListExchangeRates returns the latest rate of every currency as of ?date
+// (default today).
+func (h *RevenueHandlers) ListExchangeRates(c *gin.Context) {
+	date := time.Now().UTC()
+	if dateParam := c.Query("date"); dateParam != "" {
+		var err error
+		if date, err = time.Parse(models.DateFormat, dateParam); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'date' parameter. Use YYYY-MM-DD."})
+			return
+		}
+	}
+
+	rates, err := h.RateStore.ListRates(c.Request.Context(), date)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing exchange rates")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// SetExchangeRates stores one day of rates, as units per 1 EUR.
+func (h *RevenueHandlers) SetExchangeRates(c *gin.Context) {
+	var req models.ExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	date, err := time.Parse(models.DateFormat, req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'date'. Use YYYY-MM-DD."})
+		return
+	}
+	rates := make(map[string]float64, len(req.Rates))
+	for currency, perEUR := range req.Rates {
+		currency = strings.ToUpper(currency)
+		if len(currency) != 3 || perEUR <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Rates must map ISO 4217 codes to positive units per EUR", "currency": currency})
+			return
+		}
+		rates[currency] = perEUR
+	}
+
+	if err := h.RateStore.SetRates(c.Request.Context(), date, rates, "static"); err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error setting exchange rates for %s", req.Date)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set exchange rates"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "exchange_rates.set", req.Date, gin.H{"currencies": len(rates)})
+
+	c.JSON(http.StatusOK, gin.H{"date": req.Date, "stored": len(rates)})
+}
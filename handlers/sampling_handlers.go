@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SamplingHandlers struct {
+	SamplingStore *store.SamplingStore
+	AuditStore    *store.AuditStore
+}
+
+func NewSamplingHandlers(s *store.SamplingStore, audit *store.AuditStore) *SamplingHandlers {
+	return &SamplingHandlers{SamplingStore: s, AuditStore: audit}
+}
+
+// ListRules returns the sampling rules of the request's project.
+func (h *SamplingHandlers) ListRules(c *gin.Context) {
+	projectID := c.GetString("project_id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rules, err := h.SamplingStore.ListRules(ctx, projectID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing sampling rules for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sampling rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetRule creates or replaces the sampling rate of an event type, or of the
+// whole project when eventType is empty.
+func (h *SamplingHandlers) SetRule(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	var req models.SamplingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rule, err := h.SamplingStore.SetRule(ctx, projectID, req, c.GetInt("user_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error setting sampling rule for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set sampling rule"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "sampling.set", projectID, rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule removes the sampling rule of the eventType query parameter, or
+// the project-wide rule without it.
+func (h *SamplingHandlers) DeleteRule(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	eventType := c.Query("eventType")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	err := h.SamplingStore.DeleteRule(ctx, projectID, eventType)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sampling rule not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting sampling rule for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete sampling rule"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "sampling.delete", projectID, gin.H{"eventType": eventType})
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"mabletask/api/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleHandlers struct {
+	Scheduler *jobs.Scheduler
+}
+
+func NewScheduleHandlers(s *jobs.Scheduler) *ScheduleHandlers {
+	return &ScheduleHandlers{Scheduler: s}
+}
+
+// ListSchedules returns the scheduled tasks with their next and last run, and
+// whether the answering replica is the leader.
+func (h *ScheduleHandlers) ListSchedules(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	status, err := h.Scheduler.Status(ctx)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting scheduler status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
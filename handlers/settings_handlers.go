@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettingsHandlers serves the per-project (site) settings.
+type SettingsHandlers struct {
+	SettingsStore *store.ProjectSettingsStore
+	AuditStore    *store.AuditStore
+}
+
+func NewSettingsHandlers(s *store.ProjectSettingsStore, audit *store.AuditStore) *SettingsHandlers {
+	return &SettingsHandlers{SettingsStore: s, AuditStore: audit}
+}
+
+func (h *SettingsHandlers) GetSettings(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	projectID := c.GetString("project_id")
+	settings, err := h.SettingsStore.GetSettings(ctx, projectID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting settings for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings replaces the caller's project retention, which must not
+// exceed the project's plan limit, and base currency.
+func (h *SettingsHandlers) UpdateSettings(c *gin.Context) {
+	var req models.ProjectSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	projectID := c.GetString("project_id")
+	settings, err := h.SettingsStore.UpdateSettings(ctx, projectID, req, c.GetInt("user_id"))
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retention exceeds the plan limit", "details": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating settings for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "settings.update", projectID, gin.H{"retentionDays": settings.RetentionDays, "baseCurrency": req.BaseCurrency})
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// SetRetentionLimit sets a project's plan limit on event retention.
+func (h *SettingsHandlers) SetRetentionLimit(c *gin.Context) {
+	projectID, ok := adminProjectID(c)
+	if !ok {
+		return
+	}
+
+	var req models.RetentionLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	settings, err := h.SettingsStore.SetRetentionLimit(c.Request.Context(), projectID, req.MaxRetentionDays, c.GetInt("user_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error setting retention limit for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set retention limit"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "settings.retention_limit.set", projectID, gin.H{"maxRetentionDays": req.MaxRetentionDays})
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// adminProjectID reads the :projectId path parameter, writing a 400 response
+// when it is invalid.
+func adminProjectID(c *gin.Context) (string, bool) {
+	projectID := c.Param("projectId")
+	if !utils.IsValidProjectID(projectID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'projectId' path parameter"})
+		return "", false
+	}
+	return projectID, true
+}
+
+// ListEventTypeRetentions lists the event type retentions of a project along
+// with the server defaults applying to its other event types.
+func (h *SettingsHandlers) ListEventTypeRetentions(c *gin.Context) {
+	projectID, ok := adminProjectID(c)
+	if !ok {
+		return
+	}
+
+	retentions, err := h.SettingsStore.ListEventTypeRetentions(c.Request.Context(), projectID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing event type retention for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event type retention"})
+		return
+	}
+
+	defaults := h.SettingsStore.DefaultEventTypeRetention
+	if defaults == nil {
+		defaults = map[string]int{}
+	}
+	c.JSON(http.StatusOK, gin.H{"projectId": projectID, "eventTypes": retentions, "defaults": defaults})
+}
+
+// SetEventTypeRetention sets how long a project keeps events of one type.
+func (h *SettingsHandlers) SetEventTypeRetention(c *gin.Context) {
+	projectID, ok := adminProjectID(c)
+	if !ok {
+		return
+	}
+	eventType := c.Param("eventType")
+	if len(eventType) > 128 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'eventType' path parameter"})
+		return
+	}
+
+	var req models.EventTypeRetentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	retention, err := h.SettingsStore.SetEventTypeRetention(c.Request.Context(), projectID, eventType, req.RetentionDays, c.GetInt("user_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error setting retention of %s events for project %s", eventType, projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set event type retention"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "settings.event_type_retention.set", projectID, gin.H{"eventType": eventType, "retentionDays": req.RetentionDays})
+
+	c.JSON(http.StatusOK, retention)
+}
+
+// DeleteEventTypeRetention removes a project's retention of one event type.
+func (h *SettingsHandlers) DeleteEventTypeRetention(c *gin.Context) {
+	projectID, ok := adminProjectID(c)
+	if !ok {
+		return
+	}
+	eventType := c.Param("eventType")
+
+	err := h.SettingsStore.DeleteEventTypeRetention(c.Request.Context(), projectID, eventType)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No retention set for this event type"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting retention of %s events for project %s", eventType, projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event type retention"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "settings.event_type_retention.delete", projectID, gin.H{"eventType": eventType})
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SuppressionHandlers struct {
+	SuppressionStore *store.SuppressionStore
+}
+
+func NewSuppressionHandlers(s *store.SuppressionStore) *SuppressionHandlers {
+	return &SuppressionHandlers{SuppressionStore: s}
+}
+
+func (h *SuppressionHandlers) CreateSuppression(c *gin.Context) {
+	var req models.SuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	suppression, err := h.SuppressionStore.CreateSuppression(c.Request.Context(), c.GetString("project_id"), c.GetInt("user_id"), req)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Subject is already suppressed"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error creating suppression")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create suppression"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, suppression)
+}
+
+func (h *SuppressionHandlers) ListSuppressions(c *gin.Context) {
+	includeRemoved := c.Query("includeRemoved") == "true"
+
+	suppressions, err := h.SuppressionStore.ListSuppressions(c.Request.Context(), c.GetString("project_id"), includeRemoved)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing suppressions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list suppressions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppressions)
+}
+
+func (h *SuppressionHandlers) RemoveSuppression(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	err := h.SuppressionStore.RemoveSuppression(c.Request.Context(), c.GetString("project_id"), id, c.GetInt("user_id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Active suppression not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error removing suppression %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
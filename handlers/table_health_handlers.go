@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TableHealthHandlers struct {
+	TableHealthStore *store.TableHealthStore
+}
+
+func NewTableHealthHandlers(s *store.TableHealthStore) *TableHealthHandlers {
+	return &TableHealthHandlers{TableHealthStore: s}
+}
+
+// GetClickHouseHealth reports per-table storage health with an overall status
+// (ok, warning or critical).
+func (h *TableHealthHandlers) GetClickHouseHealth(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	health, err := h.TableHealthStore.GetHealth(ctx)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting ClickHouse table health")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ClickHouse table health"})
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+// GetStorageTiers reports how much of each table lives on each tier (volume
+// and disk) of its ClickHouse storage policy.
+func (h *TableHealthHandlers) GetStorageTiers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	tables, err := h.TableHealthStore.GetStorageTiers(ctx)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting ClickHouse storage tiers")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ClickHouse storage tiers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tables": tables})
+}
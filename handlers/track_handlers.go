@@ -2,34 +2,106 @@ package handlers
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"mabletask/api/destinations"
+	"mabletask/api/enrich"
+	"mabletask/api/logging"
 	"mabletask/api/models"
 	"mabletask/api/store"
+	"mabletask/api/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rs/zerolog/log"
 )
 
 type AnalyticsHandlers struct {
-	AnalyticsStore *store.AnalyticsStore
+	AnalyticsStore   *store.AnalyticsStore
+	SuppressionStore *store.SuppressionStore
+	BlocklistStore   *store.BlocklistStore
+	UsageStore       *store.UsageStore
+	// ProjectStore rejects events of unknown projects.
+	ProjectStore *store.ProjectStore
+	// EventTypes validates eventData against the schemas of event types.
+	EventTypes *store.EventTypeStore
+	// enrichers runs over incoming events; see SetEnrichers.
+	enrichers enrich.Pipeline
+	// TimestampWindow bounds how far a skew-corrected client timestamp may
+	// lag behind the server receive time before it is discarded.
+	TimestampWindow time.Duration
+	// Queue takes events to be written to ClickHouse in batches; nil inserts
+	// them before answering.
+	Queue EventQueue
+	// Deduper drops events resent with a recent dedupeId or client-generated
+	// eventId; nil records them again.
+	Deduper store.EventDeduper
+	// DropBots drops events of detected bots instead of recording them with
+	// IsBot set.
+	DropBots bool
+	// PrivacySignalMode is how events of requests sending DNT: 1 or
+	// Sec-GPC: 1 are handled: models.PrivacySignalModeDrop or
+	// models.PrivacySignalModeAnonymize. Empty ignores the headers.
+	PrivacySignalMode string
+	// Sampling drops the share of events sampled out by the project's
+	// sampling rules; nil keeps all events.
+	Sampling *store.SamplingStore
+	// Webhooks queues deliveries of recorded events to the project's
+	// webhooks subscribed to their type; nil forwards none.
+	Webhooks *store.WebhookStore
+	// Forwarder sends recorded events to the project's third-party
+	// destinations in the background; nil forwards none.
+	Forwarder *destinations.Forwarder
 }
 
-func NewAnalyticsHandlers(s *store.AnalyticsStore) *AnalyticsHandlers {
+// EventQueue accepts events to be written to ClickHouse asynchronously, such
+// as store.EventWriter or ingest.KafkaProducer. Enqueue returns
+// store.ErrBufferFull when it cannot take more events for now.
+type EventQueue interface {
+	Enqueue(ctx context.Context, events []models.AnalyticsEvent) error
+}
+
+func NewAnalyticsHandlers(s *store.AnalyticsStore, suppressions *store.SuppressionStore, blocklist *store.BlocklistStore, usage *store.UsageStore, projects *store.ProjectStore, eventTypes *store.EventTypeStore) *AnalyticsHandlers {
 	return &AnalyticsHandlers{
-		AnalyticsStore: s,
+		AnalyticsStore:   s,
+		SuppressionStore: suppressions,
+		BlocklistStore:   blocklist,
+		UsageStore:       usage,
+		ProjectStore:     projects,
+		EventTypes:       eventTypes,
+		TimestampWindow:  utils.GetEnvDuration("CLIENT_TIMESTAMP_WINDOW", 24*time.Hour),
+		enrichers:        enrich.MustNewShared(enrich.Ingest),
+	}
+}
+
+// SetEnrichers sets the enrichers run over incoming events, in order, in
+// place of enrich.Ingest. The pipeline is built once and shared by all
+// requests, so stateful enrichers are rejected.
+func (h *AnalyticsHandlers) SetEnrichers(names []string) error {
+	pipeline, err := enrich.NewShared(names)
+	if err != nil {
+		return err
 	}
+	h.enrichers = pipeline
+	return nil
 }
 
 func (h *AnalyticsHandlers) TrackEvent(c *gin.Context) {
-	userId := c.GetString("user_id")
-	log.Printf("request recieved::::")
+	projectID := c.GetString("project_id")
+	requestLog(c).Debug().Msg("Track request received")
+	if !h.ProjectStore.Exists(projectID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown project", "projectId": projectID})
+		return
+	}
 	var incomingEvents []models.AnalyticsEvent
-	if err := c.ShouldBindJSON(&incomingEvents); err != nil {
-		log.Printf("Error binding incoming analytics JSON: %v", err)
+	if err := bindEvents(c, &incomingEvents); err != nil {
+		requestLog(c).Error().Err(err).Msg("Error binding incoming analytics JSON")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
@@ -38,30 +110,340 @@ func (h *AnalyticsHandlers) TrackEvent(c *gin.Context) {
 		c.Status(http.StatusOK)
 		return
 	}
+	for i := range incomingEvents {
+		if err := incomingEvents[i].Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event", "details": err.Error(), "index": i})
+			return
+		}
+	}
 
-	var eventsToInsert []models.AnalyticsEvent
+	accepted, validationErrors := h.checkSchemas(projectID, incomingEvents)
+	if len(accepted) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Events do not match the schemas of their types", "validationErrors": validationErrors})
+		return
+	}
 
+	status, response := h.recordEvents(c, projectID, accepted)
+	if len(validationErrors) > 0 {
+		response["validationErrors"] = validationErrors
+	}
+	c.JSON(status, response)
+}
+
+// checkSchemas validates the eventData of events against the schemas the
+// project registered for their types. Events of strict types that fail are left out of the returned
+// events; events of lenient types keep their problems in SchemaErrors.
+func (h *AnalyticsHandlers) checkSchemas(projectID string, events []models.AnalyticsEvent) ([]models.AnalyticsEvent, []models.EventValidationError) {
+	accepted := make([]models.AnalyticsEvent, 0, len(events))
+	var validationErrors []models.EventValidationError
+	for i, event := range events {
+		mode, problems := h.EventTypes.ValidateEventData(projectID, event.EventType, event.EventData)
+		event.SchemaErrors = nil
+		if len(problems) > 0 {
+			rejected := mode == models.SchemaModeStrict
+			validationErrors = append(validationErrors, models.EventValidationError{
+				Index:     i,
+				EventType: event.EventType,
+				Rejected:  rejected,
+				Errors:    problems,
+			})
+			if rejected {
+				continue
+			}
+			event.SchemaErrors = problems
+		}
+		accepted = append(accepted, event)
+	}
+	return accepted, validationErrors
+}
+
+// recordEvents runs validated events of projectID through the ingestion
+// pipeline: enrichment, blocklist, suppressions and usage metering, then
+// writes them. It returns the status and body to answer with.
+func (h *AnalyticsHandlers) recordEvents(c *gin.Context, projectID string, incomingEvents []models.AnalyticsEvent) (int, gin.H) {
+	privacyMode := ""
+	if h.PrivacySignalMode != "" && sendsPrivacySignal(c.Request) {
+		privacyMode = h.PrivacySignalMode
+		h.AnalyticsStore.Ingest.RecordPrivacySignal(privacyMode, len(incomingEvents))
+		if privacyMode == models.PrivacySignalModeDrop {
+			requestLog(c).Warn().Msgf("Dropped %d incoming events of project %s for DNT/GPC", len(incomingEvents), projectID)
+			return http.StatusOK, gin.H{"success": true}
+		}
+	}
+
+	var (
+		eventsToInsert []models.AnalyticsEvent
+		dedupeKeys     []string
+	)
+	receivedAt := time.Now().UTC()
+
+	suppressed, blocked, bots, sampledOut := 0, 0, 0, 0
 	for _, event := range incomingEvents {
-		event.EventID = uuid.New().String()
 		event.IPAddress = c.ClientIP()
-		if event.UserID != "" {
-			event.UserID = userId
+		event.ProjectID = projectID
+		h.applyClientTimestamp(&event, receivedAt)
+		clientEventID := utils.IsEventID(event.EventID)
+		if !clientEventID {
+			event.EventID = utils.NewEventID(event.Timestamp)
+		}
+
+		if event.UserAgent == "" {
+			event.UserAgent = c.Request.UserAgent()
+		}
+		if h.BlocklistStore.Blocked(projectID, event.IPAddress, event.UserAgent, event.Referrer) {
+			blocked++
+			continue
+		}
+		event.SampleRate = 1
+		if h.Sampling != nil && !h.Sampling.Sample(projectID, &event) {
+			sampledOut++
+			continue
+		}
+		h.enrichers.Enrich(&event)
+		if event.IsBot && h.DropBots {
+			bots++
+			continue
+		}
+
+		if mode, ok := h.SuppressionStore.Lookup(projectID, event.UserID, event.AnonymousID); ok {
+			suppressed++
+			if mode != models.SuppressionModeAnonymize {
+				continue
+			}
+			event.Anonymize()
+		}
+		if privacyMode == models.PrivacySignalModeAnonymize {
+			event.Anonymize()
 		}
-		event.Timestamp = time.Now().UTC()
 
 		eventsToInsert = append(eventsToInsert, event)
+		dedupeKeys = append(dedupeKeys, dedupeKey(&event, clientEventID))
+	}
+	if suppressed > 0 {
+		requestLog(c).Info().Msgf("Suppressed %d of %d incoming events for opted-out subjects", suppressed, len(incomingEvents))
+	}
+	if blocked > 0 {
+		requestLog(c).Warn().Msgf("Dropped %d of %d incoming events matching the blocklist of project %s", blocked, len(incomingEvents), projectID)
+	}
+	if sampledOut > 0 {
+		requestLog(c).Info().Msgf("Sampled out %d of %d incoming events of project %s", sampledOut, len(incomingEvents), projectID)
+	}
+	if bots > 0 {
+		requestLog(c).Warn().Msgf("Dropped %d of %d incoming events of project %s from bots", bots, len(incomingEvents), projectID)
+	}
+	if len(eventsToInsert) == 0 {
+		return http.StatusOK, gin.H{"success": true}
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
-	if err := h.AnalyticsStore.InsertAnalyticsEvents(ctx, eventsToInsert); err != nil {
-		log.Printf("Error inserting analytics events into ClickHouse: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record analytics events"})
+	eventsToInsert, marked, duplicates := h.dropDuplicates(ctx, eventsToInsert, dedupeKeys)
+	if duplicates > 0 {
+		requestLog(c).Warn().Msgf("Dropped %d of %d incoming events of project %s as duplicates", duplicates, len(incomingEvents), projectID)
+	}
+	if len(eventsToInsert) == 0 {
+		return http.StatusOK, gin.H{"success": true, "duplicates": duplicates}
+	}
+
+	used, quota, err := h.UsageStore.ReserveEvents(ctx, projectID, len(eventsToInsert))
+	if err != nil {
+		// The events were not recorded, so a retry must not count as a duplicate.
+		h.forgetDedupeKeys(context.WithoutCancel(ctx), marked)
+	}
+	if errors.Is(err, store.ErrQuotaExceeded) {
+		requestLog(c).Info().Msgf("Project %s over monthly event quota (%d/%d), rejecting %d events", projectID, used, quota, len(eventsToInsert))
+		return http.StatusTooManyRequests, gin.H{
+			"error":             "Monthly event quota exceeded",
+			"eventsIngested":    used,
+			"monthlyEventQuota": quota,
+		}
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error metering events for project %s", projectID)
+		return http.StatusInternalServerError, gin.H{"error": "Failed to record analytics events"}
+	}
+
+	if err := h.writeEvents(ctx, eventsToInsert); err != nil {
+		requestLog(c).Error().Err(err).Msg("Error inserting analytics events into ClickHouse")
+		if err := h.UsageStore.ReleaseEvents(context.WithoutCancel(ctx), projectID, len(eventsToInsert)); err != nil {
+			requestLog(c).Error().Err(err).Msgf("Error releasing metered events for project %s", projectID)
+		}
+		h.forgetDedupeKeys(context.WithoutCancel(ctx), marked)
+		if errors.Is(err, store.ErrBufferFull) {
+			c.Header("Retry-After", "5")
+			return http.StatusServiceUnavailable, gin.H{"error": "Ingestion is busy, retry shortly"}
+		}
+		return http.StatusInternalServerError, gin.H{"error": "Failed to record analytics events"}
+	}
+	requestLog(c).Debug().Msg("Successfully logged event")
+	if h.Webhooks != nil {
+		// The events are recorded either way; a failure only loses their
+		// webhook deliveries.
+		if _, err := h.Webhooks.QueueDeliveries(context.WithoutCancel(ctx), eventsToInsert); err != nil {
+			requestLog(c).Error().Err(err).Msgf("Error queueing webhook deliveries for project %s", projectID)
+		}
+	}
+	if h.Forwarder != nil {
+		h.Forwarder.Enqueue(eventsToInsert)
+	}
+
+	response := gin.H{"success": true}
+	if duplicates > 0 {
+		response["duplicates"] = duplicates
+	}
+	if percent, status := h.UsageStore.Status(used, quota); status == models.UsageStatusWarning {
+		warning := fmt.Sprintf("%.0f%% of the monthly event quota used", percent)
+		c.Header("X-Usage-Warning", warning)
+		response["warning"] = warning
+	}
+	return http.StatusOK, response
+}
+
+// sendsPrivacySignal reports whether r opts out of tracking with Do Not Track
+// or Global Privacy Control.
+func sendsPrivacySignal(r *http.Request) bool {
+	return strings.TrimSpace(r.Header.Get("DNT")) == "1" || strings.TrimSpace(r.Header.Get("Sec-GPC")) == "1"
+}
+
+// dedupeKey identifies an event among recently ingested ones: by its
+// dedupeId, or else by its eventId when the client generated it. Events with
+// neither get "" and are never dropped as duplicates.
+func dedupeKey(event *models.AnalyticsEvent, clientEventID bool) string {
+	switch {
+	case event.DedupeID != "":
+		return event.ProjectID + ":d:" + event.DedupeID
+	case clientEventID:
+		return event.ProjectID + ":e:" + event.EventID
+	default:
+		return ""
+	}
+}
+
+// dropDuplicates leaves out the events whose dedupe key the Deduper has seen,
+// including keys repeated within events. It returns the remaining events, the
+// keys it marked as seen for them and how many events it left out. Should the
+// Deduper fail, all events are kept.
+func (h *AnalyticsHandlers) dropDuplicates(ctx context.Context, events []models.AnalyticsEvent, keys []string) ([]models.AnalyticsEvent, []string, int) {
+	if h.Deduper == nil {
+		return events, nil, 0
+	}
+	var (
+		marked  []string
+		indexes []int
+	)
+	for i, key := range keys {
+		if key != "" {
+			marked = append(marked, key)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(marked) == 0 {
+		return events, nil, 0
+	}
+	seen, err := h.Deduper.MarkSeen(ctx, marked)
+	if err != nil {
+		logging.Ctx(ctx).Error().Err(err).Msg("Error checking events for duplicates, recording them all")
+		return events, nil, 0
+	}
+
+	duplicate := make(map[int]bool)
+	fresh := marked[:0]
+	for j, key := range marked {
+		if seen[j] {
+			duplicate[indexes[j]] = true
+			continue
+		}
+		fresh = append(fresh, key)
+	}
+	if len(duplicate) == 0 {
+		return events, fresh, 0
+	}
+	kept := make([]models.AnalyticsEvent, 0, len(events)-len(duplicate))
+	for i, event := range events {
+		if !duplicate[i] {
+			kept = append(kept, event)
+		}
+	}
+	return kept, fresh, len(duplicate)
+}
+
+// forgetDedupeKeys unmarks the keys of events that were not recorded.
+func (h *AnalyticsHandlers) forgetDedupeKeys(ctx context.Context, keys []string) {
+	if h.Deduper == nil || len(keys) == 0 {
 		return
 	}
-	log.Println("Successfully logged event")
-	c.JSON(http.StatusOK, gin.H{"success": true})
+	if err := h.Deduper.Forget(ctx, keys); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Msg("Error forgetting dedupe keys of unrecorded events")
+	}
+}
+
+// ForgetEvents unmarks events given up on after they were accepted, such as
+// those the EventWriter dropped, so that retries of them are recorded.
+func (h *AnalyticsHandlers) ForgetEvents(ctx context.Context, events []models.AnalyticsEvent) {
+	keys := make([]string, 0, len(events))
+	for i := range events {
+		// Whether the eventId came from the client is no longer known;
+		// forgetting a key that was never marked is harmless.
+		if key := dedupeKey(&events[i], true); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	h.forgetDedupeKeys(ctx, keys)
+}
+
+// beaconFormField is the form field carrying the JSON events of form-encoded
+// requests.
+const beaconFormField = "data"
+
+// bindEvents decodes the JSON events of a track request. Besides JSON bodies
+// it accepts what navigator.sendBeacon sends: JSON as text/plain, and
+// application/x-www-form-urlencoded or multipart/form-data bodies with the
+// JSON in the data field.
+func bindEvents(c *gin.Context, events *[]models.AnalyticsEvent) error {
+	switch c.ContentType() {
+	case binding.MIMEPOSTForm, binding.MIMEMultipartPOSTForm:
+		data, ok := c.GetPostForm(beaconFormField)
+		if !ok {
+			return fmt.Errorf("missing %q form field", beaconFormField)
+		}
+		return json.Unmarshal([]byte(data), events)
+	default:
+		// Decodes JSON whatever the declared type, including text/plain.
+		return c.ShouldBindJSON(events)
+	}
+}
+
+// writeEvents hands events to the Queue, or inserts them when there is none.
+func (h *AnalyticsHandlers) writeEvents(ctx context.Context, events []models.AnalyticsEvent) error {
+	if h.Queue != nil {
+		return h.Queue.Enqueue(ctx, events)
+	}
+	return h.AnalyticsStore.InsertAnalyticsEvents(ctx, events)
+}
+
+// applyClientTimestamp keeps the SDK-reported event time as ClientTimestamp and
+// sets Timestamp to its skew-corrected value, or to receivedAt when the client
+// sent no time or the corrected time falls outside TimestampWindow.
+func (h *AnalyticsHandlers) applyClientTimestamp(event *models.AnalyticsEvent, receivedAt time.Time) {
+	if event.Timestamp.IsZero() {
+		event.ClientTimestamp = nil
+		event.Timestamp = receivedAt
+		return
+	}
+
+	clientTime := event.Timestamp
+	event.ClientTimestamp = &clientTime
+
+	var sentAt time.Time
+	if event.SentAt != nil {
+		sentAt = *event.SentAt
+	}
+	corrected, ok := utils.CorrectClientTimestamp(clientTime, sentAt, receivedAt, h.TimestampWindow)
+	if !ok {
+		log.Info().Msgf("Client timestamp %s outside acceptance window, using receive time", clientTime.Format(time.RFC3339))
+	}
+	event.Timestamp = corrected
 }
 
 func (h *AnalyticsHandlers) GetEventCountsOverTime(c *gin.Context) {
@@ -71,100 +453,110 @@ func (h *AnalyticsHandlers) GetEventCountsOverTime(c *gin.Context) {
 		return
 	}
 
-	// Optional eventType filter
-	eventTypeFilter := c.Query("eventType")
-
-	// Parse start and end times
-	var start, end time.Time
-	var err error
-
-	startParam := c.Query("start")
-	if startParam != "" {
-		start, err = time.Parse(time.RFC3339, startParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'start' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+	// Optional breakdown dimension, e.g. "country" or "trait.plan". groupBy is
+	// accepted as another name for it.
+	breakdown := c.Query("breakdown")
+	if groupBy := c.Query("groupBy"); groupBy != "" {
+		if breakdown != "" && breakdown != groupBy {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Give either 'breakdown' or 'groupBy', not both"})
 			return
 		}
-	} else {
-		start = time.Now().UTC().Add(-7 * 24 * time.Hour)
-		log.Printf("No 'start' timestamp provided, defaulting to 7 days ago: %s", start.Format(time.RFC3339))
+		breakdown = groupBy
 	}
-
-	endParam := c.Query("end")
-	if endParam != "" {
-		end, err = time.Parse(time.RFC3339, endParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'end' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+	var breakdownLimit uint64
+	if limitParam := c.Query("breakdownLimit"); limitParam != "" {
+		limit, err := strconv.ParseUint(limitParam, 10, 64)
+		if err != nil || limit == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'breakdownLimit' parameter. Must be a positive integer."})
 			return
 		}
-	} else {
-		end = time.Now().UTC()
+		breakdownLimit = limit
+	}
+	filters := parseEventFilters(c)
+
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	cmp, ok := parseComparison(c, start, end)
+	if !ok {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	results, err := h.AnalyticsStore.GetEventCountsOverTime(ctx, interval, start, end, eventTypeFilter)
+	if cmp != nil {
+		current, previous, err := h.AnalyticsStore.CompareEventCounts(ctx, cmp, interval, start, end, breakdown, breakdownLimit, filters)
+		if errors.Is(err, store.ErrInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			requestLog(c).Error().Err(err).Msg("Error comparing event counts over time")
+			statsQueryFailed(c, err, "Failed to retrieve event statistics")
+			return
+		}
+		c.Set("rows_returned", len(current)+len(previous))
+		respondTable(c, http.StatusOK, comparedSeries(cmp, current, previous), append(current, previous...))
+		return
+	}
+
+	results, err := h.AnalyticsStore.GetEventCountsOverTime(ctx, interval, start, end, breakdown, breakdownLimit, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		log.Printf("Error getting event counts over time: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event statistics"})
+		requestLog(c).Error().Err(err).Msg("Error getting event counts over time")
+		statsQueryFailed(c, err, "Failed to retrieve event statistics")
 		return
 	}
 
-	c.JSON(http.StatusOK, results)
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
 }
 
 func (h *AnalyticsHandlers) GetAverageEventDuration(c *gin.Context) {
-	eventTypeFilter := c.Query("eventType")
-
-	var start, end time.Time
-	var err error
+	filters := parseEventFilters(c)
 
-	startParam := c.Query("start")
-	if startParam != "" {
-		start, err = time.Parse(time.RFC3339, startParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'start' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
-			return
-		}
-	} else {
-		start = time.Now().UTC().Add(-7 * 24 * time.Hour)
-	}
-
-	endParam := c.Query("end")
-	if endParam != "" {
-		end, err = time.Parse(time.RFC3339, endParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'end' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
-			return
-		}
-	} else {
-		end = time.Now().UTC()
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	avgDuration, err := h.AnalyticsStore.GetAverageEventDuration(ctx, eventTypeFilter, start, end)
+	avgDuration, err := h.AnalyticsStore.GetAverageEventDuration(ctx, start, end, filters)
 	if err != nil {
-		log.Printf("Error getting average event duration: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve average event duration statistics"})
+		requestLog(c).Error().Err(err).Msg("Error getting average event duration")
+		statsQueryFailed(c, err, "Failed to retrieve average event duration statistics")
+		return
+	}
+	percentiles, err := h.AnalyticsStore.GetEventDurationPercentiles(ctx, start, end, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting event duration percentiles")
+		statsQueryFailed(c, err, "Failed to retrieve event duration percentiles")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"eventType":         eventTypeFilter,
+	c.Set("rows_returned", 1)
+	respond(c, http.StatusOK, gin.H{
+		"eventType":         strings.Join(filters.EventTypes, ","),
 		"startDate":         start.Format(time.RFC3339),
 		"endDate":           end.Format(time.RFC3339),
 		"averageDurationMs": avgDuration,
+		"percentilesMs":     percentiles,
 	})
 }
 
 func (h *AnalyticsHandlers) GetAverageCustomEventParameter(c *gin.Context) {
-	eventTypeFilter := c.Query("eventType")
 	paramName := c.Query("paramName")
+	filters := parseEventFilters(c)
+	eventTypes := strings.Join(filters.EventTypes, ",")
 
-	if eventTypeFilter == "" {
+	if eventTypes == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "eventType query parameter is required"})
 		return
 	}
@@ -173,43 +565,28 @@ func (h *AnalyticsHandlers) GetAverageCustomEventParameter(c *gin.Context) {
 		return
 	}
 
-	var start, end time.Time
-	var err error
-
-	startParam := c.Query("start")
-	if startParam != "" {
-		start, err = time.Parse(time.RFC3339, startParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'start' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
-			return
-		}
-	} else {
-		start = time.Now().UTC().Add(-7 * 24 * time.Hour)
-	}
-
-	endParam := c.Query("end")
-	if endParam != "" {
-		end, err = time.Parse(time.RFC3339, endParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'end' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
-			return
-		}
-	} else {
-		end = time.Now().UTC()
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	avgValue, err := h.AnalyticsStore.GetAverageCustomEventParameter(ctx, eventTypeFilter, paramName, start, end)
+	avgValue, err := h.AnalyticsStore.GetAverageCustomEventParameter(ctx, paramName, start, end, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'paramName'", "details": err.Error()})
+		return
+	}
 	if err != nil {
-		log.Printf("Error getting average of custom event parameter '%s' for eventType '%s': %v", paramName, eventTypeFilter, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve average custom event parameter statistics"})
+		requestLog(c).Error().Err(err).Msgf("Error getting average of custom event parameter '%s' for eventType '%s'", paramName, eventTypes)
+		statsQueryFailed(c, err, "Failed to retrieve average custom event parameter statistics")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"eventType":    eventTypeFilter,
+	c.Set("rows_returned", 1)
+	respond(c, http.StatusOK, gin.H{
+		"eventType":    eventTypes,
 		"paramName":    paramName,
 		"startDate":    start.Format(time.RFC3339),
 		"endDate":      end.Format(time.RFC3339),
@@ -223,90 +600,675 @@ func (h *AnalyticsHandlers) GetUniqueUsersOverTime(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "interval query parameter is required (e.g., 'Day', 'Hour')"})
 		return
 	}
+	filters := parseEventFilters(c)
 
-	var start, end time.Time
-	var err error
-	startParam := c.Query("start")
-	if startParam != "" {
-		start, err = time.Parse(time.RFC3339, startParam)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	cmp, ok := parseComparison(c, start, end)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if cmp != nil {
+		current, previous, err := h.AnalyticsStore.CompareUniqueUsers(ctx, cmp, interval, start, end, filters)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'start' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+			requestLog(c).Error().Err(err).Msg("Error comparing unique users over time")
+			statsQueryFailed(c, err, "Failed to retrieve unique user statistics")
 			return
 		}
-	} else {
-		start = time.Now().UTC().Add(-7 * 24 * time.Hour)
+		c.Set("rows_returned", len(current)+len(previous))
+		respondTable(c, http.StatusOK, comparedSeries(cmp, current, previous), append(current, previous...))
+		return
 	}
 
-	endParam := c.Query("end")
-	if endParam != "" {
-		end, err = time.Parse(time.RFC3339, endParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'end' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+	results, err := h.AnalyticsStore.GetUniqueUsersOverTime(ctx, interval, start, end, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting unique users over time")
+		statsQueryFailed(c, err, "Failed to retrieve unique user statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+func (h *AnalyticsHandlers) GetTopNPagePaths(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	var limit uint64 = 10
+	limitParam := c.Query("limit")
+	if limitParam != "" {
+		parsedLimit, err := strconv.ParseUint(limitParam, 10, 64)
+		if err != nil || parsedLimit == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit' parameter. Must be a positive integer."})
 			return
 		}
-	} else {
-		end = time.Now().UTC()
+		limit = parsedLimit
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	results, err := h.AnalyticsStore.GetUniqueUsersOverTime(ctx, interval, start, end)
+	results, err := h.AnalyticsStore.GetTopNPagePaths(ctx, start, end, limit, filters)
 	if err != nil {
-		log.Printf("Error getting unique users over time: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve unique user statistics"})
+		requestLog(c).Error().Err(err).Msg("Error getting top page paths")
+		statsQueryFailed(c, err, "Failed to retrieve top page paths statistics")
 		return
 	}
 
-	c.JSON(http.StatusOK, results)
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
 }
 
-func (h *AnalyticsHandlers) GetTopNPagePaths(c *gin.Context) {
-	var start, end time.Time
-	var err error
+// GetTopNReferrers returns the hosts referring the most page views.
+func (h *AnalyticsHandlers) GetTopNReferrers(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
 
-	startParam := c.Query("start")
-	if startParam != "" {
-		start, err = time.Parse(time.RFC3339, startParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'start' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetTopNReferrers(ctx, start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting top referrers")
+		statsQueryFailed(c, err, "Failed to retrieve top referrers statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetEntryPages returns the pages most sessions started on.
+func (h *AnalyticsHandlers) GetEntryPages(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetEntryPages(ctx, start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting entry pages")
+		statsQueryFailed(c, err, "Failed to retrieve entry page statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetExitPages returns the pages most sessions ended on, with their exit rate.
+func (h *AnalyticsHandlers) GetExitPages(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetExitPages(ctx, start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting exit pages")
+		statsQueryFailed(c, err, "Failed to retrieve exit page statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetPathFlow returns the most common page to page transitions per step of
+// sessions, for Sankey diagrams.
+func (h *AnalyticsHandlers) GetPathFlow(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+	depth := store.DefaultPathFlowDepth
+	if raw := c.Query("depth"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > store.MaxPathFlowDepth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid 'depth' parameter. Must be between 1 and %d.", store.MaxPathFlowDepth)})
 			return
 		}
-	} else {
-		start = time.Now().UTC().Add(-7 * 24 * time.Hour)
+		depth = n
 	}
 
-	endParam := c.Query("end")
-	if endParam != "" {
-		end, err = time.Parse(time.RFC3339, endParam)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'end' timestamp format. Use RFC3339 (e.g., 2006-01-02T15:04:05Z)"})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetPathFlow(ctx, start, end, depth, c.Query("from"), limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting path flow")
+		statsQueryFailed(c, err, "Failed to retrieve path flow statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetTopProducts ranks products by views, adds to cart, purchases (default)
+// or revenue, given by sort.
+func (h *AnalyticsHandlers) GetTopProducts(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetTopProducts(ctx, c.DefaultQuery("sort", "purchases"), start, end, limit, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting top products")
+		statsQueryFailed(c, err, "Failed to retrieve product statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetCartAbandonment trends the carts started per interval that were not
+// followed by a purchase: within the session, or within windowSeconds when
+// given.
+func (h *AnalyticsHandlers) GetCartAbandonment(c *gin.Context) {
+	interval := c.DefaultQuery("interval", "Day")
+	var window time.Duration
+	if raw := c.Query("windowSeconds"); raw != "" {
+		seconds, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || seconds <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'windowSeconds' parameter. Must be a positive integer."})
 			return
 		}
-	} else {
-		end = time.Now().UTC()
+		window = time.Duration(seconds) * time.Second
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
 	}
 
-	var limit uint64 = 10
-	limitParam := c.Query("limit")
-	if limitParam != "" {
-		parsedLimit, err := strconv.ParseUint(limitParam, 10, 64)
-		if err != nil || parsedLimit == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit' parameter. Must be a positive integer."})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	report, err := h.AnalyticsStore.GetCartAbandonment(ctx, interval, window, start, end, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting cart abandonment")
+		statsQueryFailed(c, err, "Failed to retrieve cart abandonment statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(report.Series))
+	respondTable(c, http.StatusOK, report, report.Series)
+}
+
+// GetAttribution credits conversions to the channels or campaigns of the
+// visitor's preceding sessions by the first_touch, last_touch (default) or
+// linear model.
+func (h *AnalyticsHandlers) GetAttribution(c *gin.Context) {
+	lookback := store.DefaultAttributionLookback
+	if raw := c.Query("lookbackDays"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		maxDays := int(store.MaxAttributionLookback.Hours() / 24)
+		if err != nil || days < 1 || days > maxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid 'lookbackDays' parameter. Must be between 1 and %d.", maxDays)})
 			return
 		}
-		limit = parsedLimit
+		lookback = time.Duration(days) * 24 * time.Hour
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	report, err := h.AnalyticsStore.GetAttribution(ctx,
+		c.DefaultQuery("model", models.AttributionLastTouch),
+		c.DefaultQuery("dimension", "channel"),
+		c.DefaultQuery("conversionEvent", models.EventTypePurchase),
+		lookback, start, end, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting attribution")
+		statsQueryFailed(c, err, "Failed to retrieve attribution statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(report.Credits))
+	respondTable(c, http.StatusOK, report, report.Credits)
+}
+
+// GetFirstTouchReport groups the visitors with matching events by their
+// first-touch `dimension` (e.g. utm_source), so that filtering to purchase
+// events shows where buyers originally came from.
+func (h *AnalyticsHandlers) GetFirstTouchReport(c *gin.Context) {
+	dimension := c.Query("dimension")
+	if dimension == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dimension query parameter is required", "dimensions": store.FirstTouchFields()})
+		return
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetFirstTouchReport(ctx, dimension, start, end, limit, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting first-touch report")
+		statsQueryFailed(c, err, "Failed to retrieve first-touch statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetWebVitals reports web vital percentiles per page and device type, for all
+// metrics or the one given by `metric`.
+func (h *AnalyticsHandlers) GetWebVitals(c *gin.Context) {
+	metric := strings.ToUpper(c.Query("metric"))
+	if metric != "" && !models.IsWebVital(metric) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'metric' parameter. Must be one of LCP, INP, CLS, TTFB or FCP."})
+		return
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetWebVitals(ctx, metric, start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting web vitals")
+		statsQueryFailed(c, err, "Failed to retrieve performance statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetOutboundDestinations reports the external destinations clicked most, by
+// URL or, with groupBy=domain, by domain.
+func (h *AnalyticsHandlers) GetOutboundDestinations(c *gin.Context) {
+	groupBy := c.DefaultQuery("groupBy", "url")
+	if groupBy != "url" && groupBy != "domain" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'groupBy' parameter. Must be 'url' or 'domain'."})
+		return
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetTopOutboundDestinations(ctx, groupBy == "domain", start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting outbound destinations")
+		statsQueryFailed(c, err, "Failed to retrieve outbound click statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetOutboundSources reports the pages with the most outbound clicks and the
+// share of their viewers who clicked out.
+func (h *AnalyticsHandlers) GetOutboundSources(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetOutboundClicksBySource(ctx, start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting outbound clicks by source")
+		statsQueryFailed(c, err, "Failed to retrieve outbound click statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetFormStats reports submission and abandonment per form or, with
+// groupBy=page, per form and page.
+func (h *AnalyticsHandlers) GetFormStats(c *gin.Context) {
+	groupBy := c.DefaultQuery("groupBy", "form")
+	if groupBy != "form" && groupBy != "page" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'groupBy' parameter. Must be 'form' or 'page'."})
+		return
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetFormStats(ctx, groupBy == "page", start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting form stats")
+		statsQueryFailed(c, err, "Failed to retrieve form statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetRetention returns the cohort retention matrix of visitors first seen in
+// the range, by Day, Week (default) or Month, for up to periods periods after
+// the first.
+func (h *AnalyticsHandlers) GetRetention(c *gin.Context) {
+	interval := c.DefaultQuery("interval", "Week")
+	periods := store.DefaultRetentionPeriods
+	if raw := c.Query("periods"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > 52 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'periods' parameter. Must be between 1 and 52."})
+			return
+		}
+		periods = n
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	cohorts, err := h.AnalyticsStore.GetRetention(ctx, interval, periods, start, end, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting retention")
+		statsQueryFailed(c, err, "Failed to retrieve retention statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(cohorts))
+	respondTable(c, http.StatusOK, models.RetentionReport{
+		Interval:  interval,
+		Periods:   periods,
+		StartDate: start.Format(time.RFC3339),
+		EndDate:   end.Format(time.RFC3339),
+		Cohorts:   cohorts,
+	}, cohorts)
+}
+
+// GetSessionsOverTime returns per interval the sessions started, their
+// average duration and events per session.
+func (h *AnalyticsHandlers) GetSessionsOverTime(c *gin.Context) {
+	interval := c.Query("interval")
+	if interval == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval query parameter is required (e.g., 'Day', 'Hour')"})
+		return
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	cmp, ok := parseComparison(c, start, end)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if cmp != nil {
+		current, previous, err := h.AnalyticsStore.CompareSessions(ctx, cmp, interval, start, end, filters)
+		if errors.Is(err, store.ErrInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			requestLog(c).Error().Err(err).Msg("Error comparing sessions over time")
+			statsQueryFailed(c, err, "Failed to retrieve session statistics")
+			return
+		}
+		c.Set("rows_returned", len(current)+len(previous))
+		respondTable(c, http.StatusOK, comparedSeries(cmp, current, previous), append(current, previous...))
+		return
+	}
+
+	results, err := h.AnalyticsStore.GetSessionsOverTime(ctx, interval, start, end, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting sessions over time")
+		statsQueryFailed(c, err, "Failed to retrieve session statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetSessionSummary returns the session count, average duration and events
+// per session over the whole range.
+func (h *AnalyticsHandlers) GetSessionSummary(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	summary, err := h.AnalyticsStore.GetSessionSummary(ctx, start, end, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting session summary")
+		statsQueryFailed(c, err, "Failed to retrieve session statistics")
+		return
+	}
+
+	c.Set("rows_returned", 1)
+	respond(c, http.StatusOK, summary)
+}
+
+// GetChannelsOverTime returns sessions per acquisition channel in each interval.
+func (h *AnalyticsHandlers) GetChannelsOverTime(c *gin.Context) {
+	interval := c.Query("interval")
+	if interval == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval query parameter is required (e.g., 'Day', 'Hour')"})
+		return
+	}
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetChannelsOverTime(ctx, interval, start, end, filters)
+	if errors.Is(err, store.ErrInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting channels over time")
+		statsQueryFailed(c, err, "Failed to retrieve channel statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetCampaigns returns sessions and conversions per UTM campaign. Sessions
+// convert on a purchase unless conversionEvent names another event type.
+func (h *AnalyticsHandlers) GetCampaigns(c *gin.Context) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
+	}
+	conversionEvent := c.DefaultQuery("conversionEvent", models.EventTypePurchase)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := h.AnalyticsStore.GetCampaigns(ctx, conversionEvent, start, end, limit, filters)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error getting campaigns")
+		statsQueryFailed(c, err, "Failed to retrieve campaign statistics")
+		return
+	}
+
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
+}
+
+// GetBrowsers returns the most common browsers by visitors, split by major
+// version with versions=true.
+func (h *AnalyticsHandlers) GetBrowsers(c *gin.Context) {
+	h.clientBreakdown(c, "browser", c.Query("versions") == "true")
+}
+
+// GetOperatingSystems returns the most common operating systems by visitors.
+func (h *AnalyticsHandlers) GetOperatingSystems(c *gin.Context) {
+	h.clientBreakdown(c, "os", false)
+}
+
+// GetDeviceTypes returns visitors by device type.
+func (h *AnalyticsHandlers) GetDeviceTypes(c *gin.Context) {
+	h.clientBreakdown(c, "device", false)
+}
+
+func (h *AnalyticsHandlers) clientBreakdown(c *gin.Context, dimension string, withVersion bool) {
+	filters := parseEventFilters(c)
+	start, end, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c, 10)
+	if !ok {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	results, err := h.AnalyticsStore.GetTopNPagePaths(ctx, start, end, limit)
+	results, err := h.AnalyticsStore.GetClientBreakdown(ctx, dimension, withVersion, start, end, limit, filters)
 	if err != nil {
-		log.Printf("Error getting top page paths: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve top page paths statistics"})
+		requestLog(c).Error().Err(err).Msgf("Error getting %s breakdown", dimension)
+		statsQueryFailed(c, err, "Failed to retrieve "+dimension+" statistics")
 		return
 	}
 
-	c.JSON(http.StatusOK, results)
+	c.Set("rows_returned", len(results))
+	respond(c, http.StatusOK, results)
 }
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// recordingQueue keeps the events handed to it instead of writing them, or
+// fails with err when set.
+type recordingQueue struct {
+	events []models.AnalyticsEvent
+	err    error
+}
+
+func (q *recordingQueue) Enqueue(_ context.Context, events []models.AnalyticsEvent) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.events = append(q.events, events...)
+	return nil
+}
+
+// expectMetering expects the queries of metering one batch of events.
+func expectMetering(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT monthly_event_quota FROM project_quotas`).
+		WillReturnRows(sqlmock.NewRows([]string{"monthly_event_quota"}))
+	mock.ExpectQuery(`INSERT INTO usage_counters`).
+		WillReturnRows(sqlmock.NewRows([]string{"events_ingested"}).AddRow(1))
+}
+
+// newTrackTestHandlers returns handlers for the default project backed by
+// mock, which expects the queries of loading the projects and of metering one
+// batch of events.
+func newTrackTestHandlers(t *testing.T) (*AnalyticsHandlers, *recordingQueue, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mock.ExpectQuery(`SELECT id FROM projects`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(models.DefaultProjectID))
+	projects := store.NewProjectStore(db)
+	if err := projects.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh projects: %v", err)
+	}
+	expectMetering(mock)
+
+	queue := &recordingQueue{}
+	h := NewAnalyticsHandlers(nil, store.NewSuppressionStore(db, nil), store.NewBlocklistStore(db),
+		store.NewUsageStore(db, nil, 0, 80), projects, store.NewEventTypeStore(db))
+	if err := h.SetEnrichers(nil); err != nil {
+		t.Fatalf("SetEnrichers: %v", err)
+	}
+	h.Queue = queue
+	return h, queue, mock
+}
+
+func TestTrackEventKeepsUserID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h, queue, mock := newTrackTestHandlers(t)
+
+	body := `[{"eventType": "page_view", "pagePath": "/pricing", "userId": "user-42", "anonymousId": "anon-1"}]`
+	w := postEvents(newTrackRouter(h), body)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if len(queue.events) != 1 {
+		t.Fatalf("stored %d events, want 1", len(queue.events))
+	}
+	stored := queue.events[0]
+	if stored.UserID != "user-42" {
+		t.Errorf("stored userId = %q, want %q", stored.UserID, "user-42")
+	}
+	if stored.AnonymousID != "anon-1" {
+		t.Errorf("stored anonymousId = %q, want %q", stored.AnonymousID, "anon-1")
+	}
+	if stored.ProjectID != models.DefaultProjectID {
+		t.Errorf("stored projectId = %q, want %q", stored.ProjectID, models.DefaultProjectID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// newTrackRouter serves h.TrackEvent for the default project.
+func newTrackRouter(h *AnalyticsHandlers) *gin.Engine {
+	r := gin.New()
+	r.POST("/api/track", func(c *gin.Context) {
+		c.Set("project_id", models.DefaultProjectID)
+	}, h.TrackEvent)
+	return r
+}
+
+func postEvents(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/track", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestDedupeKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		event         models.AnalyticsEvent
+		clientEventID bool
+		want          string
+	}{
+		{name: "dedupe id", event: models.AnalyticsEvent{ProjectID: "shop", DedupeID: "order-1", EventID: "e1"}, clientEventID: true, want: "shop:d:order-1"},
+		{name: "client event id", event: models.AnalyticsEvent{ProjectID: "shop", EventID: "e1"}, clientEventID: true, want: "shop:e:e1"},
+		{name: "server event id", event: models.AnalyticsEvent{ProjectID: "shop", EventID: "e1"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dedupeKey(&tt.event, tt.clientEventID); got != tt.want {
+				t.Errorf("dedupeKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if a, b := dedupeKey(&models.AnalyticsEvent{ProjectID: "a", DedupeID: "x"}, false), dedupeKey(&models.AnalyticsEvent{ProjectID: "b", DedupeID: "x"}, false); a == b {
+		t.Errorf("projects share dedupe key %q", a)
+	}
+}
+
+func TestDropDuplicatesWithinBatch(t *testing.T) {
+	h := &AnalyticsHandlers{Deduper: store.NewMemoryEventDeduper(time.Hour)}
+	events := []models.AnalyticsEvent{{EventType: "a"}, {EventType: "b"}, {EventType: "c"}, {EventType: "d"}, {EventType: "e"}}
+	keys := []string{"k1", "k1", "", "k2", ""}
+
+	kept, marked, duplicates := h.dropDuplicates(context.Background(), events, keys)
+	if duplicates != 1 {
+		t.Errorf("duplicates = %d, want 1", duplicates)
+	}
+	var types []string
+	for _, e := range kept {
+		types = append(types, e.EventType)
+	}
+	if got := strings.Join(types, ","); got != "a,c,d,e" {
+		t.Errorf("kept %s, want a,c,d,e", got)
+	}
+	if got := strings.Join(marked, ","); got != "k1,k2" {
+		t.Errorf("marked %s, want k1,k2", got)
+	}
+}
+
+func TestDropDuplicatesAcrossRequests(t *testing.T) {
+	h := &AnalyticsHandlers{Deduper: store.NewMemoryEventDeduper(time.Hour)}
+	events := []models.AnalyticsEvent{{EventType: "a"}, {EventType: "b"}}
+
+	if _, _, duplicates := h.dropDuplicates(context.Background(), events, []string{"k1", "k2"}); duplicates != 0 {
+		t.Fatalf("first batch: duplicates = %d, want 0", duplicates)
+	}
+	kept, marked, duplicates := h.dropDuplicates(context.Background(), events, []string{"k1", "k3"})
+	if duplicates != 1 || len(kept) != 1 || kept[0].EventType != "b" {
+		t.Errorf("second batch kept %v with %d duplicates, want only b", kept, duplicates)
+	}
+	if len(marked) != 1 || marked[0] != "k3" {
+		t.Errorf("second batch marked %v, want [k3]", marked)
+	}
+}
+
+func TestTrackEventForgetsDedupeKeysOfFailedWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h, queue, mock := newTrackTestHandlers(t)
+	h.Deduper = store.NewMemoryEventDeduper(time.Hour)
+	r := newTrackRouter(h)
+	body := `[{"eventType": "signup", "dedupeId": "signup-1"}]`
+
+	queue.err = errors.New("clickhouse unavailable")
+	mock.ExpectExec(`UPDATE usage_counters`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if w := postEvents(r, body); w.Code != http.StatusInternalServerError {
+		t.Fatalf("failed write: status = %d, want %d: %s", w.Code, http.StatusInternalServerError, w.Body.String())
+	}
+
+	queue.err = nil
+	expectMetering(mock)
+	if w := postEvents(r, body); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "duplicates") {
+		t.Fatalf("retry: status = %d, body %s; want it recorded", w.Code, w.Body.String())
+	}
+	if len(queue.events) != 1 {
+		t.Fatalf("stored %d events, want the retried one", len(queue.events))
+	}
+
+	if w := postEvents(r, body); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"duplicates":1`) {
+		t.Errorf("resend: status = %d, body %s; want it dropped as a duplicate", w.Code, w.Body.String())
+	}
+	if len(queue.events) != 1 {
+		t.Errorf("stored %d events after the resend, want 1", len(queue.events))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
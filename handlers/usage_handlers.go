@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UsageHandlers struct {
+	UsageStore *store.UsageStore
+	AuditStore *store.AuditStore
+}
+
+func NewUsageHandlers(s *store.UsageStore, audit *store.AuditStore) *UsageHandlers {
+	return &UsageHandlers{UsageStore: s, AuditStore: audit}
+}
+
+// GetUsage reports the caller's project usage for the current month against
+// its quota, plus up to `months` months of history (default 6).
+func (h *UsageHandlers) GetUsage(c *gin.Context) {
+	months := 6
+	if monthsParam := c.Query("months"); monthsParam != "" {
+		n, err := strconv.Atoi(monthsParam)
+		if err != nil || n <= 0 || n > 36 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'months' parameter. Must be between 1 and 36."})
+			return
+		}
+		months = n
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	projectID := c.GetString("project_id")
+	usage, err := h.UsageStore.GetUsage(ctx, projectID, months)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting usage for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+func (h *UsageHandlers) ListQuotas(c *gin.Context) {
+	quotas, err := h.UsageStore.ListQuotas(c.Request.Context())
+	if err != nil {
+		requestLog(c).Error().Err(err).Msg("Error listing quotas")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list quotas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"defaultMonthlyEventQuota": h.UsageStore.DefaultQuota, "projects": quotas})
+}
+
+func (h *UsageHandlers) SetQuota(c *gin.Context) {
+	projectID := c.Param("projectId")
+	if !utils.IsValidProjectID(projectID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'projectId' path parameter"})
+		return
+	}
+
+	var req models.QuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	quota, err := h.UsageStore.SetQuota(c.Request.Context(), projectID, *req.MonthlyEventQuota, c.GetInt("user_id"))
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error setting quota for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set quota"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "usage.quota.set", projectID, gin.H{"monthlyEventQuota": quota.MonthlyEventQuota})
+
+	c.JSON(http.StatusOK, quota)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandlers struct {
+	WebhookStore *store.WebhookStore
+	AuditStore   *store.AuditStore
+}
+
+func NewWebhookHandlers(s *store.WebhookStore, audit *store.AuditStore) *WebhookHandlers {
+	return &WebhookHandlers{WebhookStore: s, AuditStore: audit}
+}
+
+// CreateWebhook registers a webhook for the request's project. The response
+// carries the signing secret, which is not shown again.
+func (h *WebhookHandlers) CreateWebhook(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if err := utils.CheckOutboundURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook URL must point to a public host", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hook, err := h.WebhookStore.CreateWebhook(ctx, projectID, c.GetInt("user_id"), req)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error creating webhook for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "webhook.create", strconv.Itoa(hook.ID), gin.H{"projectId": projectID, "url": hook.URL, "eventTypes": hook.EventTypes})
+	c.JSON(http.StatusCreated, hook)
+}
+
+func (h *WebhookHandlers) ListWebhooks(c *gin.Context) {
+	projectID := c.GetString("project_id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hooks, err := h.WebhookStore.ListWebhooks(ctx, projectID)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing webhooks for project %s", projectID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *WebhookHandlers) GetWebhook(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hook, err := h.WebhookStore.GetWebhook(ctx, projectID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting webhook %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+func (h *WebhookHandlers) UpdateWebhook(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if err := utils.CheckOutboundURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook URL must point to a public host", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hook, err := h.WebhookStore.UpdateWebhook(ctx, projectID, id, req)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error updating webhook %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "webhook.update", strconv.Itoa(id), gin.H{"projectId": projectID, "url": hook.URL, "eventTypes": hook.EventTypes, "enabled": hook.Enabled})
+	c.JSON(http.StatusOK, hook)
+}
+
+// RotateSecret replaces the webhook's signing secret and returns the new one.
+func (h *WebhookHandlers) RotateSecret(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	hook, err := h.WebhookStore.RotateSecret(ctx, projectID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error rotating secret of webhook %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook secret"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "webhook.rotate_secret", strconv.Itoa(id), gin.H{"projectId": projectID})
+	c.JSON(http.StatusOK, hook)
+}
+
+func (h *WebhookHandlers) DeleteWebhook(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	err := h.WebhookStore.DeleteWebhook(ctx, projectID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error deleting webhook %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	recordAudit(c, h.AuditStore, "webhook.delete", strconv.Itoa(id), gin.H{"projectId": projectID})
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries returns the webhook's delivery log, newest first, optionally
+// only the deliveries with the status query parameter.
+func (h *WebhookHandlers) ListDeliveries(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status; use pending, delivered or failed"})
+		return
+	}
+	limit, ok := parseLimit(c, 50)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := h.WebhookStore.GetWebhook(ctx, projectID, id); errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	} else if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error getting webhook %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook"})
+		return
+	}
+
+	deliveries, err := h.WebhookStore.ListDeliveries(ctx, projectID, id, status, limit)
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error listing deliveries of webhook %d", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver queues a delivery of the webhook to be sent again now, such as
+// one that failed while the receiver was down.
+func (h *WebhookHandlers) Redeliver(c *gin.Context) {
+	projectID := c.GetString("project_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseIDParam(c, "deliveryId")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	delivery, err := h.WebhookStore.RedeliverDelivery(ctx, projectID, id, int64(deliveryID))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return
+	}
+	if err != nil {
+		requestLog(c).Error().Err(err).Msgf("Error redelivering delivery %d of webhook %d", deliveryID, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver webhook delivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
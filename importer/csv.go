@@ -0,0 +1,268 @@
+// Package importer implements the import CLI subcommand, which loads events
+// exported from legacy analytics tools out of CSV or TSV files.
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Mapping describes how CSV columns become event fields.
+//
+//	{
+//	  "columns": {"eventType": "event_name", "userId": "uid", "timestamp": "ts"},
+//	  "timestampFormat": "unixms",
+//	  "defaults": {"pagePath": "/"},
+//	  "eventData": ["plan", "coupon"]
+//	}
+type Mapping struct {
+	// Columns maps event fields (JSON names, e.g. "eventType") to CSV headers.
+	Columns map[string]string `json:"columns"`
+	// TimestampFormat is "rfc3339" (default), "unix", "unixms" or a Go layout.
+	TimestampFormat string `json:"timestampFormat"`
+	// Defaults fill event fields that are unmapped or empty in a row.
+	Defaults map[string]string `json:"defaults"`
+	// EventData lists CSV columns copied into eventData as strings.
+	EventData []string `json:"eventData"`
+}
+
+var mappableFields = map[string]bool{
+	"eventType": true, "userId": true, "sessionId": true, "anonymousId": true, "timestamp": true,
+	"pagePath": true, "referrer": true, "userAgent": true, "ipAddress": true, "durationMs": true,
+	"location": true, "groupId": true, "products": true, "eventData": true,
+	"country": true, "region": true, "city": true, "deviceType": true, "browser": true,
+	"browserVersion": true, "os": true,
+	"utmSource": true, "utmMedium": true, "utmCampaign": true, "utmTerm": true, "utmContent": true,
+}
+
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping: %w", err)
+	}
+	var m Mapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode mapping: %w", err)
+	}
+	for field := range m.Columns {
+		if !mappableFields[field] {
+			return nil, fmt.Errorf("mapping: unknown event field %q", field)
+		}
+	}
+	if m.Columns["eventType"] == "" && m.Defaults["eventType"] == "" {
+		return nil, errors.New("mapping: eventType must be mapped to a column or given a default")
+	}
+	return &m, nil
+}
+
+type Options struct {
+	ProjectID string
+	Delimiter rune
+	BatchSize int
+	// IPAnonymizer truncates or hashes imported IPs; nil keeps them.
+	IPAnonymizer *utils.IPAnonymizer
+}
+
+// Result summarizes an import run.
+type Result struct {
+	Rows       int
+	Imported   int
+	Skipped    int
+	Suppressed int
+}
+
+// ImportCSV streams rows from r, converts them with the mapping and inserts
+// them in batches. Invalid rows are skipped and counted. Suppressed subjects
+// are dropped or anonymized as they are at ingestion.
+func ImportCSV(ctx context.Context, r io.Reader, m *Mapping, opts Options, analytics *store.AnalyticsStore, suppressions *store.SuppressionStore) (*Result, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = opts.Delimiter
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	for field, column := range m.Columns {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("column %q mapped to %s is not in the header", column, field)
+		}
+	}
+
+	result := &Result{}
+	started := time.Now()
+	batch := make([]models.AnalyticsEvent, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := analytics.InsertAnalyticsEvents(ctx, batch); err != nil {
+			return err
+		}
+		result.Imported += len(batch)
+		batch = batch[:0]
+		log.Info().Msgf("Import: %d rows read, %d imported, %d skipped (%.0f rows/s)", result.Rows, result.Imported, result.Skipped, float64(result.Rows)/time.Since(started).Seconds())
+		return nil
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		result.Rows++
+		if err != nil {
+			result.Skipped++
+			log.Warn().Err(err).Msgf("Import: skipping row %d", result.Rows)
+			continue
+		}
+
+		value := func(field string) string {
+			if column, ok := m.Columns[field]; ok {
+				if i := index[column]; i < len(record) {
+					if v := strings.TrimSpace(record[i]); v != "" {
+						return v
+					}
+				}
+			}
+			return m.Defaults[field]
+		}
+		event, err := m.event(value, record, index)
+		if err != nil {
+			result.Skipped++
+			log.Warn().Err(err).Msgf("Import: skipping row %d", result.Rows)
+			continue
+		}
+		event.ProjectID = opts.ProjectID
+
+		if mode, ok := suppressions.Lookup(event.ProjectID, event.UserID, event.AnonymousID); ok {
+			result.Suppressed++
+			if mode != models.SuppressionModeAnonymize {
+				continue
+			}
+			event.Anonymize()
+		}
+		event.IPAddress = opts.IPAnonymizer.Anonymize(event.IPAddress)
+
+		batch = append(batch, event)
+		if len(batch) >= opts.BatchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, flush()
+}
+
+func (m *Mapping) event(value func(string) string, record []string, index map[string]int) (models.AnalyticsEvent, error) {
+	event := models.AnalyticsEvent{
+		EventType:      value("eventType"),
+		UserID:         value("userId"),
+		SessionID:      value("sessionId"),
+		AnonymousID:    value("anonymousId"),
+		PagePath:       value("pagePath"),
+		Referrer:       value("referrer"),
+		UserAgent:      value("userAgent"),
+		IPAddress:      value("ipAddress"),
+		Location:       value("location"),
+		GroupID:        value("groupId"),
+		Country:        value("country"),
+		Region:         value("region"),
+		City:           value("city"),
+		DeviceType:     value("deviceType"),
+		Browser:        value("browser"),
+		BrowserVersion: value("browserVersion"),
+		OS:             value("os"),
+		UTMSource:      value("utmSource"),
+		UTMMedium:      value("utmMedium"),
+		UTMCampaign:    value("utmCampaign"),
+		UTMTerm:        value("utmTerm"),
+		UTMContent:     value("utmContent"),
+	}
+	if event.EventType == "" {
+		return event, errors.New("empty eventType")
+	}
+
+	ts, err := m.parseTimestamp(value("timestamp"))
+	if err != nil {
+		return event, err
+	}
+	event.Timestamp = ts
+	event.EventID = utils.NewEventID(ts)
+
+	if raw := value("durationMs"); raw != "" {
+		d, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return event, fmt.Errorf("invalid durationMs %q", raw)
+		}
+		event.DurationMs = d
+	}
+	if raw := value("products"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &event.Products); err != nil {
+			return event, errors.New("products is not a JSON array of line items")
+		}
+	}
+
+	data := map[string]interface{}{}
+	if raw := value("eventData"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return event, errors.New("eventData is not a JSON object")
+		}
+	}
+	for _, column := range m.EventData {
+		if i, ok := index[column]; ok && i < len(record) && record[i] != "" {
+			data[column] = record[i]
+		}
+	}
+	if len(data) > 0 {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return event, err
+		}
+		event.EventData = encoded
+	}
+	if err := event.Validate(); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+func (m *Mapping) parseTimestamp(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, errors.New("empty timestamp")
+	}
+	switch strings.ToLower(m.TimestampFormat) {
+	case "", "rfc3339":
+		return time.Parse(time.RFC3339, raw)
+	case "unix", "unixms":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", raw)
+		}
+		if strings.EqualFold(m.TimestampFormat, "unix") {
+			return time.Unix(n, 0).UTC(), nil
+		}
+		return time.UnixMilli(n).UTC(), nil
+	default:
+		return time.Parse(m.TimestampFormat, raw)
+	}
+}
@@ -0,0 +1,191 @@
+// Package ingest moves tracked events through Kafka: /api/track produces them
+// to a topic and a consumer batches them into ClickHouse. Events are durable
+// once the brokers acknowledge them, and a backlog builds up in the topic
+// rather than in memory when ClickHouse falls behind.
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+)
+
+// Config selects the Kafka cluster and topic events go through.
+type Config struct {
+	Brokers []string
+	Topic   string
+	// Group is the consumer group the consumers of all replicas share.
+	Group string
+}
+
+// ConfigFromEnv reads KAFKA_BROKERS (comma-separated), KAFKA_TOPIC and
+// KAFKA_CONSUMER_GROUP.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Topic: "analytics-events", Group: "mable-ingest"}
+	for _, broker := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			cfg.Brokers = append(cfg.Brokers, broker)
+		}
+	}
+	if len(cfg.Brokers) == 0 {
+		return cfg, errors.New("KAFKA_BROKERS is required when INGEST_MODE is kafka")
+	}
+	if topic := os.Getenv("KAFKA_TOPIC"); topic != "" {
+		cfg.Topic = topic
+	}
+	if group := os.Getenv("KAFKA_CONSUMER_GROUP"); group != "" {
+		cfg.Group = group
+	}
+	return cfg, nil
+}
+
+// KafkaProducer writes events to the topic, one message per event keyed by
+// project, so each project's events stay in order within a partition.
+type KafkaProducer struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaProducer(cfg Config) *KafkaProducer {
+	return &KafkaProducer{writer: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// Requests wait for their own events only, not for a batch to fill.
+		BatchTimeout: 10 * time.Millisecond,
+	}}
+}
+
+// Enqueue returns once the brokers have acknowledged all events.
+func (p *KafkaProducer) Enqueue(ctx context.Context, events []models.AnalyticsEvent) error {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+		messages = append(messages, kafka.Message{Key: []byte(event.ProjectID), Value: value})
+	}
+	if err := p.writer.WriteMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("failed to produce events to %s: %w", p.writer.Topic, err)
+	}
+	return nil
+}
+
+func (p *KafkaProducer) Close() error {
+	return p.writer.Close()
+}
+
+// KafkaConsumer reads events from the topic and inserts them into ClickHouse
+// in batches. Offsets are committed only after a batch is inserted, so events
+// are retried until they are written; an event may be written twice when an
+// instance stops between the two.
+type KafkaConsumer struct {
+	reader        *kafka.Reader
+	analytics     *store.AnalyticsStore
+	batchSize     int
+	flushInterval time.Duration
+}
+
+// NewKafkaConsumer inserts batches of up to batchSize events, or what arrived
+// within flushInterval of the first event of a batch.
+func NewKafkaConsumer(cfg Config, analytics *store.AnalyticsStore, batchSize int, flushInterval time.Duration) *KafkaConsumer {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  cfg.Brokers,
+		Topic:    cfg.Topic,
+		GroupID:  cfg.Group,
+		MaxBytes: 10 << 20,
+	})
+	return &KafkaConsumer{reader: reader, analytics: analytics, batchSize: batchSize, flushInterval: flushInterval}
+}
+
+// Lag returns how many events in the consumer's partitions are yet to be
+// read.
+func (k *KafkaConsumer) Lag() int {
+	return int(k.reader.Stats().Lag)
+}
+
+// Start consumes in a new goroutine until ctx is cancelled.
+func (k *KafkaConsumer) Start(ctx context.Context) {
+	go func() {
+		defer k.reader.Close()
+		for ctx.Err() == nil {
+			k.consumeBatch(ctx)
+		}
+	}()
+	log.Info().Msgf("Kafka consumer started (topic %s, group %s, batches of %d)", k.reader.Config().Topic, k.reader.Config().GroupID, k.batchSize)
+}
+
+// consumeBatch reads one batch, inserts it and commits its offsets.
+func (k *KafkaConsumer) consumeBatch(ctx context.Context) {
+	var (
+		messages []kafka.Message
+		events   []models.AnalyticsEvent
+	)
+	fetchCtx := ctx
+	for len(messages) < k.batchSize {
+		msg, err := k.reader.FetchMessage(fetchCtx)
+		if ctx.Err() != nil {
+			// Uncommitted messages are consumed again after a restart.
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch events from Kafka")
+			sleep(ctx, time.Second)
+			return
+		}
+		if len(messages) == 0 {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithTimeout(ctx, k.flushInterval)
+			defer cancel()
+		}
+		messages = append(messages, msg)
+
+		var event models.AnalyticsEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			log.Error().Err(err).Msgf("Skipping undecodable event at %s/%d offset %d", msg.Topic, msg.Partition, msg.Offset)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := k.analytics.InsertEventBatch(ctx, events)
+		if err == nil {
+			break
+		}
+		log.Error().Err(err).Msgf("Failed to insert %d events from Kafka (attempt %d)", len(events), attempt)
+		if !sleep(ctx, min(time.Duration(attempt)*time.Second, 30*time.Second)) {
+			return
+		}
+	}
+	if err := k.reader.CommitMessages(ctx, messages...); err != nil && ctx.Err() == nil {
+		log.Error().Err(err).Msg("Failed to commit Kafka offsets")
+	}
+}
+
+// sleep waits for d and reports whether ctx is still live.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// alertNotification is the JSON body POSTed to an alert's webhook when it
+// starts firing or resolves.
+type alertNotification struct {
+	AlertID     int       `json:"alertId"`
+	Name        string    `json:"name"`
+	State       string    `json:"state"`
+	EventType   string    `json:"eventType"`
+	Metric      string    `json:"metric"`
+	Condition   string    `json:"condition"`
+	Threshold   float64   `json:"threshold"`
+	Value       float64   `json:"value"`
+	Baseline    *float64  `json:"baseline,omitempty"`
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+}
+
+// EvaluateAlerts evaluates every enabled alert over the window ending now and
+// notifies its webhook when its state changes. Failures are logged per alert
+// so one broken alert or webhook does not hold up the others.
+func EvaluateAlerts(alerts *store.AlertStore, analytics *store.AnalyticsStore) func(context.Context) error {
+	client := utils.OutboundClient
+	return func(ctx context.Context) error {
+		list, err := alerts.ListEnabledAlerts(ctx)
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		now := time.Now().UTC()
+		for i := range list {
+			runCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			if err := evaluateAlert(runCtx, client, alerts, analytics, &list[i], now); err != nil {
+				log.Error().Err(err).Msgf("Alert evaluation: alert %d failed", list[i].ID)
+				failed++
+			}
+			cancel()
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d alerts failed to evaluate", failed, len(list))
+		}
+		return nil
+	}
+}
+
+func evaluateAlert(ctx context.Context, client *http.Client, alerts *store.AlertStore, analytics *store.AnalyticsStore, alert *models.Alert, now time.Time) error {
+	window := time.Duration(alert.WindowSeconds) * time.Second
+	start := now.Add(-window)
+	value, err := analytics.GetAlertMetric(ctx, alert.ProjectID, alert.Metric, alert.EventType, start, now)
+	if err != nil {
+		return err
+	}
+
+	firing := false
+	var baseline *float64
+	switch alert.Condition {
+	case models.AlertConditionBelow:
+		firing = value < alert.Threshold
+	case models.AlertConditionAbove:
+		firing = value > alert.Threshold
+	case models.AlertConditionDropPct, models.AlertConditionRisePct:
+		offset := time.Duration(alert.CompareOffsetSeconds) * time.Second
+		previous, err := analytics.GetAlertMetric(ctx, alert.ProjectID, alert.Metric, alert.EventType, start.Add(-offset), now.Add(-offset))
+		if err != nil {
+			return err
+		}
+		baseline = &previous
+		// Without a baseline there is no change to measure.
+		if previous > 0 {
+			change := (value - previous) / previous * 100
+			if alert.Condition == models.AlertConditionDropPct {
+				firing = -change >= alert.Threshold
+			} else {
+				firing = change >= alert.Threshold
+			}
+		}
+	default:
+		return fmt.Errorf("invalid alert condition: %s", alert.Condition)
+	}
+
+	state := models.AlertStateOK
+	if firing {
+		state = models.AlertStateFiring
+	}
+
+	var notifyErr error
+	if state != alert.State {
+		log.Info().Msgf("Alert %d (%s) is now %s: %s %s = %g", alert.ID, alert.Name, state, alert.EventType, alert.Metric, value)
+		if alert.WebhookURL != "" {
+			notifyErr = notifyAlert(ctx, client, alert.WebhookURL, alertNotification{
+				AlertID:     alert.ID,
+				Name:        alert.Name,
+				State:       state,
+				EventType:   alert.EventType,
+				Metric:      alert.Metric,
+				Condition:   alert.Condition,
+				Threshold:   alert.Threshold,
+				Value:       value,
+				Baseline:    baseline,
+				WindowStart: start,
+				WindowEnd:   now,
+			})
+			if notifyErr != nil {
+				log.Error().Err(notifyErr).Msgf("Alert %d: failed to notify", alert.ID)
+			}
+		}
+	}
+	return alerts.RecordEvaluation(ctx, alert, state, value, baseline, now, notifyErr)
+}
+
+func notifyAlert(ctx context.Context, client *http.Client, url string, notification alertNotification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build alert notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver alert notification: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook rejected alert notification: %s", resp.Status)
+	}
+	return nil
+}
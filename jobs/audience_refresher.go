@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RefreshAudiences materializes all audiences, logging failures individually so
+// one broken definition does not block the others.
+func RefreshAudiences(s *store.AudienceStore) func(context.Context) error {
+	return func(ctx context.Context) error {
+		audiences, err := s.ListAllAudiences(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list audiences: %w", err)
+		}
+
+		failed := 0
+		for i := range audiences {
+			runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			if _, err := s.Materialize(runCtx, &audiences[i]); err != nil {
+				log.Error().Err(err).Msgf("Audience refresh: audience %d failed", audiences[i].ID)
+				failed++
+			}
+			cancel()
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d audiences failed to refresh", failed, len(audiences))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BillingExportCSV writes the monthly billing report as CSV.
+func BillingExportCSV(usage *store.UsageStore) ExportFunc {
+	return func(ctx context.Context, job *models.ExportJob, w io.Writer) error {
+		lines, err := billingUsage(ctx, usage, job)
+		if err != nil {
+			return err
+		}
+
+		cw := csv.NewWriter(w)
+		if err := cw.Write(models.BillingUsageCSVHeader); err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if err := cw.Write(line.CSVRecord()); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+// BillingExportJSON writes the monthly billing report as a JSON array.
+func BillingExportJSON(usage *store.UsageStore) ExportFunc {
+	return func(ctx context.Context, job *models.ExportJob, w io.Writer) error {
+		lines, err := billingUsage(ctx, usage, job)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(lines)
+	}
+}
+
+func billingUsage(ctx context.Context, usage *store.UsageStore, job *models.ExportJob) ([]models.BillingUsage, error) {
+	var params models.BillingExportParams
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return nil, fmt.Errorf("invalid billing export params: %s", job.Params)
+	}
+	period, err := store.ParseUsagePeriod(params.Period)
+	if err != nil {
+		return nil, err
+	}
+	return usage.GetBillingUsage(ctx, period)
+}
+
+// QueueBillingExports queues CSV and JSON billing reports for the previous
+// month once it has ended. Scheduled frequently, reports appear shortly after
+// the turn of the month and are never queued twice.
+func QueueBillingExports(exports *store.ExportStore) func(context.Context) error {
+	return func(ctx context.Context) error {
+		now := time.Now().UTC()
+		previous := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+		params := models.BillingExportParams{Period: previous.Format("2006-01")}
+
+		for _, kind := range []string{models.ExportKindBillingCSV, models.ExportKindBillingJSON} {
+			exists, err := exports.HasJob(ctx, kind, params)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			job, err := exports.CreateJob(ctx, kind, params, 0)
+			if err != nil {
+				return err
+			}
+			log.Info().Msgf("Billing export scheduler: queued %s job %s for %s", kind, job.ID, params.Period)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CleanupTask removes one kind of data once it is older than Retention. Prune
+// receives the cutoff and returns the number of items removed. Local tasks
+// prune per-instance state and run on every replica.
+type CleanupTask struct {
+	Name      string
+	Interval  time.Duration
+	Retention time.Duration
+	Local     bool
+	Prune     func(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// CleanupTaskFromEnv builds a task whose schedule is read from
+// CLEANUP_<NAME>_INTERVAL and CLEANUP_<NAME>_RETENTION. An interval of 0
+// disables the task.
+func CleanupTaskFromEnv(name string, interval, retention time.Duration, prune func(context.Context, time.Time) (int64, error)) CleanupTask {
+	prefix := "CLEANUP_" + strings.ToUpper(name) + "_"
+	return CleanupTask{
+		Name:      name,
+		Interval:  utils.GetEnvDuration(prefix+"INTERVAL", interval),
+		Retention: utils.GetEnvDuration(prefix+"RETENTION", retention),
+		Prune:     prune,
+	}
+}
+
+// ScheduleCleanup registers every enabled task as "cleanup_<name>".
+func ScheduleCleanup(s *Scheduler, tasks ...CleanupTask) error {
+	for _, task := range tasks {
+		if task.Interval <= 0 {
+			log.Info().Msgf("Cleanup task %s disabled", task.Name)
+			continue
+		}
+		register := s.Register
+		if task.Local {
+			register = s.RegisterLocal
+		}
+		if err := register("cleanup_"+task.Name, Every(task.Interval), task.run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t CleanupTask) run(ctx context.Context) error {
+	removed, err := t.Prune(ctx, time.Now().Add(-t.Retention))
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		log.Info().Msgf("Cleanup task %s: removed %d items older than %s", t.Name, removed, t.Retention)
+	}
+	return nil
+}
+
+// PruneSessions drops expired login sessions.
+func PruneSessions(sessions store.SessionStore) func(context.Context, time.Time) (int64, error) {
+	return sessions.Prune
+}
+
+// PruneExportFiles deletes the files of completed exports and marks the jobs
+// as expired.
+func PruneExportFiles(exports *store.ExportStore) func(context.Context, time.Time) (int64, error) {
+	return func(ctx context.Context, cutoff time.Time) (int64, error) {
+		paths, err := exports.ExpireJobs(ctx, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		var removed int64
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Error().Err(err).Msgf("Cleanup task export_files: failed to remove %s", path)
+				continue
+			}
+			removed++
+		}
+		return removed, nil
+	}
+}
+
+// PruneAuditLog deletes old audit entries.
+func PruneAuditLog(audit *store.AuditStore) func(context.Context, time.Time) (int64, error) {
+	return audit.DeleteBefore
+}
+
+// PruneRefreshTokens deletes refresh tokens that have expired.
+func PruneRefreshTokens(tokens *store.RefreshTokenStore) func(context.Context, time.Time) (int64, error) {
+	return tokens.DeleteExpiredBefore
+}
+
+// PrunePasswordResets deletes password reset tokens that have expired.
+func PrunePasswordResets(resets *store.PasswordResetStore) func(context.Context, time.Time) (int64, error) {
+	return resets.DeleteExpiredBefore
+}
+
+// PruneEmailChanges deletes email changes whose confirmation has expired.
+func PruneEmailChanges(changes *store.EmailChangeStore) func(context.Context, time.Time) (int64, error) {
+	return changes.DeleteExpiredBefore
+}
+
+// PruneLoginAttempts deletes failed login counts whose window has ended.
+func PruneLoginAttempts(limiter *store.LoginLimiter) func(context.Context, time.Time) (int64, error) {
+	return limiter.DeleteExpiredBefore
+}
+
+// PruneRevokedTokens deletes revocations of access tokens that have expired.
+func PruneRevokedTokens(revocations store.TokenRevocationStore) func(context.Context, time.Time) (int64, error) {
+	return revocations.DeleteExpiredBefore
+}
+
+// PruneWebhookDeliveries deletes the log of delivered and failed webhook
+// deliveries.
+func PruneWebhookDeliveries(webhooks *store.WebhookStore) func(context.Context, time.Time) (int64, error) {
+	return webhooks.DeleteDeliveriesBefore
+}
@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DataDeletion issues the mutation for a queued erasure request.
+func DataDeletion(deletions *store.DeletionStore) JobFunc {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload models.DataDeletionPayload
+		if err := unmarshalPayload(job, &payload); err != nil {
+			return err
+		}
+		err := deletions.StartDeletion(ctx, payload.DeletionID)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		if err != nil && job.FinalAttempt() {
+			if failErr := deletions.FailDeletion(ctx, payload.DeletionID, err.Error()); failErr != nil {
+				log.Error().Err(failErr).Msgf("Job %s: failed to mark deletion %d failed", job.ID, payload.DeletionID)
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"mabletask/api/store"
+)
+
+// EnforceEventRetention deletes the events each project retains no longer
+// than its retention settings, and those of its event types, allow.
+func EnforceEventRetention(settings *store.ProjectSettingsStore, analytics *store.AnalyticsStore) func(context.Context) error {
+	return func(ctx context.Context) error {
+		policy, err := settings.RetentionPolicy(ctx)
+		if err != nil {
+			return err
+		}
+		return analytics.DeleteExpiredEvents(ctx, time.Now().UTC(), policy)
+	}
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ecbRates is the document of the ECB euro foreign exchange reference rates
+// (eurofxref-daily.xml and the historical variants), as units per 1 EUR.
+type ecbRates struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// FetchExchangeRates downloads rates in the ECB reference rate format from url
+// and stores every day in the document.
+func FetchExchangeRates(rates *store.ExchangeRateStore, url string) func(context.Context) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to build exchange rate request: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to fetch exchange rates: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to fetch exchange rates: %s", resp.Status)
+		}
+
+		var doc ecbRates
+		if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+			return fmt.Errorf("failed to parse exchange rates: %w", err)
+		}
+		for _, day := range doc.Days {
+			date, err := time.Parse(models.DateFormat, day.Time)
+			if err != nil {
+				return fmt.Errorf("invalid exchange rate date %q: %w", day.Time, err)
+			}
+			perEUR := map[string]float64{}
+			for _, r := range day.Rates {
+				rate, err := strconv.ParseFloat(r.Rate, 64)
+				if err != nil || rate <= 0 {
+					log.Info().Msgf("Exchange rates: skipping %s rate %q for %s", r.Currency, r.Rate, day.Time)
+					continue
+				}
+				perEUR[r.Currency] = rate
+			}
+			if err := rates.SetRates(ctx, date, perEUR, "ecb"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ExportFunc writes the output of a single export job.
+type ExportFunc func(ctx context.Context, job *models.ExportJob, w io.Writer) error
+
+type exportHandler struct {
+	ext string
+	fn  ExportFunc
+}
+
+// ExportWorker executes export jobs delivered by the job queue and stores
+// their output as files under a local directory.
+type ExportWorker struct {
+	store    *store.ExportStore
+	dir      string
+	handlers map[string]exportHandler
+}
+
+func NewExportWorker(s *store.ExportStore, dir string) (*ExportWorker, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create export directory %s: %w", dir, err)
+	}
+	return &ExportWorker{store: s, dir: dir, handlers: map[string]exportHandler{}}, nil
+}
+
+// Register associates a job kind with the function producing its output and
+// the file extension used for the stored result.
+func (w *ExportWorker) Register(kind, ext string, fn ExportFunc) {
+	w.handlers[kind] = exportHandler{ext: ext, fn: fn}
+}
+
+// Handle runs the export referenced by a queued job. A failed attempt leaves
+// the export pending for the queue to retry; the last one marks it failed.
+func (w *ExportWorker) Handle(ctx context.Context, queued *models.Job) error {
+	var payload models.ExportJobPayload
+	if err := unmarshalPayload(queued, &payload); err != nil {
+		return err
+	}
+	job, err := w.store.StartJob(ctx, payload.ExportID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = w.run(ctx, job)
+	if err == nil {
+		return nil
+	}
+	if queued.FinalAttempt() || errors.Is(err, ErrSkipRetry) {
+		log.Error().Err(err).Msgf("Export job %s (%s) failed", job.ID, job.Kind)
+		if failErr := w.store.FailJob(ctx, job.ID, err.Error()); failErr != nil {
+			log.Error().Err(failErr).Msgf("Export worker: failed to mark job %s failed", job.ID)
+		}
+	} else if retryErr := w.store.RetryJob(ctx, job.ID, err.Error()); retryErr != nil {
+		log.Error().Err(retryErr).Msgf("Export worker: failed to schedule retry of job %s", job.ID)
+	}
+	return err
+}
+
+func (w *ExportWorker) run(ctx context.Context, job *models.ExportJob) error {
+	handler, ok := w.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("unknown export kind %q: %w", job.Kind, ErrSkipRetry)
+	}
+
+	path := filepath.Join(w.dir, job.ID+handler.ext)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	err = handler.fn(ctx, job, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	if err := w.store.CompleteJob(ctx, job.ID, path); err != nil {
+		return err
+	}
+	log.Info().Msgf("Export job %s (%s) completed", job.ID, job.Kind)
+	return nil
+}
@@ -0,0 +1,197 @@
+package jobs
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+)
+
+// PrivacyExport gathers everything held about a data subject: the account
+// record (when the export names one), identified traits, and every raw event. Events are streamed so large histories stay cheap.
+func PrivacyExport(users *store.UserStore, traits *store.TraitsStore, analytics *store.AnalyticsStore) ExportFunc {
+	return func(ctx context.Context, job *models.ExportJob, w io.Writer) error {
+		params, err := privacyExportParams(job)
+		if err != nil {
+			return err
+		}
+		header, err := privacyProfile(ctx, users, traits, params)
+		if err != nil {
+			return err
+		}
+
+		// Splice the events array into the header object: {...,"events":[...]}
+		if _, err := w.Write(header[:len(header)-1]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, `,"events":[`); err != nil {
+			return err
+		}
+
+		first := true
+		err = analytics.ForEachUserEvent(ctx, params.ProjectID, params.UserID, func(event models.AnalyticsEvent) error {
+			raw, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to encode event %s: %w", event.EventID, err)
+			}
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			_, err = w.Write(raw)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(w, "]}")
+		return err
+	}
+}
+
+// privacyEventColumns are the columns of events.csv in CSV privacy exports.
+// Structured fields are JSON-encoded.
+var privacyEventColumns = []string{
+	"eventId", "eventType", "timestamp", "clientTimestamp", "userId", "anonymousId", "sessionId", "groupId",
+	"pagePath", "referrer", "userAgent", "ipAddress", "durationMs", "location",
+	"country", "region", "city", "deviceType", "browser", "browserVersion", "os",
+	"utmSource", "utmMedium", "utmCampaign", "utmTerm", "utmContent", "channel",
+	"products", "experiments", "eventData",
+}
+
+// PrivacyExportCSV gathers the same data as PrivacyExport into a zip archive
+// of profile.json, with the account record and traits, and events.csv.
+func PrivacyExportCSV(users *store.UserStore, traits *store.TraitsStore, analytics *store.AnalyticsStore) ExportFunc {
+	return func(ctx context.Context, job *models.ExportJob, w io.Writer) error {
+		params, err := privacyExportParams(job)
+		if err != nil {
+			return err
+		}
+		header, err := privacyProfile(ctx, users, traits, params)
+		if err != nil {
+			return err
+		}
+
+		archive := zip.NewWriter(w)
+		profile, err := archive.Create("profile.json")
+		if err != nil {
+			return err
+		}
+		if _, err := profile.Write(header); err != nil {
+			return err
+		}
+
+		events, err := archive.Create("events.csv")
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(events)
+		if err := cw.Write(privacyEventColumns); err != nil {
+			return err
+		}
+		err = analytics.ForEachUserEvent(ctx, params.ProjectID, params.UserID, func(event models.AnalyticsEvent) error {
+			row, err := privacyEventRow(event)
+			if err != nil {
+				return fmt.Errorf("failed to encode event %s: %w", event.EventID, err)
+			}
+			return cw.Write(row)
+		})
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		return archive.Close()
+	}
+}
+
+func privacyExportParams(job *models.ExportJob) (models.PrivacyExportParams, error) {
+	var params models.PrivacyExportParams
+	if err := json.Unmarshal(job.Params, &params); err != nil || params.UserID == "" {
+		return params, fmt.Errorf("invalid privacy export params: %s", job.Params)
+	}
+	if params.ProjectID == "" {
+		// Exports queued before projects.
+		params.ProjectID = models.DefaultProjectID
+	}
+	return params, nil
+}
+
+// privacyProfile encodes the account record and traits of the data subject
+// as a JSON object. The account record is only looked up by the explicit
+// AccountID: analytics user IDs are not account IDs.
+func privacyProfile(ctx context.Context, users *store.UserStore, traits *store.TraitsStore, params models.PrivacyExportParams) ([]byte, error) {
+	userID := params.UserID
+	var profile *models.User
+	if params.AccountID != 0 {
+		var err error
+		profile, err = users.GetUserByID(ctx, params.AccountID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	userTraits, err := traits.GetUserTraits(ctx, params.ProjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := json.Marshal(map[string]interface{}{
+		"userId":      userID,
+		"projectId":   params.ProjectID,
+		"generatedAt": time.Now().UTC().Format(models.TimestampFormat),
+		"profile":     profile,
+		"traits":      userTraits,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode export header: %w", err)
+	}
+	return header, nil
+}
+
+// privacyEventRow renders event in the order of privacyEventColumns.
+func privacyEventRow(event models.AnalyticsEvent) ([]string, error) {
+	products, err := jsonColumn(event.Products)
+	if err != nil {
+		return nil, err
+	}
+	experiments, err := jsonColumn(event.Experiments)
+	if err != nil {
+		return nil, err
+	}
+	clientTimestamp := ""
+	if event.ClientTimestamp != nil {
+		clientTimestamp = event.ClientTimestamp.UTC().Format(models.TimestampFormat)
+	}
+	return []string{
+		event.EventID, event.EventType, event.Timestamp.UTC().Format(models.TimestampFormat), clientTimestamp,
+		event.UserID, event.AnonymousID, event.SessionID, event.GroupID,
+		event.PagePath, event.Referrer, event.UserAgent, event.IPAddress,
+		strconv.FormatInt(event.DurationMs, 10), event.Location,
+		event.Country, event.Region, event.City, event.DeviceType, event.Browser, event.BrowserVersion, event.OS,
+		event.UTMSource, event.UTMMedium, event.UTMCampaign, event.UTMTerm, event.UTMContent, event.Channel,
+		products, experiments, string(event.EventData),
+	}, nil
+}
+
+// jsonColumn encodes v for a CSV cell, leaving empty slices blank.
+func jsonColumn[T any](v []T) (string, error) {
+	if len(v) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(v)
+	return string(raw), err
+}
@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrSkipRetry marks a job error as permanent: the job is failed immediately
+// instead of being retried.
+var ErrSkipRetry = errors.New("not retryable")
+
+// JobFunc processes one queued job. Returning an error schedules a retry
+// unless the error wraps ErrSkipRetry or the job was on its last attempt.
+type JobFunc func(ctx context.Context, job *models.Job) error
+
+type queueHandler struct {
+	timeout time.Duration
+	fn      JobFunc
+}
+
+// Queue runs jobs from the persistent queue in store.JobStore.
+type Queue struct {
+	store    *store.JobStore
+	handlers map[string]queueHandler
+	kinds    []string
+}
+
+func NewQueue(s *store.JobStore) *Queue {
+	return &Queue{store: s, handlers: map[string]queueHandler{}}
+}
+
+// Register associates a job kind with its handler. Each attempt is cancelled
+// after timeout; the job stays leased for the same duration.
+func (q *Queue) Register(kind string, timeout time.Duration, fn JobFunc) {
+	if _, ok := q.handlers[kind]; !ok {
+		q.kinds = append(q.kinds, kind)
+	}
+	q.handlers[kind] = queueHandler{timeout: timeout, fn: fn}
+}
+
+// Start runs workers goroutines, each polling for due jobs once per interval,
+// until ctx is cancelled.
+func (q *Queue) Start(ctx context.Context, workers int, interval time.Duration) {
+	for i := 0; i < workers; i++ {
+		runEvery(ctx, interval, q.drain)
+	}
+	log.Info().Msgf("Job queue started (%d workers, kinds %v)", workers, q.kinds)
+}
+
+func (q *Queue) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.claim(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Job queue: failed to claim job")
+			return
+		}
+		if job == nil {
+			return
+		}
+		q.run(ctx, job)
+	}
+}
+
+// claim leases the next due job. The lease is the longest registered timeout
+// plus a margin, so a job is only re-claimed once its worker must have stopped.
+func (q *Queue) claim(ctx context.Context) (*models.Job, error) {
+	var lease time.Duration
+	for _, h := range q.handlers {
+		if h.timeout > lease {
+			lease = h.timeout
+		}
+	}
+	return q.store.Claim(ctx, q.kinds, lease+time.Minute)
+}
+
+func (q *Queue) run(ctx context.Context, job *models.Job) {
+	handler := q.handlers[job.Kind]
+
+	runCtx, cancel := context.WithTimeout(ctx, handler.timeout)
+	err := handler.fn(runCtx, job)
+	cancel()
+
+	if err == nil {
+		if err := q.store.Complete(ctx, job.ID); err != nil {
+			log.Error().Err(err).Msgf("Job queue: failed to complete job %s", job.ID)
+		}
+		return
+	}
+
+	if job.FinalAttempt() || errors.Is(err, ErrSkipRetry) {
+		log.Error().Err(err).Msgf("Job %s (%s) failed after %d attempts", job.ID, job.Kind, job.Attempts)
+		if err := q.store.Fail(ctx, job.ID, err.Error()); err != nil {
+			log.Error().Err(err).Msgf("Job queue: failed to mark job %s failed", job.ID)
+		}
+		return
+	}
+
+	delay := retryDelay(job.Attempts)
+	log.Error().Err(err).Msgf("Job %s (%s) attempt %d failed, retrying in %s", job.ID, job.Kind, job.Attempts, delay)
+	if err := q.store.Retry(ctx, job.ID, err.Error(), time.Now().Add(delay)); err != nil {
+		log.Error().Err(err).Msgf("Job queue: failed to schedule retry of job %s", job.ID)
+	}
+}
+
+// retryDelay backs off exponentially from 10s, capped at an hour.
+func retryDelay(attempt int) time.Duration {
+	delay := 10 * time.Second << (attempt - 1)
+	if attempt > 10 || delay > time.Hour {
+		return time.Hour
+	}
+	return delay
+}
+
+// unmarshalPayload decodes a job payload; malformed payloads are not retried.
+func unmarshalPayload(job *models.Job, v interface{}) error {
+	if err := json.Unmarshal(job.Payload, v); err != nil {
+		return fmt.Errorf("invalid %s payload %s: %w", job.Kind, job.Payload, ErrSkipRetry)
+	}
+	return nil
+}
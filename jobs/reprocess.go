@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"mabletask/api/enrich"
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+const reprocessBatchSize = 10000
+
+// Reprocess re-reads a range of stored events, runs them through the
+// requested enrichers and writes the result either back over the range or to a
+// separate table version. The job output is a JSON report.
+func Reprocess(analytics *store.AnalyticsStore) ExportFunc {
+	return func(ctx context.Context, job *models.ExportJob, w io.Writer) error {
+		var params models.ReprocessParams
+		if err := json.Unmarshal(job.Params, &params); err != nil {
+			return fmt.Errorf("invalid reprocess params: %s", job.Params)
+		}
+		pipeline, err := enrich.New(params.Enrichers)
+		if err != nil {
+			return err
+		}
+
+		// Replace mode stages the corrected range in a table of its own so the
+		// original rows are only deleted once every event was rewritten.
+		table := params.TargetTable
+		if params.Mode == models.ReprocessModeReplace {
+			table = "analytics_events_replay_" + strings.ReplaceAll(job.ID, "-", "")
+		}
+		if err := analytics.CreateEventTable(ctx, table); err != nil {
+			return err
+		}
+		keepStaging := false
+		if params.Mode == models.ReprocessModeReplace {
+			defer func() {
+				if keepStaging {
+					return
+				}
+				if err := analytics.DropEventTable(context.Background(), table); err != nil {
+					log.Error().Err(err).Msgf("Reprocess job %s: failed to drop staging table %s", job.ID, table)
+				}
+			}()
+		}
+
+		result := models.ReprocessResult{ReprocessParams: params}
+		batch := make([]models.AnalyticsEvent, 0, reprocessBatchSize)
+		flush := func() error {
+			if err := analytics.InsertEventsInto(ctx, table, batch); err != nil {
+				return err
+			}
+			result.EventsWritten += len(batch)
+			batch = batch[:0]
+			return nil
+		}
+
+		err = analytics.ForEachEventInRange(ctx, params.ProjectID, params.Start, params.End, func(event models.AnalyticsEvent) error {
+			result.EventsRead++
+			if pipeline.Enrich(&event) {
+				result.EventsChanged++
+			}
+			batch = append(batch, event)
+			if len(batch) == reprocessBatchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		if params.Mode == models.ReprocessModeReplace {
+			// Once the original rows may have been deleted the staged copy is
+			// the only one left: keep it and do not retry over an empty range.
+			if err := analytics.ReplaceEventRange(ctx, table, params.ProjectID, params.Start, params.End); err != nil {
+				keepStaging = true
+				return fmt.Errorf("%w (replayed events kept in %s): %w", err, table, ErrSkipRetry)
+			}
+		}
+
+		log.Info().Msgf("Reprocess job %s: %d events read, %d changed, %d written to %s", job.ID, result.EventsRead, result.EventsChanged, result.EventsWritten, table)
+		return json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron"
+	"github.com/rs/zerolog/log"
+
+	"mabletask/api/models"
+	"mabletask/api/store"
+)
+
+// Scheduler runs registered tasks on cron schedules. Tasks registered with
+// Register run only on the replica holding the leader lock; RegisterLocal
+// tasks run on every replica. The outcome of each run is stored so the admin
+// endpoint can report it from any replica.
+type Scheduler struct {
+	cron     *cron.Cron
+	runs     *store.ScheduleStore
+	leader   *store.LeaderLock
+	instance string
+	ctx      context.Context
+
+	mu    sync.Mutex
+	tasks []*scheduledTask
+}
+
+type scheduledTask struct {
+	name       string
+	spec       string
+	leaderOnly bool
+	schedule   cron.Schedule
+	fn         func(context.Context) error
+	running    int32
+}
+
+func NewScheduler(runs *store.ScheduleStore, leader *store.LeaderLock) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		cron:     cron.NewWithLocation(time.UTC),
+		runs:     runs,
+		leader:   leader,
+		instance: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		ctx:      context.Background(),
+	}
+}
+
+// Every returns the schedule spec running once per interval.
+func Every(interval time.Duration) string {
+	return "@every " + interval.String()
+}
+
+// Register schedules a leader-only task. spec is a standard five-field cron
+// expression or a descriptor such as "@daily" or "@every 1h"; the
+// SCHEDULE_<NAME> environment variable overrides it.
+func (s *Scheduler) Register(name, spec string, fn func(context.Context) error) error {
+	return s.register(name, spec, true, fn)
+}
+
+// RegisterLocal schedules a task that runs on every replica.
+func (s *Scheduler) RegisterLocal(name, spec string, fn func(context.Context) error) error {
+	return s.register(name, spec, false, fn)
+}
+
+func (s *Scheduler) register(name, spec string, leaderOnly bool, fn func(context.Context) error) error {
+	if override := os.Getenv("SCHEDULE_" + strings.ToUpper(name)); override != "" {
+		spec = override
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for %s: %w", spec, name, err)
+	}
+
+	task := &scheduledTask{name: name, spec: spec, leaderOnly: leaderOnly, schedule: schedule, fn: fn}
+	s.mu.Lock()
+	s.tasks = append(s.tasks, task)
+	s.mu.Unlock()
+	s.cron.Schedule(schedule, cron.FuncJob(func() { s.run(task) }))
+	return nil
+}
+
+// Start runs the schedules until ctx is cancelled, then gives up leadership.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.ctx = ctx
+	s.cron.Start()
+	log.Info().Msgf("Scheduler started (instance %s, %d tasks)", s.instance, len(s.tasks))
+
+	go func() {
+		<-ctx.Done()
+		s.cron.Stop()
+		s.leader.Release()
+	}()
+}
+
+func (s *Scheduler) run(task *scheduledTask) {
+	ctx := s.ctx
+	if ctx.Err() != nil {
+		return
+	}
+	if task.leaderOnly && !s.leader.IsLeader(ctx) {
+		return
+	}
+	// A run that outlasts its interval is not started again in parallel.
+	if !atomic.CompareAndSwapInt32(&task.running, 0, 1) {
+		log.Info().Msgf("Scheduler: %s still running, skipping", task.name)
+		return
+	}
+	defer atomic.StoreInt32(&task.running, 0)
+
+	if err := s.runs.RecordStart(ctx, task.name, s.instance); err != nil {
+		log.Error().Err(err).Msgf("Scheduler: failed to record start of %s", task.name)
+	}
+	started := time.Now()
+	err := task.fn(ctx)
+	if err != nil {
+		log.Error().Err(err).Msgf("Scheduler: %s failed", task.name)
+	}
+	if err := s.runs.RecordFinish(context.WithoutCancel(ctx), task.name, err, time.Since(started)); err != nil {
+		log.Error().Err(err).Msgf("Scheduler: failed to record finish of %s", task.name)
+	}
+}
+
+// Status lists the registered schedules with their next run on this instance
+// and their last recorded run on any instance.
+func (s *Scheduler) Status(ctx context.Context) (*models.SchedulerStatus, error) {
+	runs, err := s.runs.ListRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	tasks := append([]*scheduledTask(nil), s.tasks...)
+	s.mu.Unlock()
+
+	now := time.Now().UTC()
+	status := &models.SchedulerStatus{
+		Instance:  s.instance,
+		Leader:    s.leader.IsLeader(ctx),
+		Schedules: make([]models.Schedule, 0, len(tasks)),
+	}
+	for _, task := range tasks {
+		schedule := models.Schedule{
+			Name:       task.name,
+			Spec:       task.spec,
+			LeaderOnly: task.leaderOnly,
+			NextRun:    task.schedule.Next(now),
+		}
+		if run, ok := runs[task.name]; ok {
+			schedule.LastRun = &run
+		}
+		status.Schedules = append(status.Schedules, schedule)
+	}
+	sort.Slice(status.Schedules, func(i, j int) bool { return status.Schedules[i].Name < status.Schedules[j].Name })
+	return status, nil
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// runEvery calls fn once per interval in a new goroutine until ctx is cancelled.
+func runEvery(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	}()
+}
@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// webhookBatchSize is how many due deliveries one run claims at most.
+	webhookBatchSize = 100
+	webhookTimeout   = utils.OutboundTimeout
+	// webhookLease keeps claimed deliveries from being claimed again while a
+	// run sends them, even if every webhook of the batch times out.
+	webhookLease = webhookBatchSize*webhookTimeout + time.Minute
+)
+
+// webhookBody is the JSON body POSTed to a webhook for one event.
+type webhookBody struct {
+	DeliveryID int64           `json:"deliveryId"`
+	WebhookID  int             `json:"webhookId"`
+	ProjectID  string          `json:"projectId"`
+	EventType  string          `json:"eventType"`
+	Attempt    int             `json:"attempt"`
+	Event      json.RawMessage `json:"event"`
+}
+
+// DeliverWebhooks sends the due webhook deliveries. Each is POSTed with an
+// X-Webhook-Signature header of "t=<unix time>,v1=<hex HMAC-SHA256 of
+// "<unix time>.<body>" keyed by the webhook's secret>". A delivery counts as
+// delivered on a 2xx answer; otherwise it is retried with exponential
+// backoff, starting at a minute, until it has been tried maxAttempts times.
+func DeliverWebhooks(webhooks *store.WebhookStore, maxAttempts int) func(context.Context) error {
+	client := utils.OutboundClient
+	return func(ctx context.Context) error {
+		due, err := webhooks.ClaimDeliveries(ctx, webhookBatchSize, webhookLease)
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for i := range due {
+			d := &due[i]
+			status, sendErr := sendWebhook(ctx, client, d)
+			var retryAt *time.Time
+			if sendErr != nil {
+				failed++
+				if d.Attempts+1 < maxAttempts {
+					at := time.Now().Add(webhookBackoff(d.Attempts + 1))
+					retryAt = &at
+				}
+				log.Warn().Err(sendErr).Msgf("Webhook %d: delivery %d attempt %d failed", d.WebhookID, d.ID, d.Attempts+1)
+			}
+			if err := webhooks.RecordAttempt(context.WithoutCancel(ctx), d.ID, status, sendErr, retryAt); err != nil {
+				return err
+			}
+		}
+		if len(due) > 0 {
+			log.Info().Msgf("Webhook delivery: sent %d of %d deliveries", len(due)-failed, len(due))
+		}
+		return nil
+	}
+}
+
+// webhookBackoff returns the delay before the attempt after the given one:
+// a minute doubled per attempt, at most six hours.
+func webhookBackoff(attempts int) time.Duration {
+	return min(time.Minute<<min(attempts-1, 10), 6*time.Hour)
+}
+
+func sendWebhook(ctx context.Context, client *http.Client, d *store.DueDelivery) (int, error) {
+	payload, err := json.Marshal(webhookBody{
+		DeliveryID: d.ID,
+		WebhookID:  d.WebhookID,
+		ProjectID:  d.ProjectID,
+		EventType:  d.EventType,
+		Attempt:    d.Attempts + 1,
+		Event:      d.Payload,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode webhook body: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(d.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to deliver webhook: %w", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,75 @@
+// Package logging sets up the API's structured logger: leveled JSON lines
+// carrying request-scoped fields, with sensitive values redacted.
+package logging
+
+import (
+	"context"
+	stdlog "log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Setup configures the global logger from LOG_LEVEL (debug, info, warn or
+// error; default info) and LOG_FORMAT (json, the default, or console for
+// readable lines in development). Output goes to standard error with emails,
+// tokens and passwords masked. The standard library logger, which some
+// dependencies use, is sent through it as well.
+func Setup() {
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	zerolog.DurationFieldUnit = time.Millisecond
+
+	out := redactWriter{os.Stderr}
+	var logger zerolog.Logger
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "console") {
+		logger = zerolog.New(zerolog.ConsoleWriter{Out: out, TimeFormat: time.TimeOnly})
+	} else {
+		logger = zerolog.New(out)
+	}
+	log.Logger = logger.With().Timestamp().Logger()
+
+	level := zerolog.InfoLevel
+	if raw := os.Getenv("LOG_LEVEL"); raw != "" {
+		parsed, err := zerolog.ParseLevel(strings.ToLower(raw))
+		if err != nil || parsed == zerolog.NoLevel {
+			log.Warn().Str("value", raw).Msg("Invalid LOG_LEVEL, using info")
+		} else {
+			level = parsed
+		}
+	}
+	zerolog.SetGlobalLevel(level)
+
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(log.Logger)
+}
+
+type ctxKey struct{}
+
+// NewContext returns a context carrying logger, for Ctx to find.
+func NewContext(ctx context.Context, logger *zerolog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// Ctx returns the logger of the request ctx belongs to, which carries its
+// request ID, route and user, or the global logger outside of requests.
+func Ctx(ctx context.Context) *zerolog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*zerolog.Logger); ok {
+		return logger
+	}
+	return &log.Logger
+}
+
+// Annotate adds a field to the logger of the request ctx belongs to, for the
+// lines logged from then on and the request's access log line. It is meant
+// for middleware, before handlers may log concurrently, and does nothing
+// outside of requests.
+func Annotate(ctx context.Context, key string, value interface{}) {
+	if logger, ok := ctx.Value(ctxKey{}).(*zerolog.Logger); ok {
+		logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+			return c.Interface(key, value)
+		})
+	}
+}
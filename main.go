@@ -1,72 +1,454 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
 
 	"mabletask/api/database"
+	"mabletask/api/destinations"
+	"mabletask/api/enrich"
 	"mabletask/api/handlers"
+	"mabletask/api/ingest"
+	"mabletask/api/jobs"
+	"mabletask/api/logging"
+	"mabletask/api/mailer"
 	"mabletask/api/middleware"
+	"mabletask/api/models"
+	"mabletask/api/oauth"
 	"mabletask/api/store"
+	"mabletask/api/tracing"
+	"mabletask/api/utils"
 )
 
+// schedulerLockKey is the PostgreSQL advisory lock held by the replica running
+// leader-only scheduled tasks.
+const schedulerLockKey = 0x6d61626c65
+
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("No .env file found or error loading .env: %v", err)
+	envErr := godotenv.Load()
+	logging.Setup()
+	if envErr != nil {
+		log.Info().Err(envErr).Msg("No .env file found or error loading .env")
 	}
 
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	if err := utils.LoadSigningKeys(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load JWT signing keys")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to flush trace spans")
+		}
+	}()
 
 	dbClient, err := database.NewPostgresDB()
 	if err != nil {
-		log.Fatalf("Failed to initialize PostgreSQL database: %v", err)
+		log.Fatal().Err(err).Msg("Failed to initialize PostgreSQL database")
 	}
 	defer dbClient.Close()
 
 	chClient, err := database.NewClickHouseDB()
 	if err != nil {
-		log.Fatalf("Failed to initialize ClickHouse database: %v", err)
+		log.Fatal().Err(err).Msg("Failed to initialize ClickHouse database")
 	}
 	defer chClient.Close()
 
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:], dbClient, chClient); err != nil {
+			log.Fatal().Err(err).Msgf("%s failed", os.Args[1])
+		}
+		return
+	}
+
+	redisClient, err := database.NewRedisClient()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize Redis")
+	}
+	defer redisClient.Close()
+
 	userStore := store.NewUserStore(dbClient.DB)
 	analyticsStore := store.NewAnalyticsStore(chClient)
+	analyticsStore.Limiter = store.NewQueryLimiter(
+		int(utils.GetEnvInt64("QUERY_CONCURRENCY", 8)),
+		int(utils.GetEnvInt64("QUERY_CONCURRENCY_PER_PROJECT", 0)),
+		int(utils.GetEnvInt64("QUERY_QUEUE_SIZE", 32)),
+		utils.GetEnvDuration("QUERY_QUEUE_TIMEOUT", 5*time.Second),
+	)
+	traitsStore := store.NewTraitsStore(chClient)
+	groupStore := store.NewGroupStore(chClient)
+	audienceStore := store.NewAudienceStore(dbClient.DB, chClient)
+	suppressionStore := store.NewSuppressionStore(dbClient.DB, chClient)
+	blocklistStore := store.NewBlocklistStore(dbClient.DB)
+	samplingStore := store.NewSamplingStore(dbClient.DB)
+	webhookStore := store.NewWebhookStore(dbClient.DB)
+	destinationStore := store.NewDestinationStore(dbClient.DB)
+	jobStore := store.NewJobStore(dbClient.DB)
+	exportStore := store.NewExportStore(dbClient.DB)
+	scheduleStore := store.NewScheduleStore(dbClient.DB)
+	auditStore := store.NewAuditStore(dbClient.DB)
+	deletionStore := store.NewDeletionStore(dbClient.DB, chClient)
+	eventTypeStore := store.NewEventTypeStore(dbClient.DB)
+	goalStore := store.NewGoalStore(dbClient.DB, chClient)
+	experimentStore := store.NewExperimentStore(dbClient.DB)
+	projectStore := store.NewProjectStore(dbClient.DB)
+	writeKeyStore := store.NewWriteKeyStore(dbClient.DB)
+	refreshTokenStore := store.NewRefreshTokenStore(dbClient.DB, utils.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour))
+	passwordResetStore := store.NewPasswordResetStore(dbClient.DB, utils.GetEnvDuration("PASSWORD_RESET_TTL", time.Hour))
+	funnelStore := store.NewFunnelStore(dbClient.DB)
+	dashboardStore := store.NewDashboardStore(dbClient.DB)
+	reportStore := store.NewReportStore(dbClient.DB)
+	alertStore := store.NewAlertStore(dbClient.DB)
+	queryLogStore := store.NewQueryLogStore(chClient)
+	tableHealthStore := store.NewTableHealthStore(chClient)
+	partitionStore := store.NewPartitionStore(chClient)
+	baseCurrency := os.Getenv("BASE_CURRENCY")
+	if baseCurrency == "" {
+		baseCurrency = "USD"
+	}
+	settingsStore := store.NewProjectSettingsStore(dbClient.DB, int(utils.GetEnvInt64("RETENTION_MAX_DAYS", 0)), baseCurrency)
+	settingsStore.DefaultEventTypeRetention, err = store.ParseEventTypeRetentions(os.Getenv("RETENTION_EVENT_TYPES"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure event type retention")
+	}
+	adSpendStore := store.NewAdSpendStore(dbClient.DB, chClient)
+	exchangeRateStore := store.NewExchangeRateStore(dbClient.DB)
+	usageStore := store.NewUsageStore(dbClient.DB, chClient,
+		utils.GetEnvInt64("USAGE_MONTHLY_EVENT_QUOTA", 0),
+		float64(utils.GetEnvInt64("USAGE_SOFT_QUOTA_PERCENT", 80)))
+	if err := suppressionStore.Refresh(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load suppression list")
+	}
+	if err := blocklistStore.Refresh(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load blocklist")
+	}
+	if err := samplingStore.Refresh(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load sampling rules")
+	}
+	if err := webhookStore.Refresh(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load webhooks")
+	}
+	forwarder := destinations.NewForwarder(destinationStore,
+		int(utils.GetEnvInt64("DESTINATION_BATCH_SIZE", 100)),
+		int(utils.GetEnvInt64("DESTINATION_BUFFER_CAPACITY", 10000)),
+		utils.GetEnvDuration("DESTINATION_FLUSH_INTERVAL", 5*time.Second),
+	)
+	if err := forwarder.Refresh(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load destinations")
+	}
+	forwarder.Start()
+	if err := projectStore.Refresh(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load projects")
+	}
+	if err := writeKeyStore.Refresh(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load write keys")
+	}
+	if err := eventTypeStore.Refresh(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load event schemas")
+	}
+
+	geoIP, err := database.NewGeoIP()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load GeoIP database")
+	}
+	defer geoIP.Close()
+
+	loginLimiter := store.NewLoginLimiter(
+		store.NewAttemptCounter(redisClient.Client, dbClient.DB),
+		utils.GetEnvInt64("LOGIN_MAX_IP_FAILURES", 20),
+		utils.GetEnvInt64("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		utils.GetEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		utils.GetEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+	)
+	// Revocations are kept as long as the longest-lived access token, an
+	// impersonation token of at most an hour.
+	tokenRevocations := store.NewTokenRevocationStore(redisClient.Client, dbClient.DB, max(utils.AccessTokenTTL, time.Hour))
+	authHandlers := handlers.NewAuthHandlers(userStore, refreshTokenStore, passwordResetStore, loginLimiter, tokenRevocations, auditStore, mailer.FromEnv(), os.Getenv("PASSWORD_RESET_URL"))
+	sessionTTL := utils.GetEnvDuration("SESSION_TTL", 24*time.Hour)
+	sessionStore := store.NewSessionStore(redisClient.Client, sessionTTL)
+	emailChangeStore := store.NewEmailChangeStore(dbClient.DB, utils.GetEnvDuration("EMAIL_CHANGE_TTL", 24*time.Hour))
+	accountHandlers := handlers.NewAccountHandlers(authHandlers, emailChangeStore, auditStore, os.Getenv("EMAIL_VERIFY_URL"))
+	oauthProviders := oauth.ProvidersFromEnv(os.Getenv("PUBLIC_URL"))
+	log.Info().Msgf("OAuth providers enabled: %v", oauthProviders.Names())
+	oauthHandlers := handlers.NewOAuthHandlers(authHandlers, oauthProviders, os.Getenv("OAUTH_SUCCESS_URL"))
+	analyticsHandlers := handlers.NewAnalyticsHandlers(analyticsStore, suppressionStore, blocklistStore, usageStore, projectStore, eventTypeStore)
+	analyticsHandlers.Sampling = samplingStore
+	analyticsHandlers.Webhooks = webhookStore
+	analyticsHandlers.Forwarder = forwarder
+	switch mode := os.Getenv("BOT_FILTER_MODE"); mode {
+	case "", "tag":
+	case "drop":
+		analyticsHandlers.DropBots = true
+	default:
+		log.Fatal().Msgf("Unknown BOT_FILTER_MODE %q; use tag or drop", mode)
+	}
+	switch mode := os.Getenv("PRIVACY_SIGNAL_MODE"); mode {
+	case "", "off":
+	case models.PrivacySignalModeDrop, models.PrivacySignalModeAnonymize:
+		analyticsHandlers.PrivacySignalMode = mode
+	default:
+		log.Fatal().Msgf("Unknown PRIVACY_SIGNAL_MODE %q; use off, drop or anonymize", mode)
+	}
+	ipAnonymizer, err := utils.IPAnonymizerFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure IP anonymization")
+	}
+	enrich.SetIPAnonymizer(ipAnonymizer)
+	enrich.SetGeoIP(geoIP)
+	if names := os.Getenv("INGEST_ENRICHERS"); names != "" {
+		enrichers := []string{}
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" && name != "none" {
+				enrichers = append(enrichers, name)
+			}
+		}
+		if err := analyticsHandlers.SetEnrichers(enrichers); err != nil {
+			log.Fatal().Err(err).Msg("Invalid INGEST_ENRICHERS")
+		}
+		// Only the ipanonymize enricher applies IP_ANONYMIZE at ingestion.
+		if ipAnonymizer != nil && !slices.Contains(enrichers, "ipanonymize") {
+			log.Fatal().Msg("INGEST_ENRICHERS must include ipanonymize when IP_ANONYMIZE is set")
+		}
+		log.Info().Msgf("Ingestion enrichers: %v", enrichers)
+	}
+	if err := enrich.SetBotIPRanges(os.Getenv("BOT_IP_RANGES")); err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure bot detection")
+	}
+	if dedupeWindow := utils.GetEnvDuration("DEDUPE_WINDOW", 24*time.Hour); dedupeWindow > 0 {
+		analyticsHandlers.Deduper = store.NewEventDeduper(redisClient.Client, dedupeWindow)
+	}
+	ingestBatchSize := int(utils.GetEnvInt64("INGEST_BATCH_SIZE", 5000))
+	ingestFlushInterval := utils.GetEnvDuration("INGEST_FLUSH_INTERVAL", time.Second)
+	var (
+		eventWriter    *store.EventWriter
+		kafkaConsumers []*ingest.KafkaConsumer
+	)
+	switch mode := os.Getenv("INGEST_MODE"); mode {
+	case "direct":
+	case "kafka":
+		kafkaConfig, err := ingest.ConfigFromEnv()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to configure Kafka ingestion")
+		}
+		producer := ingest.NewKafkaProducer(kafkaConfig)
+		defer producer.Close()
+		analyticsHandlers.Queue = producer
+		for i := 0; i < int(utils.GetEnvInt64("KAFKA_CONSUMERS", 1)); i++ {
+			kafkaConsumers = append(kafkaConsumers, ingest.NewKafkaConsumer(kafkaConfig, analyticsStore, ingestBatchSize, ingestFlushInterval))
+		}
+		analyticsStore.Ingest.SetBufferDepth(func() int {
+			lag := 0
+			for _, consumer := range kafkaConsumers {
+				lag += consumer.Lag()
+			}
+			return lag
+		})
+	case "", "buffered":
+		eventWriter = store.NewEventWriter(analyticsStore, ingestBatchSize,
+			int(utils.GetEnvInt64("INGEST_BUFFER_CAPACITY", 100000)),
+			ingestFlushInterval,
+		)
+		// Events given up on were metered and marked against duplicates when
+		// they were accepted.
+		eventWriter.OnDrop = func(events []models.AnalyticsEvent) {
+			analyticsHandlers.ForgetEvents(context.Background(), events)
+			counts := map[string]int{}
+			for _, event := range events {
+				counts[event.ProjectID]++
+			}
+			for projectID, n := range counts {
+				if err := usageStore.ReleaseEvents(context.Background(), projectID, n); err != nil {
+					log.Error().Err(err).Msgf("Error releasing metered events for project %s", projectID)
+				}
+			}
+		}
+		eventWriter.Start()
+		analyticsHandlers.Queue = eventWriter
+	default:
+		log.Fatal().Msgf("Unknown INGEST_MODE %q; use buffered, direct or kafka", mode)
+	}
+	identifyHandlers := handlers.NewIdentifyHandlers(traitsStore)
+	groupHandlers := handlers.NewGroupHandlers(groupStore)
+	audienceHandlers := handlers.NewAudienceHandlers(audienceStore)
+	suppressionHandlers := handlers.NewSuppressionHandlers(suppressionStore)
+	exportHandlers := handlers.NewExportHandlers(exportStore)
+	exportHandlers.LinkTTL = utils.GetEnvDuration("EXPORT_LINK_TTL", 24*time.Hour)
+	if secret := cmp.Or(os.Getenv("EXPORT_LINK_SECRET"), os.Getenv("JWT_SECRET_KEY")); secret != "" {
+		exportHandlers.LinkSecret = []byte(secret)
+	}
+	privacyHandlers := handlers.NewPrivacyHandlers(exportStore, auditStore)
+	deletionHandlers := handlers.NewDeletionHandlers(deletionStore, auditStore)
+	eventTypeHandlers := handlers.NewEventTypeHandlers(eventTypeStore)
+	goalHandlers := handlers.NewGoalHandlers(goalStore)
+	funnelHandlers := handlers.NewFunnelHandlers(funnelStore, analyticsStore)
+	dashboardHandlers := handlers.NewDashboardHandlers(dashboardStore, funnelStore)
+	reportHandlers := handlers.NewReportHandlers(reportStore)
+	alertHandlers := handlers.NewAlertHandlers(alertStore)
+	queryLogHandlers := handlers.NewQueryLogHandlers(queryLogStore)
+	usageHandlers := handlers.NewUsageHandlers(usageStore, auditStore)
+	settingsHandlers := handlers.NewSettingsHandlers(settingsStore, auditStore)
+	billingHandlers := handlers.NewBillingHandlers(usageStore, exportStore)
+	ingestionHandlers := handlers.NewIngestionHandlers(analyticsStore)
+	tableHealthHandlers := handlers.NewTableHealthHandlers(tableHealthStore)
+	partitionHandlers := handlers.NewPartitionHandlers(partitionStore, auditStore)
+	blocklistHandlers := handlers.NewBlocklistHandlers(blocklistStore, auditStore)
+	samplingHandlers := handlers.NewSamplingHandlers(samplingStore, auditStore)
+	webhookHandlers := handlers.NewWebhookHandlers(webhookStore, auditStore)
+	destinationHandlers := handlers.NewDestinationHandlers(destinationStore, forwarder, auditStore)
+	reprocessHandlers := handlers.NewReprocessHandlers(exportStore, auditStore)
+	jobHandlers := handlers.NewJobHandlers(jobStore, auditStore)
+	adminHandlers := handlers.NewAdminHandlers(userStore, auditStore)
+	adSpendHandlers := handlers.NewAdSpendHandlers(adSpendStore, auditStore)
+	revenueHandlers := handlers.NewRevenueHandlers(analyticsStore, settingsStore, exchangeRateStore, auditStore)
+	experimentHandlers := handlers.NewExperimentHandlers(analyticsStore, goalStore, experimentStore)
+	projectHandlers := handlers.NewProjectHandlers(projectStore, writeKeyStore, auditStore)
+	liveHandlers := handlers.NewLiveHandlers(analyticsStore, utils.GetEnvDuration("LIVE_DASHBOARD_INTERVAL", 5*time.Second))
+
+	exportDir := os.Getenv("EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = filepath.Join(os.TempDir(), "mable-exports")
+	}
+	exportWorker, err := jobs.NewExportWorker(exportStore, exportDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize export worker")
+	}
+	exportWorker.Register(models.ExportKindPrivacy, ".json", jobs.PrivacyExport(userStore, traitsStore, analyticsStore))
+	exportWorker.Register(models.ExportKindPrivacyCSV, ".zip", jobs.PrivacyExportCSV(userStore, traitsStore, analyticsStore))
+	exportWorker.Register(models.ExportKindBillingCSV, ".csv", jobs.BillingExportCSV(usageStore))
+	exportWorker.Register(models.ExportKindBillingJSON, ".json", jobs.BillingExportJSON(usageStore))
+	exportWorker.Register(models.ExportKindReprocess, ".json", jobs.Reprocess(analyticsStore))
 
-	authHandlers := handlers.NewAuthHandlers(userStore)
-	analyticsHandlers := handlers.NewAnalyticsHandlers(analyticsStore)
+	queue := jobs.NewQueue(jobStore)
+	queue.Register(models.JobKindExport, 2*time.Hour, exportWorker.Handle)
+	queue.Register(models.JobKindDataDeletion, time.Minute, jobs.DataDeletion(deletionStore))
 
-	r := gin.Default()
+	scheduler := jobs.NewScheduler(scheduleStore, store.NewLeaderLock(dbClient.DB, schedulerLockKey))
+	scheduleErrs := []error{
+		scheduler.Register("audience_refresh", jobs.Every(utils.GetEnvDuration("AUDIENCE_REFRESH_INTERVAL", time.Hour)), jobs.RefreshAudiences(audienceStore)),
+		scheduler.RegisterLocal("suppression_refresh", jobs.Every(time.Minute), suppressionStore.Refresh),
+		scheduler.RegisterLocal("blocklist_refresh", jobs.Every(time.Minute), blocklistStore.Refresh),
+		scheduler.RegisterLocal("sampling_refresh", jobs.Every(time.Minute), samplingStore.Refresh),
+		scheduler.RegisterLocal("webhook_refresh", jobs.Every(time.Minute), webhookStore.Refresh),
+		scheduler.RegisterLocal("destination_refresh", jobs.Every(time.Minute), forwarder.Refresh),
+		scheduler.RegisterLocal("project_refresh", jobs.Every(time.Minute), projectStore.Refresh),
+		scheduler.RegisterLocal("write_key_refresh", jobs.Every(time.Minute), writeKeyStore.Refresh),
+		scheduler.RegisterLocal("event_schema_refresh", jobs.Every(time.Minute), eventTypeStore.Refresh),
+		scheduler.Register("billing_exports", jobs.Every(utils.GetEnvDuration("BILLING_EXPORT_CHECK_INTERVAL", time.Hour)), jobs.QueueBillingExports(exportStore)),
+		scheduler.Register("event_retention", jobs.Every(utils.GetEnvDuration("RETENTION_CHECK_INTERVAL", 24*time.Hour)), jobs.EnforceEventRetention(settingsStore, analyticsStore)),
+		scheduler.Register("alert_evaluation", jobs.Every(utils.GetEnvDuration("ALERT_CHECK_INTERVAL", time.Minute)), jobs.EvaluateAlerts(alertStore, analyticsStore)),
+		scheduler.Register("webhook_delivery", jobs.Every(utils.GetEnvDuration("WEBHOOK_DELIVERY_INTERVAL", 10*time.Second)), jobs.DeliverWebhooks(webhookStore, int(utils.GetEnvInt64("WEBHOOK_MAX_ATTEMPTS", 8)))),
+	}
+	if url := os.Getenv("EXCHANGE_RATES_URL"); url != "" {
+		scheduleErrs = append(scheduleErrs, scheduler.Register("exchange_rates", jobs.Every(utils.GetEnvDuration("EXCHANGE_RATES_INTERVAL", 24*time.Hour)), jobs.FetchExchangeRates(exchangeRateStore, url)))
+	}
+	sessionCleanup := jobs.CleanupTaskFromEnv("sessions", time.Hour, sessionTTL, jobs.PruneSessions(sessionStore))
+	sessionCleanup.Local = !sessionStore.Shared()
+	scheduleErrs = append(scheduleErrs, jobs.ScheduleCleanup(scheduler,
+		sessionCleanup,
+		jobs.CleanupTaskFromEnv("export_files", time.Hour, 7*24*time.Hour, jobs.PruneExportFiles(exportStore)),
+		jobs.CleanupTaskFromEnv("audit_log", 24*time.Hour, 365*24*time.Hour, jobs.PruneAuditLog(auditStore)),
+		jobs.CleanupTaskFromEnv("refresh_tokens", 24*time.Hour, 24*time.Hour, jobs.PruneRefreshTokens(refreshTokenStore)),
+		jobs.CleanupTaskFromEnv("password_resets", 24*time.Hour, 24*time.Hour, jobs.PrunePasswordResets(passwordResetStore)),
+		jobs.CleanupTaskFromEnv("email_changes", 24*time.Hour, 24*time.Hour, jobs.PruneEmailChanges(emailChangeStore)),
+		jobs.CleanupTaskFromEnv("login_attempts", time.Hour, 0, jobs.PruneLoginAttempts(loginLimiter)),
+		jobs.CleanupTaskFromEnv("revoked_tokens", time.Hour, 0, jobs.PruneRevokedTokens(tokenRevocations)),
+		jobs.CleanupTaskFromEnv("webhook_deliveries", 24*time.Hour, 30*24*time.Hour, jobs.PruneWebhookDeliveries(webhookStore)),
+	))
+	for _, err := range scheduleErrs {
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to register scheduled task")
+		}
+	}
+	scheduleHandlers := handlers.NewScheduleHandlers(scheduler)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	queue.Start(jobsCtx, int(utils.GetEnvInt64("JOB_WORKERS", 2)), 5*time.Second)
+	scheduler.Start(jobsCtx)
+	for _, consumer := range kafkaConsumers {
+		consumer.Start(jobsCtx)
+	}
+
+	r := gin.New()
+
+	r.Use(middleware.Tracing(), middleware.RequestLogger(), middleware.Recovery())
 	r.Use(middleware.CORSMiddleware())
+	r.GET("/.well-known/jwks.json", handlers.JWKS)
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"data": "Welcome to the Mable Analytics API!"})
 	})
 	api := r.Group("/api")
+	api.Use(middleware.ResolveProject())
 	{
 		// Authentication Endpoints (no authentication required)
 		api.POST("/signup", authHandlers.Signup)
 		api.POST("/login", authHandlers.Login)
 		api.POST("/logout", authHandlers.Logout)
+		api.POST("/refresh", authHandlers.Refresh)
+		api.POST("/forgot-password", authHandlers.ForgotPassword)
+		api.POST("/reset-password", authHandlers.ResetPassword)
+		api.GET("/auth/:provider", oauthHandlers.Start)
+		api.GET("/auth/:provider/callback", oauthHandlers.Callback)
+		api.POST("/account/email/verify", accountHandlers.VerifyEmail)
 		api.GET("/health", handlers.HealthCheck)
-		api.POST("/track", analyticsHandlers.TrackEvent)
+		// Signed export links authenticate by their signature
+		api.GET("/exports/:id/file", exportHandlers.DownloadSignedExport)
+		// Ingestion Endpoints (require a project write key)
+		ingest := api.Group("/")
+		ingest.Use(
+			middleware.WriteKeyRequired(writeKeyStore),
+			middleware.DecompressBody(utils.GetEnvInt64("INGEST_MAX_BODY_BYTES", 10<<20)),
+		)
+		{
+			ingest.POST("/track", analyticsHandlers.TrackEvent)
+			ingest.GET("/pixel.gif", analyticsHandlers.TrackPixel)
+			ingest.POST("/identify", identifyHandlers.Identify)
+			ingest.POST("/group", groupHandlers.Group)
+		}
 		api.GET("/", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"data": "Welcome to the Mable Analytics API!"})
 		})
 		// Protected Routes (require a valid JWT token)
 		protected := api.Group("/")
-		protected.Use(middleware.AuthRequired())
+		protected.Use(middleware.AuthRequired(tokenRevocations))
 		{
+			// Routes reading or changing the data of the project resolved from
+			// X-Project-ID require membership of that project.
+			projectAccess := middleware.ProjectAccess(projectStore)
+
 			protected.POST("/validate-user", authHandlers.GetUserByToken)
+			protected.GET("/audit-log", middleware.AdminRequired(), adminHandlers.ListAuditLog)
+			protected.PUT("/account/email", accountHandlers.ChangeEmail)
+			protected.PUT("/account/password", accountHandlers.ChangePassword)
+			protected.DELETE("/account", accountHandlers.DeleteAccount)
+			protected.GET("/usage", projectAccess, usageHandlers.GetUsage)
+			protected.GET("/settings", projectAccess, settingsHandlers.GetSettings)
+			protected.PUT("/settings", projectAccess, settingsHandlers.UpdateSettings)
+			protected.POST("/ad-spend", projectAccess, adSpendHandlers.UploadSpend)
+			protected.GET("/ad-spend", projectAccess, adSpendHandlers.ListSpend)
+			protected.GET("/traits/:userId", projectAccess, identifyHandlers.GetUserTraits)
+			protected.GET("/events", projectAccess, analyticsHandlers.ListEvents)
+			protected.GET("/ws/dashboard", projectAccess, liveHandlers.Dashboard)
 			// Example protected endpoint (e.g., get user profile)
 			protected.GET("/profile", func(c *gin.Context) {
 				userID := c.MustGet("user_id").(int)
@@ -82,13 +464,239 @@ func main() {
 			})
 
 			analyticsGroup := protected.Group("/stats")
+			analyticsGroup.Use(projectAccess, middleware.QueryLog(queryLogStore), middleware.MeterQueries(usageStore))
 			{
 				analyticsGroup.GET("/event-counts", analyticsHandlers.GetEventCountsOverTime)
 				analyticsGroup.GET("/average-event-duration", analyticsHandlers.GetAverageEventDuration)
 				analyticsGroup.GET("/average-custom-param", analyticsHandlers.GetAverageCustomEventParameter)
 				analyticsGroup.GET("/unique-users", analyticsHandlers.GetUniqueUsersOverTime)
 				analyticsGroup.GET("/top-paths", analyticsHandlers.GetTopNPagePaths)
+				analyticsGroup.GET("/top-referrers", analyticsHandlers.GetTopNReferrers)
+				analyticsGroup.GET("/entry-pages", analyticsHandlers.GetEntryPages)
+				analyticsGroup.GET("/exit-pages", analyticsHandlers.GetExitPages)
+				analyticsGroup.GET("/paths/flow", analyticsHandlers.GetPathFlow)
+				analyticsGroup.GET("/top-products", analyticsHandlers.GetTopProducts)
+				analyticsGroup.GET("/cart-abandonment", analyticsHandlers.GetCartAbandonment)
+				analyticsGroup.GET("/sessions", analyticsHandlers.GetSessionsOverTime)
+				analyticsGroup.GET("/sessions/summary", analyticsHandlers.GetSessionSummary)
+				analyticsGroup.GET("/realtime", liveHandlers.GetRealtime)
+				analyticsGroup.GET("/browsers", analyticsHandlers.GetBrowsers)
+				analyticsGroup.GET("/os", analyticsHandlers.GetOperatingSystems)
+				analyticsGroup.GET("/devices", analyticsHandlers.GetDeviceTypes)
+				analyticsGroup.GET("/retention", analyticsHandlers.GetRetention)
+				analyticsGroup.GET("/first-touch", analyticsHandlers.GetFirstTouchReport)
+				analyticsGroup.GET("/campaigns", analyticsHandlers.GetCampaigns)
+				analyticsGroup.GET("/attribution", analyticsHandlers.GetAttribution)
+				analyticsGroup.GET("/channels", analyticsHandlers.GetChannelsOverTime)
+				analyticsGroup.GET("/performance", analyticsHandlers.GetWebVitals)
+				analyticsGroup.GET("/outbound-clicks", analyticsHandlers.GetOutboundDestinations)
+				analyticsGroup.GET("/outbound-clicks/sources", analyticsHandlers.GetOutboundSources)
+				analyticsGroup.GET("/forms", analyticsHandlers.GetFormStats)
+				analyticsGroup.GET("/active-accounts", groupHandlers.GetActiveAccountsOverTime)
+				analyticsGroup.GET("/events-per-account", groupHandlers.GetEventsPerAccount)
+				analyticsGroup.GET("/goals", goalHandlers.GetGoalsReport)
+				analyticsGroup.GET("/funnel", funnelHandlers.GetFunnelReport)
+				analyticsGroup.GET("/funnel/:id", funnelHandlers.GetSavedFunnel)
+				analyticsGroup.GET("/experiments/:id", experimentHandlers.GetExperimentResults)
+				analyticsGroup.GET("/campaign-roi", adSpendHandlers.GetCampaignROI)
+				analyticsGroup.GET("/revenue", revenueHandlers.GetRevenue)
+
+			}
+
+			audiencesGroup := protected.Group("/audiences")
+			audiencesGroup.Use(projectAccess)
+			{
+				audiencesGroup.POST("", audienceHandlers.CreateAudience)
+				audiencesGroup.GET("", audienceHandlers.ListAudiences)
+				audiencesGroup.GET("/:id", audienceHandlers.GetAudience)
+				audiencesGroup.POST("/:id/refresh", audienceHandlers.RefreshAudience)
+				audiencesGroup.GET("/:id/members", audienceHandlers.GetAudienceMembers)
+				audiencesGroup.GET("/:id/history", audienceHandlers.GetAudienceHistory)
+				audiencesGroup.GET("/:id/export", audienceHandlers.ExportAudience)
+				audiencesGroup.POST("/:id/export/webhook", audienceHandlers.ExportAudienceWebhook)
+			}
+
+			suppressionsGroup := protected.Group("/suppressions")
+			suppressionsGroup.Use(projectAccess)
+			{
+				suppressionsGroup.POST("", suppressionHandlers.CreateSuppression)
+				suppressionsGroup.GET("", suppressionHandlers.ListSuppressions)
+				suppressionsGroup.DELETE("/:id", suppressionHandlers.RemoveSuppression)
+			}
 
+			projectsGroup := protected.Group("/projects")
+			{
+				projectsGroup.POST("", projectHandlers.CreateProject)
+				projectsGroup.GET("", projectHandlers.ListProjects)
+				projectsGroup.GET("/:id", projectHandlers.GetProject)
+				projectsGroup.PUT("/:id", projectHandlers.UpdateProject)
+				projectsGroup.DELETE("/:id", projectHandlers.DeleteProject)
+				projectsGroup.GET("/:id/members", projectHandlers.ListMembers)
+				projectsGroup.PUT("/:id/members", projectHandlers.SetMember)
+				projectsGroup.DELETE("/:id/members/:userId", projectHandlers.RemoveMember)
+				projectsGroup.GET("/:id/keys", projectHandlers.ListWriteKeys)
+				projectsGroup.POST("/:id/keys", projectHandlers.CreateWriteKey)
+				projectsGroup.POST("/:id/keys/:keyId/rotate", projectHandlers.RotateWriteKey)
+				projectsGroup.DELETE("/:id/keys/:keyId", projectHandlers.RevokeWriteKey)
+			}
+
+			blocklistGroup := protected.Group("/blocklist")
+			blocklistGroup.Use(projectAccess)
+			{
+				blocklistGroup.POST("", blocklistHandlers.CreateRule)
+				blocklistGroup.GET("", blocklistHandlers.ListRules)
+				blocklistGroup.DELETE("/:id", blocklistHandlers.DeleteRule)
+			}
+
+			samplingGroup := protected.Group("/sampling")
+			samplingGroup.Use(projectAccess)
+			{
+				samplingGroup.GET("", samplingHandlers.ListRules)
+				samplingGroup.PUT("", samplingHandlers.SetRule)
+				samplingGroup.DELETE("", samplingHandlers.DeleteRule)
+			}
+
+			webhooksGroup := protected.Group("/webhooks")
+			webhooksGroup.Use(projectAccess)
+			{
+				webhooksGroup.POST("", webhookHandlers.CreateWebhook)
+				webhooksGroup.GET("", webhookHandlers.ListWebhooks)
+				webhooksGroup.GET("/:id", webhookHandlers.GetWebhook)
+				webhooksGroup.PUT("/:id", webhookHandlers.UpdateWebhook)
+				webhooksGroup.DELETE("/:id", webhookHandlers.DeleteWebhook)
+				webhooksGroup.POST("/:id/rotate-secret", webhookHandlers.RotateSecret)
+				webhooksGroup.GET("/:id/deliveries", webhookHandlers.ListDeliveries)
+				webhooksGroup.POST("/:id/deliveries/:deliveryId/redeliver", webhookHandlers.Redeliver)
+			}
+
+			destinationsGroup := protected.Group("/destinations")
+			destinationsGroup.Use(projectAccess)
+			{
+				destinationsGroup.GET("/kinds", destinationHandlers.ListKinds)
+				destinationsGroup.POST("", destinationHandlers.CreateDestination)
+				destinationsGroup.GET("", destinationHandlers.ListDestinations)
+				destinationsGroup.GET("/:id", destinationHandlers.GetDestination)
+				destinationsGroup.PUT("/:id", destinationHandlers.UpdateDestination)
+				destinationsGroup.DELETE("/:id", destinationHandlers.DeleteDestination)
+			}
+
+			eventTypesGroup := protected.Group("/event-types")
+			eventTypesGroup.Use(projectAccess)
+			{
+				eventTypesGroup.POST("", eventTypeHandlers.CreateEventType)
+				eventTypesGroup.GET("", eventTypeHandlers.ListEventTypes)
+				eventTypesGroup.GET("/:name", eventTypeHandlers.GetEventType)
+				eventTypesGroup.PUT("/:name", eventTypeHandlers.UpdateEventType)
+				eventTypesGroup.POST("/:name/deprecate", eventTypeHandlers.DeprecateEventType)
+			}
+
+			goalsGroup := protected.Group("/goals")
+			goalsGroup.Use(projectAccess)
+			{
+				goalsGroup.POST("", goalHandlers.CreateGoal)
+				goalsGroup.GET("", goalHandlers.ListGoals)
+				goalsGroup.GET("/:id", goalHandlers.GetGoal)
+				goalsGroup.PUT("/:id", goalHandlers.UpdateGoal)
+				goalsGroup.DELETE("/:id", goalHandlers.DeleteGoal)
+			}
+
+			experimentsGroup := protected.Group("/experiments")
+			experimentsGroup.Use(projectAccess)
+			{
+				experimentsGroup.POST("", experimentHandlers.CreateExperiment)
+				experimentsGroup.GET("", experimentHandlers.ListExperiments)
+				experimentsGroup.GET("/:id", experimentHandlers.GetExperiment)
+				experimentsGroup.PUT("/:id", experimentHandlers.UpdateExperiment)
+				experimentsGroup.DELETE("/:id", experimentHandlers.DeleteExperiment)
+			}
+
+			funnelsGroup := protected.Group("/funnels")
+			funnelsGroup.Use(projectAccess)
+			{
+				funnelsGroup.POST("", funnelHandlers.CreateFunnel)
+				funnelsGroup.GET("", funnelHandlers.ListFunnels)
+				funnelsGroup.GET("/:id", funnelHandlers.GetFunnel)
+				funnelsGroup.PUT("/:id", funnelHandlers.UpdateFunnel)
+				funnelsGroup.DELETE("/:id", funnelHandlers.DeleteFunnel)
+			}
+
+			dashboardsGroup := protected.Group("/dashboards")
+			dashboardsGroup.Use(projectAccess)
+			{
+				dashboardsGroup.POST("", dashboardHandlers.CreateDashboard)
+				dashboardsGroup.GET("", dashboardHandlers.ListDashboards)
+				dashboardsGroup.GET("/:id", dashboardHandlers.GetDashboard)
+				dashboardsGroup.PUT("/:id", dashboardHandlers.UpdateDashboard)
+				dashboardsGroup.DELETE("/:id", dashboardHandlers.DeleteDashboard)
+				dashboardsGroup.POST("/:id/widgets", dashboardHandlers.AddWidget)
+				dashboardsGroup.PUT("/:id/widgets/order", dashboardHandlers.ReorderWidgets)
+				dashboardsGroup.PUT("/:id/widgets/:widgetId", dashboardHandlers.UpdateWidget)
+				dashboardsGroup.DELETE("/:id/widgets/:widgetId", dashboardHandlers.DeleteWidget)
+			}
+
+			reportsGroup := protected.Group("/reports")
+			reportsGroup.Use(projectAccess)
+			{
+				reportsGroup.POST("", reportHandlers.CreateReport)
+				reportsGroup.GET("", reportHandlers.ListReports)
+				reportsGroup.GET("/:id", reportHandlers.GetReport)
+				reportsGroup.PUT("/:id", reportHandlers.UpdateReport)
+				reportsGroup.DELETE("/:id", reportHandlers.DeleteReport)
+			}
+
+			alertsGroup := protected.Group("/alerts")
+			alertsGroup.Use(projectAccess)
+			{
+				alertsGroup.POST("", alertHandlers.CreateAlert)
+				alertsGroup.GET("", alertHandlers.ListAlerts)
+				alertsGroup.GET("/:id", alertHandlers.GetAlert)
+				alertsGroup.PUT("/:id", alertHandlers.UpdateAlert)
+				alertsGroup.DELETE("/:id", alertHandlers.DeleteAlert)
+				alertsGroup.GET("/:id/history", alertHandlers.GetAlertHistory)
+			}
+
+			protected.GET("/privacy/export", middleware.AdminRequired(), projectAccess, privacyHandlers.RequestExport)
+			protected.GET("/exports/:id", exportHandlers.GetExport)
+			protected.GET("/exports/:id/download", exportHandlers.DownloadExport)
+
+			adminGroup := protected.Group("/admin")
+			adminGroup.Use(middleware.AdminRequired())
+			{
+				adminGroup.POST("/deletions", deletionHandlers.CreateDeletion)
+				adminGroup.GET("/deletions", deletionHandlers.ListDeletions)
+				adminGroup.GET("/deletions/:id", deletionHandlers.GetDeletion)
+				adminGroup.GET("/query-log", queryLogHandlers.ListQueries)
+				adminGroup.GET("/query-log/summary", queryLogHandlers.SummarizeQueries)
+				adminGroup.GET("/quotas", usageHandlers.ListQuotas)
+				adminGroup.PUT("/quotas/:projectId", usageHandlers.SetQuota)
+				adminGroup.PUT("/projects/:projectId/retention-limit", settingsHandlers.SetRetentionLimit)
+				adminGroup.GET("/projects/:projectId/event-type-retention", settingsHandlers.ListEventTypeRetentions)
+				adminGroup.PUT("/projects/:projectId/event-type-retention/:eventType", settingsHandlers.SetEventTypeRetention)
+				adminGroup.DELETE("/projects/:projectId/event-type-retention/:eventType", settingsHandlers.DeleteEventTypeRetention)
+				adminGroup.GET("/exchange-rates", revenueHandlers.ListExchangeRates)
+				adminGroup.PUT("/exchange-rates", revenueHandlers.SetExchangeRates)
+				adminGroup.GET("/billing/usage", billingHandlers.GetBillingUsage)
+				adminGroup.POST("/billing/exports", billingHandlers.CreateBillingExport)
+				adminGroup.GET("/billing/exports", billingHandlers.ListBillingExports)
+				adminGroup.GET("/ingestion", ingestionHandlers.GetIngestionStats)
+				adminGroup.GET("/clickhouse/health", tableHealthHandlers.GetClickHouseHealth)
+				adminGroup.GET("/clickhouse/storage", tableHealthHandlers.GetStorageTiers)
+				adminGroup.GET("/clickhouse/tables/:table/partitions", partitionHandlers.ListPartitions)
+				adminGroup.POST("/clickhouse/tables/:table/optimize", partitionHandlers.OptimizePartitions)
+				adminGroup.POST("/clickhouse/tables/:table/drop-partitions", partitionHandlers.DropPartitions)
+				adminGroup.POST("/clickhouse/tables/:table/detach", partitionHandlers.DetachPartition)
+				adminGroup.POST("/clickhouse/tables/:table/attach", partitionHandlers.AttachPartition)
+				adminGroup.GET("/reprocess/enrichers", reprocessHandlers.ListEnrichers)
+				adminGroup.POST("/reprocess", reprocessHandlers.CreateReprocessJob)
+				adminGroup.GET("/reprocess", reprocessHandlers.ListReprocessJobs)
+				adminGroup.GET("/jobs", jobHandlers.ListJobs)
+				adminGroup.GET("/jobs/summary", jobHandlers.GetJobSummary)
+				adminGroup.GET("/jobs/:id", jobHandlers.GetJob)
+				adminGroup.POST("/jobs/:id/retry", jobHandlers.RetryJob)
+				adminGroup.GET("/schedules", scheduleHandlers.ListSchedules)
+				adminGroup.GET("/users", adminHandlers.ListUsers)
+				adminGroup.POST("/users/:id/impersonate", adminHandlers.ImpersonateUser)
+				adminGroup.GET("/audit-log", adminHandlers.ListAuditLog)
 			}
 		}
 	}
@@ -104,22 +712,35 @@ func main() {
 	}
 
 	go func() {
-		log.Printf("Go API server starting on http://localhost:%s", port)
+		log.Info().Msgf("Go API server starting on http://localhost:%s", port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Go API server failed to start: %v", err)
+			log.Fatal().Err(err).Msg("Go API server failed to start")
 		}
 	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	log.Info().Msg("Shutting down server...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Fatal().Err(err).Msg("Server forced to shutdown")
+	}
+	if eventWriter != nil {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancelFlush()
+		if err := eventWriter.Close(flushCtx); err != nil {
+			log.Error().Err(err).Msg("Failed to flush buffered events")
+		}
+	}
+	forwardCtx, cancelForward := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelForward()
+	if err := forwarder.Close(forwardCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to forward buffered events")
 	}
 
-	log.Println("Server exiting.")
+	log.Info().Msg("Server exiting.")
 }
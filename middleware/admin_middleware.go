@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"mabletask/api/logging"
+)
+
+// AdminRequired must run after AuthRequired and rejects non-admin users.
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("is_admin") {
+			logging.Ctx(c.Request.Context()).Warn().Msg("AdminRequired: Non-admin user denied access")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden: Admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
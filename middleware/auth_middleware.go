@@ -2,27 +2,26 @@ package middleware
 
 import (
 	"fmt"
-	"log"
 	"net/http"
-	"os"
+	"time"
 
+	"mabletask/api/logging"
+	"mabletask/api/store"
 	"mabletask/api/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRequired() gin.HandlerFunc {
+// AuthRequired rejects requests without a valid access token, and tokens
+// revoked by logout or a password change.
+func AuthRequired(revocations store.TokenRevocationStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		defaultToken := c.GetHeader("X-API-KEY")
-		if defaultToken == os.Getenv("AUTH_DEFAULT") {
-			c.Next()
-			return
-		}
+		ctx := c.Request.Context()
 		tokenString, err := c.Cookie("jwt_token")
 		if err != nil {
 			tokenString = c.GetHeader("Authorization")
 			if tokenString == "" {
-				log.Println("AuthRequired: No JWT token found in cookie or header")
+				logging.Ctx(ctx).Debug().Msg("AuthRequired: No JWT token found in cookie or header")
 				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No token provided"})
 				return
 			}
@@ -33,16 +32,59 @@ func AuthRequired() gin.HandlerFunc {
 		}
 		claims, err := utils.ValidateJWT(tokenString)
 		if err != nil {
-			log.Printf("AuthRequired: Invalid JWT token: %v", err)
+			logging.Ctx(ctx).Warn().Err(err).Msg("AuthRequired: Invalid JWT token")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid or expired token"})
 			return
 		}
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		revoked, err := revocations.IsRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+		if err != nil {
+			// Fail closed: a revoked token must not pass while the store is down.
+			logging.Ctx(ctx).Error().Err(err).Msg("AuthRequired: Failed to check token revocation")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+			return
+		}
+		if revoked {
+			logging.Ctx(ctx).Warn().Int("user_id", claims.UserID).Msg("AuthRequired: Revoked token used")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Token has been revoked"})
+			return
+		}
 
-		fmt.Println("claims", claims)
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Set("is_admin", claims.IsAdmin)
+		logging.Annotate(ctx, "user_id", claims.UserID)
+		if claims.ImpersonatorID != 0 {
+			c.Set("impersonator_id", claims.ImpersonatorID)
+			c.Header("X-Impersonated-By", fmt.Sprintf("%d", claims.ImpersonatorID))
+			logging.Annotate(ctx, "impersonator_id", claims.ImpersonatorID)
+			if !impersonationAllowed(c) {
+				logging.Ctx(ctx).Warn().Msg("AuthRequired: Write by impersonation session denied")
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden: Impersonation sessions are read-only"})
+				return
+			}
+		}
 
-		log.Printf("AuthRequired: User authenticated - ID: %d, Email: %s", claims.UserID, claims.Email)
+		logging.Ctx(ctx).Debug().Msg("AuthRequired: User authenticated")
 		c.Next()
 	}
 }
+
+// impersonationReadRoutes are non-GET routes that only read, and so stay
+// available to impersonation sessions.
+var impersonationReadRoutes = map[string]bool{
+	"/api/validate-user": true,
+}
+
+// impersonationAllowed reports whether an impersonation session may make the
+// request: reads only, so support staff cannot change a customer's data.
+func impersonationAllowed(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return impersonationReadRoutes[c.FullPath()]
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveProject sets "project_id" from the X-Project-ID header (or the
+// projectId query parameter), defaulting to models.DefaultProjectID.
+func ResolveProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID := c.GetHeader("X-Project-ID")
+		if projectID == "" {
+			projectID = c.Query("projectId")
+		}
+		if projectID == "" {
+			projectID = models.DefaultProjectID
+		}
+		if !utils.IsValidProjectID(projectID) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+		c.Set("project_id", projectID)
+		c.Request = c.Request.WithContext(store.WithProject(c.Request.Context(), projectID))
+		logging.Annotate(c.Request.Context(), "project_id", projectID)
+		c.Next()
+	}
+}
+
+// ProjectAccess must run after AuthRequired and rejects users who are not
+// members of the resolved project. Admins may access every project.
+func ProjectAccess(projects *store.ProjectStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("is_admin") {
+			c.Next()
+			return
+		}
+		projectID := c.GetString("project_id")
+		role, err := projects.MemberRole(c.Request.Context(), projectID, c.GetInt("user_id"))
+		if err != nil {
+			logging.Ctx(c.Request.Context()).Error().Err(err).Msg("ProjectAccess: Failed to check project membership")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check project access"})
+			return
+		}
+		if role == "" {
+			logging.Ctx(c.Request.Context()).Warn().Msg("ProjectAccess: User denied access to project")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden: No access to project"})
+			return
+		}
+		c.Set("project_role", role)
+		c.Next()
+	}
+}
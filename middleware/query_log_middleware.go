@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryLog must run after AuthRequired. It records every request it wraps in
+// the query audit log, including the "rows_returned" count set by the handler.
+// Entries are written in the background so logging never delays the response.
+func QueryLog(queryLogStore *store.QueryLogStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		c.Next()
+
+		entry := models.QueryLogEntry{
+			Timestamp:    started.UTC(),
+			UserID:       c.GetInt("user_id"),
+			UserEmail:    c.GetString("user_email"),
+			Endpoint:     c.FullPath(),
+			Params:       c.Request.URL.RawQuery,
+			Status:       c.Writer.Status(),
+			DurationMs:   time.Since(started).Milliseconds(),
+			RowsReturned: c.GetInt("rows_returned"),
+			IPAddress:    c.ClientIP(),
+		}
+		logger := logging.Ctx(c.Request.Context())
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := queryLogStore.Record(ctx, entry); err != nil {
+				logger.Error().Err(err).Msgf("QueryLog: failed to record query on %s", entry.Endpoint)
+			}
+		}()
+	}
+}
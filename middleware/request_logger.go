@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"mabletask/api/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRequestIDLength bounds the X-Request-ID accepted from clients and
+// proxies.
+const maxRequestIDLength = 128
+
+// RequestLogger gives every request an ID, taken from a valid X-Request-ID
+// header or generated, and echoes it in the X-Request-ID response header. The
+// request's context carries a logger with the request ID, method, route and
+// trace ID, to which AuthRequired and ResolveProject add the user and project;
+// handlers log through it with logging.Ctx. Once the request is handled it
+// logs an access line, at warn level for 4xx and error level for 5xx
+// responses. It must run after Tracing, for the trace ID.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		requestID := c.GetHeader("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Set("request_id", requestID)
+		c.Header("X-Request-ID", requestID)
+
+		fields := log.With().
+			Str("request_id", requestID).
+			Str("method", c.Request.Method).
+			Str("route", c.FullPath())
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			fields = fields.Str("trace_id", span.TraceID().String())
+		}
+		logger := fields.Logger()
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), &logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		var event *zerolog.Event
+		switch {
+		case status >= http.StatusInternalServerError:
+			event = logger.Error()
+		case status >= http.StatusBadRequest:
+			event = logger.Warn()
+		default:
+			event = logger.Info()
+		}
+		event.
+			Str("path", c.Request.URL.Path).
+			Int("status", status).
+			Dur("latency", time.Since(started)).
+			Int("size", c.Writer.Size()).
+			Str("client_ip", c.ClientIP())
+		if errs := c.Errors.String(); errs != "" {
+			event.Str("errors", errs)
+		}
+		event.Msg("Request handled")
+	}
+}
+
+// validRequestID reports whether id is a non-empty header value of printable
+// ASCII without spaces, short enough to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Recovery answers 500 to requests whose handler panicked, logging the panic
+// and stack with the request's fields. It must run after RequestLogger.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logging.Ctx(c.Request.Context()).Error().
+			Interface("panic", recovered).
+			Bytes("stack", debug.Stack()).
+			Msg("Handler panicked")
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
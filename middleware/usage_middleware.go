@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MeterQueries must run after ResolveProject. It counts every request it wraps
+// against the project's monthly query usage, in the background.
+func MeterQueries(usageStore *store.UsageStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		projectID := c.GetString("project_id")
+		logger := logging.Ctx(c.Request.Context())
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := usageStore.RecordQuery(ctx, projectID); err != nil {
+				logger.Error().Err(err).Msg("MeterQueries: failed to record query")
+			}
+		}()
+	}
+}
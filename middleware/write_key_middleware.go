@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"mabletask/api/logging"
+	"mabletask/api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WriteKeyRequired authenticates ingestion requests by a project write key,
+// sent in the X-Write-Key header, as the username of HTTP Basic auth or, for
+// clients that cannot set headers, in the writeKey query parameter. The key's
+// project replaces the one set by ResolveProject.
+func WriteKeyRequired(keys *store.WriteKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Write-Key")
+		if key == "" {
+			key, _, _ = c.Request.BasicAuth()
+		}
+		if key == "" {
+			key = c.Query("writeKey")
+		}
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No write key provided"})
+			return
+		}
+
+		projectID, ok := keys.Resolve(key)
+		if !ok {
+			logging.Ctx(c.Request.Context()).Warn().Msgf("WriteKeyRequired: Invalid write key from %s", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid or revoked write key"})
+			return
+		}
+		c.Set("project_id", projectID)
+		c.Request = c.Request.WithContext(store.WithProject(c.Request.Context(), projectID))
+		logging.Annotate(c.Request.Context(), "project_id", projectID)
+		c.Next()
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mabletask/api/store"
+	"mabletask/api/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+const testWriteKey = "wk_0123456789abcdef0123456789abcdef"
+
+// newWriteKeyRouter serves /track behind ResolveProject and WriteKeyRequired,
+// answering with the resolved project. testWriteKey belongs to project "shop".
+func newWriteKeyRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	mock.ExpectQuery(`SELECT key_hash, project_id, expires_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"key_hash", "project_id", "expires_at"}).
+			AddRow(utils.HashToken(testWriteKey), "shop", nil))
+	keys := store.NewWriteKeyStore(db)
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/track", ResolveProject(), WriteKeyRequired(keys), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("project_id"))
+	})
+	return r
+}
+
+func TestWriteKeyRequired(t *testing.T) {
+	r := newWriteKeyRouter(t)
+
+	tests := []struct {
+		name       string
+		header     string
+		query      string
+		basic      bool
+		projectID  string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "missing key", wantStatus: http.StatusUnauthorized},
+		{name: "unknown key", header: "wk_00000000000000000000000000000000", wantStatus: http.StatusUnauthorized},
+		{name: "header", header: testWriteKey, wantStatus: http.StatusOK, wantBody: "shop"},
+		{name: "basic auth", basic: true, wantStatus: http.StatusOK, wantBody: "shop"},
+		{name: "query parameter", query: "?writeKey=" + testWriteKey, wantStatus: http.StatusOK, wantBody: "shop"},
+		{name: "key overrides X-Project-ID", header: testWriteKey, projectID: "other", wantStatus: http.StatusOK, wantBody: "shop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/track"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Write-Key", tt.header)
+			}
+			if tt.basic {
+				req.SetBasicAuth(testWriteKey, "")
+			}
+			if tt.projectID != "" {
+				req.Header.Set("X-Project-ID", tt.projectID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("project = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// AdSpendEntry is one day of spend on a campaign, as uploaded. Campaign is
+// matched against the utm_campaign of visitors.
+type AdSpendEntry struct {
+	Campaign string  `json:"campaign"`
+	Date     string  `json:"date"`
+	Source   string  `json:"source,omitempty"`
+	Spend    float64 `json:"spend"`
+	Currency string  `json:"currency"`
+}
+
+// Validate reports the first problem with the entry, if any.
+func (e AdSpendEntry) Validate() error {
+	if e.Campaign == "" {
+		return errors.New("ad spend needs a campaign")
+	}
+	if _, err := time.Parse(DateFormat, e.Date); err != nil {
+		return fmt.Errorf("campaign %s: date must be YYYY-MM-DD", e.Campaign)
+	}
+	if e.Spend < 0 || math.IsNaN(e.Spend) || math.IsInf(e.Spend, 0) {
+		return fmt.Errorf("campaign %s: spend must be a non-negative number", e.Campaign)
+	}
+	if !currencyPattern.MatchString(e.Currency) {
+		return fmt.Errorf("campaign %s: currency must be an ISO 4217 code, e.g. EUR", e.Campaign)
+	}
+	return nil
+}
+
+type AdSpendRequest struct {
+	Entries []AdSpendEntry `json:"entries" binding:"required,min=1"`
+}
+
+type AdSpend struct {
+	AdSpendEntry
+	UploadedBy *int      `json:"uploadedBy,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// CampaignROIPoint is a campaign's spend and the revenue of the visitors it
+// acquired (first-touch utm_campaign) in one time bucket. ROAS is revenue per
+// unit of spend and is unset without spend.
+type CampaignROIPoint struct {
+	Time      time.Time `json:"time"`
+	Campaign  string    `json:"campaign"`
+	Spend     float64   `json:"spend"`
+	Revenue   float64   `json:"revenue"`
+	Purchases uint64    `json:"purchases"`
+	ROAS      *float64  `json:"roas,omitempty"`
+}
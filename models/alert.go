@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+const (
+	AlertMetricCount       = "count"
+	AlertMetricUniqueUsers = "unique_users"
+)
+
+// Alert conditions. Below and above compare the metric over the window with
+// the threshold; drop_pct and rise_pct compare it with the same window
+// CompareOffsetSeconds earlier and fire when it changed by at least
+// threshold percent.
+const (
+	AlertConditionBelow   = "below"
+	AlertConditionAbove   = "above"
+	AlertConditionDropPct = "drop_pct"
+	AlertConditionRisePct = "rise_pct"
+)
+
+const (
+	AlertStateOK     = "ok"
+	AlertStateFiring = "firing"
+)
+
+// AlertRequest defines an alert, e.g. "page_view count in the last hour below
+// 100" or "purchase count dropped 50% vs the same hour yesterday".
+// CompareOffsetSeconds defaults to a day for drop_pct and rise_pct; Enabled
+// defaults to true.
+type AlertRequest struct {
+	Name                 string   `json:"name" binding:"required,max=255"`
+	EventType            string   `json:"eventType" binding:"required"`
+	Metric               string   `json:"metric" binding:"omitempty,oneof=count unique_users"`
+	Condition            string   `json:"condition" binding:"required,oneof=below above drop_pct rise_pct"`
+	Threshold            *float64 `json:"threshold" binding:"required,gte=0"`
+	WindowSeconds        int      `json:"windowSeconds" binding:"required,min=60,max=2592000"`
+	CompareOffsetSeconds int      `json:"compareOffsetSeconds" binding:"omitempty,min=60,max=31536000"`
+	WebhookURL           string   `json:"webhookUrl" binding:"omitempty,url"`
+	Enabled              *bool    `json:"enabled"`
+}
+
+// Alert watches a metric of ProjectID's events.
+type Alert struct {
+	ID                   int        `json:"id"`
+	ProjectID            string     `json:"projectId"`
+	Name                 string     `json:"name"`
+	EventType            string     `json:"eventType"`
+	Metric               string     `json:"metric"`
+	Condition            string     `json:"condition"`
+	Threshold            float64    `json:"threshold"`
+	WindowSeconds        int        `json:"windowSeconds"`
+	CompareOffsetSeconds int        `json:"compareOffsetSeconds,omitempty"`
+	WebhookURL           string     `json:"webhookUrl,omitempty"`
+	Enabled              bool       `json:"enabled"`
+	State                string     `json:"state"`
+	LastValue            *float64   `json:"lastValue,omitempty"`
+	LastEvaluatedAt      *time.Time `json:"lastEvaluatedAt,omitempty"`
+	LastTriggeredAt      *time.Time `json:"lastTriggeredAt,omitempty"`
+	CreatedBy            *int       `json:"createdBy,omitempty"`
+	CreatedAt            time.Time  `json:"createdAt"`
+	UpdatedAt            time.Time  `json:"updatedAt"`
+}
+
+// Comparative reports whether the alert compares against an earlier window.
+func (a *Alert) Comparative() bool {
+	return a.Condition == AlertConditionDropPct || a.Condition == AlertConditionRisePct
+}
+
+// AlertEvent records a state transition of an alert: the value (and, for
+// comparative alerts, the baseline) that caused it and whether the webhook
+// accepted the notification.
+type AlertEvent struct {
+	ID          int64     `json:"id"`
+	AlertID     int       `json:"alertId"`
+	State       string    `json:"state"`
+	Value       float64   `json:"value"`
+	Baseline    *float64  `json:"baseline,omitempty"`
+	Notified    bool      `json:"notified"`
+	NotifyError string    `json:"notifyError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
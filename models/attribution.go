@@ -0,0 +1,26 @@
+package models
+
+// Attribution models crediting a conversion to the sessions leading up to it.
+const (
+	AttributionFirstTouch = "first_touch"
+	AttributionLastTouch  = "last_touch"
+	AttributionLinear     = "linear"
+)
+
+// AttributionCredit is the conversion credit of one channel or campaign.
+// Under the linear model credit is split between sessions, so Conversions may
+// be fractional.
+type AttributionCredit struct {
+	Value       string  `json:"value"`
+	Conversions float64 `json:"conversions"`
+	Share       float64 `json:"share"`
+}
+
+type AttributionReport struct {
+	Model           string              `json:"model"`
+	Dimension       string              `json:"dimension"`
+	ConversionEvent string              `json:"conversionEvent"`
+	LookbackDays    int                 `json:"lookbackDays"`
+	Conversions     uint64              `json:"conversions"`
+	Credits         []AttributionCredit `json:"credits"`
+}
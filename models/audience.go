@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// AudienceDefinition selects users who performed EventType at least MinCount
+// times within the last WithinDays days and whose traits match Traits.
+type AudienceDefinition struct {
+	EventType  string            `json:"eventType"`
+	MinCount   uint64            `json:"minCount"`
+	WithinDays int               `json:"withinDays"`
+	Traits     map[string]string `json:"traits,omitempty"`
+}
+
+type AudienceRequest struct {
+	Name       string             `json:"name" binding:"required"`
+	Definition AudienceDefinition `json:"definition"`
+}
+
+// Audience is a saved definition evaluated against ProjectID's events.
+type Audience struct {
+	ID         int                `json:"id"`
+	ProjectID  string             `json:"projectId"`
+	Name       string             `json:"name"`
+	Definition AudienceDefinition `json:"definition"`
+	CreatedBy  *int               `json:"createdBy,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt"`
+}
+
+type AudienceSnapshot struct {
+	ComputedAt time.Time `json:"computedAt"`
+	Size       uint64    `json:"size"`
+}
+
+type AudienceMembers struct {
+	AudienceID int       `json:"audienceId"`
+	ComputedAt time.Time `json:"computedAt"`
+	UserIDs    []string  `json:"userIds"`
+}
+
+type AudienceWebhookRequest struct {
+	URL string `json:"url" binding:"required,url,startswith=http"`
+}
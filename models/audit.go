@@ -0,0 +1,17 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	ActorID   *int            `json:"actorId,omitempty"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Details   json.RawMessage `json:"details"`
+	IPAddress string          `json:"ipAddress"`
+	UserAgent string          `json:"userAgent"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
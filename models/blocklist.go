@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	// BlocklistTypeIP matches the client IP against an address or CIDR range.
+	BlocklistTypeIP = "ip"
+	// BlocklistTypeUserAgent matches a case-insensitive user agent substring.
+	BlocklistTypeUserAgent = "user_agent"
+	// BlocklistTypeReferrer matches the referrer's domain and its subdomains.
+	BlocklistTypeReferrer = "referrer"
+)
+
+type BlocklistRuleRequest struct {
+	Type   string `json:"type" binding:"required,oneof=ip user_agent referrer"`
+	Value  string `json:"value" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+type BlocklistRule struct {
+	ID        int        `json:"id"`
+	ProjectID string     `json:"projectId"`
+	Type      string     `json:"type"`
+	Value     string     `json:"value"`
+	Reason    string     `json:"reason"`
+	Hits      int64      `json:"hits"`
+	LastHitAt *time.Time `json:"lastHitAt,omitempty"`
+	CreatedBy *int       `json:"createdBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// CampaignStats is one UTM source, medium and campaign combination with the
+// sessions it brought and how many of them converted.
+type CampaignStats struct {
+	Source         string  `json:"source"`
+	Medium         string  `json:"medium"`
+	Campaign       string  `json:"campaign"`
+	Sessions       uint64  `json:"sessions"`
+	Conversions    uint64  `json:"conversions"`
+	ConversionRate float64 `json:"conversionRate"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// CartAbandonmentPoint counts the carts started in one time bucket, by the
+// first add_to_cart, and how many were not followed by a purchase.
+type CartAbandonmentPoint struct {
+	Time            time.Time `json:"time"`
+	Carts           uint64    `json:"carts"`
+	Abandoned       uint64    `json:"abandoned"`
+	AbandonmentRate float64   `json:"abandonmentRate"`
+}
+
+// CartAbandonmentReport trends cart abandonment over a range. Without a
+// window a cart is a session and converts on a purchase later in the session;
+// with WindowSeconds it is a visitor's and converts on a purchase within the
+// window, in any session.
+type CartAbandonmentReport struct {
+	Interval        string                 `json:"interval"`
+	WindowSeconds   int64                  `json:"windowSeconds,omitempty"`
+	Carts           uint64                 `json:"carts"`
+	Abandoned       uint64                 `json:"abandoned"`
+	AbandonmentRate float64                `json:"abandonmentRate"`
+	Series          []CartAbandonmentPoint `json:"series"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ChannelCount is the number of sessions that started in one time bucket from
+// one acquisition channel.
+type ChannelCount struct {
+	Time     time.Time `json:"time"`
+	Channel  string    `json:"channel"`
+	Sessions uint64    `json:"sessions"`
+}
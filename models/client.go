@@ -0,0 +1,12 @@
+package models
+
+// ClientCount is one browser, browser version, operating system or device
+// type with its events and visitors, and its share of all visitors in the
+// range.
+type ClientCount struct {
+	Value    string  `json:"value"`
+	Version  string  `json:"version,omitempty"`
+	Events   uint64  `json:"events"`
+	Visitors uint64  `json:"visitors"`
+	Share    float64 `json:"share"`
+}
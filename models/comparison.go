@@ -0,0 +1,12 @@
+package models
+
+// ComparedSeries is a time series with the series of the range it is compared
+// with. Comparison points are placed on the buckets of Current they line up
+// with, and carry their own bucket as comparedTime.
+type ComparedSeries[T any] struct {
+	Compare             string `json:"compare"`
+	ComparisonStartDate string `json:"comparisonStartDate"`
+	ComparisonEndDate   string `json:"comparisonEndDate"`
+	Current             []T    `json:"current"`
+	Comparison          []T    `json:"comparison"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+const (
+	WidgetTypeMetric = "metric"
+	WidgetTypeSeries = "series"
+	WidgetTypeFunnel = "funnel"
+	WidgetTypeTable  = "table"
+)
+
+// WidgetQuery is the stats query a widget renders: the /api/stats endpoint
+// (e.g. "event-counts") and its query parameters. Funnel widgets reference a
+// saved funnel instead.
+type WidgetQuery struct {
+	Endpoint string            `json:"endpoint" binding:"required,oneof=event-counts average-event-duration average-custom-param unique-users top-paths active-accounts events-per-account goals funnel"`
+	Params   map[string]string `json:"params,omitempty"`
+	FunnelID int               `json:"funnelId,omitempty"`
+}
+
+// WidgetLayout places a widget on the dashboard grid.
+type WidgetLayout struct {
+	X int `json:"x" binding:"min=0"`
+	Y int `json:"y" binding:"min=0"`
+	W int `json:"w" binding:"min=0"`
+	H int `json:"h" binding:"min=0"`
+}
+
+type DashboardRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type WidgetRequest struct {
+	Type     string       `json:"type" binding:"required,oneof=metric series funnel table"`
+	Title    string       `json:"title" binding:"required"`
+	Query    WidgetQuery  `json:"query"`
+	Position *int         `json:"position,omitempty" binding:"omitempty,min=0"`
+	Layout   WidgetLayout `json:"layout"`
+}
+
+// ReorderWidgetsRequest lists every widget ID of a dashboard in display order.
+type ReorderWidgetsRequest struct {
+	WidgetIDs []int `json:"widgetIds" binding:"required"`
+}
+
+type DashboardWidget struct {
+	ID          int          `json:"id"`
+	DashboardID int          `json:"dashboardId"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Query       WidgetQuery  `json:"query"`
+	Position    int          `json:"position"`
+	Layout      WidgetLayout `json:"layout"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+}
+
+type Dashboard struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	CreatedBy   *int              `json:"createdBy,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	Widgets     []DashboardWidget `json:"widgets,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	DeletionStatusPending   = "pending"
+	DeletionStatusRunning   = "running"
+	DeletionStatusCompleted = "completed"
+	DeletionStatusFailed    = "failed"
+)
+
+type DeletionRequest struct {
+	// ProjectID is the project whose events are deleted, set from the
+	// caller's project rather than the body.
+	ProjectID string `json:"-"`
+	Type      string `json:"type" binding:"required,oneof=user anonymous session"`
+	SubjectID string `json:"subjectId" binding:"required"`
+}
+
+type DataDeletion struct {
+	ID          int        `json:"id"`
+	ProjectID   string     `json:"projectId"`
+	Type        string     `json:"type"`
+	SubjectID   string     `json:"subjectId"`
+	MutationID  string     `json:"mutationId"`
+	Status      string     `json:"status"`
+	PartsToDo   int64      `json:"partsToDo"`
+	FailReason  string     `json:"failReason,omitempty"`
+	RequestedBy *int       `json:"requestedBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Kinds of Destination.
+const (
+	DestinationKindWebhook = "webhook"
+	DestinationKindSegment = "segment"
+	DestinationKindGA4     = "ga4"
+)
+
+// DestinationRequest sets up forwarding of a project's events to a
+// third-party tool. Config depends on Kind; an empty EventTypes forwards all
+// events. Enabled defaults to true.
+type DestinationRequest struct {
+	Name       string          `json:"name" binding:"required,max=255"`
+	Kind       string          `json:"kind" binding:"required,oneof=webhook segment ga4"`
+	Config     json.RawMessage `json:"config" binding:"required"`
+	EventTypes []string        `json:"eventTypes" binding:"max=50,dive,required,max=128"`
+	Enabled    *bool           `json:"enabled"`
+}
+
+// Destination forwards the recorded events of ProjectID to an external tool.
+// Secret fields of Config are hidden when it is returned. EventsForwarded,
+// LastSuccessAt and LastError describe recent forwarding.
+type Destination struct {
+	ID              int             `json:"id"`
+	ProjectID       string          `json:"projectId"`
+	Name            string          `json:"name"`
+	Kind            string          `json:"kind"`
+	Config          json.RawMessage `json:"config"`
+	EventTypes      []string        `json:"eventTypes"`
+	Enabled         bool            `json:"enabled"`
+	EventsForwarded int64           `json:"eventsForwarded"`
+	LastSuccessAt   *time.Time      `json:"lastSuccessAt,omitempty"`
+	LastError       string          `json:"lastError,omitempty"`
+	LastErrorAt     *time.Time      `json:"lastErrorAt,omitempty"`
+	CreatedBy       *int            `json:"createdBy,omitempty"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+}
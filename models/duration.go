@@ -0,0 +1,9 @@
+package models
+
+// DurationPercentiles are percentiles of event durations in milliseconds.
+type DurationPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// First-class event types whose eventData follows a typed payload. Events of
+// any other type are accepted with free-form eventData.
+const (
+	EventTypePageView  = "page_view"
+	EventTypeAddToCart = "add_to_cart"
+	EventTypePurchase  = "purchase"
+	EventTypeSearch    = "search"
+)
+
+// PageViewPayload is the eventData of a page_view event, whose page is the
+// event's PagePath.
+type PageViewPayload struct {
+	Title string `json:"title,omitempty"`
+}
+
+// AddToCartPayload is the eventData of an add_to_cart event. The added items
+// are the event's Products.
+type AddToCartPayload struct {
+	CartID string `json:"cartId,omitempty"`
+}
+
+// PurchasePayload is the eventData of a purchase event. Revenue is the order
+// total in Currency; the purchased items are the event's Products.
+type PurchasePayload struct {
+	OrderID  string  `json:"orderId,omitempty"`
+	Revenue  float64 `json:"revenue"`
+	Currency string  `json:"currency"`
+	Tax      float64 `json:"tax,omitempty"`
+	Shipping float64 `json:"shipping,omitempty"`
+	Coupon   string  `json:"coupon,omitempty"`
+}
+
+// SearchPayload is the eventData of a search event.
+type SearchPayload struct {
+	Query        string `json:"query"`
+	ResultsCount *int   `json:"resultsCount,omitempty"`
+}
+
+// PageViewPayload decodes the eventData of a page_view event.
+func (e *AnalyticsEvent) PageViewPayload() (PageViewPayload, error) {
+	var p PageViewPayload
+	if err := decodePayload(e.EventData, &p); err != nil {
+		return p, err
+	}
+	if e.PagePath == "" {
+		return p, errors.New("page_view needs a pagePath")
+	}
+	return p, nil
+}
+
+// AddToCartPayload decodes the eventData of an add_to_cart event.
+func (e *AnalyticsEvent) AddToCartPayload() (AddToCartPayload, error) {
+	var p AddToCartPayload
+	if err := decodePayload(e.EventData, &p); err != nil {
+		return p, err
+	}
+	if len(e.Products) == 0 {
+		return p, errors.New("add_to_cart needs at least one product")
+	}
+	return p, nil
+}
+
+// PurchasePayload decodes the eventData of a purchase event.
+func (e *AnalyticsEvent) PurchasePayload() (PurchasePayload, error) {
+	var p PurchasePayload
+	if err := decodePayload(e.EventData, &p); err != nil {
+		return p, err
+	}
+	if p.Revenue < 0 || math.IsNaN(p.Revenue) || math.IsInf(p.Revenue, 0) {
+		return p, errors.New("purchase revenue must be a non-negative number")
+	}
+	if !currencyPattern.MatchString(p.Currency) {
+		return p, errors.New("purchase needs an ISO 4217 currency, e.g. EUR")
+	}
+	if p.Tax < 0 || p.Shipping < 0 {
+		return p, errors.New("purchase tax and shipping must not be negative")
+	}
+	return p, nil
+}
+
+// SearchPayload decodes the eventData of a search event.
+func (e *AnalyticsEvent) SearchPayload() (SearchPayload, error) {
+	var p SearchPayload
+	if err := decodePayload(e.EventData, &p); err != nil {
+		return p, err
+	}
+	if p.Query == "" {
+		return p, errors.New("search needs a query")
+	}
+	if p.ResultsCount != nil && *p.ResultsCount < 0 {
+		return p, errors.New("search resultsCount must not be negative")
+	}
+	return p, nil
+}
+
+// validatePayload checks the eventData of first-class event types.
+func (e *AnalyticsEvent) validatePayload() error {
+	var err error
+	switch e.EventType {
+	case EventTypePageView:
+		_, err = e.PageViewPayload()
+	case EventTypeAddToCart:
+		_, err = e.AddToCartPayload()
+	case EventTypePurchase:
+		_, err = e.PurchasePayload()
+	case EventTypeSearch:
+		_, err = e.SearchPayload()
+	case EventTypeWebVital:
+		_, err = e.WebVitalPayload()
+	case EventTypeOutboundClick:
+		_, err = e.OutboundClickPayload()
+	case EventTypeFormStart, EventTypeFormSubmit, EventTypeFormAbandon:
+		_, err = e.FormPayload()
+	case EventTypeExperimentExposure:
+		_, err = e.ExperimentExposurePayload()
+	}
+	return err
+}
+
+func decodePayload(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid eventData: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package models
+
+// EntryPage is a page sessions started on, with its share of all sessions.
+type EntryPage struct {
+	PagePath string  `json:"pagePath"`
+	Entries  uint64  `json:"entries"`
+	Share    float64 `json:"share"`
+}
+
+// ExitPage is a page sessions ended on. ExitRate is the share of the page's
+// views that were the last of their session.
+type ExitPage struct {
+	PagePath string  `json:"pagePath"`
+	Exits    uint64  `json:"exits"`
+	Views    uint64  `json:"views"`
+	ExitRate float64 `json:"exitRate"`
+}
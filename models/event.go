@@ -2,26 +2,169 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
+// TimestampFormat is RFC3339 with a fixed millisecond fraction, matching the
+// DateTime64(3) precision of the analytics_events.timestamp column.
+const TimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// DateFormat is the layout of calendar dates in requests and reports.
+const DateFormat = "2006-01-02"
+
+// Device types of AnalyticsEvent.DeviceType.
+const (
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeTablet  = "tablet"
+	DeviceTypeBot     = "bot"
+)
+
+// Acquisition channels of AnalyticsEvent.Channel, classified from the referrer.
+const (
+	ChannelDirect   = "direct"
+	ChannelSearch   = "search"
+	ChannelSocial   = "social"
+	ChannelReferral = "referral"
+)
+
+// DefaultProjectID is the project (site) events and queries belong to when the
+// caller does not name one.
+const DefaultProjectID = "default"
+
 type AnalyticsEvent struct {
-	EventID    string          `json:"eventId"`
-	EventType  string          `json:"eventType"`
-	UserID     string          `json:"userId"`
-	SessionID  string          `json:"sessionId"`
-	Timestamp  time.Time       `json:"timestamp"`
-	PagePath   string          `json:"pagePath"`
-	Referrer   string          `json:"referrer"`
-	UserAgent  string          `json:"userAgent"`
-	IPAddress  string          `json:"ipAddress"`
-	DurationMs int64           `json:"durationMs"`
-	Products   json.RawMessage `json:"products,omitempty"`
-	Location   string          `json:"location,omitempty"`
-	EventData  json.RawMessage `json:"eventData,omitempty"`
+	EventID    string            `json:"eventId"`
+	EventType  string            `json:"eventType"`
+	UserID     string            `json:"userId"`
+	SessionID  string            `json:"sessionId"`
+	Timestamp  time.Time         `json:"timestamp"`
+	PagePath   string            `json:"pagePath"`
+	Referrer   string            `json:"referrer"`
+	UserAgent  string            `json:"userAgent"`
+	IPAddress  string            `json:"ipAddress"`
+	DurationMs int64             `json:"durationMs"`
+	Products   []ProductLineItem `json:"products,omitempty"`
+	// Experiments lists the A/B experiment variants the visitor was assigned.
+	Experiments ExperimentAssignments `json:"experiments,omitempty"`
+	Location    string                `json:"location,omitempty"`
+	EventData   json.RawMessage       `json:"eventData,omitempty"`
+	GroupID     string                `json:"groupId,omitempty"`
+	// AnonymousID identifies a visitor before (or without) a known UserID.
+	AnonymousID string `json:"anonymousId,omitempty"`
+	// ProjectID is the site the event was tracked for. It is set by the server
+	// from the request, never taken from the event body.
+	ProjectID string `json:"projectId,omitempty"`
+	// Country (ISO 3166-1 alpha-2), DeviceType (desktop, mobile, tablet, bot),
+	// Browser with its major BrowserVersion, and OS describe the client and
+	// are used to segment stats. The client fields are parsed from UserAgent
+	// at ingestion when it is known. Country,
+	// Region (ISO 3166-2 subdivision) and City are resolved from the client IP
+	// at ingestion when a GeoIP database is configured.
+	Country        string `json:"country,omitempty"`
+	Region         string `json:"region,omitempty"`
+	City           string `json:"city,omitempty"`
+	DeviceType     string `json:"deviceType,omitempty"`
+	Browser        string `json:"browser,omitempty"`
+	BrowserVersion string `json:"browserVersion,omitempty"`
+	OS             string `json:"os,omitempty"`
+
+	// UTM campaign parameters. They may be sent as event fields; otherwise
+	// they are parsed from the query string of PagePath at ingestion.
+	UTMSource   string `json:"utmSource,omitempty"`
+	UTMMedium   string `json:"utmMedium,omitempty"`
+	UTMCampaign string `json:"utmCampaign,omitempty"`
+	UTMTerm     string `json:"utmTerm,omitempty"`
+	UTMContent  string `json:"utmContent,omitempty"`
+
+	// Channel is the acquisition channel (direct, search, social or
+	// referral) classified from Referrer at ingestion.
+	Channel string `json:"channel,omitempty"`
+
+	// IsBot marks events of known bots and crawlers, detected at ingestion
+	// from the user agent and client IP. Stats leave them out by default.
+	IsBot bool `json:"isBot,omitempty"`
+
+	// SampleRate is the share of the project's events of this type kept by
+	// ingestion sampling, set at ingestion; stats count each event as
+	// 1 / SampleRate events. 0 is stored as 1.
+	SampleRate float64 `json:"sampleRate,omitempty"`
+
+	// SchemaErrors lists how eventData fails the JSON Schema of a lenient
+	// event type. It is set at ingestion; empty means valid or unchecked.
+	SchemaErrors []string `json:"schemaErrors,omitempty"`
+
+	// DedupeID is an SDK-chosen key that stays the same when the event is
+	// sent again. Events repeating a recent DedupeID, or a client-generated
+	// EventID, of the same project are dropped at ingestion. It is not
+	// persisted.
+	DedupeID string `json:"dedupeId,omitempty"`
+
+	// ClientTimestamp is the event time as reported by the SDK, before skew
+	// correction. SentAt is when the SDK sent the batch and is only used to
+	// compute clock skew; it is not persisted.
+	ClientTimestamp *time.Time `json:"clientTimestamp,omitempty"`
+	SentAt          *time.Time `json:"sentAt,omitempty"`
+}
+
+// MaxDedupeIDLength bounds AnalyticsEvent.DedupeID.
+const MaxDedupeIDLength = 128
+
+// Validate reports the first problem with the event's content, if any: an
+// overlong dedupeId, an invalid product line item or experiment assignment,
+// or eventData not matching the typed payload of a first-class event type
+// such as purchase.
+func (e *AnalyticsEvent) Validate() error {
+	if len(e.DedupeID) > MaxDedupeIDLength {
+		return fmt.Errorf("dedupeId must be at most %d characters", MaxDedupeIDLength)
+	}
+	for _, p := range e.Products {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, a := range e.Experiments {
+		if err := a.Validate(); err != nil {
+			return err
+		}
+	}
+	return e.validatePayload()
+}
+
+// Anonymize strips every field that could identify the subject.
+func (e *AnalyticsEvent) Anonymize() {
+	e.UserID = ""
+	e.AnonymousID = ""
+	e.SessionID = ""
+	e.IPAddress = ""
+	e.UserAgent = ""
+}
+
+// MarshalJSON always renders the timestamp with millisecond precision so that
+// events sharing the same second keep a stable, comparable ordering for clients.
+func (e AnalyticsEvent) MarshalJSON() ([]byte, error) {
+	type event AnalyticsEvent
+	out := struct {
+		event
+		Timestamp       string  `json:"timestamp"`
+		ClientTimestamp *string `json:"clientTimestamp,omitempty"`
+	}{
+		event:     event(e),
+		Timestamp: e.Timestamp.UTC().Format(TimestampFormat),
+	}
+	if e.ClientTimestamp != nil {
+		ts := e.ClientTimestamp.UTC().Format(TimestampFormat)
+		out.ClientTimestamp = &ts
+	}
+	return json.Marshal(out)
 }
 
 type TopPathResult struct {
 	PagePath string `json:"pagePath"`
 	Count    uint64 `json:"count"`
 }
+
+type TopReferrerResult struct {
+	Referrer string `json:"referrer"` // Referring host, without www.
+	Count    uint64 `json:"count"`
+}
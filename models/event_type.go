@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Schema modes decide what happens to events whose eventData does not match
+// the JSON Schema of their type: strict rejects them, lenient records them
+// with their SchemaErrors.
+const (
+	SchemaModeStrict  = "strict"
+	SchemaModeLenient = "lenient"
+)
+
+// EventProperty describes a property expected in an event's eventData.
+type EventProperty struct {
+	Name        string `json:"name" binding:"required"`
+	Type        string `json:"type" binding:"required,oneof=string number boolean object array"`
+	Required    bool   `json:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+type EventTypeRequest struct {
+	Name               string          `json:"name" binding:"required,max=128"`
+	DisplayName        string          `json:"displayName"`
+	Category           string          `json:"category"`
+	Description        string          `json:"description"`
+	ExpectedProperties []EventProperty `json:"expectedProperties" binding:"dive"`
+	// Schema is a JSON Schema (draft 2020-12 unless it says otherwise) the
+	// eventData of events of the type must match; empty disables validation.
+	Schema     json.RawMessage `json:"schema,omitempty"`
+	SchemaMode string          `json:"schemaMode" binding:"omitempty,oneof=strict lenient"`
+}
+
+// EventValidationError reports how the eventData of the event at Index of an
+// ingestion batch fails the schema of its type. Rejected events, of strict
+// types, were not recorded.
+type EventValidationError struct {
+	Index     int      `json:"index"`
+	EventType string   `json:"eventType"`
+	Rejected  bool     `json:"rejected"`
+	Errors    []string `json:"errors"`
+}
+
+type DeprecateEventTypeRequest struct {
+	Note string `json:"note"`
+}
+
+type EventType struct {
+	Name               string          `json:"name"`
+	DisplayName        string          `json:"displayName"`
+	Category           string          `json:"category"`
+	Description        string          `json:"description"`
+	ExpectedProperties []EventProperty `json:"expectedProperties"`
+	Schema             json.RawMessage `json:"schema,omitempty"`
+	SchemaMode         string          `json:"schemaMode"`
+	Deprecated         bool            `json:"deprecated"`
+	DeprecationNote    string          `json:"deprecationNote,omitempty"`
+	DeprecatedAt       *time.Time      `json:"deprecatedAt,omitempty"`
+	CreatedAt          time.Time       `json:"createdAt"`
+	UpdatedAt          time.Time       `json:"updatedAt"`
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// EventTypeExperimentExposure records that the visitor was shown a variant
+// of an A/B experiment. It counts as an assignment of that variant.
+const EventTypeExperimentExposure = "experiment_exposure"
+
+// ExperimentAssignment records that the visitor saw Variant of an A/B
+// experiment when the event was tracked.
+type ExperimentAssignment struct {
+	ID      string `json:"id"`
+	Variant string `json:"variant"`
+}
+
+func (a ExperimentAssignment) Validate() error {
+	if a.ID == "" || a.Variant == "" {
+		return errors.New("experiment assignment needs an id and a variant")
+	}
+	return nil
+}
+
+// ExperimentAssignments are the experiments of an event. Besides a list of
+// assignments they may be sent as a map of experiment id to variant, e.g.
+// {"checkout-button": "green"}.
+type ExperimentAssignments []ExperimentAssignment
+
+func (a *ExperimentAssignments) UnmarshalJSON(data []byte) error {
+	var byID map[string]string
+	if err := json.Unmarshal(data, &byID); err == nil {
+		ids := make([]string, 0, len(byID))
+		for id := range byID {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		assignments := make(ExperimentAssignments, 0, len(ids))
+		for _, id := range ids {
+			assignments = append(assignments, ExperimentAssignment{ID: id, Variant: byID[id]})
+		}
+		*a = assignments
+		return nil
+	}
+	var list []ExperimentAssignment
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("experiments must be a list of {id, variant} or a map of experiment id to variant")
+	}
+	*a = list
+	return nil
+}
+
+// ExperimentExposurePayload is the eventData of an experiment_exposure event.
+type ExperimentExposurePayload struct {
+	ExperimentID string `json:"experimentId"`
+	Variant      string `json:"variant"`
+}
+
+// ExperimentExposurePayload decodes the eventData of an experiment_exposure
+// event.
+func (e *AnalyticsEvent) ExperimentExposurePayload() (ExperimentExposurePayload, error) {
+	var p ExperimentExposurePayload
+	if err := decodePayload(e.EventData, &p); err != nil {
+		return p, err
+	}
+	if p.ExperimentID == "" || p.Variant == "" {
+		return p, errors.New("experiment_exposure needs an experimentId and a variant")
+	}
+	return p, nil
+}
+
+// Lifecycle states of an experiment definition.
+const (
+	ExperimentDraft     = "draft"
+	ExperimentRunning   = "running"
+	ExperimentCompleted = "completed"
+)
+
+// ExperimentRequest defines an A/B experiment. ID is the experiment id sent
+// on events and cannot change once created. GoalID and Control are the
+// defaults of the experiment's results.
+type ExperimentRequest struct {
+	ID          string   `json:"id" binding:"required,max=128"`
+	Name        string   `json:"name" binding:"required,max=255"`
+	Description string   `json:"description"`
+	Variants    []string `json:"variants" binding:"required,min=2,dive,required,max=128"`
+	Control     string   `json:"control" binding:"max=128"`
+	GoalID      *int     `json:"goalId" binding:"omitempty,min=1"`
+	Status      string   `json:"status" binding:"omitempty,oneof=draft running completed"`
+}
+
+// Validate checks that the variants are distinct and include the control.
+func (r ExperimentRequest) Validate() error {
+	seen := make(map[string]bool, len(r.Variants))
+	for _, v := range r.Variants {
+		if seen[v] {
+			return fmt.Errorf("variant %q is listed twice", v)
+		}
+		seen[v] = true
+	}
+	if r.Control != "" && !seen[r.Control] {
+		return fmt.Errorf("control %q is not one of the variants", r.Control)
+	}
+	return nil
+}
+
+// Experiment is an A/B experiment definition. StartedAt and EndedAt are set
+// when the status first becomes running and completed respectively.
+type Experiment struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Variants    []string   `json:"variants"`
+	Control     string     `json:"control,omitempty"`
+	GoalID      *int       `json:"goalId,omitempty"`
+	Status      string     `json:"status"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	EndedAt     *time.Time `json:"endedAt,omitempty"`
+	CreatedBy   *int       `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// VariantResult is the outcome of one experiment variant. Lift, ZScore and
+// PValue compare the variant against the control and are unset for the
+// control itself.
+type VariantResult struct {
+	Variant        string   `json:"variant"`
+	Users          uint64   `json:"users"`
+	Conversions    uint64   `json:"conversions"`
+	ConversionRate float64  `json:"conversionRate"`
+	Lift           *float64 `json:"lift,omitempty"`
+	ZScore         *float64 `json:"zScore,omitempty"`
+	PValue         *float64 `json:"pValue,omitempty"`
+	Significant    bool     `json:"significant"`
+	// SampledUsers and SampledConversions are the counts actually stored,
+	// before scaling for sampling; the significance test runs on them.
+	SampledUsers       uint64 `json:"-"`
+	SampledConversions uint64 `json:"-"`
+}
+
+type ExperimentResults struct {
+	ExperimentID string `json:"experimentId"`
+	GoalID       int    `json:"goalId"`
+	GoalName     string `json:"goalName"`
+	Control      string `json:"control"`
+	// Confidence is the level at which a difference is reported significant.
+	Confidence float64         `json:"confidence"`
+	Variants   []VariantResult `json:"variants"`
+}
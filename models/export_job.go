@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+	// ExportStatusExpired marks completed jobs whose file was removed by cleanup.
+	ExportStatusExpired = "expired"
+
+	ExportKindPrivacy     = "privacy_export"
+	ExportKindPrivacyCSV  = "privacy_export_csv"
+	ExportKindBillingCSV  = "billing_usage_csv"
+	ExportKindBillingJSON = "billing_usage_json"
+	ExportKindReprocess   = "event_reprocess"
+)
+
+type ExportJob struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Params      json.RawMessage `json:"params"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	FilePath    string          `json:"-"`
+	RequestedBy *int            `json:"requestedBy,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	StartedAt   *time.Time      `json:"startedAt,omitempty"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty"`
+	DownloadURL string          `json:"downloadUrl,omitempty"`
+	// SignedDownloadURL downloads the file without authentication until
+	// SignedDownloadExpiresAt, e.g. for the data subject to fetch their export.
+	SignedDownloadURL       string     `json:"signedDownloadUrl,omitempty"`
+	SignedDownloadExpiresAt *time.Time `json:"signedDownloadExpiresAt,omitempty"`
+}
+
+// PrivacyExportParams names the data subject of a privacy export: a user ID
+// of the project, whose user IDs are its own, and optionally the API account
+// an admin has linked to that subject.
+type PrivacyExportParams struct {
+	ProjectID string `json:"projectId"`
+	UserID    string `json:"userId"`
+	AccountID int    `json:"accountId,omitempty"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// FirstTouchCount is one first-touch value (e.g. the first utm_source) with
+// the visitors it acquired and their events in the reported range.
+type FirstTouchCount struct {
+	Value    string `json:"value"`
+	Visitors uint64 `json:"visitors"`
+	Events   uint64 `json:"events"`
+}
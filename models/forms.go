@@ -0,0 +1,52 @@
+package models
+
+import "errors"
+
+// Form interaction event types. A form_abandon is sent when the visitor leaves
+// a started form without submitting it.
+const (
+	EventTypeFormStart   = "form_start"
+	EventTypeFormSubmit  = "form_submit"
+	EventTypeFormAbandon = "form_abandon"
+)
+
+// FormPayload is the eventData of form_start, form_submit and form_abandon
+// events. LastField is the last field the visitor touched, for abandons.
+type FormPayload struct {
+	FormID    string `json:"formId"`
+	FormName  string `json:"formName,omitempty"`
+	LastField string `json:"lastField,omitempty"`
+}
+
+// IsFormEvent reports whether eventType is a form interaction event type.
+func IsFormEvent(eventType string) bool {
+	switch eventType {
+	case EventTypeFormStart, EventTypeFormSubmit, EventTypeFormAbandon:
+		return true
+	}
+	return false
+}
+
+// FormPayload decodes the eventData of a form interaction event.
+func (e *AnalyticsEvent) FormPayload() (FormPayload, error) {
+	var p FormPayload
+	if err := decodePayload(e.EventData, &p); err != nil {
+		return p, err
+	}
+	if p.FormID == "" {
+		return p, errors.New(e.EventType + " needs a formId")
+	}
+	return p, nil
+}
+
+// FormStats summarizes interactions with a form, over all pages or on
+// PagePath. Counts are of sessions: a session that submits twice counts once.
+type FormStats struct {
+	FormID          string  `json:"formId"`
+	PagePath        string  `json:"pagePath,omitempty"`
+	Starts          uint64  `json:"starts"`
+	Submissions     uint64  `json:"submissions"`
+	Abandons        uint64  `json:"abandons"`
+	SubmissionRate  float64 `json:"submissionRate"`
+	AbandonmentRate float64 `json:"abandonmentRate"`
+}
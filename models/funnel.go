@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type FunnelStepDefinition struct {
+	EventType string `json:"eventType" binding:"required"`
+	PagePath  string `json:"pagePath,omitempty"`
+}
+
+// FunnelDefinition is an ordered list of steps a visitor must complete within
+// WindowSeconds of the first step. Traits restricts the funnel to matching users.
+type FunnelDefinition struct {
+	Steps         []FunnelStepDefinition `json:"steps" binding:"required,min=2,max=10,dive"`
+	WindowSeconds int64                  `json:"windowSeconds" binding:"omitempty,min=1"`
+	Traits        map[string]string      `json:"traits,omitempty"`
+}
+
+type FunnelRequest struct {
+	Name       string           `json:"name" binding:"required"`
+	Definition FunnelDefinition `json:"definition"`
+}
+
+type Funnel struct {
+	ID         int              `json:"id"`
+	Name       string           `json:"name"`
+	Definition FunnelDefinition `json:"definition"`
+	CreatedBy  *int             `json:"createdBy,omitempty"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+}
+
+type FunnelStepResult struct {
+	Step      int    `json:"step"`
+	EventType string `json:"eventType"`
+	PagePath  string `json:"pagePath,omitempty"`
+	// Visitors is the number of visitors who reached this step.
+	Visitors uint64 `json:"visitors"`
+	// ConversionRate is relative to the first step, StepConversionRate to the
+	// previous one; DropOffRate is 1 - StepConversionRate.
+	ConversionRate     float64 `json:"conversionRate"`
+	StepConversionRate float64 `json:"stepConversionRate"`
+	DropOffRate        float64 `json:"dropOffRate"`
+}
+
+type FunnelResult struct {
+	FunnelID      *int               `json:"funnelId,omitempty"`
+	WindowSeconds int64              `json:"windowSeconds"`
+	StartDate     string             `json:"startDate"`
+	EndDate       string             `json:"endDate"`
+	Steps         []FunnelStepResult `json:"steps"`
+}
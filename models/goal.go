@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// GoalRequest defines a conversion goal. An event converts when it matches
+// every condition that is set: the event type, the page path and/or an
+// eventData property. A property without PropertyValue matches events that
+// have the property at all.
+type GoalRequest struct {
+	Name          string `json:"name" binding:"required"`
+	EventType     string `json:"eventType"`
+	PagePath      string `json:"pagePath"`
+	PathMatch     string `json:"pathMatch" binding:"omitempty,oneof=exact prefix regex"`
+	Property      string `json:"property" binding:"max=128"`
+	PropertyValue string `json:"propertyValue" binding:"omitempty,max=1024"`
+}
+
+// Validate checks that the goal matches something, which binding tags cannot
+// express.
+func (r GoalRequest) Validate() error {
+	if r.EventType == "" && r.PagePath == "" && r.Property == "" {
+		return errors.New("a goal must match an eventType, a pagePath, a property, or a combination")
+	}
+	if r.PropertyValue != "" && r.Property == "" {
+		return errors.New("propertyValue requires property")
+	}
+	return nil
+}
+
+type Goal struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	EventType     string    `json:"eventType"`
+	PagePath      string    `json:"pagePath"`
+	PathMatch     string    `json:"pathMatch"`
+	Property      string    `json:"property,omitempty"`
+	PropertyValue string    `json:"propertyValue,omitempty"`
+	CreatedBy     *int      `json:"createdBy,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type GoalConversionPoint struct {
+	Time           time.Time `json:"time"`
+	Conversions    uint64    `json:"conversions"`
+	Sessions       uint64    `json:"sessions"`
+	ConversionRate float64   `json:"conversionRate"`
+}
+
+type GoalReport struct {
+	GoalID int                   `json:"goalId"`
+	Name   string                `json:"name"`
+	Series []GoalConversionPoint `json:"series"`
+}
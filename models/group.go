@@ -0,0 +1,14 @@
+package models
+
+type GroupRequest struct {
+	UserID  string                 `json:"userId" binding:"required"`
+	GroupID string                 `json:"groupId" binding:"required"`
+	Name    string                 `json:"name"`
+	Traits  map[string]interface{} `json:"traits"`
+}
+
+type AccountActivity struct {
+	GroupID     string `json:"groupId"`
+	EventCount  uint64 `json:"eventCount"`
+	ActiveUsers uint64 `json:"activeUsers"`
+}
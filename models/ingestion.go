@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Modes of honoring the Do Not Track (DNT: 1) and Global Privacy Control
+// (Sec-GPC: 1) headers of tracking requests.
+const (
+	// PrivacySignalModeDrop discards the events of such requests.
+	PrivacySignalModeDrop = "drop"
+	// PrivacySignalModeAnonymize keeps the events but strips identifiers.
+	PrivacySignalModeAnonymize = "anonymize"
+)
+
+type InsertError struct {
+	Time   time.Time `json:"time"`
+	Events int       `json:"events"`
+	Error  string    `json:"error"`
+}
+
+// IngestionStats is a snapshot of this process's ingestion counters. Rates and
+// latencies cover the last minute; totals cover the process lifetime.
+type IngestionStats struct {
+	Since              time.Time     `json:"since"`
+	EventsPerSecond    float64       `json:"eventsPerSecond"`
+	EventsInserted     uint64        `json:"eventsInserted"`
+	BatchesInserted    uint64        `json:"batchesInserted"`
+	BufferDepth        int           `json:"bufferDepth"`
+	LastFlushLatencyMs float64       `json:"lastFlushLatencyMs"`
+	AvgFlushLatencyMs  float64       `json:"avgFlushLatencyMs"`
+	MaxFlushLatencyMs  float64       `json:"maxFlushLatencyMs"`
+	DeadLetterCount    uint64        `json:"deadLetterCount"`
+	RecentInsertErrors []InsertError `json:"recentInsertErrors"`
+	// PrivacySignals counts the events of requests sending DNT or GPC by
+	// what was done with them.
+	PrivacySignals PrivacySignalCounts `json:"privacySignals"`
+}
+
+type PrivacySignalCounts struct {
+	Dropped    uint64 `json:"dropped"`
+	Anonymized uint64 `json:"anonymized"`
+}
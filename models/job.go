@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+
+	JobKindExport       = "export"
+	JobKindDataDeletion = "data_deletion"
+)
+
+// Job is an entry of the persistent background queue.
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	LastError   string          `json:"lastError,omitempty"`
+	RunAt       time.Time       `json:"runAt"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	StartedAt   *time.Time      `json:"startedAt,omitempty"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty"`
+}
+
+// FinalAttempt reports whether a failure of the current attempt is permanent.
+func (j *Job) FinalAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
+
+type JobCount struct {
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+type ExportJobPayload struct {
+	ExportID string `json:"exportId"`
+}
+
+type DataDeletionPayload struct {
+	DeletionID int `json:"deletionId"`
+}
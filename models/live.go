@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// LiveSummary is the real-time overview pushed to dashboard WebSocket clients
+// and returned by /api/stats/realtime.
+type LiveSummary struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	// ActiveUsers counts distinct visitors over the last 5 minutes.
+	ActiveUsers uint64 `json:"activeUsers"`
+	// EventsPerMinute counts events over the last full minute.
+	EventsPerMinute uint64 `json:"eventsPerMinute"`
+	// EventsPerSecond is EventsPerMinute averaged per second.
+	EventsPerSecond   float64         `json:"eventsPerSecond"`
+	TopPages          []TopPathResult `json:"topPages"`
+	LatestConversions []Conversion    `json:"latestConversions"`
+}
+
+// Conversion is a purchase event as shown in live views.
+type Conversion struct {
+	EventID   string    `json:"eventId"`
+	VisitorID string    `json:"visitorId"`
+	OrderID   string    `json:"orderId,omitempty"`
+	Revenue   float64   `json:"revenue"`
+	Currency  string    `json:"currency"`
+	Timestamp time.Time `json:"timestamp"`
+}
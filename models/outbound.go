@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+)
+
+// EventTypeOutboundClick records a click on a link leaving the site.
+const EventTypeOutboundClick = "outbound_click"
+
+// OutboundClickPayload is the eventData of an outbound_click event. URL is the
+// link's absolute destination; the source page is the event's PagePath.
+type OutboundClickPayload struct {
+	URL      string `json:"url"`
+	LinkText string `json:"linkText,omitempty"`
+}
+
+// OutboundClickPayload decodes the eventData of an outbound_click event.
+func (e *AnalyticsEvent) OutboundClickPayload() (OutboundClickPayload, error) {
+	var p OutboundClickPayload
+	if err := decodePayload(e.EventData, &p); err != nil {
+		return p, err
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return p, errors.New("outbound_click needs an absolute http(s) url")
+	}
+	return p, nil
+}
+
+// OutboundDestination is an external destination with its clicks and the
+// visitors who clicked through to it.
+type OutboundDestination struct {
+	Destination string `json:"destination"`
+	Clicks      uint64 `json:"clicks"`
+	Visitors    uint64 `json:"visitors"`
+}
+
+// OutboundSource is a page of the site with the outbound clicks made from it.
+// ClickThroughRate is the share of the page's viewers who clicked out.
+type OutboundSource struct {
+	PagePath         string  `json:"pagePath"`
+	Clicks           uint64  `json:"clicks"`
+	Visitors         uint64  `json:"visitors"`
+	PageViewers      uint64  `json:"pageViewers"`
+	ClickThroughRate float64 `json:"clickThroughRate"`
+}
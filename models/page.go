@@ -0,0 +1,8 @@
+package models
+
+// Page is one page of a listing. NextCursor is passed back as the cursor
+// parameter to fetch the following page and is empty on the last one.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
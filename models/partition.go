@@ -0,0 +1,29 @@
+package models
+
+type Partition struct {
+	PartitionID string `json:"partitionId"`
+	Partition   string `json:"partition"`
+	Parts       uint64 `json:"parts"`
+	Rows        uint64 `json:"rows"`
+	BytesOnDisk uint64 `json:"bytesOnDisk"`
+	Detached    bool   `json:"detached"`
+}
+
+type OptimizePartitionsRequest struct {
+	// PartitionIDs to optimize; empty optimizes the whole table.
+	PartitionIDs []string `json:"partitionIds"`
+}
+
+// DropPartitionsRequest drops every partition whose newest row is older than
+// OlderThan (RFC3339). Confirm must repeat the table name.
+type DropPartitionsRequest struct {
+	OlderThan string `json:"olderThan" binding:"required"`
+	Confirm   string `json:"confirm"`
+}
+
+// PartitionRequest detaches or attaches one partition. Confirm must repeat the
+// table name.
+type PartitionRequest struct {
+	PartitionID string `json:"partitionId" binding:"required"`
+	Confirm     string `json:"confirm"`
+}
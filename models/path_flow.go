@@ -0,0 +1,11 @@
+package models
+
+// PathTransition is a navigation from one page to the next within sessions.
+// Step is the position of From among the session's page views, starting at 1
+// for the entry page, so transitions can be laid out in Sankey columns.
+type PathTransition struct {
+	Step  uint64 `json:"step"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count uint64 `json:"count"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+)
+
+// EventTypeProductView is the event of a visitor viewing the products in
+// Products, e.g. on a product detail page. Its eventData is free-form.
+const EventTypeProductView = "product_view"
+
+// ProductLineItem is one product in an ecommerce event, e.g. the items of a
+// cart or an order. Price is the unit price in Currency.
+type ProductLineItem struct {
+	ID       string  `json:"id,omitempty"`
+	SKU      string  `json:"sku,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Price    float64 `json:"price"`
+	Quantity uint32  `json:"quantity"`
+	// Currency is an ISO 4217 code such as "EUR".
+	Currency string `json:"currency,omitempty"`
+}
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Validate reports the first problem with the line item, if any.
+func (p ProductLineItem) Validate() error {
+	if p.ID == "" && p.SKU == "" {
+		return errors.New("product needs an id or sku")
+	}
+	if p.Price < 0 || math.IsNaN(p.Price) || math.IsInf(p.Price, 0) {
+		return fmt.Errorf("product %s: price must be a non-negative number", p.key())
+	}
+	if p.Quantity == 0 {
+		return fmt.Errorf("product %s: quantity must be at least 1", p.key())
+	}
+	if p.Currency != "" && !currencyPattern.MatchString(p.Currency) {
+		return fmt.Errorf("product %s: currency must be an ISO 4217 code, e.g. EUR", p.key())
+	}
+	return nil
+}
+
+func (p ProductLineItem) key() string {
+	if p.ID != "" {
+		return p.ID
+	}
+	return p.SKU
+}
+
+// ProductStats is one product's funnel over a time range. Products are
+// identified by id, or by sku for line items without one. AddsToCart and
+// Purchases count units; Orders counts the purchases containing the product.
+// Revenue sums price × quantity of purchased units per currency.
+type ProductStats struct {
+	ProductID  string          `json:"productId"`
+	Name       string          `json:"name"`
+	Views      uint64          `json:"views"`
+	AddsToCart uint64          `json:"addsToCart"`
+	Purchases  uint64          `json:"purchases"`
+	Orders     uint64          `json:"orders"`
+	Revenue    []RevenueAmount `json:"revenue"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Roles of a project member. Owners manage the project and its members.
+const (
+	ProjectRoleOwner  = "owner"
+	ProjectRoleMember = "member"
+)
+
+// ProjectRequest defines a project (site). ID is the project_id events are
+// tracked with and cannot change once created.
+type ProjectRequest struct {
+	ID     string `json:"id" binding:"required,max=64"`
+	Name   string `json:"name" binding:"required,max=255"`
+	Domain string `json:"domain" binding:"max=255"`
+}
+
+type Project struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Domain string `json:"domain,omitempty"`
+	// Role is the caller's role in the project, empty for admins who are not
+	// members.
+	Role      string    `json:"role,omitempty"`
+	CreatedBy *int      `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ProjectMemberRequest struct {
+	UserID int    `json:"userId" binding:"required,min=1"`
+	Role   string `json:"role" binding:"omitempty,oneof=owner member"`
+}
+
+type ProjectMember struct {
+	UserID    int       `json:"userId"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
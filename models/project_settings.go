@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type ProjectSettingsRequest struct {
+	// RetentionDays is how long the project's events are kept; null keeps them
+	// as long as the plan allows.
+	RetentionDays *int `json:"retentionDays" binding:"omitempty,min=1"`
+	// BaseCurrency is the ISO 4217 currency revenue is reported in; empty
+	// uses the server default.
+	BaseCurrency string `json:"baseCurrency" binding:"omitempty,len=3,uppercase"`
+}
+
+type RetentionLimitRequest struct {
+	// MaxRetentionDays is the plan limit; null restores the server default.
+	MaxRetentionDays *int `json:"maxRetentionDays" binding:"omitempty,min=1"`
+}
+
+type EventTypeRetentionRequest struct {
+	RetentionDays int `json:"retentionDays" binding:"required,min=1"`
+}
+
+// EventTypeRetention is how long a project keeps the events of one type. It
+// only shortens the project's retention.
+type EventTypeRetention struct {
+	ProjectID     string     `json:"projectId"`
+	EventType     string     `json:"eventType"`
+	RetentionDays int        `json:"retentionDays"`
+	UpdatedBy     *int       `json:"updatedBy,omitempty"`
+	UpdatedAt     *time.Time `json:"updatedAt,omitempty"`
+}
+
+type ProjectSettings struct {
+	ProjectID     string `json:"projectId"`
+	RetentionDays *int   `json:"retentionDays"`
+	// MaxRetentionDays is the plan limit, 0 when retention is unlimited.
+	MaxRetentionDays int `json:"maxRetentionDays"`
+	// EffectiveRetentionDays is the window actually enforced, 0 when events
+	// are kept forever.
+	EffectiveRetentionDays int        `json:"effectiveRetentionDays"`
+	BaseCurrency           string     `json:"baseCurrency"`
+	UpdatedBy              *int       `json:"updatedBy,omitempty"`
+	UpdatedAt              *time.Time `json:"updatedAt,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// QueryLogEntry records one call to a stats endpoint.
+type QueryLogEntry struct {
+	Timestamp    time.Time `json:"timestamp"`
+	UserID       int       `json:"userId"`
+	UserEmail    string    `json:"userEmail"`
+	Endpoint     string    `json:"endpoint"`
+	Params       string    `json:"params"`
+	Status       int       `json:"status"`
+	DurationMs   int64     `json:"durationMs"`
+	RowsReturned int       `json:"rowsReturned"`
+	IPAddress    string    `json:"ipAddress"`
+}
+
+// QueryLogSummary aggregates logged queries per user and endpoint.
+type QueryLogSummary struct {
+	UserID          int     `json:"userId"`
+	UserEmail       string  `json:"userEmail"`
+	Endpoint        string  `json:"endpoint"`
+	Queries         uint64  `json:"queries"`
+	TotalDurationMs int64   `json:"totalDurationMs"`
+	AvgDurationMs   float64 `json:"avgDurationMs"`
+	MaxDurationMs   int64   `json:"maxDurationMs"`
+	TotalRows       uint64  `json:"totalRows"`
+}
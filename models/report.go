@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ReportDefinition is a stats query to re-run later: the /api/stats endpoint
+// (Metric, e.g. "event-counts"), its filters and other query parameters, the
+// interval of time series, and a time range given either as a relative Range
+// preset (e.g. "last_30d") or as fixed Start and End.
+type ReportDefinition struct {
+	Metric   string            `json:"metric" binding:"required,oneof=event-counts average-event-duration average-custom-param unique-users top-paths top-referrers entry-pages exit-pages paths/flow top-products cart-abandonment sessions sessions/summary retention browsers os devices first-touch performance outbound-clicks outbound-clicks/sources forms campaigns attribution channels active-accounts events-per-account goals funnel campaign-roi revenue"`
+	Filters  map[string]string `json:"filters,omitempty"`
+	Interval string            `json:"interval,omitempty" binding:"omitempty,oneof=Minute Hour Day Week Month Quarter Year"`
+	Range    string            `json:"range,omitempty"`
+	Start    string            `json:"start,omitempty"`
+	End      string            `json:"end,omitempty"`
+}
+
+// Validate checks the time range, which binding tags cannot express.
+func (d ReportDefinition) Validate(rangePresets []string) error {
+	if d.Range != "" {
+		if d.Start != "" || d.End != "" {
+			return errors.New("give either range or start/end, not both")
+		}
+		if !slices.Contains(rangePresets, d.Range) {
+			return fmt.Errorf("range must be one of %v", rangePresets)
+		}
+	}
+	return nil
+}
+
+type ReportRequest struct {
+	Name       string           `json:"name" binding:"required,max=255"`
+	Definition ReportDefinition `json:"definition"`
+}
+
+type Report struct {
+	ID         int              `json:"id"`
+	UserID     int              `json:"userId"`
+	Name       string           `json:"name"`
+	Definition ReportDefinition `json:"definition"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+}
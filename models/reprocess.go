@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+const (
+	// ReprocessModeReplace rewrites the range in analytics_events in place.
+	ReprocessModeReplace = "replace"
+	// ReprocessModeTable writes the enriched events to a separate table
+	// version, leaving analytics_events untouched.
+	ReprocessModeTable = "table"
+)
+
+// ReprocessRequest re-runs enrichment over the events in [Start, End]
+// (RFC3339). In replace mode Confirm must repeat "analytics_events".
+type ReprocessRequest struct {
+	Start       string   `json:"start" binding:"required"`
+	End         string   `json:"end" binding:"required"`
+	ProjectID   string   `json:"projectId"`
+	Enrichers   []string `json:"enrichers" binding:"required,min=1"`
+	Mode        string   `json:"mode" binding:"omitempty,oneof=replace table"`
+	TargetTable string   `json:"targetTable"`
+	Confirm     string   `json:"confirm"`
+}
+
+type ReprocessParams struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	ProjectID   string    `json:"projectId,omitempty"`
+	Enrichers   []string  `json:"enrichers"`
+	Mode        string    `json:"mode"`
+	TargetTable string    `json:"targetTable,omitempty"`
+}
+
+// ReprocessResult is the report stored as the output of a reprocess job.
+type ReprocessResult struct {
+	ReprocessParams
+	EventsRead    int `json:"eventsRead"`
+	EventsChanged int `json:"eventsChanged"`
+	EventsWritten int `json:"eventsWritten"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RetentionCohort is the visitors first seen in the period starting at
+// Cohort. Retained[i] is how many of them were active i periods later and
+// Retention[i] that share of Size; index 0 is the cohort period itself.
+type RetentionCohort struct {
+	Cohort    time.Time `json:"cohort"`
+	Size      uint64    `json:"size"`
+	Retained  []uint64  `json:"retained"`
+	Retention []float64 `json:"retention"`
+}
+
+// RetentionReport is a cohort matrix: one row per first-seen period.
+type RetentionReport struct {
+	Interval  string            `json:"interval"`
+	Periods   int               `json:"periods"`
+	StartDate string            `json:"startDate"`
+	EndDate   string            `json:"endDate"`
+	Cohorts   []RetentionCohort `json:"cohorts"`
+}
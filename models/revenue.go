@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ExchangeRateRequest sets the rates of one day, as units of each currency
+// per 1 EUR.
+type ExchangeRateRequest struct {
+	Date  string             `json:"date" binding:"required"` // YYYY-MM-DD
+	Rates map[string]float64 `json:"rates" binding:"required,min=1"`
+}
+
+type ExchangeRate struct {
+	Date      string    `json:"date"`
+	Currency  string    `json:"currency"`
+	PerEUR    float64   `json:"perEur"`
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RevenueAmount is revenue as recorded, in its original currency.
+type RevenueAmount struct {
+	Currency  string  `json:"currency"`
+	Revenue   float64 `json:"revenue"`
+	Purchases uint64  `json:"purchases"`
+}
+
+// RevenuePoint is the revenue of one time bucket converted to the base
+// currency at each purchase day's rate, with the original amounts.
+// AverageOrderValue is over the purchases that could be converted.
+type RevenuePoint struct {
+	Time              time.Time       `json:"time"`
+	Revenue           float64         `json:"revenue"`
+	Purchases         uint64          `json:"purchases"`
+	AverageOrderValue float64         `json:"averageOrderValue"`
+	ByCurrency        []RevenueAmount `json:"byCurrency"`
+}
+
+type RevenueReport struct {
+	BaseCurrency string `json:"baseCurrency"`
+	Interval     string `json:"interval"`
+	// TotalRevenue, Purchases and AverageOrderValue cover the whole range.
+	TotalRevenue      float64        `json:"totalRevenue"`
+	Purchases         uint64         `json:"purchases"`
+	AverageOrderValue float64        `json:"averageOrderValue"`
+	Series            []RevenuePoint `json:"series"`
+	// UnconvertedCurrencies lists currencies without an exchange rate; their
+	// amounts are in ByCurrency but not in the converted Revenue.
+	UnconvertedCurrencies []string `json:"unconvertedCurrencies,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type SamplingRuleRequest struct {
+	// EventType is the event type sampled; empty samples every event type of
+	// the project without a rule of its own.
+	EventType string `json:"eventType" binding:"max=128"`
+	// SampleRate is the share of events kept, above 0 and at most 1.
+	SampleRate float64 `json:"sampleRate" binding:"required,gt=0,lte=1"`
+}
+
+type SamplingRule struct {
+	ProjectID  string     `json:"projectId"`
+	EventType  string     `json:"eventType"`
+	SampleRate float64    `json:"sampleRate"`
+	UpdatedBy  *int       `json:"updatedBy,omitempty"`
+	UpdatedAt  *time.Time `json:"updatedAt,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+const (
+	ScheduleRunRunning   = "running"
+	ScheduleRunSucceeded = "succeeded"
+	ScheduleRunFailed    = "failed"
+)
+
+// ScheduleRun is the outcome of the latest run of a scheduled task.
+type ScheduleRun struct {
+	Instance   string     `json:"instance"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Runs       int64      `json:"runs"`
+	Failures   int64      `json:"failures"`
+	DurationMs int64      `json:"durationMs"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+type Schedule struct {
+	Name string `json:"name"`
+	Spec string `json:"spec"`
+	// LeaderOnly tasks run on a single replica; the others (e.g. refreshing
+	// in-memory caches) run on every replica.
+	LeaderOnly bool         `json:"leaderOnly"`
+	NextRun    time.Time    `json:"nextRun"`
+	LastRun    *ScheduleRun `json:"lastRun,omitempty"`
+}
+
+type SchedulerStatus struct {
+	Instance  string     `json:"instance"`
+	Leader    bool       `json:"leader"`
+	Schedules []Schedule `json:"schedules"`
+}
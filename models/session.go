@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SessionMetrics aggregates sessions: those started in the period at Time for
+// a series, or in the whole range for a summary. Duration is from a session's
+// first to its last event, so single-event sessions last 0.
+type SessionMetrics struct {
+	Time *time.Time `json:"time,omitempty"`
+	// ComparedTime is set on points of a comparison series to their own
+	// period, while Time is the period of the current range they line up with.
+	ComparedTime       *time.Time `json:"comparedTime,omitempty"`
+	Sessions           uint64     `json:"sessions"`
+	AvgDurationSeconds float64    `json:"avgDurationSeconds"`
+	EventsPerSession   float64    `json:"eventsPerSession"`
+}
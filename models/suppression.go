@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	SuppressionTypeUser      = "user"
+	SuppressionTypeAnonymous = "anonymous"
+
+	// SuppressionModeDrop discards events from the subject at ingestion.
+	SuppressionModeDrop = "drop"
+	// SuppressionModeAnonymize keeps the events but strips identifiers.
+	SuppressionModeAnonymize = "anonymize"
+)
+
+type SuppressionRequest struct {
+	Type      string `json:"type" binding:"required,oneof=user anonymous"`
+	SubjectID string `json:"subjectId" binding:"required"`
+	Mode      string `json:"mode" binding:"omitempty,oneof=drop anonymize"`
+	Reason    string `json:"reason"`
+}
+
+type Suppression struct {
+	ID        int        `json:"id"`
+	Type      string     `json:"type"`
+	SubjectID string     `json:"subjectId"`
+	Mode      string     `json:"mode"`
+	Reason    string     `json:"reason"`
+	CreatedBy *int       `json:"createdBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RemovedBy *int       `json:"removedBy,omitempty"`
+	RemovedAt *time.Time `json:"removedAt,omitempty"`
+}
@@ -0,0 +1,57 @@
+package models
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusWarning  = "warning"
+	HealthStatusCritical = "critical"
+)
+
+type PartitionUsage struct {
+	Partition   string `json:"partition"`
+	Parts       uint64 `json:"parts"`
+	Rows        uint64 `json:"rows"`
+	BytesOnDisk uint64 `json:"bytesOnDisk"`
+}
+
+type TableHealth struct {
+	Table               string   `json:"table"`
+	Status              string   `json:"status"`
+	Warnings            []string `json:"warnings,omitempty"`
+	ActiveParts         uint64   `json:"activeParts"`
+	MaxPartsInPartition uint64   `json:"maxPartsInPartition"`
+	Rows                uint64   `json:"rows"`
+	BytesOnDisk         uint64   `json:"bytesOnDisk"`
+	MergesInProgress    uint64   `json:"mergesInProgress"`
+	LongestMergeSeconds float64  `json:"longestMergeSeconds"`
+	PendingMutations    uint64   `json:"pendingMutations"`
+	// Replication fields are only set for Replicated* tables.
+	ReplicationDelaySeconds *uint64          `json:"replicationDelaySeconds,omitempty"`
+	ReplicationQueueSize    *uint32          `json:"replicationQueueSize,omitempty"`
+	ReadOnlyReplica         bool             `json:"readOnlyReplica,omitempty"`
+	Partitions              []PartitionUsage `json:"partitions"`
+}
+
+type ClickHouseHealth struct {
+	Status string        `json:"status"`
+	Tables []TableHealth `json:"tables"`
+}
+
+// StorageTier is the share of a table stored on one disk of a storage policy
+// volume, e.g. the local "hot" volume or an S3-backed "cold" one.
+type StorageTier struct {
+	Volume      string `json:"volume"`
+	Disk        string `json:"disk"`
+	DiskType    string `json:"diskType"`
+	Parts       uint64 `json:"parts"`
+	Rows        uint64 `json:"rows"`
+	BytesOnDisk uint64 `json:"bytesOnDisk"`
+	// FreeSpace and TotalSpace describe the whole disk, shared by all tables.
+	FreeSpace  uint64 `json:"freeSpace"`
+	TotalSpace uint64 `json:"totalSpace"`
+}
+
+type TableStorage struct {
+	Table         string        `json:"table"`
+	StoragePolicy string        `json:"storagePolicy"`
+	Tiers         []StorageTier `json:"tiers"`
+}
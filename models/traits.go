@@ -0,0 +1,23 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type IdentifyRequest struct {
+	UserID  string                 `json:"userId" binding:"required"`
+	Email   string                 `json:"email" binding:"omitempty,email"`
+	Plan    string                 `json:"plan"`
+	Company string                 `json:"company"`
+	Traits  map[string]interface{} `json:"traits"`
+}
+
+type UserTraits struct {
+	UserID    string          `json:"userId"`
+	Email     string          `json:"email"`
+	Plan      string          `json:"plan"`
+	Company   string          `json:"company"`
+	Traits    json.RawMessage `json:"traits"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
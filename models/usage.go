@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	UsageStatusOK       = "ok"
+	UsageStatusWarning  = "warning"
+	UsageStatusExceeded = "exceeded"
+)
+
+// UsagePeriod is a project's usage for one calendar month (UTC).
+type UsagePeriod struct {
+	Period         string `json:"period"` // YYYY-MM
+	EventsIngested int64  `json:"eventsIngested"`
+	Queries        int64  `json:"queries"`
+}
+
+type Usage struct {
+	ProjectID string `json:"projectId"`
+	UsagePeriod
+	// MonthlyEventQuota is 0 when ingestion is unlimited.
+	MonthlyEventQuota int64         `json:"monthlyEventQuota"`
+	PercentUsed       float64       `json:"percentUsed"`
+	Status            string        `json:"status"`
+	History           []UsagePeriod `json:"history,omitempty"`
+}
+
+type QuotaRequest struct {
+	MonthlyEventQuota *int64 `json:"monthlyEventQuota" binding:"required,min=0"`
+}
+
+type ProjectQuota struct {
+	ProjectID         string    `json:"projectId"`
+	MonthlyEventQuota int64     `json:"monthlyEventQuota"`
+	UpdatedBy         *int      `json:"updatedBy,omitempty"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// BillingUsage is one project's line in the monthly billing report.
+// StorageBytesEstimate apportions the analytics_events on-disk size by the
+// project's share of stored events.
+type BillingUsage struct {
+	Period               string `json:"period"`
+	ProjectID            string `json:"projectId"`
+	EventsIngested       int64  `json:"eventsIngested"`
+	Queries              int64  `json:"queries"`
+	StoredEvents         uint64 `json:"storedEvents"`
+	StorageBytesEstimate uint64 `json:"storageBytesEstimate"`
+}
+
+// BillingUsageCSVHeader matches the column order of BillingUsage.CSVRecord.
+var BillingUsageCSVHeader = []string{"period", "project_id", "events_ingested", "queries", "stored_events", "storage_bytes_estimate"}
+
+func (b BillingUsage) CSVRecord() []string {
+	return []string{
+		b.Period,
+		b.ProjectID,
+		strconv.FormatInt(b.EventsIngested, 10),
+		strconv.FormatInt(b.Queries, 10),
+		strconv.FormatUint(b.StoredEvents, 10),
+		strconv.FormatUint(b.StorageBytesEstimate, 10),
+	}
+}
+
+type BillingExportRequest struct {
+	Period string `json:"period" binding:"required"` // YYYY-MM
+	Format string `json:"format" binding:"omitempty,oneof=csv json"`
+}
+
+type BillingExportParams struct {
+	Period string `json:"period"`
+}
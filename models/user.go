@@ -16,6 +16,74 @@ type User struct {
 	ID             int       `json:"id"`
 	Email          string    `json:"email"`
 	HashedPassword []byte    `json:"-"`
+	IsAdmin        bool      `json:"is_admin"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
+
+// RefreshRequest redeems a refresh token sent in the body rather than the
+// refresh_token cookie.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken is a newly issued refresh token; only its hash is stored.
+type RefreshToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// ForgotPasswordRequest asks for a password reset link sent to Email.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password with the token of a reset link.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// ChangeEmailRequest asks to move the account to Email, which must be
+// confirmed with the token sent there. CurrentPassword is required for
+// accounts that have a password.
+type ChangeEmailRequest struct {
+	Email           string `json:"email" binding:"required,email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+// VerifyEmailRequest confirms an email change.
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ChangePasswordRequest sets a new password. CurrentPassword is required for
+// accounts that have a password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+// DeleteAccountRequest confirms the deletion of the caller's account.
+// CurrentPassword is required for accounts that have a password.
+type DeleteAccountRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+// ImpersonationRequest asks for a support token acting as another user.
+// Reason is recorded in the audit log.
+type ImpersonationRequest struct {
+	Reason     string `json:"reason" binding:"required,max=500"`
+	TTLMinutes int    `json:"ttlMinutes" binding:"omitempty,min=1,max=60"`
+}
+
+// ImpersonationToken is a read-only token acting as UserID on behalf of the
+// admin ImpersonatorID, until ExpiresAt.
+type ImpersonationToken struct {
+	Token          string    `json:"token"`
+	UserID         int       `json:"userId"`
+	UserEmail      string    `json:"userEmail"`
+	ImpersonatorID int       `json:"impersonatorId"`
+	Impersonation  bool      `json:"impersonation"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// EventTypeWebVital reports one Core Web Vitals measurement of a page load.
+const EventTypeWebVital = "web_vital"
+
+const (
+	WebVitalRatingGood             = "good"
+	WebVitalRatingNeedsImprovement = "needs-improvement"
+	WebVitalRatingPoor             = "poor"
+)
+
+// webVitalThresholds are the upper bounds of the "good" and "needs
+// improvement" ratings of each metric, as published at web.dev. CLS is
+// unitless; the other metrics are in milliseconds.
+var webVitalThresholds = map[string][2]float64{
+	"LCP":  {2500, 4000},
+	"INP":  {200, 500},
+	"CLS":  {0.1, 0.25},
+	"TTFB": {800, 1800},
+	"FCP":  {1800, 3000},
+}
+
+// WebVitalPayload is the eventData of a web_vital event, as reported by the
+// web-vitals library: Name is LCP, INP, CLS, TTFB or FCP.
+type WebVitalPayload struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	// Rating is derived from Value when the client does not send it.
+	Rating         string `json:"rating,omitempty"`
+	NavigationType string `json:"navigationType,omitempty"`
+}
+
+// WebVitalPayload decodes the eventData of a web_vital event.
+func (e *AnalyticsEvent) WebVitalPayload() (WebVitalPayload, error) {
+	var p WebVitalPayload
+	if err := decodePayload(e.EventData, &p); err != nil {
+		return p, err
+	}
+	p.Name = strings.ToUpper(p.Name)
+	thresholds, ok := webVitalThresholds[p.Name]
+	if !ok {
+		return p, fmt.Errorf("web_vital name must be one of LCP, INP, CLS, TTFB or FCP, got %q", p.Name)
+	}
+	if p.Value < 0 || math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+		return p, errors.New("web_vital value must be a non-negative number")
+	}
+	switch p.Rating {
+	case "":
+		p.Rating = rateWebVital(p.Value, thresholds)
+	case WebVitalRatingGood, WebVitalRatingNeedsImprovement, WebVitalRatingPoor:
+	default:
+		return p, fmt.Errorf("web_vital rating must be good, needs-improvement or poor, got %q", p.Rating)
+	}
+	return p, nil
+}
+
+func rateWebVital(value float64, thresholds [2]float64) string {
+	switch {
+	case value <= thresholds[0]:
+		return WebVitalRatingGood
+	case value <= thresholds[1]:
+		return WebVitalRatingNeedsImprovement
+	default:
+		return WebVitalRatingPoor
+	}
+}
+
+// IsWebVital reports whether name is a supported metric, e.g. "LCP".
+func IsWebVital(name string) bool {
+	_, ok := webVitalThresholds[name]
+	return ok
+}
+
+// RateWebVital rates a value of the named metric, e.g. a 75th percentile,
+// or returns "" for an unknown metric.
+func RateWebVital(name string, value float64) string {
+	thresholds, ok := webVitalThresholds[name]
+	if !ok {
+		return ""
+	}
+	return rateWebVital(value, thresholds)
+}
+
+// WebVitalStats summarizes one metric for a page and device type.
+type WebVitalStats struct {
+	PagePath   string  `json:"pagePath"`
+	DeviceType string  `json:"deviceType"`
+	Metric     string  `json:"metric"`
+	Samples    uint64  `json:"samples"`
+	P50        float64 `json:"p50"`
+	P75        float64 `json:"p75"`
+	P95        float64 `json:"p95"`
+	// Rating rates the 75th percentile, as Core Web Vitals assessments do.
+	Rating string `json:"rating"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookRequest registers a webhook receiving the project's events of
+// EventTypes, e.g. ["purchase"]. Enabled defaults to true.
+type WebhookRequest struct {
+	Name       string   `json:"name" binding:"max=255"`
+	URL        string   `json:"url" binding:"required,url,max=2048,startswith=http"`
+	EventTypes []string `json:"eventTypes" binding:"required,min=1,max=50,dive,required,max=128"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// Webhook forwards the tracked events of ProjectID whose type is in
+// EventTypes to URL. Secret, which signs deliveries, is only returned when
+// the webhook is created or its secret rotated.
+type Webhook struct {
+	ID         int       `json:"id"`
+	ProjectID  string    `json:"projectId"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"eventTypes"`
+	Enabled    bool      `json:"enabled"`
+	CreatedBy  *int      `json:"createdBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook, with the
+// outcome of its last attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int             `json:"webhookId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	ResponseStatus *int            `json:"responseStatus,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	CompletedAt    *time.Time      `json:"completedAt,omitempty"`
+}
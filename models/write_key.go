@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type WriteKeyRequest struct {
+	Name string `json:"name" binding:"max=255"`
+}
+
+// RotateWriteKeyRequest replaces a write key. The old key keeps working for
+// GraceSeconds so trackers can be redeployed with the new one.
+type RotateWriteKeyRequest struct {
+	GraceSeconds int `json:"graceSeconds" binding:"min=0,max=604800"`
+}
+
+// WriteKey authenticates ingestion for ProjectID. Key, the secret itself, is
+// only returned when the key is created; afterwards Prefix identifies it.
+type WriteKey struct {
+	ID        int        `json:"id"`
+	ProjectID string     `json:"projectId"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Key       string     `json:"key,omitempty"`
+	CreatedBy *int       `json:"createdBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
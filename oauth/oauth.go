@@ -0,0 +1,147 @@
+// Package oauth signs users in with third-party identity providers through
+// the OAuth2 authorization code flow. Providers are enabled by setting their
+// client credentials in the environment.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// Identity is the account a user signed in with at a provider.
+type Identity struct {
+	// Subject is the provider's stable id of the account.
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Provider is an enabled identity provider.
+type Provider struct {
+	Name   string
+	Config *oauth2.Config
+	// identity fetches the signed-in account with a client authorized by the
+	// exchanged token.
+	identity func(ctx context.Context, client *http.Client) (*Identity, error)
+}
+
+// Identity exchanges the authorization code of a callback, verified against
+// the PKCE verifier, and returns the account it grants access to.
+func (p *Provider) Identity(ctx context.Context, code, verifier string) (*Identity, error) {
+	token, err := p.Config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange %s authorization code: %w", p.Name, err)
+	}
+	identity, err := p.identity(ctx, p.Config.Client(ctx, token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s account: %w", p.Name, err)
+	}
+	identity.Email = strings.ToLower(strings.TrimSpace(identity.Email))
+	return identity, nil
+}
+
+// Providers are the enabled providers by name.
+type Providers map[string]*Provider
+
+// Names lists the enabled providers.
+func (p Providers) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProvidersFromEnv enables each provider whose <NAME>_CLIENT_ID and
+// <NAME>_CLIENT_SECRET are set. Callbacks are expected at
+// <baseURL>/api/auth/<name>/callback.
+func ProvidersFromEnv(baseURL string) Providers {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	providers := Providers{}
+	add := func(name string, endpoint oauth2.Endpoint, scopes []string, identity func(context.Context, *http.Client) (*Identity, error)) {
+		prefix := strings.ToUpper(name) + "_"
+		id, secret := os.Getenv(prefix+"CLIENT_ID"), os.Getenv(prefix+"CLIENT_SECRET")
+		if id == "" || secret == "" {
+			return
+		}
+		providers[name] = &Provider{
+			Name: name,
+			Config: &oauth2.Config{
+				ClientID:     id,
+				ClientSecret: secret,
+				Endpoint:     endpoint,
+				RedirectURL:  baseURL + "/api/auth/" + name + "/callback",
+				Scopes:       scopes,
+			},
+			identity: identity,
+		}
+	}
+	add("google", endpoints.Google, []string{"openid", "email"}, googleIdentity)
+	add("github", endpoints.GitHub, []string{"read:user", "user:email"}, githubIdentity)
+	return providers
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func googleIdentity(ctx context.Context, client *http.Client) (*Identity, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := getJSON(ctx, client, "https://openidconnect.googleapis.com/v1/userinfo", &info); err != nil {
+		return nil, err
+	}
+	return &Identity{Subject: info.Sub, Email: info.Email, EmailVerified: info.EmailVerified}, nil
+}
+
+// githubIdentity uses the primary email of the account, which GitHub only
+// reports as verified once the user confirmed it.
+func githubIdentity(ctx context.Context, client *http.Client) (*Identity, error) {
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user", &user); err != nil {
+		return nil, err
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user/emails", &emails); err != nil {
+		return nil, err
+	}
+	identity := &Identity{Subject: strconv.FormatInt(user.ID, 10)}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email, identity.EmailVerified = e.Email, e.Verified
+		}
+	}
+	return identity, nil
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/models"
+)
+
+// AdSpendStore keeps uploaded ad spend in PostgreSQL and relates it to the
+// revenue of acquired visitors in ClickHouse.
+type AdSpendStore struct {
+	db *sql.DB
+	ch *database.ClickHouseClient
+}
+
+func NewAdSpendStore(db *sql.DB, chClient *database.ClickHouseClient) *AdSpendStore {
+	return &AdSpendStore{db: db, ch: chClient}
+}
+
+// UpsertSpend stores the entries for the project in one transaction, replacing
+// earlier uploads for the same campaign, date and source.
+func (s *AdSpendStore) UpsertSpend(ctx context.Context, projectID string, entries []models.AdSpendEntry, uploadedBy int) error {
+	var uploader interface{}
+	if uploadedBy != 0 {
+		uploader = uploadedBy
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin ad spend upload: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO ad_spend (project_id, campaign, spend_date, source, spend, currency, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (project_id, campaign, spend_date, source) DO UPDATE
+		SET spend = EXCLUDED.spend,
+		    currency = EXCLUDED.currency,
+		    uploaded_by = EXCLUDED.uploaded_by,
+		    updated_at = CURRENT_TIMESTAMP;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare ad spend upload: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		if _, err := stmt.ExecContext(ctx, projectID, e.Campaign, e.Date, e.Source, e.Spend, e.Currency, uploader); err != nil {
+			return fmt.Errorf("failed to store ad spend for campaign %s on %s: %w", e.Campaign, e.Date, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit ad spend upload: %w", err)
+	}
+	return nil
+}
+
+// ListSpend returns the project's spend on the days from start to end, by
+// date and campaign.
+func (s *AdSpendStore) ListSpend(ctx context.Context, projectID string, start, end time.Time) ([]models.AdSpend, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT campaign, spend_date, source, spend, currency, uploaded_by, updated_at
+		FROM ad_spend
+		WHERE project_id = $1 AND spend_date BETWEEN $2::DATE AND $3::DATE
+		ORDER BY spend_date, campaign, source;
+	`, projectID, start.UTC().Format(models.DateFormat), end.UTC().Format(models.DateFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ad spend: %w", err)
+	}
+	defer rows.Close()
+
+	spend := []models.AdSpend{}
+	for rows.Next() {
+		var a models.AdSpend
+		var date time.Time
+		var uploadedBy sql.NullInt64
+		if err := rows.Scan(&a.Campaign, &date, &a.Source, &a.Spend, &a.Currency, &uploadedBy, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ad spend: %w", err)
+		}
+		a.Date = date.Format(models.DateFormat)
+		if uploadedBy.Valid {
+			id := int(uploadedBy.Int64)
+			a.UploadedBy = &id
+		}
+		spend = append(spend, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ad spend: %w", err)
+	}
+	return spend, nil
+}
+
+// calendarBucket truncates a UTC day to the start of its Day, Week (Monday) or
+// Month bucket.
+func calendarBucket(day time.Time, interval string) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case "Week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "Month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// GetCampaignROI returns spend, attributed revenue and ROAS per campaign and
+// Day, Week or Month bucket. Revenue is that of purchase events by visitors
+// whose first-touch utm_campaign is the campaign, on the day of the purchase.
+func (s *AdSpendStore) GetCampaignROI(ctx context.Context, projectID, interval string, start, end time.Time, filters EventFilters) ([]models.CampaignROIPoint, error) {
+	switch interval {
+	case "Day", "Week", "Month":
+	default:
+		return nil, fmt.Errorf("%w: interval %q must be Day, Week or Month", ErrInvalid, interval)
+	}
+
+	type key struct {
+		bucket   time.Time
+		campaign string
+	}
+	points := map[key]*models.CampaignROIPoint{}
+	point := func(day time.Time, campaign string) *models.CampaignROIPoint {
+		k := key{calendarBucket(day, interval), campaign}
+		if p, ok := points[k]; ok {
+			return p
+		}
+		p := &models.CampaignROIPoint{Time: k.bucket, Campaign: campaign}
+		points[k] = p
+		return p
+	}
+
+	spend, err := s.ListSpend(ctx, projectID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range spend {
+		day, _ := time.Parse(models.DateFormat, a.Date)
+		point(day, a.Campaign).Spend += a.Spend
+	}
+
+	join, err := firstTouchJoin("utm_campaign")
+	if err != nil {
+		return nil, err
+	}
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{projectID, start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT toDate(timestamp) AS day, first_touch_value AS campaign,
+		       `+sampledSum("JSONExtractFloat(toString(event_data), 'revenue')")+` AS revenue, `+sampledCount+` AS purchases
+		FROM analytics_events
+		%s
+		WHERE event_type = '%s' AND analytics_events.project_id = ? AND first_touch_value != '' AND %s%s
+		GROUP BY day, campaign
+	`, join, models.EventTypePurchase, timeRangeClause, filterClause)
+
+	rows, err := s.ch.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaign revenue: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day time.Time
+		var campaign string
+		var revenue float64
+		var purchases uint64
+		if err := rows.Scan(&day, &campaign, &revenue, &purchases); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign revenue: %w", err)
+		}
+		p := point(day, campaign)
+		p.Revenue += revenue
+		p.Purchases += purchases
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating campaign revenue: %w", err)
+	}
+
+	results := make([]models.CampaignROIPoint, 0, len(points))
+	for _, p := range points {
+		if p.Spend > 0 {
+			roas := p.Revenue / p.Spend
+			p.ROAS = &roas
+		}
+		results = append(results, *p)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Time.Equal(results[j].Time) {
+			return results[i].Time.Before(results[j].Time)
+		}
+		return results[i].Campaign < results[j].Campaign
+	})
+	return results, nil
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// GetAlertMetric returns an alert metric for the project's events of eventType
+// between start and end: the number of events, or of distinct visitors.
+func (s *AnalyticsStore) GetAlertMetric(ctx context.Context, projectID, metric, eventType string, start, end time.Time) (float64, error) {
+	var expr string
+	switch metric {
+	case models.AlertMetricCount:
+		expr = sampledCount
+	case models.AlertMetricUniqueUsers:
+		expr = fmt.Sprintf("uniq(%s)", visitorExpr)
+	default:
+		return 0, fmt.Errorf("invalid alert metric: %s", metric)
+	}
+
+	filterClause, filterArgs := EventFilters{ProjectID: projectID, EventTypes: []string{eventType}}.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT toFloat64(%s)
+		FROM analytics_events
+		WHERE %s%s
+	`, expr, timeRangeClause, filterClause)
+
+	var value float64
+	if err := s.queryRow(ctx, query, args...).Scan(&value); err != nil {
+		return 0, fmt.Errorf("failed to query alert metric: %w", err)
+	}
+	return value, nil
+}
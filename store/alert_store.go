@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// AlertStore keeps alert definitions, their current state and the history of
+// state transitions in PostgreSQL.
+type AlertStore struct {
+	db *sql.DB
+}
+
+func NewAlertStore(db *sql.DB) *AlertStore {
+	return &AlertStore{db: db}
+}
+
+const alertColumns = `id, project_id, name, event_type, metric, condition, threshold, window_seconds, compare_offset_seconds, webhook_url, enabled, state, last_value, last_evaluated_at, last_triggered_at, created_by, created_at, updated_at`
+
+func scanAlert(row rowScanner) (*models.Alert, error) {
+	var (
+		alert           models.Alert
+		lastValue       sql.NullFloat64
+		lastEvaluatedAt sql.NullTime
+		lastTriggeredAt sql.NullTime
+		createdBy       sql.NullInt64
+	)
+	err := row.Scan(&alert.ID, &alert.ProjectID, &alert.Name, &alert.EventType, &alert.Metric, &alert.Condition, &alert.Threshold,
+		&alert.WindowSeconds, &alert.CompareOffsetSeconds, &alert.WebhookURL, &alert.Enabled, &alert.State,
+		&lastValue, &lastEvaluatedAt, &lastTriggeredAt, &createdBy, &alert.CreatedAt, &alert.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastValue.Valid {
+		alert.LastValue = &lastValue.Float64
+	}
+	if lastEvaluatedAt.Valid {
+		alert.LastEvaluatedAt = &lastEvaluatedAt.Time
+	}
+	if lastTriggeredAt.Valid {
+		alert.LastTriggeredAt = &lastTriggeredAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		alert.CreatedBy = &id
+	}
+	return &alert, nil
+}
+
+func normalizeAlert(req *models.AlertRequest) {
+	if req.Metric == "" {
+		req.Metric = models.AlertMetricCount
+	}
+	switch req.Condition {
+	case models.AlertConditionDropPct, models.AlertConditionRisePct:
+		if req.CompareOffsetSeconds == 0 {
+			req.CompareOffsetSeconds = int((24 * time.Hour).Seconds())
+		}
+	default:
+		req.CompareOffsetSeconds = 0
+	}
+	if req.Enabled == nil {
+		enabled := true
+		req.Enabled = &enabled
+	}
+}
+
+func (s *AlertStore) CreateAlert(ctx context.Context, projectID string, createdBy int, req models.AlertRequest) (*models.Alert, error) {
+	normalizeAlert(&req)
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO alerts (project_id, name, event_type, metric, condition, threshold, window_seconds, compare_offset_seconds, webhook_url, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING `+alertColumns+`;
+	`, projectID, req.Name, req.EventType, req.Metric, req.Condition, *req.Threshold, req.WindowSeconds, req.CompareOffsetSeconds, req.WebhookURL, *req.Enabled, creator)
+	alert, err := scanAlert(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alert: %w", err)
+	}
+	return alert, nil
+}
+
+// ListAlerts returns the project's alerts.
+func (s *AlertStore) ListAlerts(ctx context.Context, projectID string) ([]models.Alert, error) {
+	return s.listAlerts(ctx, `SELECT `+alertColumns+` FROM alerts WHERE project_id = $1 ORDER BY id;`, projectID)
+}
+
+// ListEnabledAlerts returns the enabled alerts of every project.
+func (s *AlertStore) ListEnabledAlerts(ctx context.Context) ([]models.Alert, error) {
+	return s.listAlerts(ctx, `SELECT `+alertColumns+` FROM alerts WHERE enabled ORDER BY id;`)
+}
+
+func (s *AlertStore) listAlerts(ctx context.Context, query string, args ...interface{}) ([]models.Alert, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []models.Alert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		alerts = append(alerts, *alert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+func (s *AlertStore) GetAlert(ctx context.Context, projectID string, id int) (*models.Alert, error) {
+	alert, err := scanAlert(s.db.QueryRowContext(ctx, `SELECT `+alertColumns+` FROM alerts WHERE id = $1 AND project_id = $2;`, id, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+	return alert, nil
+}
+
+// UpdateAlert replaces the alert's definition. Its state is reset to ok, so a
+// firing alert whose definition changed notifies again if it still fires.
+func (s *AlertStore) UpdateAlert(ctx context.Context, projectID string, id int, req models.AlertRequest) (*models.Alert, error) {
+	normalizeAlert(&req)
+
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE alerts
+		SET name = $3, event_type = $4, metric = $5, condition = $6, threshold = $7, window_seconds = $8,
+		    compare_offset_seconds = $9, webhook_url = $10, enabled = $11, state = 'ok', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+alertColumns+`;
+	`, id, projectID, req.Name, req.EventType, req.Metric, req.Condition, *req.Threshold, req.WindowSeconds, req.CompareOffsetSeconds, req.WebhookURL, *req.Enabled)
+	alert, err := scanAlert(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update alert: %w", err)
+	}
+	return alert, nil
+}
+
+func (s *AlertStore) DeleteAlert(ctx context.Context, projectID string, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM alerts WHERE id = $1 AND project_id = $2;`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("alert %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// RecordEvaluation stores the outcome of evaluating an alert at evaluatedAt.
+// When state differs from the stored state the transition is added to the
+// alert's history along with the notification outcome.
+func (s *AlertStore) RecordEvaluation(ctx context.Context, alert *models.Alert, state string, value float64, baseline *float64, evaluatedAt time.Time, notifyErr error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var triggeredAt interface{}
+	if state == models.AlertStateFiring && alert.State != models.AlertStateFiring {
+		triggeredAt = evaluatedAt
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE alerts
+		SET state = $2, last_value = $3, last_evaluated_at = $4, last_triggered_at = COALESCE($5, last_triggered_at)
+		WHERE id = $1;
+	`, alert.ID, state, value, evaluatedAt, triggeredAt); err != nil {
+		return fmt.Errorf("failed to update alert %d state: %w", alert.ID, err)
+	}
+
+	if state != alert.State {
+		var baselineValue interface{}
+		if baseline != nil {
+			baselineValue = *baseline
+		}
+		notifyError := ""
+		if notifyErr != nil {
+			notifyError = notifyErr.Error()
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO alert_events (alert_id, state, value, baseline, notified, notify_error, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7);
+		`, alert.ID, state, value, baselineValue, alert.WebhookURL != "" && notifyErr == nil, notifyError, evaluatedAt); err != nil {
+			return fmt.Errorf("failed to record alert %d event: %w", alert.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit alert %d evaluation: %w", alert.ID, err)
+	}
+	return nil
+}
+
+// ListAlertEvents returns the alert's state transitions, newest first.
+func (s *AlertStore) ListAlertEvents(ctx context.Context, alertID int, limit uint64) ([]models.AlertEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, alert_id, state, value, baseline, notified, notify_error, created_at
+		FROM alert_events
+		WHERE alert_id = $1
+		ORDER BY id DESC
+		LIMIT $2;
+	`, alertID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AlertEvent{}
+	for rows.Next() {
+		var (
+			event    models.AlertEvent
+			baseline sql.NullFloat64
+		)
+		if err := rows.Scan(&event.ID, &event.AlertID, &event.State, &event.Value, &baseline, &event.Notified, &event.NotifyError, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert event: %w", err)
+		}
+		if baseline.Valid {
+			event.Baseline = &baseline.Float64
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating alert events: %w", err)
+	}
+	return events, nil
+}
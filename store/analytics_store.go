@@ -2,29 +2,53 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
 	"math"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
 	"mabletask/api/database"
+	"mabletask/api/logging"
 	"mabletask/api/models"
 	"mabletask/api/utils"
 )
 
 type AnalyticsStore struct {
-	DB *database.ClickHouseClient
+	DB     *database.ClickHouseClient
+	Ingest *IngestStats
+	// Limiter bounds concurrent read queries; nil means unlimited.
+	Limiter *QueryLimiter
 }
 
 type EventTypeCountByTime struct {
 	Time      time.Time `json:"time"`
 	EventType *string   `json:"eventType,omitempty"`
+	Breakdown *string   `json:"breakdown,omitempty"`
 	Count     uint64    `json:"count"`
+	// ComparedTime is set on points of a comparison series to their own
+	// bucket, while Time is the bucket of the current range they line up with.
+	ComparedTime *time.Time `json:"comparedTime,omitempty"`
+}
+
+// timeRangeClause filters on the DateTime64(3) timestamp column. Bounds are bound
+// as Unix milliseconds because the driver formats time.Time arguments with
+// second precision, which would silently drop sub-second ordering.
+const timeRangeClause = "timestamp >= fromUnixTimestamp64Milli(toInt64(?), 'UTC') AND timestamp <= fromUnixTimestamp64Milli(toInt64(?), 'UTC')"
+
+// timeBucket returns the ClickHouse expression grouping timestamps into the
+// given interval. The interval must already be validated with utils.IsValidInterval.
+func timeBucket(interval string) string {
+	return fmt.Sprintf("toStartOf%s(timestamp)", interval)
 }
 
 func NewAnalyticsStore(chClient *database.ClickHouseClient) *AnalyticsStore {
 	return &AnalyticsStore{
-		DB: chClient,
+		DB:     chClient,
+		Ingest: NewIngestStats(),
 	}
 }
 
@@ -33,78 +57,187 @@ func (s *AnalyticsStore) InsertAnalyticsEvents(ctx context.Context, events []mod
 		return nil
 	}
 
+	started := time.Now()
+	if err := s.insertEvents(ctx, "analytics_events", events); err != nil {
+		s.Ingest.RecordFailure(len(events), err)
+		return err
+	}
+	s.Ingest.RecordFlush(len(events), time.Since(started))
+
+	logging.Ctx(ctx).Debug().Msgf("Successfully inserted %d analytics events.", len(events))
+	return nil
+}
+
+// InsertEventBatch inserts events like InsertAnalyticsEvents for callers that
+// retry failed inserts themselves: only successful inserts are counted in the
+// ingestion statistics, and callers record the events they give up on.
+func (s *AnalyticsStore) InsertEventBatch(ctx context.Context, events []models.AnalyticsEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	started := time.Now()
+	if err := s.insertEvents(ctx, "analytics_events", events); err != nil {
+		return err
+	}
+	s.Ingest.RecordFlush(len(events), time.Since(started))
+	return nil
+}
+
+// insertEvents writes events to table, which must have the analytics_events schema.
+func (s *AnalyticsStore) insertEvents(ctx context.Context, table string, events []models.AnalyticsEvent) error {
 	batch, err := s.DB.Conn.PrepareBatch(ctx, `
-		INSERT INTO analytics_events (
+		INSERT INTO `+table+` (
 			event_id, event_type, user_id, session_id, timestamp, page_path, referrer, user_agent,
-			ip_address, duration_ms, products, location, event_data
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ip_address, duration_ms, location, event_data, client_timestamp, group_id,
+			anonymous_id, project_id, country, device_type, browser,
+			products.id, products.sku, products.name, products.price, products.quantity, products.currency,
+			experiments.id, experiments.variant, web_vital_name, web_vital_value, web_vital_rating,
+			destination_url, form_id, revenue, currency, region, city, browser_version, os,
+			utm_source, utm_medium, utm_campaign, utm_term, utm_content, channel, schema_errors, is_bot, sample_rate
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare batch insert: %w", err)
 	}
 
 	for _, event := range events {
+		var clientTimestamp *time.Time
+		if event.ClientTimestamp != nil {
+			ts := event.ClientTimestamp.UTC().Truncate(time.Millisecond)
+			clientTimestamp = &ts
+		}
+		products := newProductColumns(event.Products)
+		experiments := newExperimentColumns(exposedExperiments(&event))
+		vital := newWebVitalColumns(&event)
+		revenue := newRevenueColumns(&event)
 		err := batch.Append(
 			event.EventID,
 			event.EventType,
 			event.UserID,
 			event.SessionID,
-			event.Timestamp,
+			event.Timestamp.UTC().Truncate(time.Millisecond),
 			event.PagePath,
 			event.Referrer,
 			event.UserAgent,
 			event.IPAddress,
 			event.DurationMs,
-			event.Products,
 			event.Location,
 			event.EventData,
+			clientTimestamp,
+			event.GroupID,
+			event.AnonymousID,
+			event.ProjectID,
+			event.Country,
+			event.DeviceType,
+			event.Browser,
+			products.ids,
+			products.skus,
+			products.names,
+			products.prices,
+			products.quantities,
+			products.currencies,
+			experiments.ids,
+			experiments.variants,
+			vital.name,
+			vital.value,
+			vital.rating,
+			outboundDestination(&event),
+			formID(&event),
+			revenue.revenue,
+			revenue.currency,
+			event.Region,
+			event.City,
+			event.BrowserVersion,
+			event.OS,
+			event.UTMSource,
+			event.UTMMedium,
+			event.UTMCampaign,
+			event.UTMTerm,
+			event.UTMContent,
+			event.Channel,
+			schemaErrors(&event),
+			event.IsBot,
+			sampleRate(&event),
 		)
 		if err != nil {
-			log.Printf("Error appending event to batch (EventID: %s): %v", event.EventID, err)
+			logging.Ctx(ctx).Error().Err(err).Msgf("Error appending event to batch (EventID: %s)", event.EventID)
 		}
 	}
 
-	err = batch.Send()
-	if err != nil {
+	if err := batch.Send(); err != nil {
 		return fmt.Errorf("failed to send batch: %w", err)
 	}
-
-	log.Printf("Successfully inserted %d analytics events.", len(events))
 	return nil
 }
 
-func (s *AnalyticsStore) GetEventCountsOverTime(ctx context.Context, interval string, start, end time.Time, eventTypeFilter string) ([]EventTypeCountByTime, error) {
+// DefaultBreakdownLimit is how many breakdown values get their own series
+// before the remaining ones are grouped as "other".
+const DefaultBreakdownLimit = 10
+
+// GetEventCountsOverTime counts events per time bucket. With a breakdown
+// dimension, the breakdownLimit most frequent values over the whole range get
+// one series each and all other values are counted as "other". When filtering
+// by event types, each type is counted separately as well.
+func (s *AnalyticsStore) GetEventCountsOverTime(ctx context.Context, interval string, start, end time.Time, breakdown string, breakdownLimit uint64, filters EventFilters) ([]EventTypeCountByTime, error) {
 	var query string
 	var args []interface{}
-	args = append(args, start, end)
 
 	if !utils.IsValidInterval(interval) {
 		return nil, fmt.Errorf("invalid interval: %s", interval)
 	}
 
-	selectCols := fmt.Sprintf("toStartOf%s(timestamp) as time_bucket, count() as total_events", interval)
+	selectCols := fmt.Sprintf("%s as time_bucket, %s as total_events", timeBucket(interval), sampledCount)
 	groupByCols := "time_bucket"
-	whereClause := "WHERE timestamp >= ? AND timestamp <= ?"
+	joinClause := ""
+	whereClause := "WHERE " + timeRangeClause
 	orderByCols := "time_bucket ASC"
-	isFilteringByType := eventTypeFilter != ""
+	isFilteringByType := len(filters.EventTypes) > 0
+	isBreakdown := breakdown != ""
+
+	whereArgs := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	filterClause, filterArgs := filters.clause()
+	whereClause += filterClause
+	whereArgs = append(whereArgs, filterArgs...)
+
+	if isBreakdown {
+		expr, join, joinArgs, err := breakdownDimension(breakdown)
+		if err != nil {
+			return nil, err
+		}
+		if breakdownLimit == 0 {
+			breakdownLimit = DefaultBreakdownLimit
+		}
+		// The top values are ranked over the same events, and by the same
+		// sampled counts, as the series.
+		topValues := fmt.Sprintf("SELECT %s FROM analytics_events %s %s GROUP BY %s ORDER BY %s DESC LIMIT %d",
+			expr, join, whereClause, expr, sampledCount, breakdownLimit)
+		selectCols += fmt.Sprintf(", if(%s IN (%s), %s, 'other') AS breakdown_series", expr, topValues, expr)
+		args = append(args, joinArgs...)
+		args = append(args, whereArgs...)
+		joinClause = join
+		args = append(args, joinArgs...)
+		groupByCols += ", breakdown_series"
+		orderByCols += ", breakdown_series ASC"
+	}
 
 	if isFilteringByType {
 		selectCols += ", event_type"
 		groupByCols += ", event_type"
-		whereClause += " AND event_type = ?"
-		args = append(args, eventTypeFilter)
 		orderByCols += ", event_type ASC"
 	}
 
+	args = append(args, whereArgs...)
+
 	query = fmt.Sprintf(`
 		SELECT %s
 		FROM analytics_events
 		%s
+		%s
 		GROUP BY %s
 		ORDER BY %s
-	`, selectCols, whereClause, groupByCols, orderByCols)
+	`, selectCols, joinClause, whereClause, groupByCols, orderByCols)
 
-	rows, err := s.DB.Conn.Query(ctx, query, args...)
+	rows, err := s.query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query event counts over time: %w", err)
 	}
@@ -113,24 +246,29 @@ func (s *AnalyticsStore) GetEventCountsOverTime(ctx context.Context, interval st
 	var results []EventTypeCountByTime
 	for rows.Next() {
 		var (
-			timeBucket    time.Time
-			count         uint64
-			eventTypeDB   string
-			currentResult EventTypeCountByTime
+			timeBucket     time.Time
+			count          uint64
+			eventTypeDB    string
+			breakdownValue string
+			currentResult  EventTypeCountByTime
 		)
 
+		dest := []interface{}{&timeBucket, &count}
+		if isBreakdown {
+			dest = append(dest, &breakdownValue)
+		}
+		if isFilteringByType {
+			dest = append(dest, &eventTypeDB)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for event counts over time")
+			continue
+		}
+		if isBreakdown {
+			currentResult.Breakdown = &breakdownValue
+		}
 		if isFilteringByType {
-			if err := rows.Scan(&timeBucket, &count, &eventTypeDB); err != nil {
-				log.Printf("Error scanning row for event counts over time (with type filter): %v", err)
-				continue
-			}
 			currentResult.EventType = &eventTypeDB
-		} else {
-			if err := rows.Scan(&timeBucket, &count); err != nil {
-				log.Printf("Error scanning row for event counts over time (no type filter): %v", err)
-				continue
-			}
-			currentResult.EventType = nil
 		}
 
 		currentResult.Time = timeBucket
@@ -145,20 +283,19 @@ func (s *AnalyticsStore) GetEventCountsOverTime(ctx context.Context, interval st
 	return results, nil
 }
 
-func (s *AnalyticsStore) GetAverageEventDuration(ctx context.Context, eventTypeFilter string, start, end time.Time) (float64, error) {
+func (s *AnalyticsStore) GetAverageEventDuration(ctx context.Context, start, end time.Time, filters EventFilters) (float64, error) {
 	var query string
 	var args []interface{}
 
-	query = `SELECT avg(duration_ms) FROM analytics_events WHERE timestamp >= ? AND timestamp <= ?`
-	args = append(args, start, end)
+	query = `SELECT avg(duration_ms) FROM analytics_events WHERE ` + timeRangeClause
+	args = append(args, start.UnixMilli(), end.UnixMilli())
 
-	if eventTypeFilter != "" {
-		query += ` AND event_type = ?`
-		args = append(args, eventTypeFilter)
-	}
+	filterClause, filterArgs := filters.clause()
+	query += filterClause
+	args = append(args, filterArgs...)
 
 	var avgDuration float64
-	err := s.DB.Conn.QueryRow(ctx, query, args...).Scan(&avgDuration)
+	err := s.queryRow(ctx, query, args...).Scan(&avgDuration)
 	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
 			return 0.0, nil
@@ -169,21 +306,54 @@ func (s *AnalyticsStore) GetAverageEventDuration(ctx context.Context, eventTypeF
 	return avgDuration, nil
 }
 
-func (s *AnalyticsStore) GetAverageCustomEventParameter(ctx context.Context, eventTypeFilter, paramName string, start, end time.Time) (float64, error) {
-	if paramName == "" {
-		return 0.0, fmt.Errorf("parameter name for average calculation cannot be empty")
+// GetEventDurationPercentiles returns the 50th, 90th, 95th and 99th percentile
+// of duration_ms over the matching events, 0 when there are none.
+func (s *AnalyticsStore) GetEventDurationPercentiles(ctx context.Context, start, end time.Time, filters EventFilters) (*models.DurationPercentiles, error) {
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	query := `
+		SELECT arrayMap(q -> ifNotFinite(q, 0), quantiles(0.5, 0.9, 0.95, 0.99)(duration_ms))
+		FROM analytics_events
+		WHERE ` + timeRangeClause + filterClause
+
+	var q []float64
+	if err := s.queryRow(ctx, query, args...).Scan(&q); err != nil {
+		return nil, fmt.Errorf("failed to query event duration percentiles: %w", err)
 	}
+	if len(q) != 4 {
+		return nil, fmt.Errorf("unexpected event duration quantiles: %v", q)
+	}
+	return &models.DurationPercentiles{P50: q[0], P90: q[1], P95: q[2], P99: q[3]}, nil
+}
+
+// eventDataKeyPattern matches the event_data keys stats may be computed over.
+var eventDataKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// GetAverageCustomEventParameter averages a numeric event_data field over the
+// events of filters.EventTypes, which must not be empty.
+func (s *AnalyticsStore) GetAverageCustomEventParameter(ctx context.Context, paramName string, start, end time.Time, filters EventFilters) (float64, error) {
+	if !eventDataKeyPattern.MatchString(paramName) {
+		return 0.0, fmt.Errorf("%w: parameter name %q must be a letter or underscore followed by up to 63 letters, digits or underscores", ErrInvalid, paramName)
+	}
+	if len(filters.EventTypes) == 0 {
+		return 0.0, fmt.Errorf("event type for average calculation cannot be empty")
+	}
+
+	filterClause, filterArgs := filters.clause()
 
 	query := fmt.Sprintf(`
-		SELECT avg(JSONExtractFloat(toString(event_data), '%s'))
+		SELECT avg(JSONExtractFloat(toString(event_data), ?))
 		FROM analytics_events
-		WHERE event_type = ? AND timestamp >= ? AND timestamp <= ?
-	`, paramName)
+		WHERE %s%s
+	`, timeRangeClause, filterClause)
 
-	args := []interface{}{eventTypeFilter, start, end}
+	args := []interface{}{paramName, start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
 
 	var avgValue float64
-	err := s.DB.Conn.QueryRow(ctx, query, args...).Scan(&avgValue)
+	err := s.queryRow(ctx, query, args...).Scan(&avgValue)
 	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
 			return 0.0, nil
@@ -198,20 +368,29 @@ func (s *AnalyticsStore) GetAverageCustomEventParameter(ctx context.Context, eve
 	return avgValue, nil
 }
 
-func (s *AnalyticsStore) GetUniqueUsersOverTime(ctx context.Context, interval string, start, end time.Time) ([]EventTypeCountByTime, error) {
+func (s *AnalyticsStore) GetUniqueUsersOverTime(ctx context.Context, interval string, start, end time.Time, filters EventFilters) ([]EventTypeCountByTime, error) {
 	if !utils.IsValidInterval(interval) {
 		return nil, fmt.Errorf("invalid interval: %s", interval)
 	}
 
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	// Each user is weighted by the sample rate of their events in the bucket.
 	query := fmt.Sprintf(`
-		SELECT toStartOf%s(timestamp) AS time_bucket, uniq(user_id) AS unique_users
-		FROM analytics_events
-		WHERE timestamp >= ? AND timestamp <= ?
+		SELECT time_bucket, %s AS unique_users
+		FROM (
+			SELECT %s AS time_bucket, user_id, %s AS weight
+			FROM analytics_events
+			WHERE %s%s
+			GROUP BY time_bucket, user_id
+		)
 		GROUP BY time_bucket
 		ORDER BY time_bucket ASC
-	`, interval)
+	`, weightedCount, timeBucket(interval), sampledWeight, timeRangeClause, filterClause)
 
-	rows, err := s.DB.Conn.Query(ctx, query, start, end)
+	rows, err := s.query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query unique users over time: %w", err)
 	}
@@ -222,7 +401,7 @@ func (s *AnalyticsStore) GetUniqueUsersOverTime(ctx context.Context, interval st
 		var timeBucket time.Time
 		var uniqueUsers uint64
 		if err := rows.Scan(&timeBucket, &uniqueUsers); err != nil {
-			log.Printf("Error scanning row for unique users: %v", err)
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for unique users")
 			continue
 		}
 		results = append(results, EventTypeCountByTime{
@@ -238,20 +417,25 @@ func (s *AnalyticsStore) GetUniqueUsersOverTime(ctx context.Context, interval st
 	return results, nil
 }
 
-func (s *AnalyticsStore) GetTopNPagePaths(ctx context.Context, start, end time.Time, limit uint64) ([]models.TopPathResult, error) {
+func (s *AnalyticsStore) GetTopNPagePaths(ctx context.Context, start, end time.Time, limit uint64, filters EventFilters) ([]models.TopPathResult, error) {
 	if limit == 0 {
 		limit = 10
 	}
 
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
 	query := `
-		SELECT page_path, count() as view_count
+		SELECT page_path, ` + sampledCount + ` as view_count
 		FROM analytics_events
-		WHERE event_type = 'page_view' AND timestamp >= ? AND timestamp <= ?
+		WHERE event_type = 'page_view' AND ` + timeRangeClause + filterClause + `
 		GROUP BY page_path
 		ORDER BY view_count DESC
 		LIMIT ?
 	`
-	rows, err := s.DB.Conn.Query(ctx, query, start, end, limit)
+	rows, err := s.query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query top page paths: %w", err)
 	}
@@ -262,7 +446,7 @@ func (s *AnalyticsStore) GetTopNPagePaths(ctx context.Context, start, end time.T
 		var pagePath string
 		var count uint64
 		if err := rows.Scan(&pagePath, &count); err != nil {
-			log.Printf("Error scanning row for top page paths: %v", err)
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for top page paths")
 			continue
 		}
 		results = append(results, models.TopPathResult{
@@ -277,3 +461,250 @@ func (s *AnalyticsStore) GetTopNPagePaths(ctx context.Context, start, end time.T
 
 	return results, nil
 }
+
+// GetTopNReferrers returns the hosts that referred the most page views.
+// Referrers are normalized to their host without www., so links from any page
+// of a site count together; page views without a referrer are left out.
+func (s *AnalyticsStore) GetTopNReferrers(ctx context.Context, start, end time.Time, limit uint64, filters EventFilters) ([]models.TopReferrerResult, error) {
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := `
+		SELECT domainWithoutWWW(if(position(referrer, '://') > 0, referrer, concat('//', referrer))) AS referrer_host,
+		       ` + sampledCount + ` as view_count
+		FROM analytics_events
+		WHERE event_type = 'page_view' AND referrer != '' AND ` + timeRangeClause + filterClause + `
+		GROUP BY referrer_host
+		HAVING referrer_host != ''
+		ORDER BY view_count DESC, referrer_host
+		LIMIT ?
+	`
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top referrers: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.TopReferrerResult
+	for rows.Next() {
+		var r models.TopReferrerResult
+		if err := rows.Scan(&r.Referrer, &r.Count); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for top referrers")
+			continue
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for top referrers: %w", err)
+	}
+
+	return results, nil
+}
+
+// eventColumns selects every analytics_events column in the order expected by scanEvent.
+const eventColumns = `
+	toString(event_id), event_type, user_id, session_id, timestamp, page_path, referrer, user_agent,
+	ip_address, duration_ms, location, toString(event_data), client_timestamp, group_id,
+	anonymous_id, project_id, country, device_type, browser,
+	products.id, products.sku, products.name, products.price, products.quantity, products.currency,
+	experiments.id, experiments.variant, region, city, browser_version, os,
+	utm_source, utm_medium, utm_campaign, utm_term, utm_content, channel, schema_errors, is_bot, sample_rate
+`
+
+func scanEvent(rows driver.Rows) (models.AnalyticsEvent, error) {
+	var (
+		event       models.AnalyticsEvent
+		products    productColumns
+		experiments experimentColumns
+		eventData   string
+	)
+	err := rows.Scan(
+		&event.EventID,
+		&event.EventType,
+		&event.UserID,
+		&event.SessionID,
+		&event.Timestamp,
+		&event.PagePath,
+		&event.Referrer,
+		&event.UserAgent,
+		&event.IPAddress,
+		&event.DurationMs,
+		&event.Location,
+		&eventData,
+		&event.ClientTimestamp,
+		&event.GroupID,
+		&event.AnonymousID,
+		&event.ProjectID,
+		&event.Country,
+		&event.DeviceType,
+		&event.Browser,
+		&products.ids,
+		&products.skus,
+		&products.names,
+		&products.prices,
+		&products.quantities,
+		&products.currencies,
+		&experiments.ids,
+		&experiments.variants,
+		&event.Region,
+		&event.City,
+		&event.BrowserVersion,
+		&event.OS,
+		&event.UTMSource,
+		&event.UTMMedium,
+		&event.UTMCampaign,
+		&event.UTMTerm,
+		&event.UTMContent,
+		&event.Channel,
+		&event.SchemaErrors,
+		&event.IsBot,
+		&event.SampleRate,
+	)
+	if err != nil {
+		return event, err
+	}
+	event.Products = products.lineItems()
+	event.Experiments = experiments.assignments()
+	if eventData != "" && eventData != "{}" {
+		event.EventData = json.RawMessage(eventData)
+	}
+	return event, nil
+}
+
+// ForEachUserEvent streams every event recorded for userID in projectID in
+// timestamp order.
+func (s *AnalyticsStore) ForEachUserEvent(ctx context.Context, projectID, userID string, fn func(models.AnalyticsEvent) error) error {
+	query := `SELECT ` + eventColumns + ` FROM analytics_events WHERE project_id = ? AND user_id = ? ORDER BY timestamp`
+	rows, err := s.query(ctx, query, projectID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query events for user: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan event for user: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating events for user: %w", err)
+	}
+	return nil
+}
+
+// visitorExpr identifies the person behind an event: the known user when
+// available, otherwise the anonymous visitor, otherwise the session.
+const visitorExpr = "if(user_id != '', user_id, if(anonymous_id != '', anonymous_id, session_id))"
+
+// DefaultFunnelWindow is used when a funnel does not specify a conversion window.
+const DefaultFunnelWindow = 24 * time.Hour
+
+// GetFunnel computes how many visitors completed each funnel step, in order,
+// within the conversion window, using ClickHouse's windowFunnel.
+func (s *AnalyticsStore) GetFunnel(ctx context.Context, steps []models.FunnelStepDefinition, window time.Duration, start, end time.Time, filters EventFilters) ([]models.FunnelStepResult, error) {
+	if len(steps) < 2 {
+		return nil, fmt.Errorf("a funnel needs at least two steps")
+	}
+	if window <= 0 {
+		window = DefaultFunnelWindow
+	}
+
+	var conds []string
+	var args []interface{}
+	args = append(args, int64(window.Seconds()))
+	for _, step := range steps {
+		cond := "event_type = ?"
+		args = append(args, step.EventType)
+		if step.PagePath != "" {
+			cond += " AND page_path = ?"
+			args = append(args, step.PagePath)
+		}
+		conds = append(conds, "("+cond+")")
+	}
+
+	stepTypes := make([]string, 0, len(steps))
+	for _, step := range steps {
+		stepTypes = append(stepTypes, step.EventType)
+	}
+	filterClause, filterArgs := filters.clause()
+	args = append(args, start.UnixMilli(), end.UnixMilli(), stepTypes)
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT level, `+weightedCount+` AS visitors
+		FROM (
+			SELECT %s AS visitor, windowFunnel(?)(toDateTime(timestamp), %s) AS level,
+			       `+sampledSequenceWeight+` AS weight
+			FROM analytics_events
+			WHERE %s AND event_type IN ?%s
+			GROUP BY visitor
+		)
+		WHERE level > 0
+		GROUP BY level
+	`, visitorExpr, strings.Join(conds, ", "), timeRangeClause, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query funnel: %w", err)
+	}
+	defer rows.Close()
+
+	// reachedExactly[i] is the number of visitors whose furthest step is i+1.
+	reachedExactly := make([]uint64, len(steps))
+	for rows.Next() {
+		var level uint8
+		var visitors uint64
+		if err := rows.Scan(&level, &visitors); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for funnel")
+			continue
+		}
+		if int(level) <= len(steps) {
+			reachedExactly[level-1] = visitors
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for funnel: %w", err)
+	}
+
+	results := make([]models.FunnelStepResult, len(steps))
+	var reached uint64
+	for i := len(steps) - 1; i >= 0; i-- {
+		reached += reachedExactly[i]
+		results[i] = models.FunnelStepResult{
+			Step:      i + 1,
+			EventType: steps[i].EventType,
+			PagePath:  steps[i].PagePath,
+			Visitors:  reached,
+		}
+	}
+	for i := range results {
+		if results[0].Visitors > 0 {
+			results[i].ConversionRate = float64(results[i].Visitors) / float64(results[0].Visitors)
+		}
+		if i == 0 {
+			results[i].StepConversionRate = 1
+			if results[i].Visitors == 0 {
+				results[i].StepConversionRate = 0
+			}
+		} else if results[i-1].Visitors > 0 {
+			results[i].StepConversionRate = float64(results[i].Visitors) / float64(results[i-1].Visitors)
+		}
+		if i > 0 {
+			results[i].DropOffRate = 1 - results[i].StepConversionRate
+		}
+	}
+
+	return results, nil
+}
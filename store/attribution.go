@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// DefaultAttributionLookback and MaxAttributionLookback bound how long before
+// a conversion sessions are credited for it.
+const (
+	DefaultAttributionLookback = 30 * 24 * time.Hour
+	MaxAttributionLookback     = 90 * 24 * time.Hour
+)
+
+// attributionDimensions maps the dimensions conversions can be attributed to
+// to the expression of a session's value, taken from its first event.
+var attributionDimensions = map[string]string{
+	"channel":         "channel",
+	"utm_source":      "utm_source",
+	"utm_medium":      "utm_medium",
+	"utm_campaign":    "utm_campaign",
+	"referrer_domain": "domainWithoutWWW(referrer)",
+}
+
+// attributionModels select the credited values and their weights from a
+// conversion's path, the (session start, value) tuples in order.
+var attributionModels = map[string]string{
+	models.AttributionFirstTouch: "[path[1].2] AS credited, [1.0] AS weights",
+	models.AttributionLastTouch:  "[path[-1].2] AS credited, [1.0] AS weights",
+	models.AttributionLinear:     "arrayMap(x -> x.2, path) AS credited, arrayMap(x -> 1 / length(path), path) AS weights",
+}
+
+// AttributionDimensions lists the dimensions accepted by GetAttribution.
+func AttributionDimensions() []string {
+	names := make([]string, 0, len(attributionDimensions))
+	for name := range attributionDimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetAttribution credits the conversionEventType events in the range to the
+// dimension values of the visitor's sessions that started up to lookback
+// before each conversion, by the given model: all credit to the first or the
+// last session, or an equal share to each. Filters select the conversions;
+// conversions of visitors without a session are left out.
+func (s *AnalyticsStore) GetAttribution(ctx context.Context, model, dimension, conversionEventType string, lookback time.Duration, start, end time.Time, filters EventFilters) (*models.AttributionReport, error) {
+	credit, ok := attributionModels[model]
+	if !ok {
+		return nil, fmt.Errorf("%w: model must be one of %s, %s or %s", ErrInvalid, models.AttributionFirstTouch, models.AttributionLastTouch, models.AttributionLinear)
+	}
+	column, ok := attributionDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("%w: dimension must be one of %v", ErrInvalid, AttributionDimensions())
+	}
+	if lookback <= 0 || lookback > MaxAttributionLookback {
+		return nil, fmt.Errorf("%w: lookback must be between 1 and %d days", ErrInvalid, int(MaxAttributionLookback.Hours()/24))
+	}
+
+	filterClause, filterArgs := filters.clause()
+	projectClause, projectArgs := filters.projectClause()
+	args := []interface{}{conversionEventType, start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, start.Add(-lookback).UnixMilli(), end.UnixMilli())
+	args = append(args, projectArgs...)
+	args = append(args, int64(lookback.Seconds()))
+
+	query := fmt.Sprintf(`
+		SELECT value, sum(weight) AS conversions
+		FROM (
+			SELECT %[5]s
+			FROM (
+				SELECT c.event_id AS event_id,
+				       arraySort(x -> x.1, groupArray((t.started, t.value))) AS path
+				FROM (
+					SELECT event_id, %[1]s AS visitor, timestamp AS converted_at
+					FROM analytics_events
+					WHERE event_type = ? AND %[2]s%[3]s
+				) AS c
+				INNER JOIN (
+					SELECT %[1]s AS visitor, session_id, min(timestamp) AS started, argMin(%[4]s, timestamp) AS value
+					FROM analytics_events
+					WHERE session_id != '' AND %[2]s%[6]s
+					GROUP BY visitor, session_id
+				) AS t ON c.visitor = t.visitor
+				WHERE t.started <= c.converted_at AND t.started >= c.converted_at - toIntervalSecond(?)
+				GROUP BY c.event_id
+			)
+		)
+		ARRAY JOIN credited AS value, weights AS weight
+		GROUP BY value
+		ORDER BY conversions DESC, value
+	`, visitorExpr, timeRangeClause, filterClause, column, credit, projectClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attribution: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.AttributionReport{
+		Model:           model,
+		Dimension:       dimension,
+		ConversionEvent: conversionEventType,
+		LookbackDays:    int(lookback.Hours() / 24),
+		Credits:         []models.AttributionCredit{},
+	}
+	// Every model hands out one conversion of credit per conversion.
+	var total float64
+	for rows.Next() {
+		var r models.AttributionCredit
+		if err := rows.Scan(&r.Value, &r.Conversions); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for attribution")
+			continue
+		}
+		total += r.Conversions
+		report.Credits = append(report.Credits, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for attribution: %w", err)
+	}
+	report.Conversions = uint64(math.Round(total))
+	for i := range report.Credits {
+		report.Credits[i].Share = report.Credits[i].Conversions / total
+	}
+	return report, nil
+}
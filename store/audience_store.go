@@ -0,0 +1,250 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// AudienceStore keeps audience definitions in PostgreSQL and materializes their
+// membership into ClickHouse.
+type AudienceStore struct {
+	db *sql.DB
+	ch *database.ClickHouseClient
+}
+
+func NewAudienceStore(db *sql.DB, chClient *database.ClickHouseClient) *AudienceStore {
+	return &AudienceStore{db: db, ch: chClient}
+}
+
+func (s *AudienceStore) CreateAudience(ctx context.Context, projectID string, createdBy int, req models.AudienceRequest) (*models.Audience, error) {
+	definition, err := json.Marshal(req.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audience definition: %w", err)
+	}
+
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	audience := &models.Audience{ProjectID: projectID, Name: req.Name, Definition: req.Definition}
+	query := `
+		INSERT INTO audiences (project_id, name, definition, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_by, created_at, updated_at;
+	`
+	var createdByDB sql.NullInt64
+	err = s.db.QueryRowContext(ctx, query, projectID, req.Name, definition, creator).Scan(
+		&audience.ID,
+		&createdByDB,
+		&audience.CreatedAt,
+		&audience.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audience: %w", err)
+	}
+	if createdByDB.Valid {
+		id := int(createdByDB.Int64)
+		audience.CreatedBy = &id
+	}
+
+	return audience, nil
+}
+
+// ListAudiences returns the project's audiences.
+func (s *AudienceStore) ListAudiences(ctx context.Context, projectID string) ([]models.Audience, error) {
+	return s.listAudiences(ctx, `SELECT `+audienceColumns+` FROM audiences WHERE project_id = $1 ORDER BY id;`, projectID)
+}
+
+// ListAllAudiences returns the audiences of every project.
+func (s *AudienceStore) ListAllAudiences(ctx context.Context) ([]models.Audience, error) {
+	return s.listAudiences(ctx, `SELECT `+audienceColumns+` FROM audiences ORDER BY id;`)
+}
+
+func (s *AudienceStore) listAudiences(ctx context.Context, query string, args ...interface{}) ([]models.Audience, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audiences: %w", err)
+	}
+	defer rows.Close()
+
+	var audiences []models.Audience
+	for rows.Next() {
+		audience, err := scanAudience(rows)
+		if err != nil {
+			return nil, err
+		}
+		audiences = append(audiences, *audience)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audiences: %w", err)
+	}
+
+	return audiences, nil
+}
+
+func (s *AudienceStore) GetAudience(ctx context.Context, projectID string, id int) (*models.Audience, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+audienceColumns+` FROM audiences WHERE id = $1 AND project_id = $2;`, id, projectID)
+	audience, err := scanAudience(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("audience %d: %w", id, ErrNotFound)
+	}
+	return audience, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const audienceColumns = `id, project_id, name, definition, created_by, created_at, updated_at`
+
+func scanAudience(row rowScanner) (*models.Audience, error) {
+	var audience models.Audience
+	var definition []byte
+	var createdBy sql.NullInt64
+	if err := row.Scan(&audience.ID, &audience.ProjectID, &audience.Name, &definition, &createdBy, &audience.CreatedAt, &audience.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan audience: %w", err)
+	}
+	if err := json.Unmarshal(definition, &audience.Definition); err != nil {
+		return nil, fmt.Errorf("failed to decode definition for audience %d: %w", audience.ID, err)
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		audience.CreatedBy = &id
+	}
+	return &audience, nil
+}
+
+// Materialize evaluates the audience definition against analytics_events and
+// appends the resulting membership as a new snapshot. It returns the snapshot size.
+func (s *AudienceStore) Materialize(ctx context.Context, audience *models.Audience) (uint64, error) {
+	def := audience.Definition
+	if def.MinCount == 0 {
+		def.MinCount = 1
+	}
+	if def.WithinDays <= 0 {
+		def.WithinDays = 30
+	}
+
+	computedAt := time.Now().UTC().Truncate(time.Millisecond)
+	since := computedAt.Add(-time.Duration(def.WithinDays) * 24 * time.Hour)
+
+	args := []interface{}{audience.ID, computedAt.UnixMilli(), since.UnixMilli()}
+	whereClause := "user_id != '' AND timestamp >= fromUnixTimestamp64Milli(toInt64(?), 'UTC')"
+	if def.EventType != "" {
+		whereClause += " AND event_type = ?"
+		args = append(args, def.EventType)
+	}
+	filterClause, filterArgs := EventFilters{ProjectID: audience.ProjectID, Traits: def.Traits}.clause()
+	whereClause += filterClause
+	args = append(args, filterArgs...)
+	args = append(args, def.MinCount)
+
+	query := fmt.Sprintf(`
+		INSERT INTO audience_members (audience_id, user_id, computed_at)
+		SELECT toUInt64(?), user_id, fromUnixTimestamp64Milli(toInt64(?), 'UTC')
+		FROM analytics_events
+		WHERE %s
+		GROUP BY user_id
+		HAVING count() >= ?
+	`, whereClause)
+
+	if err := s.ch.Conn.Exec(ctx, query, args...); err != nil {
+		return 0, fmt.Errorf("failed to materialize audience %d: %w", audience.ID, err)
+	}
+
+	var size uint64
+	err := s.ch.Conn.QueryRow(ctx, `
+		SELECT count()
+		FROM audience_members
+		WHERE audience_id = ? AND computed_at = fromUnixTimestamp64Milli(toInt64(?), 'UTC')
+	`, audience.ID, computedAt.UnixMilli()).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count members of audience %d: %w", audience.ID, err)
+	}
+
+	logging.Ctx(ctx).Info().Msgf("Materialized audience %d (%s): %d members", audience.ID, audience.Name, size)
+	return size, nil
+}
+
+// GetLatestMembers returns the user IDs in the most recent snapshot of the audience.
+func (s *AudienceStore) GetLatestMembers(ctx context.Context, audienceID int) (*models.AudienceMembers, error) {
+	rows, err := s.ch.Conn.Query(ctx, `
+		SELECT user_id, computed_at
+		FROM audience_members
+		WHERE audience_id = ?
+		  AND computed_at = (SELECT max(computed_at) FROM audience_members WHERE audience_id = ?)
+		ORDER BY user_id
+	`, audienceID, audienceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audience members: %w", err)
+	}
+	defer rows.Close()
+
+	members := &models.AudienceMembers{AudienceID: audienceID, UserIDs: []string{}}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID, &members.ComputedAt); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for audience members")
+			continue
+		}
+		members.UserIDs = append(members.UserIDs, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for audience members: %w", err)
+	}
+
+	return members, nil
+}
+
+// GetMembershipHistory returns snapshot sizes, newest first. When userID is set
+// only snapshots containing that user are returned.
+func (s *AudienceStore) GetMembershipHistory(ctx context.Context, audienceID int, userID string, limit uint64) ([]models.AudienceSnapshot, error) {
+	query := `
+		SELECT computed_at, count() AS size
+		FROM audience_members
+		WHERE audience_id = ?
+	`
+	args := []interface{}{audienceID}
+	if userID != "" {
+		query += ` AND computed_at IN (SELECT computed_at FROM audience_members WHERE audience_id = ? AND user_id = ?)`
+		args = append(args, audienceID, userID)
+	}
+	query += `
+		GROUP BY computed_at
+		ORDER BY computed_at DESC
+		LIMIT ?
+	`
+	args = append(args, limit)
+
+	rows, err := s.ch.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audience history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.AudienceSnapshot
+	for rows.Next() {
+		var snapshot models.AudienceSnapshot
+		if err := rows.Scan(&snapshot.ComputedAt, &snapshot.Size); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for audience history")
+			continue
+		}
+		history = append(history, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for audience history: %w", err)
+	}
+
+	return history, nil
+}
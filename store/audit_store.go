@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"mabletask/api/models"
+)
+
+type AuditStore struct {
+	db *sql.DB
+}
+
+func NewAuditStore(db *sql.DB) *AuditStore {
+	return &AuditStore{db: db}
+}
+
+// Record appends an entry to the audit log. Details may be any JSON-encodable value.
+func (s *AuditStore) Record(ctx context.Context, actorID int, action, target string, details interface{}, ipAddress, userAgent string) error {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	rawDetails, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+	var actor interface{}
+	if actorID != 0 {
+		actor = actorID
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (actor_id, action, target, details, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`, actor, action, target, rawDetails, ipAddress, userAgent)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// AuditFilter narrows ListEntries; zero values are ignored. An Action ending
+// in ".*" matches every action with that prefix, e.g. "auth.*".
+type AuditFilter struct {
+	Action  string
+	ActorID int
+	Target  string
+	Since   time.Time
+	Until   time.Time
+}
+
+// ListEntries returns a page of audit entries, newest first.
+func (s *AuditStore) ListEntries(ctx context.Context, f AuditFilter, page PageRequest) (models.Page[models.AuditEntry], error) {
+	afterTime, afterID := page.keysetArgs()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, actor_id, action, target, details, ip_address, user_agent, created_at
+		FROM audit_log
+		WHERE ($1 = '' OR action = $1 OR (RIGHT($1, 2) = '.*' AND action LIKE LEFT($1, -1) || '%'))
+			AND ($2 = 0 OR actor_id = $2)
+			AND ($3::timestamptz IS NULL OR (created_at, id) < ($3, $4::bigint))
+			AND ($6 = '' OR target = $6)
+			AND ($7::timestamptz IS NULL OR created_at >= $7)
+			AND ($8::timestamptz IS NULL OR created_at < $8)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $5;
+	`, f.Action, f.ActorID, afterTime, afterID, page.fetchLimit(), f.Target, nullTime(f.Since), nullTime(f.Until))
+	if err != nil {
+		return models.Page[models.AuditEntry]{}, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var (
+			entry   models.AuditEntry
+			actorID sql.NullInt64
+		)
+		err := rows.Scan(&entry.ID, &actorID, &entry.Action, &entry.Target, &entry.Details, &entry.IPAddress, &entry.UserAgent, &entry.CreatedAt)
+		if err != nil {
+			return models.Page[models.AuditEntry]{}, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			entry.ActorID = &id
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Page[models.AuditEntry]{}, fmt.Errorf("error iterating audit entries: %w", err)
+	}
+	return newPage(entries, page, func(e models.AuditEntry) Cursor {
+		return Cursor{Time: e.CreatedAt, ID: strconv.FormatInt(e.ID, 10)}
+	}), nil
+}
+
+// DeleteBefore removes audit entries recorded before cutoff.
+func (s *AuditStore) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM audit_log WHERE created_at < $1;`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune audit log: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// nullTime passes the zero time as NULL.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,362 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// BlocklistStore manages per-project ingestion blocklists. PostgreSQL holds
+// the rules and their hit counts; an in-memory copy serves the ingestion path,
+// which counts hits locally until the next Refresh writes them back.
+type BlocklistStore struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	rules map[string]*projectBlocklist // project_id -> compiled rules
+
+	hitsMu sync.Mutex
+	hits   map[int]int64 // rule id -> hits not yet written
+}
+
+type projectBlocklist struct {
+	networks   []blockedNetwork
+	userAgents []blockedValue
+	referrers  []blockedValue
+}
+
+type blockedNetwork struct {
+	id      int
+	network *net.IPNet
+}
+
+type blockedValue struct {
+	id    int
+	value string
+}
+
+func NewBlocklistStore(db *sql.DB) *BlocklistStore {
+	return &BlocklistStore{db: db, rules: map[string]*projectBlocklist{}, hits: map[int]int64{}}
+}
+
+// normalizeBlocklistValue validates a rule value and returns its canonical
+// form: IPs become single-address CIDR ranges, user agents and domains are
+// lower-cased and referrers are reduced to their host.
+func normalizeBlocklistValue(ruleType, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch ruleType {
+	case models.BlocklistTypeIP:
+		if ip := net.ParseIP(value); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			return (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String(), nil
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not an IP address or CIDR range", ErrInvalid, value)
+		}
+		return network.String(), nil
+	case models.BlocklistTypeUserAgent:
+		if len(value) < 2 {
+			return "", fmt.Errorf("%w: user agent pattern must be at least 2 characters", ErrInvalid)
+		}
+		return strings.ToLower(value), nil
+	case models.BlocklistTypeReferrer:
+		host := referrerHost(value)
+		if host == "" || !strings.Contains(host, ".") {
+			return "", fmt.Errorf("%w: %q is not a domain", ErrInvalid, value)
+		}
+		return host, nil
+	}
+	return "", fmt.Errorf("%w: unknown blocklist type %q", ErrInvalid, ruleType)
+}
+
+// referrerHost extracts the lower-cased host of a referrer URL or bare domain.
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+	if !strings.Contains(referrer, "://") {
+		referrer = "http://" + referrer
+	}
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// Refresh writes the locally counted hits back to PostgreSQL and reloads the
+// rules of every project.
+func (s *BlocklistStore) Refresh(ctx context.Context) error {
+	if err := s.flushHits(ctx); err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, project_id, rule_type, value FROM blocklist_rules;`)
+	if err != nil {
+		return fmt.Errorf("failed to load blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	rules := map[string]*projectBlocklist{}
+	for rows.Next() {
+		var (
+			id                         int
+			projectID, ruleType, value string
+		)
+		if err := rows.Scan(&id, &projectID, &ruleType, &value); err != nil {
+			return fmt.Errorf("failed to scan blocklist rule: %w", err)
+		}
+		list := rules[projectID]
+		if list == nil {
+			list = &projectBlocklist{}
+			rules[projectID] = list
+		}
+		list.add(id, ruleType, value)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating blocklist: %w", err)
+	}
+
+	s.mu.Lock()
+	s.rules = rules
+	s.mu.Unlock()
+	return nil
+}
+
+func (l *projectBlocklist) add(id int, ruleType, value string) {
+	switch ruleType {
+	case models.BlocklistTypeIP:
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			log.Warn().Msgf("Blocklist rule %d: invalid range %q", id, value)
+			return
+		}
+		l.networks = append(l.networks, blockedNetwork{id: id, network: network})
+	case models.BlocklistTypeUserAgent:
+		l.userAgents = append(l.userAgents, blockedValue{id: id, value: value})
+	case models.BlocklistTypeReferrer:
+		l.referrers = append(l.referrers, blockedValue{id: id, value: value})
+	}
+}
+
+// without returns a copy of the rules minus rule id. Compiled rules are never
+// modified in place because Blocked reads them without holding the lock.
+func (l *projectBlocklist) without(id int) *projectBlocklist {
+	copied := &projectBlocklist{}
+	if l == nil {
+		return copied
+	}
+	for _, n := range l.networks {
+		if n.id != id {
+			copied.networks = append(copied.networks, n)
+		}
+	}
+	for _, ua := range l.userAgents {
+		if ua.id != id {
+			copied.userAgents = append(copied.userAgents, ua)
+		}
+	}
+	for _, r := range l.referrers {
+		if r.id != id {
+			copied.referrers = append(copied.referrers, r)
+		}
+	}
+	return copied
+}
+
+func (s *BlocklistStore) flushHits(ctx context.Context) error {
+	s.hitsMu.Lock()
+	hits := s.hits
+	s.hits = map[int]int64{}
+	s.hitsMu.Unlock()
+
+	var firstErr error
+	for id, n := range hits {
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE blocklist_rules SET hits = hits + $2, last_hit_at = CURRENT_TIMESTAMP WHERE id = $1;
+		`, id, n)
+		if err != nil {
+			// Keep the hits for the next flush.
+			s.recordHits(id, n)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to record blocklist hits: %w", err)
+			}
+		}
+	}
+	return firstErr
+}
+
+func (s *BlocklistStore) recordHits(id int, n int64) {
+	s.hitsMu.Lock()
+	s.hits[id] += n
+	s.hitsMu.Unlock()
+}
+
+// Blocked reports whether an event with these attributes matches one of the
+// project's rules, counting the hit against the first matching rule.
+func (s *BlocklistStore) Blocked(projectID, ip, userAgent, referrer string) bool {
+	s.mu.RLock()
+	list := s.rules[projectID]
+	s.mu.RUnlock()
+	if list == nil {
+		return false
+	}
+
+	id, ok := list.match(ip, userAgent, referrer)
+	if ok {
+		s.recordHits(id, 1)
+	}
+	return ok
+}
+
+func (l *projectBlocklist) match(ip, userAgent, referrer string) (int, bool) {
+	if len(l.networks) > 0 {
+		if addr := net.ParseIP(ip); addr != nil {
+			for _, n := range l.networks {
+				if n.network.Contains(addr) {
+					return n.id, true
+				}
+			}
+		}
+	}
+	if len(l.userAgents) > 0 && userAgent != "" {
+		userAgent = strings.ToLower(userAgent)
+		for _, ua := range l.userAgents {
+			if strings.Contains(userAgent, ua.value) {
+				return ua.id, true
+			}
+		}
+	}
+	if len(l.referrers) > 0 {
+		if host := referrerHost(referrer); host != "" {
+			for _, r := range l.referrers {
+				if host == r.value || strings.HasSuffix(host, "."+r.value) {
+					return r.id, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+const blocklistColumns = `id, project_id, rule_type, value, reason, hits, last_hit_at, created_by, created_at`
+
+func scanBlocklistRule(row rowScanner) (*models.BlocklistRule, error) {
+	var (
+		rule      models.BlocklistRule
+		lastHitAt sql.NullTime
+		createdBy sql.NullInt64
+	)
+	err := row.Scan(&rule.ID, &rule.ProjectID, &rule.Type, &rule.Value, &rule.Reason, &rule.Hits, &lastHitAt, &createdBy, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastHitAt.Valid {
+		rule.LastHitAt = &lastHitAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		rule.CreatedBy = &id
+	}
+	return &rule, nil
+}
+
+func (s *BlocklistStore) CreateRule(ctx context.Context, projectID string, createdBy int, req models.BlocklistRuleRequest) (*models.BlocklistRule, error) {
+	value, err := normalizeBlocklistValue(req.Type, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO blocklist_rules (project_id, rule_type, value, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+blocklistColumns+`;
+	`, projectID, req.Type, value, req.Reason, creator)
+	rule, err := scanBlocklistRule(row)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, fmt.Errorf("blocklist rule %s '%s': %w", req.Type, value, ErrAlreadyExists)
+		}
+		return nil, fmt.Errorf("failed to create blocklist rule: %w", err)
+	}
+
+	s.mu.Lock()
+	list := s.rules[projectID].without(0)
+	list.add(rule.ID, rule.Type, rule.Value)
+	s.rules[projectID] = list
+	s.mu.Unlock()
+
+	logging.Ctx(ctx).Info().Msgf("Blocklist rule %d created for project %s (%s '%s')", rule.ID, projectID, rule.Type, rule.Value)
+	return rule, nil
+}
+
+// ListRules returns the project's rules. Hit counts include the hits recorded
+// on this instance since the last Refresh.
+func (s *BlocklistStore) ListRules(ctx context.Context, projectID string) ([]models.BlocklistRule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+blocklistColumns+`
+		FROM blocklist_rules
+		WHERE project_id = $1
+		ORDER BY id;
+	`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocklist rules: %w", err)
+	}
+	defer rows.Close()
+
+	s.hitsMu.Lock()
+	defer s.hitsMu.Unlock()
+
+	rules := []models.BlocklistRule{}
+	for rows.Next() {
+		rule, err := scanBlocklistRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan blocklist rule: %w", err)
+		}
+		rule.Hits += s.hits[rule.ID]
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating blocklist rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *BlocklistStore) DeleteRule(ctx context.Context, projectID string, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM blocklist_rules WHERE id = $1 AND project_id = $2;`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete blocklist rule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("blocklist rule %d: %w", id, ErrNotFound)
+	}
+
+	s.mu.Lock()
+	s.rules[projectID] = s.rules[projectID].without(id)
+	s.mu.Unlock()
+
+	s.hitsMu.Lock()
+	delete(s.hits, id)
+	s.hitsMu.Unlock()
+	return nil
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// GetCampaigns returns the limit campaigns that brought the most sessions. A
+// session belongs to the UTM source, medium and campaign of its first event
+// carrying any of them, and converts when it has an event of
+// conversionEventType. Sessions without UTM parameters are left out.
+func (s *AnalyticsStore) GetCampaigns(ctx context.Context, conversionEventType string, start, end time.Time, limit uint64, filters EventFilters) ([]models.CampaignStats, error) {
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{conversionEventType, start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT utm.1 AS source, utm.2 AS medium, utm.3 AS campaign,
+		       `+weightedCount+` AS sessions,
+		       `+weightedCountIf("converted")+` AS conversions
+		FROM (
+			SELECT session_id,
+			       argMinIf((utm_source, utm_medium, utm_campaign), timestamp,
+			                utm_source != '' OR utm_medium != '' OR utm_campaign != '') AS utm,
+			       countIf(event_type = ?) > 0 AS converted,
+			       `+sampledWeight+` AS weight
+			FROM analytics_events
+			WHERE %s%s
+			GROUP BY session_id
+		)
+		WHERE utm != ('', '', '')
+		GROUP BY source, medium, campaign
+		ORDER BY sessions DESC, source, medium, campaign
+		LIMIT ?
+	`, timeRangeClause, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.CampaignStats{}
+	for rows.Next() {
+		var r models.CampaignStats
+		if err := rows.Scan(&r.Source, &r.Medium, &r.Campaign, &r.Sessions, &r.Conversions); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for campaigns")
+			continue
+		}
+		if r.Sessions > 0 {
+			r.ConversionRate = float64(r.Conversions) / float64(r.Sessions)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for campaigns: %w", err)
+	}
+
+	return results, nil
+}
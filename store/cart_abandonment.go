@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// sessionCartWindow bounds the conversion window of carts per session; the
+// session itself is the real bound.
+const sessionCartWindow = 30 * 24 * time.Hour
+
+// GetCartAbandonment counts, per interval, the carts started in the range and
+// those not followed by a purchase. With a zero window carts are tracked per
+// session; otherwise per visitor, with purchases up to window after the range
+// counted for carts near its end.
+func (s *AnalyticsStore) GetCartAbandonment(ctx context.Context, interval string, window time.Duration, start, end time.Time, filters EventFilters) (*models.CartAbandonmentReport, error) {
+	if !utils.IsValidInterval(interval) {
+		return nil, fmt.Errorf("%w: interval %q", ErrInvalid, interval)
+	}
+
+	key := "session_id"
+	funnelWindow := sessionCartWindow
+	if window > 0 {
+		key = visitorExpr
+		funnelWindow = window
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{
+		int64(funnelWindow.Seconds()),
+		models.EventTypeAddToCart, models.EventTypePurchase,
+		models.EventTypeAddToCart, models.EventTypeAddToCart, models.EventTypePurchase,
+		start.UnixMilli(), end.Add(window).UnixMilli(),
+	}
+	args = append(args, filterArgs...)
+	args = append(args, start.UnixMilli(), end.UnixMilli())
+
+	query := fmt.Sprintf(`
+		SELECT toStartOf%[1]s(cart_at) AS time_bucket, %[5]s AS carts, %[6]s AS abandoned
+		FROM (
+			SELECT %[2]s AS cart_key,
+			       windowFunnel(?)(timestamp, event_type = ?, event_type = ?) AS level,
+			       minIf(timestamp, event_type = ?) AS cart_at,
+			       %[7]s AS weight
+			FROM analytics_events
+			WHERE event_type IN (?, ?) AND %[3]s%[4]s
+			GROUP BY cart_key
+			HAVING cart_key != ''
+			   AND cart_at >= fromUnixTimestamp64Milli(toInt64(?), 'UTC')
+			   AND cart_at <= fromUnixTimestamp64Milli(toInt64(?), 'UTC')
+		)
+		GROUP BY time_bucket
+		ORDER BY time_bucket ASC
+	`, interval, key, timeRangeClause, filterClause, weightedCount, weightedCountIf("level < 2"), sampledSequenceWeight)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cart abandonment: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.CartAbandonmentReport{Interval: interval, WindowSeconds: int64(window.Seconds()), Series: []models.CartAbandonmentPoint{}}
+	for rows.Next() {
+		var p models.CartAbandonmentPoint
+		if err := rows.Scan(&p.Time, &p.Carts, &p.Abandoned); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for cart abandonment")
+			continue
+		}
+		if p.Carts > 0 {
+			p.AbandonmentRate = float64(p.Abandoned) / float64(p.Carts)
+		}
+		report.Carts += p.Carts
+		report.Abandoned += p.Abandoned
+		report.Series = append(report.Series, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for cart abandonment: %w", err)
+	}
+	if report.Carts > 0 {
+		report.AbandonmentRate = float64(report.Abandoned) / float64(report.Carts)
+	}
+
+	return report, nil
+}
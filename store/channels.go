@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// GetChannelsOverTime returns the number of sessions started in each interval
+// of the range per acquisition channel. A session's channel is that of its
+// first event, so navigation within the site does not count as referral.
+func (s *AnalyticsStore) GetChannelsOverTime(ctx context.Context, interval string, start, end time.Time, filters EventFilters) ([]models.ChannelCount, error) {
+	if !utils.IsValidInterval(interval) {
+		return nil, fmt.Errorf("%w: interval %q", ErrInvalid, interval)
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT toStartOf%s(started) AS time_bucket, channel, `+weightedCount+` AS sessions
+		FROM (
+			SELECT session_id, min(timestamp) AS started, argMin(channel, timestamp) AS channel,
+			       `+sampledWeight+` AS weight
+			FROM analytics_events
+			WHERE session_id != '' AND %s%s
+			GROUP BY session_id
+		)
+		GROUP BY time_bucket, channel
+		ORDER BY time_bucket ASC, sessions DESC, channel
+	`, interval, timeRangeClause, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query channels over time: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.ChannelCount{}
+	for rows.Next() {
+		var r models.ChannelCount
+		if err := rows.Scan(&r.Time, &r.Channel, &r.Sessions); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for channels over time")
+			continue
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for channels over time: %w", err)
+	}
+
+	return results, nil
+}
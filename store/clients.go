@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// clientDimensions maps the client breakdowns to the columns parsed from the
+// user agent at ingestion.
+var clientDimensions = map[string]string{
+	"browser": "browser",
+	"os":      "os",
+	"device":  "device_type",
+}
+
+// GetClientBreakdown returns the limit most common values of a client
+// dimension (browser, os or device) by visitors, with browser versions as
+// separate rows when withVersion is set. Events without a value are reported
+// as "".
+func (s *AnalyticsStore) GetClientBreakdown(ctx context.Context, dimension string, withVersion bool, start, end time.Time, limit uint64, filters EventFilters) ([]models.ClientCount, error) {
+	column, ok := clientDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("%w: client dimension %q", ErrInvalid, dimension)
+	}
+	version := "''"
+	if withVersion && dimension == "browser" {
+		version = "browser_version"
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, args...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s AS value, %[2]s AS version, `+sampledCount+` AS events, uniqExact(%[3]s) AS visitors,
+		       visitors / greatest((SELECT uniqExact(%[3]s) FROM analytics_events WHERE %[4]s%[5]s), 1) AS share
+		FROM analytics_events
+		WHERE %[4]s%[5]s
+		GROUP BY value, version
+		ORDER BY visitors DESC, value, version
+		LIMIT ?
+	`, column, version, visitorExpr, timeRangeClause, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s breakdown: %w", dimension, err)
+	}
+	defer rows.Close()
+
+	var results []models.ClientCount
+	for rows.Next() {
+		var r models.ClientCount
+		if err := rows.Scan(&r.Value, &r.Version, &r.Events, &r.Visitors, &r.Share); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msgf("Error scanning row for %s breakdown", dimension)
+			continue
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for %s breakdown: %w", dimension, err)
+	}
+
+	return results, nil
+}
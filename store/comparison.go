@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// Comparison modes of the time-series endpoints.
+const (
+	ComparePreviousPeriod = "previous_period"
+	ComparePreviousYear   = "previous_year"
+)
+
+// Comparison is the range a series is compared against, and how its buckets
+// map onto the compared range.
+type Comparison struct {
+	Mode       string
+	Start, End time.Time
+	// align maps a time of the comparison range to the same point of the
+	// current range.
+	align func(time.Time) time.Time
+}
+
+// NewComparison returns the comparison range of [start, end] for mode: the
+// equally long range right before it, or the same range one year earlier.
+func NewComparison(mode string, start, end time.Time) (*Comparison, error) {
+	switch mode {
+	case ComparePreviousPeriod:
+		length := end.Sub(start) + time.Millisecond
+		return &Comparison{
+			Mode:  mode,
+			Start: start.Add(-length),
+			End:   start.Add(-time.Millisecond),
+			align: func(t time.Time) time.Time { return t.Add(length) },
+		}, nil
+	case ComparePreviousYear:
+		return &Comparison{
+			Mode:  mode,
+			Start: start.AddDate(-1, 0, 0),
+			End:   end.AddDate(-1, 0, 0),
+			align: func(t time.Time) time.Time { return t.AddDate(1, 0, 0) },
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: compare %q (one of %s, %s)", ErrInvalid, mode, ComparePreviousPeriod, ComparePreviousYear)
+}
+
+// alignBucket returns the bucket of the current range that the comparison
+// bucket at t lines up with.
+func (c *Comparison) alignBucket(interval string, t time.Time) time.Time {
+	return bucketStart(interval, c.align(t))
+}
+
+// bucketStart mirrors timeBucket for times in UTC: the start of the interval
+// containing t. Weeks start on Sunday, as toStartOfWeek does by default.
+func bucketStart(interval string, t time.Time) time.Time {
+	t = t.UTC()
+	y, m, d := t.Date()
+	switch interval {
+	case "Minute":
+		return t.Truncate(time.Minute)
+	case "Hour":
+		return t.Truncate(time.Hour)
+	case "Day":
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	case "Week":
+		return time.Date(y, m, d-int(t.Weekday()), 0, 0, 0, 0, time.UTC)
+	case "Month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case "Quarter":
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// CompareEventCounts is GetEventCountsOverTime for the current range and the
+// comparison range. Comparison points are moved to the bucket of the current
+// range they line up with; ComparedTime keeps their own bucket.
+func (s *AnalyticsStore) CompareEventCounts(ctx context.Context, cmp *Comparison, interval string, start, end time.Time, breakdown string, breakdownLimit uint64, filters EventFilters) (current, previous []EventTypeCountByTime, err error) {
+	if current, err = s.GetEventCountsOverTime(ctx, interval, start, end, breakdown, breakdownLimit, filters); err != nil {
+		return nil, nil, err
+	}
+	if previous, err = s.GetEventCountsOverTime(ctx, interval, cmp.Start, cmp.End, breakdown, breakdownLimit, filters); err != nil {
+		return nil, nil, err
+	}
+	for i := range previous {
+		own := previous[i].Time
+		previous[i].ComparedTime = &own
+		previous[i].Time = cmp.alignBucket(interval, own)
+	}
+	return current, previous, nil
+}
+
+// CompareUniqueUsers is GetUniqueUsersOverTime for the current range and the
+// comparison range, aligned as by CompareEventCounts.
+func (s *AnalyticsStore) CompareUniqueUsers(ctx context.Context, cmp *Comparison, interval string, start, end time.Time, filters EventFilters) (current, previous []EventTypeCountByTime, err error) {
+	if current, err = s.GetUniqueUsersOverTime(ctx, interval, start, end, filters); err != nil {
+		return nil, nil, err
+	}
+	if previous, err = s.GetUniqueUsersOverTime(ctx, interval, cmp.Start, cmp.End, filters); err != nil {
+		return nil, nil, err
+	}
+	for i := range previous {
+		own := previous[i].Time
+		previous[i].ComparedTime = &own
+		previous[i].Time = cmp.alignBucket(interval, own)
+	}
+	return current, previous, nil
+}
+
+// CompareSessions is GetSessionsOverTime for the current range and the
+// comparison range, aligned as by CompareEventCounts.
+func (s *AnalyticsStore) CompareSessions(ctx context.Context, cmp *Comparison, interval string, start, end time.Time, filters EventFilters) (current, previous []models.SessionMetrics, err error) {
+	if current, err = s.GetSessionsOverTime(ctx, interval, start, end, filters); err != nil {
+		return nil, nil, err
+	}
+	if previous, err = s.GetSessionsOverTime(ctx, interval, cmp.Start, cmp.End, filters); err != nil {
+		return nil, nil, err
+	}
+	for i := range previous {
+		own := *previous[i].Time
+		aligned := cmp.alignBucket(interval, own)
+		previous[i].ComparedTime = &own
+		previous[i].Time = &aligned
+	}
+	return current, previous, nil
+}
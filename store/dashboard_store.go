@@ -0,0 +1,316 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"mabletask/api/models"
+)
+
+type DashboardStore struct {
+	db *sql.DB
+}
+
+func NewDashboardStore(db *sql.DB) *DashboardStore {
+	return &DashboardStore{db: db}
+}
+
+const dashboardColumns = `id, name, description, created_by, created_at, updated_at`
+
+const widgetColumns = `id, dashboard_id, type, title, query, position, layout, created_at, updated_at`
+
+func scanDashboard(row rowScanner) (*models.Dashboard, error) {
+	var dashboard models.Dashboard
+	var createdBy sql.NullInt64
+	if err := row.Scan(&dashboard.ID, &dashboard.Name, &dashboard.Description, &createdBy, &dashboard.CreatedAt, &dashboard.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		dashboard.CreatedBy = &id
+	}
+	return &dashboard, nil
+}
+
+func scanWidget(row rowScanner) (*models.DashboardWidget, error) {
+	var (
+		widget        models.DashboardWidget
+		query, layout []byte
+	)
+	if err := row.Scan(&widget.ID, &widget.DashboardID, &widget.Type, &widget.Title, &query, &widget.Position, &layout, &widget.CreatedAt, &widget.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(query, &widget.Query); err != nil {
+		return nil, fmt.Errorf("failed to decode query for widget %d: %w", widget.ID, err)
+	}
+	if err := json.Unmarshal(layout, &widget.Layout); err != nil {
+		return nil, fmt.Errorf("failed to decode layout for widget %d: %w", widget.ID, err)
+	}
+	return &widget, nil
+}
+
+func (s *DashboardStore) CreateDashboard(ctx context.Context, projectID string, createdBy int, req models.DashboardRequest) (*models.Dashboard, error) {
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO dashboards (project_id, name, description, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+dashboardColumns+`;
+	`, projectID, req.Name, req.Description, creator)
+	dashboard, err := scanDashboard(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dashboard: %w", err)
+	}
+	return dashboard, nil
+}
+
+// ListDashboards returns the project's dashboards without their widgets.
+func (s *DashboardStore) ListDashboards(ctx context.Context, projectID string) ([]models.Dashboard, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+dashboardColumns+` FROM dashboards WHERE project_id = $1 ORDER BY id;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dashboards: %w", err)
+	}
+	defer rows.Close()
+
+	var dashboards []models.Dashboard
+	for rows.Next() {
+		dashboard, err := scanDashboard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dashboard: %w", err)
+		}
+		dashboards = append(dashboards, *dashboard)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating dashboards: %w", err)
+	}
+	return dashboards, nil
+}
+
+// GetDashboard returns a dashboard with its widgets in display order.
+func (s *DashboardStore) GetDashboard(ctx context.Context, projectID string, id int) (*models.Dashboard, error) {
+	dashboard, err := scanDashboard(s.db.QueryRowContext(ctx, `SELECT `+dashboardColumns+` FROM dashboards WHERE id = $1 AND project_id = $2;`, id, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("dashboard %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dashboard: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+widgetColumns+`
+		FROM dashboard_widgets
+		WHERE dashboard_id = $1
+		ORDER BY position, id;
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list widgets: %w", err)
+	}
+	defer rows.Close()
+
+	dashboard.Widgets = []models.DashboardWidget{}
+	for rows.Next() {
+		widget, err := scanWidget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan widget: %w", err)
+		}
+		dashboard.Widgets = append(dashboard.Widgets, *widget)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating widgets: %w", err)
+	}
+	return dashboard, nil
+}
+
+func (s *DashboardStore) UpdateDashboard(ctx context.Context, projectID string, id int, req models.DashboardRequest) (*models.Dashboard, error) {
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE dashboards
+		SET name = $3, description = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+dashboardColumns+`;
+	`, id, projectID, req.Name, req.Description)
+	dashboard, err := scanDashboard(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("dashboard %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update dashboard: %w", err)
+	}
+	return dashboard, nil
+}
+
+// DeleteDashboard removes a dashboard; its widgets are removed by cascade.
+func (s *DashboardStore) DeleteDashboard(ctx context.Context, projectID string, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM dashboards WHERE id = $1 AND project_id = $2;`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete dashboard: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("dashboard %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// AddWidget appends a widget to a dashboard, or inserts it at req.Position.
+func (s *DashboardStore) AddWidget(ctx context.Context, projectID string, dashboardID int, req models.WidgetRequest) (*models.DashboardWidget, error) {
+	query, layout, err := encodeWidget(req)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var nextPosition int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT MAX(position) + 1 FROM dashboard_widgets WHERE dashboard_id = d.id), 0)
+		FROM dashboards d
+		WHERE d.id = $1 AND d.project_id = $2
+		FOR UPDATE;
+	`, dashboardID, projectID).Scan(&nextPosition)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("dashboard %d: %w", dashboardID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock dashboard: %w", err)
+	}
+
+	position := nextPosition
+	if req.Position != nil && *req.Position < nextPosition {
+		position = *req.Position
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE dashboard_widgets SET position = position + 1
+			WHERE dashboard_id = $1 AND position >= $2;
+		`, dashboardID, position); err != nil {
+			return nil, fmt.Errorf("failed to shift widgets: %w", err)
+		}
+	}
+
+	widget, err := scanWidget(tx.QueryRowContext(ctx, `
+		INSERT INTO dashboard_widgets (dashboard_id, type, title, query, position, layout)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+widgetColumns+`;
+	`, dashboardID, req.Type, req.Title, query, position, layout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add widget: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit widget: %w", err)
+	}
+	return widget, nil
+}
+
+// UpdateWidget replaces a widget's type, title, query and layout. Position is
+// changed through ReorderWidgets and is left untouched here.
+func (s *DashboardStore) UpdateWidget(ctx context.Context, projectID string, dashboardID, widgetID int, req models.WidgetRequest) (*models.DashboardWidget, error) {
+	query, layout, err := encodeWidget(req)
+	if err != nil {
+		return nil, err
+	}
+
+	widget, err := scanWidget(s.db.QueryRowContext(ctx, `
+		UPDATE dashboard_widgets
+		SET type = $4, title = $5, query = $6, layout = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE dashboard_id = (SELECT id FROM dashboards WHERE id = $1 AND project_id = $3) AND id = $2
+		RETURNING `+widgetColumns+`;
+	`, dashboardID, widgetID, projectID, req.Type, req.Title, query, layout))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("widget %d on dashboard %d: %w", widgetID, dashboardID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update widget: %w", err)
+	}
+	return widget, nil
+}
+
+func (s *DashboardStore) DeleteWidget(ctx context.Context, projectID string, dashboardID, widgetID int) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM dashboard_widgets
+		WHERE dashboard_id = (SELECT id FROM dashboards WHERE id = $1 AND project_id = $3) AND id = $2;
+	`, dashboardID, widgetID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete widget: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("widget %d on dashboard %d: %w", widgetID, dashboardID, ErrNotFound)
+	}
+	return nil
+}
+
+// ReorderWidgets sets widget positions to their index in widgetIDs, which must
+// list every widget of the dashboard exactly once.
+func (s *DashboardStore) ReorderWidgets(ctx context.Context, projectID string, dashboardID int, widgetIDs []int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT true FROM dashboards WHERE id = $1 AND project_id = $2 FOR UPDATE;`, dashboardID, projectID).Scan(&exists); err == sql.ErrNoRows {
+		return fmt.Errorf("dashboard %d: %w", dashboardID, ErrNotFound)
+	} else if err != nil {
+		return fmt.Errorf("failed to lock dashboard: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM dashboard_widgets WHERE dashboard_id = $1;`, dashboardID)
+	if err != nil {
+		return fmt.Errorf("failed to list widgets: %w", err)
+	}
+	current := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan widget id: %w", err)
+		}
+		current[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating widgets: %w", err)
+	}
+
+	if len(widgetIDs) != len(current) {
+		return fmt.Errorf("expected %d widget ids, got %d: %w", len(current), len(widgetIDs), ErrInvalid)
+	}
+	seen := make(map[int]bool, len(widgetIDs))
+	for _, id := range widgetIDs {
+		if !current[id] || seen[id] {
+			return fmt.Errorf("widget %d is unknown or listed twice: %w", id, ErrInvalid)
+		}
+		seen[id] = true
+	}
+
+	for position, id := range widgetIDs {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE dashboard_widgets SET position = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE dashboard_id = $1 AND id = $2;
+		`, dashboardID, id, position); err != nil {
+			return fmt.Errorf("failed to move widget %d: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit widget order: %w", err)
+	}
+	return nil
+}
+
+func encodeWidget(req models.WidgetRequest) ([]byte, []byte, error) {
+	query, err := json.Marshal(req.Query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode widget query: %w", err)
+	}
+	layout, err := json.Marshal(req.Layout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode widget layout: %w", err)
+	}
+	return query, layout, nil
+}
@@ -0,0 +1,304 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// deletionColumns maps subject types to the analytics_events column they match.
+var deletionColumns = map[string]string{
+	"user":      "user_id",
+	"anonymous": "anonymous_id",
+	"session":   "session_id",
+}
+
+// userDataTables hold per-project user data from a user's identify and group
+// calls, erased along with the events of a user subject. Audience memberships
+// are erased too, by the project's audience IDs.
+var userDataTables = []string{"user_traits", "user_groups"}
+
+// DeletionStore issues ClickHouse DELETE mutations for erasure requests and
+// tracks their progress in PostgreSQL.
+type DeletionStore struct {
+	db *sql.DB
+	ch *database.ClickHouseClient
+}
+
+func NewDeletionStore(db *sql.DB, chClient *database.ClickHouseClient) *DeletionStore {
+	return &DeletionStore{db: db, ch: chClient}
+}
+
+const deletionColumnsSQL = `id, project_id, subject_type, subject_id, mutation_id, status, parts_to_do, fail_reason, requested_by, created_at, completed_at`
+
+func scanDeletion(row rowScanner) (*models.DataDeletion, error) {
+	var (
+		d           models.DataDeletion
+		requestedBy sql.NullInt64
+		completedAt sql.NullTime
+	)
+	if err := row.Scan(&d.ID, &d.ProjectID, &d.Type, &d.SubjectID, &d.MutationID, &d.Status, &d.PartsToDo, &d.FailReason, &requestedBy, &d.CreatedAt, &completedAt); err != nil {
+		return nil, err
+	}
+	if requestedBy.Valid {
+		id := int(requestedBy.Int64)
+		d.RequestedBy = &id
+	}
+	if completedAt.Valid {
+		d.CompletedAt = &completedAt.Time
+	}
+	return &d, nil
+}
+
+// CreateDeletion records an erasure request for the events of
+// req.ProjectID and queues the job that issues its mutation (see
+// StartDeletion).
+func (s *DeletionStore) CreateDeletion(ctx context.Context, requestedBy int, req models.DeletionRequest) (*models.DataDeletion, error) {
+	if _, ok := deletionColumns[req.Type]; !ok {
+		return nil, fmt.Errorf("invalid deletion subject type: %s", req.Type)
+	}
+	var requester interface{}
+	if requestedBy != 0 {
+		requester = requestedBy
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO data_deletions (project_id, subject_type, subject_id, status, requested_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+deletionColumnsSQL+`;
+	`, req.ProjectID, req.Type, req.SubjectID, models.DeletionStatusPending, requester)
+	deletion, err := scanDeletion(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record deletion request: %w", err)
+	}
+	if _, err := enqueueJob(ctx, tx, models.JobKindDataDeletion, models.DataDeletionPayload{DeletionID: deletion.ID}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit deletion request: %w", err)
+	}
+	return deletion, nil
+}
+
+// StartDeletion issues the asynchronous mutation removing every event of the
+// request's project for its subject, and for a user their traits, account
+// associations and audience memberships in the project, and marks it
+// running. Requests that are no longer pending are left untouched.
+func (s *DeletionStore) StartDeletion(ctx context.Context, id int) error {
+	row := s.db.QueryRowContext(ctx, `SELECT `+deletionColumnsSQL+` FROM data_deletions WHERE id = $1;`, id)
+	deletion, err := scanDeletion(row)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("deletion %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get deletion: %w", err)
+	}
+	if deletion.Status != models.DeletionStatusPending {
+		return nil
+	}
+	column := deletionColumns[deletion.Type]
+
+	// ALTER ... DELETE does not return the mutation ID; it is the first
+	// analytics_events mutation created from issuedAt on that no other
+	// deletion has claimed.
+	var issuedAt time.Time
+	if err := s.ch.Conn.QueryRow(ctx, "SELECT now()").Scan(&issuedAt); err != nil {
+		return fmt.Errorf("failed to read ClickHouse time: %w", err)
+	}
+	if err := s.ch.Conn.Exec(ctx, fmt.Sprintf("ALTER TABLE analytics_events DELETE WHERE project_id = ? AND %s = ?", column), deletion.ProjectID, deletion.SubjectID); err != nil {
+		return fmt.Errorf("failed to issue delete mutation: %w", err)
+	}
+	mutationID, err := s.claimMutation(ctx, issuedAt)
+	if err != nil {
+		logging.Ctx(ctx).Warn().Err(err).Msgf("Could not resolve mutation ID for deletion %d", id)
+	}
+
+	if err := s.ch.Conn.Exec(ctx, "ALTER TABLE first_touch DELETE WHERE project_id = ? AND visitor_id = ?", deletion.ProjectID, deletion.SubjectID); err != nil {
+		return fmt.Errorf("failed to issue first-touch delete mutation: %w", err)
+	}
+	if deletion.Type == "user" {
+		if err := s.deleteUserData(ctx, deletion.ProjectID, deletion.SubjectID); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE data_deletions SET status = $2, mutation_id = $3 WHERE id = $1;
+	`, id, models.DeletionStatusRunning, mutationID)
+	if err != nil {
+		return fmt.Errorf("failed to mark deletion as running: %w", err)
+	}
+	return nil
+}
+
+// claimMutation returns the oldest analytics_events mutation created at or
+// after issuedAt whose ID no deletion has recorded yet.
+func (s *DeletionStore) claimMutation(ctx context.Context, issuedAt time.Time) (string, error) {
+	rows, err := s.ch.Conn.Query(ctx, `
+		SELECT mutation_id
+		FROM system.mutations
+		WHERE database = currentDatabase() AND table = 'analytics_events' AND create_time >= ?
+		ORDER BY create_time, mutation_id
+	`, issuedAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to list mutations: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []string
+	for rows.Next() {
+		var mutationID string
+		if err := rows.Scan(&mutationID); err != nil {
+			return "", fmt.Errorf("failed to scan mutation: %w", err)
+		}
+		candidates = append(candidates, mutationID)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating mutations: %w", err)
+	}
+
+	for _, mutationID := range candidates {
+		var claimed bool
+		err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM data_deletions WHERE mutation_id = $1);`, mutationID).Scan(&claimed)
+		if err != nil {
+			return "", fmt.Errorf("failed to check mutation %s: %w", mutationID, err)
+		}
+		if !claimed {
+			return mutationID, nil
+		}
+	}
+	return "", fmt.Errorf("no unclaimed mutation since %s", issuedAt.Format(time.RFC3339))
+}
+
+// deleteUserData issues the mutations removing the traits, account
+// associations and audience memberships of a user in the project.
+func (s *DeletionStore) deleteUserData(ctx context.Context, projectID, userID string) error {
+	for _, table := range userDataTables {
+		if err := s.ch.Conn.Exec(ctx, fmt.Sprintf("ALTER TABLE %s DELETE WHERE project_id = ? AND user_id = ?", table), projectID, userID); err != nil {
+			return fmt.Errorf("failed to issue %s delete mutation: %w", table, err)
+		}
+	}
+
+	// audience_members has no project_id; the project's audiences live in
+	// PostgreSQL.
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM audiences WHERE project_id = $1;`, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to list audiences of project %s: %w", projectID, err)
+	}
+	defer rows.Close()
+	var audienceIDs []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to scan audience: %w", err)
+		}
+		audienceIDs = append(audienceIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating audiences: %w", err)
+	}
+	if len(audienceIDs) == 0 {
+		return nil
+	}
+	if err := s.ch.Conn.Exec(ctx, "ALTER TABLE audience_members DELETE WHERE audience_id IN ? AND user_id = ?", audienceIDs, userID); err != nil {
+		return fmt.Errorf("failed to issue audience_members delete mutation: %w", err)
+	}
+	return nil
+}
+
+// FailDeletion records that the mutation for a request could not be issued.
+func (s *DeletionStore) FailDeletion(ctx context.Context, id int, reason string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE data_deletions SET status = $2, fail_reason = $3, completed_at = CURRENT_TIMESTAMP WHERE id = $1;
+	`, id, models.DeletionStatusFailed, reason)
+	if err != nil {
+		return fmt.Errorf("failed to mark deletion as failed: %w", err)
+	}
+	return nil
+}
+
+func (s *DeletionStore) ListDeletions(ctx context.Context, projectID string) ([]models.DataDeletion, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+deletionColumnsSQL+` FROM data_deletions WHERE project_id = $1 ORDER BY id DESC;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deletions: %w", err)
+	}
+	defer rows.Close()
+
+	var deletions []models.DataDeletion
+	for rows.Next() {
+		d, err := scanDeletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deletion: %w", err)
+		}
+		deletions = append(deletions, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deletions: %w", err)
+	}
+	return deletions, nil
+}
+
+// GetDeletion returns the deletion request of the project, refreshing the
+// status of a running mutation from system.mutations first.
+func (s *DeletionStore) GetDeletion(ctx context.Context, projectID string, id int) (*models.DataDeletion, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+deletionColumnsSQL+` FROM data_deletions WHERE id = $1 AND project_id = $2;`, id, projectID)
+	deletion, err := scanDeletion(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("deletion %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deletion: %w", err)
+	}
+
+	if deletion.Status != models.DeletionStatusRunning || deletion.MutationID == "" {
+		return deletion, nil
+	}
+
+	var (
+		isDone     uint8
+		partsToDo  int64
+		failReason string
+	)
+	err = s.ch.Conn.QueryRow(ctx, `
+		SELECT is_done, parts_to_do, latest_fail_reason
+		FROM system.mutations
+		WHERE database = currentDatabase() AND table = 'analytics_events' AND mutation_id = ?
+	`, deletion.MutationID).Scan(&isDone, &partsToDo, &failReason)
+	if err != nil {
+		logging.Ctx(ctx).Warn().Err(err).Msgf("Could not refresh mutation %s for deletion %d", deletion.MutationID, id)
+		return deletion, nil
+	}
+
+	status := models.DeletionStatusRunning
+	switch {
+	case isDone == 1:
+		status = models.DeletionStatusCompleted
+	case failReason != "":
+		status = models.DeletionStatusFailed
+	}
+
+	row = s.db.QueryRowContext(ctx, `
+		UPDATE data_deletions
+		SET status = $2, parts_to_do = $3, fail_reason = $4,
+		    completed_at = CASE WHEN $2 = 'running' THEN NULL ELSE CURRENT_TIMESTAMP END
+		WHERE id = $1
+		RETURNING `+deletionColumnsSQL+`;
+	`, id, status, partsToDo, failReason)
+	deletion, err = scanDeletion(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update deletion status: %w", err)
+	}
+	return deletion, nil
+}
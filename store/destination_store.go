@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"mabletask/api/models"
+)
+
+// DestinationStore keeps the third-party destinations of projects and the
+// outcome of forwarding to them in PostgreSQL.
+type DestinationStore struct {
+	db *sql.DB
+}
+
+func NewDestinationStore(db *sql.DB) *DestinationStore {
+	return &DestinationStore{db: db}
+}
+
+const destinationColumns = `id, project_id, name, kind, config, event_types, enabled, events_forwarded, last_success_at, last_error, last_error_at, created_by, created_at, updated_at`
+
+func scanDestination(row rowScanner) (*models.Destination, error) {
+	var (
+		d                          models.Destination
+		config                     []byte
+		lastSuccessAt, lastErrorAt sql.NullTime
+		createdBy                  sql.NullInt64
+	)
+	err := row.Scan(&d.ID, &d.ProjectID, &d.Name, &d.Kind, &config, pq.Array(&d.EventTypes), &d.Enabled,
+		&d.EventsForwarded, &lastSuccessAt, &d.LastError, &lastErrorAt, &createdBy, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Config = config
+	if d.EventTypes == nil {
+		d.EventTypes = []string{}
+	}
+	if lastSuccessAt.Valid {
+		d.LastSuccessAt = &lastSuccessAt.Time
+	}
+	if lastErrorAt.Valid {
+		d.LastErrorAt = &lastErrorAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		d.CreatedBy = &id
+	}
+	return &d, nil
+}
+
+// normalizeDestination fills the defaults of a request: all event types and
+// enabled.
+func normalizeDestination(req *models.DestinationRequest) {
+	if req.EventTypes == nil {
+		req.EventTypes = []string{}
+	}
+	if req.Enabled == nil {
+		enabled := true
+		req.Enabled = &enabled
+	}
+}
+
+func (s *DestinationStore) CreateDestination(ctx context.Context, projectID string, createdBy int, req models.DestinationRequest) (*models.Destination, error) {
+	normalizeDestination(&req)
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	d, err := scanDestination(s.db.QueryRowContext(ctx, `
+		INSERT INTO destinations (project_id, name, kind, config, event_types, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+destinationColumns+`;
+	`, projectID, req.Name, req.Kind, []byte(req.Config), pq.Array(req.EventTypes), *req.Enabled, creator))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination: %w", err)
+	}
+	return d, nil
+}
+
+// ListDestinations returns the project's destinations.
+func (s *DestinationStore) ListDestinations(ctx context.Context, projectID string) ([]models.Destination, error) {
+	return s.list(ctx, `SELECT `+destinationColumns+` FROM destinations WHERE project_id = $1 ORDER BY id;`, projectID)
+}
+
+// ListEnabledDestinations returns the enabled destinations of all projects.
+func (s *DestinationStore) ListEnabledDestinations(ctx context.Context) ([]models.Destination, error) {
+	return s.list(ctx, `SELECT `+destinationColumns+` FROM destinations WHERE enabled ORDER BY id;`)
+}
+
+func (s *DestinationStore) list(ctx context.Context, query string, args ...interface{}) ([]models.Destination, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destinations: %w", err)
+	}
+	defer rows.Close()
+
+	destinations := []models.Destination{}
+	for rows.Next() {
+		d, err := scanDestination(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan destination: %w", err)
+		}
+		destinations = append(destinations, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating destinations: %w", err)
+	}
+	return destinations, nil
+}
+
+func (s *DestinationStore) GetDestination(ctx context.Context, projectID string, id int) (*models.Destination, error) {
+	d, err := scanDestination(s.db.QueryRowContext(ctx, `SELECT `+destinationColumns+` FROM destinations WHERE id = $1 AND project_id = $2;`, id, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("destination %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destination: %w", err)
+	}
+	return d, nil
+}
+
+// UpdateDestination replaces the definition of a destination of the project.
+// Its forwarding history is kept.
+func (s *DestinationStore) UpdateDestination(ctx context.Context, projectID string, id int, req models.DestinationRequest) (*models.Destination, error) {
+	normalizeDestination(&req)
+	d, err := scanDestination(s.db.QueryRowContext(ctx, `
+		UPDATE destinations
+		SET name = $3, kind = $4, config = $5, event_types = $6, enabled = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+destinationColumns+`;
+	`, id, projectID, req.Name, req.Kind, []byte(req.Config), pq.Array(req.EventTypes), *req.Enabled))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("destination %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update destination: %w", err)
+	}
+	return d, nil
+}
+
+func (s *DestinationStore) DeleteDestination(ctx context.Context, projectID string, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM destinations WHERE id = $1 AND project_id = $2;`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete destination: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("destination %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// RecordForwarding stores the outcome of forwarding n events to a
+// destination: they count as forwarded when sendErr is nil, and sendErr is
+// kept as the last error otherwise.
+func (s *DestinationStore) RecordForwarding(ctx context.Context, id int, n int, sendErr error) error {
+	var err error
+	if sendErr == nil {
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE destinations
+			SET events_forwarded = events_forwarded + $2, last_success_at = CURRENT_TIMESTAMP
+			WHERE id = $1;
+		`, id, n)
+	} else {
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE destinations SET last_error = $2, last_error_at = CURRENT_TIMESTAMP WHERE id = $1;
+		`, id, sendErr.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record forwarding to destination %d: %w", id, err)
+	}
+	return nil
+}
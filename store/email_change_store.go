@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mabletask/api/utils"
+)
+
+// EmailChangeStore keeps pending email changes until the new address is
+// confirmed. Like password reset tokens, a token is redeemed once, before it
+// expires, and requesting a new change invalidates the user's earlier ones.
+type EmailChangeStore struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+// NewEmailChangeStore issues confirmation tokens valid for ttl.
+func NewEmailChangeStore(db *sql.DB, ttl time.Duration) *EmailChangeStore {
+	return &EmailChangeStore{db: db, ttl: ttl}
+}
+
+// CreateToken records a change of the user's email to newEmail and returns
+// the token confirming it, with its expiry.
+func (s *EmailChangeStore) CreateToken(ctx context.Context, userID int, newEmail string) (string, time.Time, error) {
+	token, err := utils.NewToken(utils.EmailChangePrefix)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE email_changes SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL;
+	`, userID); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to invalidate email changes: %w", err)
+	}
+	var expiresAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO email_changes (user_id, new_email, token_hash, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))
+		RETURNING expires_at;
+	`, userID, newEmail, utils.HashToken(token), s.ttl.Seconds()).Scan(&expiresAt)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store email change: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to commit email change: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// Redeem consumes token and returns the user and the email it confirms.
+// Unknown, used or expired tokens give ErrRevoked.
+func (s *EmailChangeStore) Redeem(ctx context.Context, token string) (int, string, error) {
+	var (
+		userID   int
+		newEmail string
+	)
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE email_changes SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id, new_email;
+	`, utils.HashToken(token)).Scan(&userID, &newEmail)
+	if err == sql.ErrNoRows {
+		return 0, "", fmt.Errorf("email change token: %w", ErrRevoked)
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to redeem email change token: %w", err)
+	}
+	return userID, newEmail, nil
+}
+
+// DeleteExpiredBefore removes email changes that expired before cutoff.
+func (s *EmailChangeStore) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM email_changes WHERE expires_at < $1;`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune email changes: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// sessionPageViews restricts a query to the page views of sessions in the
+// range. Pages are compared without their query string, so campaign links
+// count towards the page they lead to.
+const sessionPageViews = `event_type = 'page_view' AND session_id != '' AND ` + timeRangeClause
+
+// GetEntryPages returns the limit pages most sessions started on: the page of
+// each session's first page view.
+func (s *AnalyticsStore) GetEntryPages(ctx context.Context, start, end time.Time, limit uint64, filters EventFilters) ([]models.EntryPage, error) {
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT entry_page, `+weightedCount+` AS entries, entries / sum(entries) OVER () AS share
+		FROM (
+			SELECT session_id, argMin(cutQueryString(page_path), timestamp) AS entry_page,
+			       `+sampledWeight+` AS weight
+			FROM analytics_events
+			WHERE %s%s
+			GROUP BY session_id
+		)
+		GROUP BY entry_page
+		ORDER BY entries DESC, entry_page
+		LIMIT ?
+	`, sessionPageViews, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entry pages: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.EntryPage{}
+	for rows.Next() {
+		var r models.EntryPage
+		if err := rows.Scan(&r.PagePath, &r.Entries, &r.Share); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for entry pages")
+			continue
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for entry pages: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetExitPages returns the limit pages most sessions ended on: the page of
+// each session's last page view, with the page's views in the range.
+func (s *AnalyticsStore) GetExitPages(ctx context.Context, start, end time.Time, limit uint64, filters EventFilters) ([]models.ExitPage, error) {
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, args...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT exit_page, exits, views, exits / greatest(views, 1) AS exit_rate
+		FROM (
+			SELECT exit_page, `+weightedCount+` AS exits
+			FROM (
+				SELECT session_id, argMax(cutQueryString(page_path), timestamp) AS exit_page,
+				       `+sampledWeight+` AS weight
+				FROM analytics_events
+				WHERE %[1]s%[2]s
+				GROUP BY session_id
+			)
+			GROUP BY exit_page
+		) AS e
+		ANY LEFT JOIN (
+			SELECT cutQueryString(page_path) AS exit_page, `+sampledCount+` AS views
+			FROM analytics_events
+			WHERE %[1]s%[2]s
+			GROUP BY exit_page
+		) AS v USING (exit_page)
+		ORDER BY exits DESC, exit_page
+		LIMIT ?
+	`, sessionPageViews, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exit pages: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.ExitPage{}
+	for rows.Next() {
+		var r models.ExitPage
+		if err := rows.Scan(&r.PagePath, &r.Exits, &r.Views, &r.ExitRate); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for exit pages")
+			continue
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for exit pages: %w", err)
+	}
+
+	return results, nil
+}
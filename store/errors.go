@@ -0,0 +1,17 @@
+package store
+
+import "errors"
+
+// ErrNotFound is wrapped by store methods when the requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists is wrapped by store methods when a unique record already exists.
+var ErrAlreadyExists = errors.New("already exists")
+
+// ErrInvalid is wrapped by store methods when a request is inconsistent with
+// the stored records, e.g. it references records that do not belong together.
+var ErrInvalid = errors.New("invalid")
+
+// ErrRevoked is wrapped by store methods when a credential has been revoked
+// or has expired.
+var ErrRevoked = errors.New("revoked")
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// EventDeduper remembers the client-supplied IDs of recently ingested events,
+// so that events of a retried batch are not recorded twice. IDs are
+// remembered for a window after they were first seen.
+type EventDeduper interface {
+	// MarkSeen marks keys as seen and reports for each whether it had been
+	// seen before within the window.
+	MarkSeen(ctx context.Context, keys []string) ([]bool, error)
+	// Forget unmarks keys, e.g. when the events they belong to could not be
+	// recorded and may be retried.
+	Forget(ctx context.Context, keys []string) error
+}
+
+// NewEventDeduper remembers IDs in Redis when a client is given, so all
+// replicas see them, and in process memory otherwise.
+func NewEventDeduper(client *redis.Client, window time.Duration) EventDeduper {
+	if client != nil {
+		return &RedisEventDeduper{client: client, window: window}
+	}
+	return NewMemoryEventDeduper(window)
+}
+
+// RedisEventDeduper keeps IDs in Redis keys that expire with the window.
+type RedisEventDeduper struct {
+	client *redis.Client
+	window time.Duration
+}
+
+const dedupeKeyPrefix = "dedupe:"
+
+func (r *RedisEventDeduper) MarkSeen(ctx context.Context, keys []string) ([]bool, error) {
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.SetNX(ctx, dedupeKeyPrefix+key, 1, r.window)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to check event ids: %w", err)
+	}
+	seen := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		seen[i] = !cmd.Val()
+	}
+	return seen, nil
+}
+
+func (r *RedisEventDeduper) Forget(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = dedupeKeyPrefix + key
+	}
+	if err := r.client.Del(ctx, prefixed...).Err(); err != nil {
+		return fmt.Errorf("failed to forget event ids: %w", err)
+	}
+	return nil
+}
+
+// MemoryEventDeduper keeps IDs in process memory. Each replica only sees the
+// IDs it ingested itself, which suits single-instance deployments.
+type MemoryEventDeduper struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // key -> when it was first seen
+	lastSweep time.Time
+}
+
+func NewMemoryEventDeduper(window time.Duration) *MemoryEventDeduper {
+	return &MemoryEventDeduper{window: window, seen: map[string]time.Time{}, lastSweep: time.Now()}
+}
+
+func (m *MemoryEventDeduper) MarkSeen(ctx context.Context, keys []string) ([]bool, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Expired IDs are dropped in a sweep every tenth of the window.
+	if now.Sub(m.lastSweep) >= m.window/10 {
+		for key, at := range m.seen {
+			if now.Sub(at) >= m.window {
+				delete(m.seen, key)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	seen := make([]bool, len(keys))
+	for i, key := range keys {
+		if at, ok := m.seen[key]; ok && now.Sub(at) < m.window {
+			seen[i] = true
+			continue
+		}
+		m.seen[key] = now
+	}
+	return seen, nil
+}
+
+func (m *MemoryEventDeduper) Forget(ctx context.Context, keys []string) error {
+	m.mu.Lock()
+	for _, key := range keys {
+		delete(m.seen, key)
+	}
+	m.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryEventDeduperMarkSeen(t *testing.T) {
+	d := NewMemoryEventDeduper(time.Hour)
+	ctx := context.Background()
+
+	seen, err := d.MarkSeen(ctx, []string{"a", "b", "a"})
+	if err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	if want := []bool{false, false, true}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("first batch seen = %v, want %v", seen, want)
+	}
+
+	seen, err = d.MarkSeen(ctx, []string{"b", "c"})
+	if err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	if want := []bool{true, false}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("second batch seen = %v, want %v", seen, want)
+	}
+}
+
+func TestMemoryEventDeduperForget(t *testing.T) {
+	d := NewMemoryEventDeduper(time.Hour)
+	ctx := context.Background()
+
+	if _, err := d.MarkSeen(ctx, []string{"a", "b"}); err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	if err := d.Forget(ctx, []string{"a", "unknown"}); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	seen, err := d.MarkSeen(ctx, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	if want := []bool{false, true}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("seen after Forget = %v, want %v", seen, want)
+	}
+}
+
+func TestMemoryEventDeduperWindow(t *testing.T) {
+	d := NewMemoryEventDeduper(20 * time.Millisecond)
+	ctx := context.Background()
+
+	if _, err := d.MarkSeen(ctx, []string{"a"}); err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	seen, err := d.MarkSeen(ctx, []string{"a"})
+	if err != nil {
+		t.Fatalf("MarkSeen: %v", err)
+	}
+	if seen[0] {
+		t.Error("key still seen after the window")
+	}
+}
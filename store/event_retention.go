@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeleteExpiredEvents issues one DELETE mutation removing the events the
+// policy no longer retains. An event expires after the shorter of its
+// project's and its event type's retention.
+func (s *AnalyticsStore) DeleteExpiredEvents(ctx context.Context, now time.Time, policy RetentionPolicy) error {
+	cutoff := func(days int) int64 {
+		if days <= 0 {
+			return 0
+		}
+		return now.AddDate(0, 0, -days).UnixMilli()
+	}
+
+	projects := make([]string, 0, len(policy.Projects))
+	cutoffs := make([]int64, 0, len(policy.Projects))
+	for projectID, days := range policy.Projects {
+		projects = append(projects, projectID)
+		cutoffs = append(cutoffs, cutoff(days))
+	}
+	defaultCutoff := cutoff(policy.Default)
+
+	// Event types of a project are keyed "<project>:<event type>"; project
+	// IDs cannot contain a colon.
+	var typeKeys []string
+	var typeCutoffs []int64
+	for projectID, types := range policy.EventTypes {
+		for eventType, days := range types {
+			typeKeys = append(typeKeys, projectID+":"+eventType)
+			typeCutoffs = append(typeCutoffs, cutoff(days))
+		}
+	}
+	var defaultTypes []string
+	var defaultTypeCutoffs []int64
+	for eventType, days := range policy.DefaultEventTypes {
+		defaultTypes = append(defaultTypes, eventType)
+		defaultTypeCutoffs = append(defaultTypeCutoffs, cutoff(days))
+	}
+
+	// A cutoff of 0 (the epoch) keeps everything; the later of the project
+	// and event type cutoffs wins.
+	var conds []string
+	var args []interface{}
+	switch {
+	case len(projects) > 0:
+		conds = append(conds, "transform(project_id, ?, ?, toInt64(?))")
+		args = append(args, projects, cutoffs, defaultCutoff)
+	case defaultCutoff > 0:
+		conds = append(conds, "toInt64(?)")
+		args = append(args, defaultCutoff)
+	}
+	switch {
+	case len(typeKeys) > 0 && len(defaultTypes) > 0:
+		conds = append(conds, "transform(concat(project_id, ':', event_type), ?, ?, transform(event_type, ?, ?, toInt64(0)))")
+		args = append(args, typeKeys, typeCutoffs, defaultTypes, defaultTypeCutoffs)
+	case len(typeKeys) > 0:
+		conds = append(conds, "transform(concat(project_id, ':', event_type), ?, ?, toInt64(0))")
+		args = append(args, typeKeys, typeCutoffs)
+	case len(defaultTypes) > 0:
+		conds = append(conds, "transform(event_type, ?, ?, toInt64(0))")
+		args = append(args, defaultTypes, defaultTypeCutoffs)
+	}
+
+	var expr string
+	switch len(conds) {
+	case 0:
+		return nil
+	case 1:
+		expr = conds[0]
+	default:
+		expr = "greatest(" + conds[0] + ", " + conds[1] + ")"
+	}
+	query := "ALTER TABLE analytics_events DELETE WHERE timestamp < fromUnixTimestamp64Milli(" + expr + ", 'UTC')"
+	if err := s.DB.Conn.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete expired events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// eventSchema is the compiled schema of an event type.
+type eventSchema struct {
+	schema *jsonschema.Schema
+	mode   string
+}
+
+// compileEventSchema compiles the JSON Schema of event type name. Remote
+// $refs are not followed.
+func compileEventSchema(name string, raw json.RawMessage) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: schema is not valid JSON: %v", ErrInvalid, err)
+	}
+	location := "mem://event-types/" + url.PathEscape(name)
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(location, doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	schema, err := compiler.Compile(location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid schema: %v", ErrInvalid, err)
+	}
+	return schema, nil
+}
+
+func schemaKey(projectID, eventType string) string {
+	return projectID + ":" + eventType
+}
+
+// hasSchema reports whether raw holds a schema rather than nothing or null.
+func hasSchema(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null"))
+}
+
+// Refresh reloads the schemas used by ValidateEventData.
+func (s *EventTypeStore) Refresh(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT project_id, name, schema, schema_mode FROM event_types WHERE schema IS NOT NULL;`)
+	if err != nil {
+		return fmt.Errorf("failed to load event schemas: %w", err)
+	}
+	defer rows.Close()
+
+	schemas := map[string]eventSchema{}
+	for rows.Next() {
+		var (
+			projectID, name, mode string
+			raw                   []byte
+		)
+		if err := rows.Scan(&projectID, &name, &raw, &mode); err != nil {
+			return fmt.Errorf("failed to scan event schema: %w", err)
+		}
+		schema, err := compileEventSchema(name, raw)
+		if err != nil {
+			// Stored schemas compiled when they were saved; skip rather
+			// than fail ingestion should one stop compiling.
+			logging.Ctx(ctx).Warn().Err(err).Msgf("Skipping schema of event type %s of project %s", name, projectID)
+			continue
+		}
+		schemas[schemaKey(projectID, name)] = eventSchema{schema: schema, mode: mode}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating event schemas: %w", err)
+	}
+
+	s.mu.Lock()
+	s.schemas = schemas
+	s.mu.Unlock()
+	return nil
+}
+
+// setSchema updates the in-memory schema of an event type after it was saved.
+func (s *EventTypeStore) setSchema(projectID, name string, schema *jsonschema.Schema, mode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if schema == nil {
+		delete(s.schemas, schemaKey(projectID, name))
+		return
+	}
+	s.schemas[schemaKey(projectID, name)] = eventSchema{schema: schema, mode: mode}
+}
+
+// ValidateEventData checks the eventData of an event of eventType against the
+// schema the project registered for the type, treating missing eventData as an
+// empty object. It returns the type's schema mode and the problems found, or ""
+// when the type has no schema.
+func (s *EventTypeStore) ValidateEventData(projectID, eventType string, data json.RawMessage) (string, []string) {
+	s.mu.RLock()
+	schema, ok := s.schemas[schemaKey(projectID, eventType)]
+	s.mu.RUnlock()
+	if !ok {
+		return "", nil
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = json.RawMessage("{}")
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return schema.mode, []string{"eventData is not valid JSON"}
+	}
+	err = schema.schema.Validate(instance)
+	if err == nil {
+		return schema.mode, nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return schema.mode, []string{err.Error()}
+	}
+	return schema.mode, schemaProblems(validationErr)
+}
+
+// schemaProblems flattens a validation error into one message per failing
+// location, such as "/price: got string, want number".
+func schemaProblems(err *jsonschema.ValidationError) []string {
+	var problems []string
+	for _, unit := range err.BasicOutput().Errors {
+		if unit.Error == nil || len(unit.Errors) > 0 {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		problems = append(problems, location+": "+unit.Error.String())
+	}
+	if len(problems) == 0 {
+		problems = []string{err.Error()}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// schemaErrors returns the schema_errors column of an event, never nil.
+func schemaErrors(event *models.AnalyticsEvent) []string {
+	if event.SchemaErrors == nil {
+		return []string{}
+	}
+	return event.SchemaErrors
+}
+
+// schemaMode defaults an event type's schema mode to lenient.
+func schemaMode(req models.EventTypeRequest) string {
+	if req.SchemaMode == "" {
+		return models.SchemaModeLenient
+	}
+	return req.SchemaMode
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"mabletask/api/models"
+)
+
+// RetentionPolicy is how many days events are kept, 0 meaning forever.
+type RetentionPolicy struct {
+	// Projects holds the retention of projects with saved settings; other
+	// projects keep events for Default days.
+	Projects map[string]int
+	Default  int
+	// EventTypes holds the retention of event types by project and event
+	// type; DefaultEventTypes that of event types a project has no rule for.
+	// They only shorten the retention of the project.
+	EventTypes        map[string]map[string]int
+	DefaultEventTypes map[string]int
+}
+
+// ParseEventTypeRetentions reads a comma-separated list of event type
+// retentions in days, e.g. "web_vital=30,page_view=365".
+func ParseEventTypeRetentions(spec string) (map[string]int, error) {
+	retentions := map[string]int{}
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		eventType, rawDays, ok := strings.Cut(entry, "=")
+		days, err := strconv.Atoi(strings.TrimSpace(rawDays))
+		if !ok || err != nil || days <= 0 || strings.TrimSpace(eventType) == "" {
+			return nil, fmt.Errorf("invalid event type retention %q; want <eventType>=<days>", entry)
+		}
+		retentions[strings.TrimSpace(eventType)] = days
+	}
+	return retentions, nil
+}
+
+const eventTypeRetentionColumns = `project_id, event_type, retention_days, updated_by, updated_at`
+
+func scanEventTypeRetention(row rowScanner) (*models.EventTypeRetention, error) {
+	var (
+		retention models.EventTypeRetention
+		updatedBy sql.NullInt64
+	)
+	if err := row.Scan(&retention.ProjectID, &retention.EventType, &retention.RetentionDays, &updatedBy, &retention.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if updatedBy.Valid {
+		id := int(updatedBy.Int64)
+		retention.UpdatedBy = &id
+	}
+	return &retention, nil
+}
+
+// ListEventTypeRetentions returns the event type retentions of a project.
+// Event types covered only by DefaultEventTypeRetention are not included.
+func (s *ProjectSettingsStore) ListEventTypeRetentions(ctx context.Context, projectID string) ([]models.EventTypeRetention, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+eventTypeRetentionColumns+` FROM event_type_retention WHERE project_id = $1 ORDER BY event_type;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event type retention of project %s: %w", projectID, err)
+	}
+	defer rows.Close()
+
+	retentions := []models.EventTypeRetention{}
+	for rows.Next() {
+		retention, err := scanEventTypeRetention(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event type retention: %w", err)
+		}
+		retentions = append(retentions, *retention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event type retention: %w", err)
+	}
+	return retentions, nil
+}
+
+// SetEventTypeRetention sets how many days the project keeps events of
+// eventType.
+func (s *ProjectSettingsStore) SetEventTypeRetention(ctx context.Context, projectID, eventType string, days, updatedBy int) (*models.EventTypeRetention, error) {
+	var updater interface{}
+	if updatedBy != 0 {
+		updater = updatedBy
+	}
+	retention, err := scanEventTypeRetention(s.db.QueryRowContext(ctx, `
+		INSERT INTO event_type_retention (project_id, event_type, retention_days, updated_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id, event_type) DO UPDATE
+		SET retention_days = EXCLUDED.retention_days,
+		    updated_by = EXCLUDED.updated_by,
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING `+eventTypeRetentionColumns+`;
+	`, projectID, eventType, days, updater))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set retention of %s events for project %s: %w", eventType, projectID, err)
+	}
+	return retention, nil
+}
+
+// DeleteEventTypeRetention removes the project's retention of eventType, so
+// its events are kept as long as the project's other events.
+func (s *ProjectSettingsStore) DeleteEventTypeRetention(ctx context.Context, projectID, eventType string) error {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM event_type_retention WHERE project_id = $1 AND event_type = $2;`, projectID, eventType)
+	if err != nil {
+		return fmt.Errorf("failed to delete retention of %s events for project %s: %w", eventType, projectID, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RetentionPolicy gathers the retention of every project and event type.
+func (s *ProjectSettingsStore) RetentionPolicy(ctx context.Context) (RetentionPolicy, error) {
+	policy := RetentionPolicy{
+		Default:           s.DefaultMaxRetentionDays,
+		EventTypes:        map[string]map[string]int{},
+		DefaultEventTypes: s.DefaultEventTypeRetention,
+	}
+	var err error
+	if policy.Projects, err = s.ListRetentions(ctx); err != nil {
+		return policy, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT project_id, event_type, retention_days FROM event_type_retention;`)
+	if err != nil {
+		return policy, fmt.Errorf("failed to list event type retention: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			projectID, eventType string
+			days                 int
+		)
+		if err := rows.Scan(&projectID, &eventType, &days); err != nil {
+			return policy, fmt.Errorf("failed to scan event type retention: %w", err)
+		}
+		if policy.EventTypes[projectID] == nil {
+			policy.EventTypes[projectID] = map[string]int{}
+		}
+		policy.EventTypes[projectID][eventType] = days
+	}
+	if err := rows.Err(); err != nil {
+		return policy, fmt.Errorf("error iterating event type retention: %w", err)
+	}
+	return policy, nil
+}
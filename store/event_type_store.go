@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"mabletask/api/models"
+)
+
+// EventTypeStore manages the registry of event types. The JSON Schemas of the
+// types are also kept compiled in memory for ingestion, see ValidateEventData.
+type EventTypeStore struct {
+	db *sql.DB
+
+	mu      sync.RWMutex
+	schemas map[string]eventSchema // project_id + ":" + event type -> compiled schema
+}
+
+func NewEventTypeStore(db *sql.DB) *EventTypeStore {
+	return &EventTypeStore{db: db, schemas: map[string]eventSchema{}}
+}
+
+const eventTypeColumns = `name, display_name, category, description, expected_properties, schema, schema_mode, deprecated, deprecation_note, deprecated_at, created_at, updated_at`
+
+func scanEventType(row rowScanner) (*models.EventType, error) {
+	var (
+		et           models.EventType
+		properties   []byte
+		schema       []byte
+		deprecatedAt sql.NullTime
+	)
+	err := row.Scan(&et.Name, &et.DisplayName, &et.Category, &et.Description, &properties, &schema, &et.SchemaMode, &et.Deprecated, &et.DeprecationNote, &deprecatedAt, &et.CreatedAt, &et.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(properties, &et.ExpectedProperties); err != nil {
+		return nil, fmt.Errorf("failed to decode expected properties of %s: %w", et.Name, err)
+	}
+	if schema != nil {
+		et.Schema = json.RawMessage(schema)
+	}
+	if deprecatedAt.Valid {
+		et.DeprecatedAt = &deprecatedAt.Time
+	}
+	return &et, nil
+}
+
+func encodeProperties(properties []models.EventProperty) ([]byte, error) {
+	if properties == nil {
+		properties = []models.EventProperty{}
+	}
+	raw, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode expected properties: %w", err)
+	}
+	return raw, nil
+}
+
+// encodeSchema compiles the schema of an event type request, returning it
+// compiled and as stored (nil for none). Invalid schemas wrap ErrInvalid.
+func encodeSchema(req models.EventTypeRequest) (*jsonschema.Schema, []byte, error) {
+	if !hasSchema(req.Schema) {
+		return nil, nil, nil
+	}
+	schema, err := compileEventSchema(req.Name, req.Schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schema, []byte(req.Schema), nil
+}
+
+func (s *EventTypeStore) CreateEventType(ctx context.Context, projectID string, req models.EventTypeRequest) (*models.EventType, error) {
+	properties, err := encodeProperties(req.ExpectedProperties)
+	if err != nil {
+		return nil, err
+	}
+	compiled, schema, err := encodeSchema(req)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO event_types (project_id, name, display_name, category, description, expected_properties, schema, schema_mode)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+eventTypeColumns+`;
+	`, projectID, req.Name, req.DisplayName, req.Category, req.Description, properties, schema, schemaMode(req))
+	et, err := scanEventType(row)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, fmt.Errorf("event type '%s': %w", req.Name, ErrAlreadyExists)
+		}
+		return nil, fmt.Errorf("failed to create event type: %w", err)
+	}
+	s.setSchema(projectID, et.Name, compiled, et.SchemaMode)
+	return et, nil
+}
+
+func (s *EventTypeStore) ListEventTypes(ctx context.Context, projectID string, includeDeprecated bool) ([]models.EventType, error) {
+	query := `SELECT ` + eventTypeColumns + ` FROM event_types WHERE project_id = $1`
+	if !includeDeprecated {
+		query += ` AND NOT deprecated`
+	}
+	query += ` ORDER BY category, name;`
+
+	rows, err := s.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event types: %w", err)
+	}
+	defer rows.Close()
+
+	var eventTypes []models.EventType
+	for rows.Next() {
+		et, err := scanEventType(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event type: %w", err)
+		}
+		eventTypes = append(eventTypes, *et)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event types: %w", err)
+	}
+	return eventTypes, nil
+}
+
+func (s *EventTypeStore) GetEventType(ctx context.Context, projectID, name string) (*models.EventType, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+eventTypeColumns+` FROM event_types WHERE project_id = $1 AND name = $2;`, projectID, name)
+	et, err := scanEventType(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("event type '%s': %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event type: %w", err)
+	}
+	return et, nil
+}
+
+// UpdateEventType replaces the descriptive fields of an event type. The name
+// is immutable since it is what SDKs send.
+func (s *EventTypeStore) UpdateEventType(ctx context.Context, projectID, name string, req models.EventTypeRequest) (*models.EventType, error) {
+	properties, err := encodeProperties(req.ExpectedProperties)
+	if err != nil {
+		return nil, err
+	}
+	compiled, schema, err := encodeSchema(req)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE event_types
+		SET display_name = $3, category = $4, description = $5, expected_properties = $6,
+		    schema = $7, schema_mode = $8, updated_at = CURRENT_TIMESTAMP
+		WHERE project_id = $1 AND name = $2
+		RETURNING `+eventTypeColumns+`;
+	`, projectID, name, req.DisplayName, req.Category, req.Description, properties, schema, schemaMode(req))
+	et, err := scanEventType(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("event type '%s': %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event type: %w", err)
+	}
+	s.setSchema(projectID, et.Name, compiled, et.SchemaMode)
+	return et, nil
+}
+
+func (s *EventTypeStore) DeprecateEventType(ctx context.Context, projectID, name, note string) (*models.EventType, error) {
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE event_types
+		SET deprecated = TRUE, deprecation_note = $3,
+		    deprecated_at = COALESCE(deprecated_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE project_id = $1 AND name = $2
+		RETURNING `+eventTypeColumns+`;
+	`, projectID, name, note)
+	et, err := scanEventType(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("event type '%s': %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to deprecate event type: %w", err)
+	}
+	return et, nil
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mabletask/api/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrBufferFull is returned by EventWriter.Enqueue when the buffer holds as
+// many events as it allows, e.g. while ClickHouse is unavailable. Callers
+// should retry later.
+var ErrBufferFull = errors.New("event buffer full")
+
+const (
+	eventWriteTimeout = 30 * time.Second
+	eventWriteRetries = 3
+)
+
+// EventWriter takes event persistence out of the request path: events are
+// buffered in memory and a background goroutine inserts them into
+// analytics_events in batches, once batchSize events are waiting or every
+// flushInterval. Buffered events are lost if the process dies before they
+// are flushed.
+type EventWriter struct {
+	store         *AnalyticsStore
+	batchSize     int
+	capacity      int
+	flushInterval time.Duration
+	// OnDrop is called with the events of a batch given up after its
+	// retries failed; nil just drops them.
+	OnDrop func(events []models.AnalyticsEvent)
+
+	mu       sync.Mutex
+	pending  []models.AnalyticsEvent
+	inFlight int
+	closed   bool
+
+	ready chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewEventWriter buffers at most capacity events, and reports the buffer
+// depth in the store's ingestion statistics. Start must be called for events
+// to be written.
+func NewEventWriter(s *AnalyticsStore, batchSize, capacity int, flushInterval time.Duration) *EventWriter {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if capacity < batchSize {
+		capacity = batchSize
+	}
+	w := &EventWriter{
+		store:         s,
+		batchSize:     batchSize,
+		capacity:      capacity,
+		flushInterval: flushInterval,
+		ready:         make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	s.Ingest.SetBufferDepth(w.Depth)
+	return w
+}
+
+// Enqueue buffers events for writing. Either all of them are buffered or, when
+// they do not fit or the writer is closed, none are and ErrBufferFull is
+// returned.
+func (w *EventWriter) Enqueue(ctx context.Context, events []models.AnalyticsEvent) error {
+	w.mu.Lock()
+	if w.closed || len(w.pending)+w.inFlight+len(events) > w.capacity {
+		w.mu.Unlock()
+		return ErrBufferFull
+	}
+	w.pending = append(w.pending, events...)
+	full := len(w.pending) >= w.batchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.ready <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Depth returns how many events are buffered or being written.
+func (w *EventWriter) Depth() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending) + w.inFlight
+}
+
+// Start runs the background writer until Close.
+func (w *EventWriter) Start() {
+	go w.run()
+	log.Info().Msgf("Event writer started (batches of %d, every %s, buffer of %d)", w.batchSize, w.flushInterval, w.capacity)
+}
+
+// Close stops accepting events and waits until the buffered ones are written
+// or ctx is done, in which case the events still buffered are lost.
+func (w *EventWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.stop)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d buffered events not written: %w", w.Depth(), ctx.Err())
+	}
+}
+
+func (w *EventWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			w.flush(true)
+			return
+		case <-ticker.C:
+			w.flush(true)
+		case <-w.ready:
+			w.flush(false)
+		}
+	}
+}
+
+// flush writes the buffered events in batches of batchSize. Unless all is
+// set, a last partial batch is left for the next flush.
+func (w *EventWriter) flush(all bool) {
+	for {
+		w.mu.Lock()
+		n := min(len(w.pending), w.batchSize)
+		if n == 0 || (!all && n < w.batchSize) {
+			w.mu.Unlock()
+			return
+		}
+		batch := w.pending[:n:n]
+		w.pending = w.pending[n:]
+		w.inFlight = n
+		w.mu.Unlock()
+
+		w.write(batch)
+
+		w.mu.Lock()
+		w.inFlight = 0
+		w.mu.Unlock()
+	}
+}
+
+// write inserts a batch, retrying with backoff before giving it up.
+func (w *EventWriter) write(batch []models.AnalyticsEvent) {
+	var err error
+	for attempt := 0; attempt < eventWriteRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), eventWriteTimeout)
+		err = w.store.InsertEventBatch(ctx, batch)
+		cancel()
+		if err == nil {
+			return
+		}
+		log.Error().Err(err).Msgf("Failed to write %d buffered events (attempt %d of %d)", len(batch), attempt+1, eventWriteRetries)
+	}
+	w.store.Ingest.RecordFailure(len(batch), err)
+	if w.OnDrop != nil {
+		w.OnDrop(batch)
+	}
+}
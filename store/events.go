@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// EventQuery selects raw events of a project between Start and End. Besides
+// the segmentation Filters, events can be narrowed to one user, anonymous
+// visitor or session.
+type EventQuery struct {
+	Filters     EventFilters
+	UserID      string
+	AnonymousID string
+	SessionID   string
+	Start, End  time.Time
+}
+
+// ListEvents returns a page of the project's events matching q, newest first,
+// keyed by (timestamp, event_id).
+func (s *AnalyticsStore) ListEvents(ctx context.Context, projectID string, q EventQuery, page PageRequest) (models.Page[models.AnalyticsEvent], error) {
+	q.Filters.ProjectID = projectID
+	filterClause, filterArgs := q.Filters.clause()
+	args := []interface{}{q.Start.UnixMilli(), q.End.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	where := timeRangeClause + filterClause
+	for _, eq := range [][2]string{{"user_id", q.UserID}, {"anonymous_id", q.AnonymousID}, {"session_id", q.SessionID}} {
+		if eq[1] != "" {
+			where += " AND " + eq[0] + " = ?"
+			args = append(args, eq[1])
+		}
+	}
+	if page.After != nil {
+		where += " AND (timestamp, event_id) < (fromUnixTimestamp64Milli(toInt64(?), 'UTC'), toUUID(?))"
+		args = append(args, page.After.Time.UnixMilli(), page.After.ID)
+	}
+	args = append(args, page.fetchLimit())
+
+	rows, err := s.query(ctx, `
+		SELECT `+eventColumns+`
+		FROM analytics_events
+		WHERE `+where+`
+		ORDER BY timestamp DESC, event_id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return models.Page[models.AnalyticsEvent]{}, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AnalyticsEvent{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return models.Page[models.AnalyticsEvent]{}, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Page[models.AnalyticsEvent]{}, fmt.Errorf("error iterating events: %w", err)
+	}
+
+	return newPage(events, page, func(e models.AnalyticsEvent) Cursor {
+		return Cursor{Time: e.Timestamp, ID: e.EventID}
+	}), nil
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// ExchangeRateStore keeps daily exchange rates against the euro, used to
+// report revenue in a project's base currency.
+type ExchangeRateStore struct {
+	db *sql.DB
+}
+
+func NewExchangeRateStore(db *sql.DB) *ExchangeRateStore {
+	return &ExchangeRateStore{db: db}
+}
+
+// SetRates stores the rates of one day, as units of currency per 1 EUR,
+// replacing earlier rates of that day.
+func (s *ExchangeRateStore) SetRates(ctx context.Context, date time.Time, rates map[string]float64, source string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin exchange rate update: %w", err)
+	}
+	defer tx.Rollback()
+
+	for currency, perEUR := range rates {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO exchange_rates (rate_date, currency, per_eur, source)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (currency, rate_date) DO UPDATE
+			SET per_eur = EXCLUDED.per_eur, source = EXCLUDED.source, updated_at = CURRENT_TIMESTAMP;
+		`, date.Format(models.DateFormat), currency, perEUR, source)
+		if err != nil {
+			return fmt.Errorf("failed to store %s rate for %s: %w", currency, date.Format(models.DateFormat), err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit exchange rates: %w", err)
+	}
+	return nil
+}
+
+// ListRates returns the latest rate of every currency as of date.
+func (s *ExchangeRateStore) ListRates(ctx context.Context, date time.Time) ([]models.ExchangeRate, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (currency) rate_date, currency, per_eur, source, updated_at
+		FROM exchange_rates
+		WHERE rate_date <= $1::DATE
+		ORDER BY currency, rate_date DESC;
+	`, date.Format(models.DateFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var r models.ExchangeRate
+		var day time.Time
+		if err := rows.Scan(&day, &r.Currency, &r.PerEUR, &r.Source, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rate: %w", err)
+		}
+		r.Date = day.Format(models.DateFormat)
+		rates = append(rates, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+type datedRate struct {
+	day    time.Time
+	perEUR float64
+}
+
+// RateTable converts amounts between currencies at the rate of a given day.
+type RateTable struct {
+	rates map[string][]datedRate // ascending by day
+}
+
+// LoadRates reads the rates of currencies needed to convert amounts dated from
+// start to end: every rate in the range plus the last one before it.
+func (s *ExchangeRateStore) LoadRates(ctx context.Context, currencies []string, start, end time.Time) (*RateTable, error) {
+	table := &RateTable{rates: map[string][]datedRate{}}
+	for _, currency := range currencies {
+		if currency == "EUR" {
+			continue
+		}
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT rate_date, per_eur FROM exchange_rates
+			WHERE currency = $1 AND rate_date <= $3::DATE AND rate_date >= COALESCE(
+				(SELECT max(rate_date) FROM exchange_rates WHERE currency = $1 AND rate_date <= $2::DATE), $2::DATE)
+			ORDER BY rate_date;
+		`, currency, start.Format(models.DateFormat), end.Format(models.DateFormat))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s exchange rates: %w", currency, err)
+		}
+		for rows.Next() {
+			var r datedRate
+			if err := rows.Scan(&r.day, &r.perEUR); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan exchange rate: %w", err)
+			}
+			table.rates[currency] = append(table.rates[currency], r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating exchange rates: %w", err)
+		}
+	}
+	return table, nil
+}
+
+// perEUR returns the latest rate of currency on or before day, or the earliest
+// loaded one when day precedes them all.
+func (t *RateTable) perEUR(currency string, day time.Time) (float64, bool) {
+	if currency == "EUR" {
+		return 1, true
+	}
+	rates := t.rates[currency]
+	if len(rates) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].day.After(day) })
+	if i == 0 {
+		return rates[0].perEUR, true
+	}
+	return rates[i-1].perEUR, true
+}
+
+// Convert converts amount from one currency to another at day's rates.
+func (t *RateTable) Convert(amount float64, from, to string, day time.Time) (float64, bool) {
+	if from == to {
+		return amount, true
+	}
+	fromRate, ok := t.perEUR(from, day)
+	if !ok {
+		return 0, false
+	}
+	toRate, ok := t.perEUR(to, day)
+	if !ok {
+		return 0, false
+	}
+	return amount / fromRate * toRate, true
+}
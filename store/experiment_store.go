@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"mabletask/api/models"
+)
+
+// ExperimentStore keeps A/B experiment definitions. Their results are computed
+// from events by AnalyticsStore.GetExperimentVariants.
+type ExperimentStore struct {
+	db *sql.DB
+}
+
+func NewExperimentStore(db *sql.DB) *ExperimentStore {
+	return &ExperimentStore{db: db}
+}
+
+const experimentDefinitionColumns = `id, name, description, variants, control, goal_id, status, started_at, ended_at, created_by, created_at, updated_at`
+
+func scanExperiment(row rowScanner) (*models.Experiment, error) {
+	var (
+		exp                models.Experiment
+		variants           []byte
+		goalID, createdBy  sql.NullInt64
+		startedAt, endedAt sql.NullTime
+	)
+	err := row.Scan(&exp.ID, &exp.Name, &exp.Description, &variants, &exp.Control, &goalID, &exp.Status, &startedAt, &endedAt, &createdBy, &exp.CreatedAt, &exp.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(variants, &exp.Variants); err != nil {
+		return nil, fmt.Errorf("failed to decode variants of experiment %s: %w", exp.ID, err)
+	}
+	if goalID.Valid {
+		id := int(goalID.Int64)
+		exp.GoalID = &id
+	}
+	if startedAt.Valid {
+		exp.StartedAt = &startedAt.Time
+	}
+	if endedAt.Valid {
+		exp.EndedAt = &endedAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		exp.CreatedBy = &id
+	}
+	return &exp, nil
+}
+
+func normalizeExperiment(req *models.ExperimentRequest) {
+	if req.Status == "" {
+		req.Status = models.ExperimentDraft
+	}
+}
+
+// experimentWriteError maps constraint violations of an experiment write to
+// the store's sentinel errors.
+func experimentWriteError(req models.ExperimentRequest, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return fmt.Errorf("experiment '%s': %w", req.ID, ErrAlreadyExists)
+		case "23503":
+			if req.GoalID == nil {
+				break
+			}
+			return fmt.Errorf("%w: goal %d does not exist", ErrInvalid, *req.GoalID)
+		}
+	}
+	return nil
+}
+
+// checkGoal reports a goal of req missing from the project as ErrInvalid; the
+// foreign key only ensures it exists in some project.
+func (s *ExperimentStore) checkGoal(ctx context.Context, projectID string, req models.ExperimentRequest) error {
+	if req.GoalID == nil {
+		return nil
+	}
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM goals WHERE id = $1 AND project_id = $2);`, *req.GoalID, projectID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check goal: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: goal %d does not exist", ErrInvalid, *req.GoalID)
+	}
+	return nil
+}
+
+func (s *ExperimentStore) CreateExperiment(ctx context.Context, projectID string, createdBy int, req models.ExperimentRequest) (*models.Experiment, error) {
+	normalizeExperiment(&req)
+	if err := s.checkGoal(ctx, projectID, req); err != nil {
+		return nil, err
+	}
+	variants, err := json.Marshal(req.Variants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode variants: %w", err)
+	}
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO experiments (project_id, id, name, description, variants, control, goal_id, status, started_at, ended_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+		        CASE WHEN $8::VARCHAR <> 'draft' THEN CURRENT_TIMESTAMP END,
+		        CASE WHEN $8::VARCHAR = 'completed' THEN CURRENT_TIMESTAMP END,
+		        $9)
+		RETURNING `+experimentDefinitionColumns+`;
+	`, projectID, req.ID, req.Name, req.Description, variants, req.Control, req.GoalID, req.Status, creator)
+	exp, err := scanExperiment(row)
+	if err != nil {
+		if mapped := experimentWriteError(req, err); mapped != nil {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("failed to create experiment: %w", err)
+	}
+	return exp, nil
+}
+
+func (s *ExperimentStore) ListExperiments(ctx context.Context, projectID string) ([]models.Experiment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+experimentDefinitionColumns+` FROM experiments WHERE project_id = $1 ORDER BY created_at DESC, id;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list experiments: %w", err)
+	}
+	defer rows.Close()
+
+	var experiments []models.Experiment
+	for rows.Next() {
+		exp, err := scanExperiment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan experiment: %w", err)
+		}
+		experiments = append(experiments, *exp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating experiments: %w", err)
+	}
+	return experiments, nil
+}
+
+func (s *ExperimentStore) GetExperiment(ctx context.Context, projectID, id string) (*models.Experiment, error) {
+	exp, err := scanExperiment(s.db.QueryRowContext(ctx, `SELECT `+experimentDefinitionColumns+` FROM experiments WHERE id = $1 AND project_id = $2;`, id, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("experiment '%s': %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experiment: %w", err)
+	}
+	return exp, nil
+}
+
+// UpdateExperiment replaces the definition of an experiment; its id in the
+// path wins over req.ID. StartedAt is kept once set, and EndedAt is set while
+// the experiment is completed and cleared if it is reopened.
+func (s *ExperimentStore) UpdateExperiment(ctx context.Context, projectID, id string, req models.ExperimentRequest) (*models.Experiment, error) {
+	normalizeExperiment(&req)
+	req.ID = id
+	if err := s.checkGoal(ctx, projectID, req); err != nil {
+		return nil, err
+	}
+	variants, err := json.Marshal(req.Variants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode variants: %w", err)
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE experiments
+		SET name = $3, description = $4, variants = $5, control = $6, goal_id = $7, status = $8,
+		    started_at = COALESCE(started_at, CASE WHEN $8::VARCHAR <> 'draft' THEN CURRENT_TIMESTAMP END),
+		    ended_at = CASE WHEN $8::VARCHAR = 'completed' THEN COALESCE(ended_at, CURRENT_TIMESTAMP) END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+experimentDefinitionColumns+`;
+	`, id, projectID, req.Name, req.Description, variants, req.Control, req.GoalID, req.Status)
+	exp, err := scanExperiment(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("experiment '%s': %w", id, ErrNotFound)
+	}
+	if err != nil {
+		if mapped := experimentWriteError(req, err); mapped != nil {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("failed to update experiment: %w", err)
+	}
+	return exp, nil
+}
+
+func (s *ExperimentStore) DeleteExperiment(ctx context.Context, projectID, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM experiments WHERE id = $1 AND project_id = $2;`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete experiment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("experiment '%s': %w", id, ErrNotFound)
+	}
+	return nil
+}
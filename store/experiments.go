@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// experimentColumns holds experiment assignments as the parallel arrays of the
+// analytics_events experiments Nested column.
+type experimentColumns struct {
+	ids      []string
+	variants []string
+}
+
+func newExperimentColumns(assignments []models.ExperimentAssignment) experimentColumns {
+	cols := experimentColumns{
+		ids:      make([]string, len(assignments)),
+		variants: make([]string, len(assignments)),
+	}
+	for i, a := range assignments {
+		cols.ids[i] = a.ID
+		cols.variants[i] = a.Variant
+	}
+	return cols
+}
+
+// exposedExperiments returns the event's experiment assignments including,
+// for an experiment_exposure event, the exposed variant, so that exposures
+// count like any other assignment.
+func exposedExperiments(event *models.AnalyticsEvent) []models.ExperimentAssignment {
+	if event.EventType != models.EventTypeExperimentExposure {
+		return event.Experiments
+	}
+	p, err := event.ExperimentExposurePayload()
+	if err != nil {
+		return event.Experiments
+	}
+	for _, a := range event.Experiments {
+		if a.ID == p.ExperimentID {
+			return event.Experiments
+		}
+	}
+	assignments := append(make([]models.ExperimentAssignment, 0, len(event.Experiments)+1), event.Experiments...)
+	return append(assignments, models.ExperimentAssignment{ID: p.ExperimentID, Variant: p.Variant})
+}
+
+func (cols experimentColumns) assignments() []models.ExperimentAssignment {
+	if len(cols.ids) == 0 {
+		return nil
+	}
+	assignments := make([]models.ExperimentAssignment, len(cols.ids))
+	for i := range assignments {
+		assignments[i] = models.ExperimentAssignment{ID: cols.ids[i], Variant: cols.variants[i]}
+	}
+	return assignments
+}
+
+// GetExperimentVariants counts, per variant of the experiment, the visitors
+// exposed to it in the range and how many of them converted on the goal at or
+// after their first exposure. filters select the exposures; any goal event of
+// an exposed visitor in the same project counts. Variants are ordered by name.
+// Users and Conversions are scaled back up by the sample rates of the
+// exposure and goal events; the significance test uses the sampled counts.
+func (s *AnalyticsStore) GetExperimentVariants(ctx context.Context, experimentID string, goal *models.Goal, start, end time.Time, filters EventFilters) ([]models.VariantResult, error) {
+	cond, condArgs := goalCondition(goal)
+	filterClause, filterArgs := filters.clause()
+	projectClause, projectArgs := filters.projectClause()
+
+	args := []interface{}{experimentID, start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, condArgs...)
+	args = append(args, start.UnixMilli(), end.UnixMilli())
+	args = append(args, projectArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT variant,
+		       `+weightedCount+` AS users,
+		       toUInt64(round(sumIf(greatest(weight, goal_weight), converted_at >= exposed_at))) AS conversions,
+		       count() AS sampled_users,
+		       countIf(converted_at >= exposed_at) AS sampled_conversions
+		FROM (
+			SELECT %[1]s AS visitor, exp_variant AS variant, min(timestamp) AS exposed_at,
+			       %[6]s AS weight
+			FROM analytics_events
+			ARRAY JOIN experiments.id AS exp_id, experiments.variant AS exp_variant
+			WHERE exp_id = ? AND %[2]s%[3]s
+			GROUP BY visitor, variant
+		) AS e
+		LEFT JOIN (
+			SELECT %[1]s AS visitor, max(timestamp) AS converted_at,
+			       %[6]s AS goal_weight
+			FROM analytics_events
+			WHERE %[4]s AND %[2]s%[5]s
+			GROUP BY visitor
+		) AS g USING (visitor)
+		GROUP BY variant
+		ORDER BY variant
+	`, visitorExpr, timeRangeClause, filterClause, cond, projectClause, sampledWeight)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query experiment %s: %w", experimentID, err)
+	}
+	defer rows.Close()
+
+	variants := []models.VariantResult{}
+	for rows.Next() {
+		var v models.VariantResult
+		if err := rows.Scan(&v.Variant, &v.Users, &v.Conversions, &v.SampledUsers, &v.SampledConversions); err != nil {
+			return nil, fmt.Errorf("failed to scan experiment variant: %w", err)
+		}
+		if v.Users > 0 {
+			v.ConversionRate = float64(v.Conversions) / float64(v.Users)
+		}
+		variants = append(variants, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating experiment variants: %w", err)
+	}
+	return variants, nil
+}
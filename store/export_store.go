@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"mabletask/api/models"
+)
+
+type ExportStore struct {
+	db *sql.DB
+}
+
+func NewExportStore(db *sql.DB) *ExportStore {
+	return &ExportStore{db: db}
+}
+
+const exportJobColumns = `id, kind, params, status, error, file_path, requested_by, created_at, started_at, completed_at`
+
+func scanExportJob(row rowScanner) (*models.ExportJob, error) {
+	var (
+		job         models.ExportJob
+		params      []byte
+		requestedBy sql.NullInt64
+		startedAt   sql.NullTime
+		completedAt sql.NullTime
+	)
+	err := row.Scan(&job.ID, &job.Kind, &params, &job.Status, &job.Error, &job.FilePath, &requestedBy, &job.CreatedAt, &startedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Params = params
+	if requestedBy.Valid {
+		id := int(requestedBy.Int64)
+		job.RequestedBy = &id
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return &job, nil
+}
+
+func (s *ExportStore) CreateJob(ctx context.Context, kind string, params interface{}, requestedBy int) (*models.ExportJob, error) {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode export params: %w", err)
+	}
+	var requester interface{}
+	if requestedBy != 0 {
+		requester = requestedBy
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO export_jobs (id, kind, params, requested_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+exportJobColumns+`;
+	`, uuid.New().String(), kind, rawParams, requester)
+	job, err := scanExportJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export job: %w", err)
+	}
+	if _, err := enqueueJob(ctx, tx, models.JobKindExport, models.ExportJobPayload{ExportID: job.ID}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit export job: %w", err)
+	}
+	return job, nil
+}
+
+func (s *ExportStore) GetJob(ctx context.Context, id string) (*models.ExportJob, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("export job %s: %w", id, ErrNotFound)
+	}
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+exportJobColumns+` FROM export_jobs WHERE id = $1;`, id)
+	job, err := scanExportJob(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("export job %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export job: %w", err)
+	}
+	return job, nil
+}
+
+// StartJob marks an export picked up by the job queue as running. Exports that
+// already completed or failed are reported as ErrNotFound.
+func (s *ExportStore) StartJob(ctx context.Context, id string) (*models.ExportJob, error) {
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE export_jobs
+		SET status = 'running', started_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ('pending', 'running')
+		RETURNING `+exportJobColumns+`;
+	`, id)
+	job, err := scanExportJob(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("export job %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start export job: %w", err)
+	}
+	return job, nil
+}
+
+// RetryJob returns an export whose attempt failed to pending, keeping the error
+// visible until the next attempt succeeds.
+func (s *ExportStore) RetryJob(ctx context.Context, id, message string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = 'pending', error = $2
+		WHERE id = $1;
+	`, id, message)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule export job: %w", err)
+	}
+	return nil
+}
+
+func (s *ExportStore) CompleteJob(ctx context.Context, id, filePath string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = 'completed', file_path = $2, error = '', completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`, id, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to complete export job: %w", err)
+	}
+	return nil
+}
+
+func (s *ExportStore) FailJob(ctx context.Context, id, message string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = 'failed', error = $2, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`, id, message)
+	if err != nil {
+		return fmt.Errorf("failed to mark export job as failed: %w", err)
+	}
+	return nil
+}
+
+// ExpireJobs marks completed jobs finished before cutoff as expired and returns
+// the paths of their files, which the caller is expected to remove. The rows
+// are kept so schedulers relying on HasJob do not queue the jobs again.
+func (s *ExportStore) ExpireJobs(ctx context.Context, cutoff time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE export_jobs
+		SET status = 'expired', file_path = ''
+		FROM (
+			SELECT id, file_path FROM export_jobs
+			WHERE status = 'completed' AND completed_at < $1
+			FOR UPDATE
+		) AS old
+		WHERE export_jobs.id = old.id
+		RETURNING old.file_path;
+	`, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire export jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan expired export job: %w", err)
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating expired export jobs: %w", err)
+	}
+	return paths, nil
+}
+
+// ListJobs returns a page of jobs of the given kinds, newest first.
+func (s *ExportStore) ListJobs(ctx context.Context, kinds []string, page PageRequest) (models.Page[models.ExportJob], error) {
+	afterTime, afterID := page.keysetArgs()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+exportJobColumns+`
+		FROM export_jobs
+		WHERE kind = ANY($1) AND ($2::timestamptz IS NULL OR (created_at, id) < ($2, $3::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4;
+	`, pq.Array(kinds), afterTime, afterID, page.fetchLimit())
+	if err != nil {
+		return models.Page[models.ExportJob]{}, fmt.Errorf("failed to list export jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.ExportJob{}
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			return models.Page[models.ExportJob]{}, fmt.Errorf("failed to scan export job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Page[models.ExportJob]{}, fmt.Errorf("error iterating export jobs: %w", err)
+	}
+	return newPage(jobs, page, func(j models.ExportJob) Cursor {
+		return Cursor{Time: j.CreatedAt, ID: j.ID}
+	}), nil
+}
+
+// HasJob reports whether a job of this kind with identical params exists that
+// has not failed.
+func (s *ExportStore) HasJob(ctx context.Context, kind string, params interface{}) (bool, error) {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode export params: %w", err)
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM export_jobs
+			WHERE kind = $1 AND params = $2::jsonb AND status <> 'failed'
+		);
+	`, kind, rawParams).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up export job: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"mabletask/api/models"
+)
+
+// suppressionClause excludes subjects on the opt-out list of the event's
+// project. It is applied to every stats query regardless of the requested
+// filters.
+const suppressionClause = ` AND (project_id, user_id) NOT IN (SELECT project_id, subject_id FROM suppressed_ids FINAL WHERE subject_type = 'user' AND active = 1)` +
+	` AND (project_id, anonymous_id) NOT IN (SELECT project_id, subject_id FROM suppressed_ids FINAL WHERE subject_type = 'anonymous' AND active = 1)`
+
+// EventFilters narrows stats queries to a segment of events. The zero value
+// applies no filtering beyond excluding suppressed subjects.
+type EventFilters struct {
+	// ProjectID keeps only events of the project (site). Stats handlers always
+	// set it to the caller's project.
+	ProjectID string
+
+	// Traits keeps only events from users whose identified traits equal the
+	// given values, e.g. {"plan": "pro"}.
+	Traits map[string]string
+
+	// EventTypes keeps only events of one of the given types.
+	EventTypes []string
+
+	Country    string // ISO country code
+	Region     string // ISO 3166-2 subdivision code, without the country
+	City       string
+	DeviceType string // desktop, mobile, tablet or bot
+	Browser    string
+	OS         string // Operating system family, e.g. Windows
+	// PagePathPrefix keeps events whose page path starts with the prefix.
+	PagePathPrefix string
+	// ReferrerDomain keeps events referred by the domain or its subdomains.
+	ReferrerDomain string
+	Channel        string // direct, search, social or referral
+	// UTMSource, UTMMedium and UTMCampaign match the event's UTM parameters.
+	UTMSource   string
+	UTMMedium   string
+	UTMCampaign string
+
+	// IncludeBots keeps events of bots and crawlers, which are otherwise left
+	// out. Filtering on the bot device type includes them as well.
+	IncludeBots bool
+}
+
+// traitColumns are merged user traits columns addressable by name; any other
+// trait is looked up in the custom traits JSON document.
+var traitColumns = map[string]bool{
+	"email":   true,
+	"plan":    true,
+	"company": true,
+}
+
+func traitExpr(name string) (string, []interface{}) {
+	if traitColumns[name] {
+		return name, nil
+	}
+	return "JSONExtractString(traits, ?)", []interface{}{name}
+}
+
+// projectClause renders only the project condition of the filters, for
+// subqueries that must stay within the project without narrowing to the
+// segment, e.g. the conversions of segmented visitors.
+func (f EventFilters) projectClause() (string, []interface{}) {
+	if f.ProjectID == "" {
+		return "", nil
+	}
+	return " AND project_id = ?", []interface{}{f.ProjectID}
+}
+
+// clause renders the filters as additional WHERE conditions, each prefixed
+// with " AND ", along with their positional arguments.
+func (f EventFilters) clause() (string, []interface{}) {
+	var sb strings.Builder
+	var args []interface{}
+
+	sb.WriteString(suppressionClause)
+
+	if f.ProjectID != "" {
+		sb.WriteString(" AND project_id = ?")
+		args = append(args, f.ProjectID)
+	}
+
+	if len(f.Traits) > 0 {
+		names := make([]string, 0, len(f.Traits))
+		for name := range f.Traits {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		conds := make([]string, 0, len(names))
+		for _, name := range names {
+			expr, exprArgs := traitExpr(name)
+			conds = append(conds, expr+" = ?")
+			args = append(args, exprArgs...)
+			args = append(args, f.Traits[name])
+		}
+		// Traits are matched within the event's project, whose user IDs are
+		// its own.
+		sb.WriteString(" AND (project_id, user_id) IN (SELECT project_id, user_id FROM " + currentUserTraits + " WHERE " + strings.Join(conds, " AND ") + ")")
+	}
+
+	if len(f.EventTypes) > 0 {
+		sb.WriteString(" AND event_type IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(f.EventTypes)), ", ") + ")")
+		for _, t := range f.EventTypes {
+			args = append(args, t)
+		}
+	}
+
+	if !f.IncludeBots && f.DeviceType != models.DeviceTypeBot {
+		sb.WriteString(" AND NOT is_bot")
+	}
+
+	if f.Country != "" {
+		sb.WriteString(" AND country = ?")
+		args = append(args, f.Country)
+	}
+	if f.Region != "" {
+		sb.WriteString(" AND region = ?")
+		args = append(args, f.Region)
+	}
+	if f.City != "" {
+		sb.WriteString(" AND city = ?")
+		args = append(args, f.City)
+	}
+	if f.DeviceType != "" {
+		sb.WriteString(" AND device_type = ?")
+		args = append(args, f.DeviceType)
+	}
+	if f.Browser != "" {
+		sb.WriteString(" AND browser = ?")
+		args = append(args, f.Browser)
+	}
+	if f.OS != "" {
+		sb.WriteString(" AND os = ?")
+		args = append(args, f.OS)
+	}
+	if f.PagePathPrefix != "" {
+		sb.WriteString(" AND startsWith(page_path, ?)")
+		args = append(args, f.PagePathPrefix)
+	}
+	if f.ReferrerDomain != "" {
+		sb.WriteString(" AND (domainWithoutWWW(referrer) = ? OR endsWith(domainWithoutWWW(referrer), ?))")
+		args = append(args, f.ReferrerDomain, "."+f.ReferrerDomain)
+	}
+	if f.Channel != "" {
+		sb.WriteString(" AND channel = ?")
+		args = append(args, f.Channel)
+	}
+	if f.UTMSource != "" {
+		sb.WriteString(" AND utm_source = ?")
+		args = append(args, f.UTMSource)
+	}
+	if f.UTMMedium != "" {
+		sb.WriteString(" AND utm_medium = ?")
+		args = append(args, f.UTMMedium)
+	}
+	if f.UTMCampaign != "" {
+		sb.WriteString(" AND utm_campaign = ?")
+		args = append(args, f.UTMCampaign)
+	}
+
+	return sb.String(), args
+}
+
+// breakdownColumns maps event breakdown dimensions to their expression.
+var breakdownColumns = map[string]string{
+	"event_type":   "event_type",
+	"country":      "country",
+	"region":       "region",
+	"city":         "city",
+	"device":       "device_type",
+	"browser":      "browser",
+	"os":           "os",
+	"channel":      "channel",
+	"utm_source":   "utm_source",
+	"utm_medium":   "utm_medium",
+	"utm_campaign": "utm_campaign",
+	"page_path":    "cutQueryString(page_path)",
+	"referrer":     "referrer",
+	// referrer_domain groups subdomains apart, unlike the referrerDomain filter.
+	"referrer_domain": "domainWithoutWWW(referrer)",
+}
+
+// breakdownDimension resolves a breakdown dimension to the expression yielding
+// its value, plus, for "trait.<name>" and "first_touch.<field>", the JOIN
+// clause that expression needs.
+func breakdownDimension(breakdown string) (expr, join string, joinArgs []interface{}, err error) {
+	if expr, ok := breakdownColumns[breakdown]; ok {
+		return expr, "", nil, nil
+	}
+	if field, ok := strings.CutPrefix(breakdown, "first_touch."); ok {
+		join, err := firstTouchJoin(field)
+		return "first_touch_value", join, nil, err
+	}
+	name, ok := strings.CutPrefix(breakdown, "trait.")
+	if !ok || name == "" {
+		return "", "", nil, fmt.Errorf("%w: breakdown %q", ErrInvalid, breakdown)
+	}
+	traitCol, args := traitExpr(name)
+	join = fmt.Sprintf(`LEFT JOIN (SELECT project_id, user_id, %s AS trait_value FROM %s) AS bd
+		ON bd.project_id = analytics_events.project_id AND bd.user_id = analytics_events.user_id`, traitCol, currentUserTraits)
+	return "trait_value", join, args, nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// firstTouchFields maps first-touch dimensions to the expression reading them
+// from the first_touch aggregate states.
+var firstTouchFields = map[string]string{
+	"referrer":        "argMinMerge(referrer)",
+	"referrer_domain": "domainWithoutWWW(argMinMerge(referrer))",
+	"landing_page":    "argMinMerge(landing_page)",
+	"utm_source":      "argMinMerge(utm_source)",
+	"utm_medium":      "argMinMerge(utm_medium)",
+	"utm_campaign":    "argMinMerge(utm_campaign)",
+	"utm_term":        "argMinMerge(utm_term)",
+	"utm_content":     "argMinMerge(utm_content)",
+}
+
+// FirstTouchFields lists the first-touch dimensions, sorted.
+func FirstTouchFields() []string {
+	fields := make([]string, 0, len(firstTouchFields))
+	for field := range firstTouchFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// firstTouchJoin returns the JOIN attaching the visitor's first-touch value of
+// field to each event as first_touch_value ("" for visitors without one).
+func firstTouchJoin(field string) (string, error) {
+	expr, ok := firstTouchFields[field]
+	if !ok {
+		return "", fmt.Errorf("%w: first-touch dimension %q (one of %s)", ErrInvalid, field, strings.Join(FirstTouchFields(), ", "))
+	}
+	return fmt.Sprintf(`LEFT JOIN (
+			SELECT project_id, visitor_id, %s AS first_touch_value
+			FROM first_touch
+			GROUP BY project_id, visitor_id
+		) AS ft ON ft.project_id = analytics_events.project_id AND ft.visitor_id = %s`, expr, visitorExpr), nil
+}
+
+// GetFirstTouchReport groups the visitors with matching events in the range
+// by where they originally came from, e.g. the first utm_source of buyers
+// when filtered to purchase events.
+func (s *AnalyticsStore) GetFirstTouchReport(ctx context.Context, field string, start, end time.Time, limit uint64, filters EventFilters) ([]models.FirstTouchCount, error) {
+	join, err := firstTouchJoin(field)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT first_touch_value, uniqExact(%s) AS visitors, `+sampledCount+` AS events
+		FROM analytics_events
+		%s
+		WHERE %s%s
+		GROUP BY first_touch_value
+		ORDER BY visitors DESC, first_touch_value
+		LIMIT ?
+	`, visitorExpr, join, timeRangeClause, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query first-touch report: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.FirstTouchCount{}
+	for rows.Next() {
+		var r models.FirstTouchCount
+		if err := rows.Scan(&r.Value, &r.Visitors, &r.Events); err != nil {
+			return nil, fmt.Errorf("failed to scan first-touch report: %w", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating first-touch report: %w", err)
+	}
+	return results, nil
+}
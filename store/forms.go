@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// formID returns the form of a form interaction event, or "" for events of
+// other types.
+func formID(event *models.AnalyticsEvent) string {
+	if !models.IsFormEvent(event.EventType) {
+		return ""
+	}
+	p, err := event.FormPayload()
+	if err != nil {
+		return ""
+	}
+	return p.FormID
+}
+
+// GetFormStats returns, per form (and per page with byPage), the sessions that
+// started, submitted and abandoned it in the range, for the limit forms
+// started most. Of the sessions that started the form, the submission rate is
+// the share that submitted it and the abandonment rate the share that did
+// not, whether or not they sent form_abandon. Abandons counts form_abandon
+// sessions that never submitted.
+func (s *AnalyticsStore) GetFormStats(ctx context.Context, byPage bool, start, end time.Time, limit uint64, filters EventFilters) ([]models.FormStats, error) {
+	if limit == 0 {
+		limit = 10
+	}
+	keys, pathSelect := "form_id", "'' AS path"
+	if byPage {
+		keys, pathSelect = "form_id, path", "cutQueryString(page_path) AS path"
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT form_id, path,
+		       countIf(started) AS starts,
+		       countIf(submitted) AS submissions,
+		       countIf(abandoned AND NOT submitted) AS abandons,
+		       countIf(started AND submitted) AS completed
+		FROM (
+			SELECT session_id, form_id, %[1]s,
+			       max(event_type = '%[2]s') AS started,
+			       max(event_type = '%[3]s') AS submitted,
+			       max(event_type = '%[4]s') AS abandoned
+			FROM analytics_events
+			WHERE event_type IN ('%[2]s', '%[3]s', '%[4]s') AND form_id != '' AND %[5]s%[6]s
+			GROUP BY session_id, %[7]s
+		)
+		GROUP BY form_id, path
+		ORDER BY starts DESC, form_id, path
+		LIMIT ?
+	`, pathSelect, models.EventTypeFormStart, models.EventTypeFormSubmit, models.EventTypeFormAbandon,
+		timeRangeClause, filterClause, keys)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query form stats: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.FormStats{}
+	for rows.Next() {
+		var r models.FormStats
+		var completed uint64
+		if err := rows.Scan(&r.FormID, &r.PagePath, &r.Starts, &r.Submissions, &r.Abandons, &completed); err != nil {
+			return nil, fmt.Errorf("failed to scan form stats: %w", err)
+		}
+		if r.Starts > 0 {
+			r.SubmissionRate = float64(completed) / float64(r.Starts)
+			r.AbandonmentRate = 1 - r.SubmissionRate
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating form stats: %w", err)
+	}
+	return results, nil
+}
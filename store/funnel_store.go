@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"mabletask/api/models"
+)
+
+type FunnelStore struct {
+	db *sql.DB
+}
+
+func NewFunnelStore(db *sql.DB) *FunnelStore {
+	return &FunnelStore{db: db}
+}
+
+const funnelColumns = `id, name, definition, created_by, created_at, updated_at`
+
+func scanFunnel(row rowScanner) (*models.Funnel, error) {
+	var (
+		funnel     models.Funnel
+		definition []byte
+		createdBy  sql.NullInt64
+	)
+	if err := row.Scan(&funnel.ID, &funnel.Name, &definition, &createdBy, &funnel.CreatedAt, &funnel.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(definition, &funnel.Definition); err != nil {
+		return nil, fmt.Errorf("failed to decode definition for funnel %d: %w", funnel.ID, err)
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		funnel.CreatedBy = &id
+	}
+	return &funnel, nil
+}
+
+func (s *FunnelStore) CreateFunnel(ctx context.Context, projectID string, createdBy int, req models.FunnelRequest) (*models.Funnel, error) {
+	definition, err := json.Marshal(req.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode funnel definition: %w", err)
+	}
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO funnels (project_id, name, definition, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+funnelColumns+`;
+	`, projectID, req.Name, definition, creator)
+	funnel, err := scanFunnel(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create funnel: %w", err)
+	}
+	return funnel, nil
+}
+
+func (s *FunnelStore) ListFunnels(ctx context.Context, projectID string) ([]models.Funnel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+funnelColumns+` FROM funnels WHERE project_id = $1 ORDER BY id;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list funnels: %w", err)
+	}
+	defer rows.Close()
+
+	var funnels []models.Funnel
+	for rows.Next() {
+		funnel, err := scanFunnel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan funnel: %w", err)
+		}
+		funnels = append(funnels, *funnel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating funnels: %w", err)
+	}
+	return funnels, nil
+}
+
+func (s *FunnelStore) GetFunnel(ctx context.Context, projectID string, id int) (*models.Funnel, error) {
+	funnel, err := scanFunnel(s.db.QueryRowContext(ctx, `SELECT `+funnelColumns+` FROM funnels WHERE id = $1 AND project_id = $2;`, id, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("funnel %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funnel: %w", err)
+	}
+	return funnel, nil
+}
+
+func (s *FunnelStore) UpdateFunnel(ctx context.Context, projectID string, id int, req models.FunnelRequest) (*models.Funnel, error) {
+	definition, err := json.Marshal(req.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode funnel definition: %w", err)
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE funnels
+		SET name = $3, definition = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+funnelColumns+`;
+	`, id, projectID, req.Name, definition)
+	funnel, err := scanFunnel(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("funnel %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update funnel: %w", err)
+	}
+	return funnel, nil
+}
+
+func (s *FunnelStore) DeleteFunnel(ctx context.Context, projectID string, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM funnels WHERE id = $1 AND project_id = $2;`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete funnel: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("funnel %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
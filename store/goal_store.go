@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// GoalStore keeps goal definitions in PostgreSQL and evaluates them against
+// analytics events in ClickHouse.
+type GoalStore struct {
+	db *sql.DB
+	ch *database.ClickHouseClient
+}
+
+func NewGoalStore(db *sql.DB, chClient *database.ClickHouseClient) *GoalStore {
+	return &GoalStore{db: db, ch: chClient}
+}
+
+const goalColumns = `id, name, event_type, page_path, path_match, property, property_value, created_by, created_at, updated_at`
+
+func scanGoal(row rowScanner) (*models.Goal, error) {
+	var goal models.Goal
+	var createdBy sql.NullInt64
+	if err := row.Scan(&goal.ID, &goal.Name, &goal.EventType, &goal.PagePath, &goal.PathMatch, &goal.Property, &goal.PropertyValue, &createdBy, &goal.CreatedAt, &goal.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		goal.CreatedBy = &id
+	}
+	return &goal, nil
+}
+
+func normalizeGoal(req *models.GoalRequest) {
+	if req.PathMatch == "" {
+		req.PathMatch = "exact"
+	}
+}
+
+func (s *GoalStore) CreateGoal(ctx context.Context, projectID string, createdBy int, req models.GoalRequest) (*models.Goal, error) {
+	normalizeGoal(&req)
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO goals (project_id, name, event_type, page_path, path_match, property, property_value, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+goalColumns+`;
+	`, projectID, req.Name, req.EventType, req.PagePath, req.PathMatch, req.Property, req.PropertyValue, creator)
+	goal, err := scanGoal(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create goal: %w", err)
+	}
+	return goal, nil
+}
+
+func (s *GoalStore) ListGoals(ctx context.Context, projectID string) ([]models.Goal, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+goalColumns+` FROM goals WHERE project_id = $1 ORDER BY id;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	defer rows.Close()
+
+	var goals []models.Goal
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan goal: %w", err)
+		}
+		goals = append(goals, *goal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating goals: %w", err)
+	}
+	return goals, nil
+}
+
+func (s *GoalStore) GetGoal(ctx context.Context, projectID string, id int) (*models.Goal, error) {
+	goal, err := scanGoal(s.db.QueryRowContext(ctx, `SELECT `+goalColumns+` FROM goals WHERE id = $1 AND project_id = $2;`, id, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("goal %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goal: %w", err)
+	}
+	return goal, nil
+}
+
+func (s *GoalStore) UpdateGoal(ctx context.Context, projectID string, id int, req models.GoalRequest) (*models.Goal, error) {
+	normalizeGoal(&req)
+
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE goals
+		SET name = $3, event_type = $4, page_path = $5, path_match = $6, property = $7, property_value = $8,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+goalColumns+`;
+	`, id, projectID, req.Name, req.EventType, req.PagePath, req.PathMatch, req.Property, req.PropertyValue)
+	goal, err := scanGoal(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("goal %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update goal: %w", err)
+	}
+	return goal, nil
+}
+
+func (s *GoalStore) DeleteGoal(ctx context.Context, projectID string, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM goals WHERE id = $1 AND project_id = $2;`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete goal: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("goal %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// goalCondition renders the goal's match conditions as a ClickHouse boolean expression.
+func goalCondition(goal *models.Goal) (string, []interface{}) {
+	cond := "1"
+	var args []interface{}
+	if goal.EventType != "" {
+		cond += " AND event_type = ?"
+		args = append(args, goal.EventType)
+	}
+	if goal.PagePath != "" {
+		switch goal.PathMatch {
+		case "prefix":
+			cond += " AND startsWith(page_path, ?)"
+		case "regex":
+			cond += " AND match(page_path, ?)"
+		default:
+			cond += " AND page_path = ?"
+		}
+		args = append(args, goal.PagePath)
+	}
+	if goal.Property != "" {
+		if goal.PropertyValue != "" {
+			// Strings compare by content, other JSON values by their literal, e.g. 42 or true.
+			cond += " AND (JSONExtractString(toString(event_data), ?) = ? OR JSONExtractRaw(toString(event_data), ?) = ?)"
+			args = append(args, goal.Property, goal.PropertyValue, goal.Property, goal.PropertyValue)
+		} else {
+			cond += " AND JSONHas(toString(event_data), ?)"
+			args = append(args, goal.Property)
+		}
+	}
+	return cond, args
+}
+
+// GetGoalConversionsOverTime returns, per time bucket, the number of sessions
+// that converted on the goal and the share of all sessions that did.
+func (s *GoalStore) GetGoalConversionsOverTime(ctx context.Context, goal *models.Goal, interval string, start, end time.Time, filters EventFilters) ([]models.GoalConversionPoint, error) {
+	if !utils.IsValidInterval(interval) {
+		return nil, fmt.Errorf("invalid interval: %s", interval)
+	}
+
+	cond, condArgs := goalCondition(goal)
+	filterClause, filterArgs := filters.clause()
+	args := append([]interface{}{}, condArgs...)
+	args = append(args, start.UnixMilli(), end.UnixMilli())
+	args = append(args, filterArgs...)
+
+	// A session's goal events weigh by their own sample rate, which may differ
+	// from that of the session's other events; minIf is 0 without any.
+	query := fmt.Sprintf(`
+		SELECT time_bucket,
+		       toUInt64(round(sum(conversion_weight))) AS conversions,
+		       %s AS sessions
+		FROM (
+			SELECT %s AS time_bucket, session_id,
+			       %s AS conversion_weight,
+			       %s AS weight
+			FROM analytics_events
+			WHERE %s%s
+			GROUP BY time_bucket, session_id
+		)
+		GROUP BY time_bucket
+		ORDER BY time_bucket ASC
+	`, weightedCount, timeBucket(interval), sampledWeightIf(cond), sampledWeight, timeRangeClause, filterClause)
+
+	rows, err := s.ch.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversions for goal %d: %w", goal.ID, err)
+	}
+	defer rows.Close()
+
+	series := []models.GoalConversionPoint{}
+	for rows.Next() {
+		var point models.GoalConversionPoint
+		if err := rows.Scan(&point.Time, &point.Conversions, &point.Sessions); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for goal conversions")
+			continue
+		}
+		if point.Sessions > 0 {
+			point.ConversionRate = float64(point.Conversions) / float64(point.Sessions)
+		}
+		series = append(series, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for goal conversions: %w", err)
+	}
+	return series, nil
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// accountJoin resolves each event to an account: the event's own group_id when
+// set, otherwise the group the user was most recently associated with in the
+// event's project.
+const accountJoin = `
+	LEFT JOIN (
+		SELECT project_id, user_id, argMax(group_id, updated_at) AS assoc_group_id
+		FROM user_groups
+		GROUP BY project_id, user_id
+	) AS ug ON ug.project_id = analytics_events.project_id AND ug.user_id = analytics_events.user_id
+`
+
+const accountExpr = "if(group_id != '', group_id, assoc_group_id)"
+
+type GroupStore struct {
+	DB *database.ClickHouseClient
+}
+
+func NewGroupStore(chClient *database.ClickHouseClient) *GroupStore {
+	return &GroupStore{DB: chClient}
+}
+
+func (s *GroupStore) AssociateUser(ctx context.Context, projectID string, req models.GroupRequest) error {
+	traits := req.Traits
+	if traits == nil {
+		traits = map[string]interface{}{}
+	}
+	rawTraits, err := json.Marshal(traits)
+	if err != nil {
+		return fmt.Errorf("failed to encode group traits: %w", err)
+	}
+
+	err = s.DB.Conn.Exec(ctx, `
+		INSERT INTO user_groups (project_id, user_id, group_id, group_name, traits, updated_at)
+		VALUES (?, ?, ?, ?, ?, fromUnixTimestamp64Milli(toInt64(?), 'UTC'))
+	`, projectID, req.UserID, req.GroupID, req.Name, string(rawTraits), time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to associate user with group: %w", err)
+	}
+	return nil
+}
+
+// GetActiveAccountsOverTime counts distinct accounts with at least one event per bucket.
+func (s *GroupStore) GetActiveAccountsOverTime(ctx context.Context, interval string, start, end time.Time, filters EventFilters) ([]EventTypeCountByTime, error) {
+	if !utils.IsValidInterval(interval) {
+		return nil, fmt.Errorf("invalid interval: %s", interval)
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT %s AS time_bucket, uniqIf(%s, %s != '') AS active_accounts
+		FROM analytics_events
+		%s
+		WHERE %s%s
+		GROUP BY time_bucket
+		ORDER BY time_bucket ASC
+	`, timeBucket(interval), accountExpr, accountExpr, accountJoin, timeRangeClause, filterClause)
+
+	rows, err := s.DB.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active accounts over time: %w", err)
+	}
+	defer rows.Close()
+
+	var results []EventTypeCountByTime
+	for rows.Next() {
+		var timeBucket time.Time
+		var count uint64
+		if err := rows.Scan(&timeBucket, &count); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for active accounts")
+			continue
+		}
+		results = append(results, EventTypeCountByTime{Time: timeBucket, Count: count})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for active accounts: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetEventsPerAccount ranks accounts by event volume within the time range.
+func (s *GroupStore) GetEventsPerAccount(ctx context.Context, start, end time.Time, limit uint64, filters EventFilters) ([]models.AccountActivity, error) {
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s AS account, `+sampledCount+` AS event_count, uniq(user_id) AS active_users
+		FROM analytics_events
+		%s
+		WHERE %s%s
+		GROUP BY account
+		HAVING account != ''
+		ORDER BY event_count DESC
+		LIMIT ?
+	`, accountExpr, accountJoin, timeRangeClause, filterClause)
+
+	rows, err := s.DB.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events per account: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.AccountActivity
+	for rows.Next() {
+		var r models.AccountActivity
+		if err := rows.Scan(&r.GroupID, &r.EventCount, &r.ActiveUsers); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for events per account")
+			continue
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for events per account: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"mabletask/api/models"
+)
+
+const (
+	ingestWindow          = 60 // seconds
+	maxRecentInsertErrors = 20
+)
+
+type ingestSecond struct {
+	unix      int64
+	events    uint64
+	flushes   uint64
+	latencyMs float64
+	maxMs     float64
+}
+
+// IngestStats keeps in-process ingestion counters for the admin ingestion
+// endpoint. Per-second buckets cover the last minute.
+type IngestStats struct {
+	mu            sync.Mutex
+	since         time.Time
+	buckets       [ingestWindow]ingestSecond
+	inserted      uint64
+	batches       uint64
+	deadLetters   uint64
+	lastLatencyMs float64
+	errors        []models.InsertError
+	bufferDepth   func() int
+	privacy       models.PrivacySignalCounts
+}
+
+func NewIngestStats() *IngestStats {
+	return &IngestStats{since: time.Now().UTC()}
+}
+
+func (s *IngestStats) bucket(now time.Time) *ingestSecond {
+	unix := now.Unix()
+	b := &s.buckets[unix%ingestWindow]
+	if b.unix != unix {
+		*b = ingestSecond{unix: unix}
+	}
+	return b
+}
+
+// RecordFlush counts a successful batch insert of n events.
+func (s *IngestStats) RecordFlush(n int, latency time.Duration) {
+	ms := float64(latency.Microseconds()) / 1000
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.bucket(time.Now())
+	b.events += uint64(n)
+	b.flushes++
+	b.latencyMs += ms
+	if ms > b.maxMs {
+		b.maxMs = ms
+	}
+	s.inserted += uint64(n)
+	s.batches++
+	s.lastLatencyMs = ms
+}
+
+// RecordFailure counts n events lost to a failed insert.
+func (s *IngestStats) RecordFailure(n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters += uint64(n)
+	s.errors = append(s.errors, models.InsertError{Time: time.Now().UTC(), Events: n, Error: err.Error()})
+	if len(s.errors) > maxRecentInsertErrors {
+		s.errors = s.errors[len(s.errors)-maxRecentInsertErrors:]
+	}
+}
+
+// RecordPrivacySignal counts n events of a request sending DNT or GPC that
+// were handled according to mode.
+func (s *IngestStats) RecordPrivacySignal(mode string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch mode {
+	case models.PrivacySignalModeDrop:
+		s.privacy.Dropped += uint64(n)
+	case models.PrivacySignalModeAnonymize:
+		s.privacy.Anonymized += uint64(n)
+	}
+}
+
+// SetBufferDepth registers a function reporting how many events are waiting to
+// be written, for ingestion paths that buffer before inserting.
+func (s *IngestStats) SetBufferDepth(fn func() int) {
+	s.mu.Lock()
+	s.bufferDepth = fn
+	s.mu.Unlock()
+}
+
+func (s *IngestStats) Snapshot() models.IngestionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := models.IngestionStats{
+		Since:              s.since,
+		EventsInserted:     s.inserted,
+		BatchesInserted:    s.batches,
+		LastFlushLatencyMs: s.lastLatencyMs,
+		DeadLetterCount:    s.deadLetters,
+		RecentInsertErrors: append([]models.InsertError{}, s.errors...),
+		PrivacySignals:     s.privacy,
+	}
+	if s.bufferDepth != nil {
+		snap.BufferDepth = s.bufferDepth()
+	}
+
+	now := time.Now().Unix()
+	var events, flushes uint64
+	var latencyMs float64
+	for _, b := range s.buckets {
+		if now-b.unix >= ingestWindow {
+			continue
+		}
+		events += b.events
+		flushes += b.flushes
+		latencyMs += b.latencyMs
+		if b.maxMs > snap.MaxFlushLatencyMs {
+			snap.MaxFlushLatencyMs = b.maxMs
+		}
+	}
+	snap.EventsPerSecond = float64(events) / ingestWindow
+	if flushes > 0 {
+		snap.AvgFlushLatencyMs = latencyMs / float64(flushes)
+	}
+	return snap
+}
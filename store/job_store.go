@@ -0,0 +1,252 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"mabletask/api/models"
+)
+
+// DefaultJobMaxAttempts is how often a job is tried before it is marked failed.
+const DefaultJobMaxAttempts = 5
+
+// JobStore is the PostgreSQL-backed background job queue.
+type JobStore struct {
+	db *sql.DB
+}
+
+func NewJobStore(db *sql.DB) *JobStore {
+	return &JobStore{db: db}
+}
+
+// JobFilter narrows ListJobs; zero values are ignored.
+type JobFilter struct {
+	Kind   string
+	Status string
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx, so jobs can be enqueued in
+// the same transaction as the record they act on.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, last_error, run_at, created_at, started_at, completed_at`
+
+func scanJob(row rowScanner) (*models.Job, error) {
+	var (
+		job         models.Job
+		payload     []byte
+		startedAt   sql.NullTime
+		completedAt sql.NullTime
+	)
+	err := row.Scan(&job.ID, &job.Kind, &payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.LastError,
+		&job.RunAt, &job.CreatedAt, &startedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = payload
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return &job, nil
+}
+
+func enqueueJob(ctx context.Context, q queryRower, kind string, payload interface{}) (*models.Job, error) {
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	row := q.QueryRowContext(ctx, `
+		INSERT INTO jobs (id, kind, payload, max_attempts)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+jobColumns+`;
+	`, uuid.New().String(), kind, rawPayload, DefaultJobMaxAttempts)
+	job, err := scanJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+	return job, nil
+}
+
+// Enqueue adds a job that is due immediately.
+func (s *JobStore) Enqueue(ctx context.Context, kind string, payload interface{}) (*models.Job, error) {
+	return enqueueJob(ctx, s.db, kind, payload)
+}
+
+// Claim leases the oldest due job of one of the given kinds for lease and
+// counts the attempt, or returns nil when none is due. Running jobs whose
+// lease expired are claimed again.
+func (s *JobStore) Claim(ctx context.Context, kinds []string, lease time.Duration) (*models.Job, error) {
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, started_at = CURRENT_TIMESTAMP,
+		    locked_until = CURRENT_TIMESTAMP + $2::BIGINT * INTERVAL '1 millisecond'
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ANY($1) AND (
+				(status = 'pending' AND run_at <= CURRENT_TIMESTAMP) OR
+				(status = 'running' AND locked_until < CURRENT_TIMESTAMP)
+			)
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns+`;
+	`, pq.Array(kinds), lease.Milliseconds())
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return job, nil
+}
+
+func (s *JobStore) Complete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'completed', last_error = '', locked_until = NULL, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to complete job: %w", err)
+	}
+	return nil
+}
+
+// Retry puts a failed attempt back in the queue, due at runAt.
+func (s *JobStore) Retry(ctx context.Context, id, message string, runAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'pending', last_error = $2, run_at = $3, locked_until = NULL
+		WHERE id = $1;
+	`, id, message, runAt)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule job: %w", err)
+	}
+	return nil
+}
+
+func (s *JobStore) Fail(ctx context.Context, id, message string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'failed', last_error = $2, locked_until = NULL, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`, id, message)
+	if err != nil {
+		return fmt.Errorf("failed to mark job as failed: %w", err)
+	}
+	return nil
+}
+
+// Requeue gives a failed job a fresh set of attempts.
+func (s *JobStore) Requeue(ctx context.Context, id string) (*models.Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE jobs
+		SET status = 'pending', attempts = 0, run_at = CURRENT_TIMESTAMP, completed_at = NULL
+		WHERE id = $1 AND status = 'failed'
+		RETURNING `+jobColumns+`;
+	`, id)
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		if _, getErr := s.GetJob(ctx, id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, fmt.Errorf("%w: job %s has not failed", ErrInvalid, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue job: %w", err)
+	}
+	return job, nil
+}
+
+func (s *JobStore) GetJob(ctx context.Context, id string) (*models.Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1;`, id)
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns a page of jobs, newest first.
+func (s *JobStore) ListJobs(ctx context.Context, f JobFilter, page PageRequest) (models.Page[models.Job], error) {
+	afterTime, afterID := page.keysetArgs()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE ($1 = '' OR kind = $1) AND ($2 = '' OR status = $2)
+			AND ($3::timestamptz IS NULL OR (created_at, id) < ($3, $4::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $5;
+	`, f.Kind, f.Status, afterTime, afterID, page.fetchLimit())
+	if err != nil {
+		return models.Page[models.Job]{}, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return models.Page[models.Job]{}, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Page[models.Job]{}, fmt.Errorf("error iterating jobs: %w", err)
+	}
+	return newPage(jobs, page, func(j models.Job) Cursor {
+		return Cursor{Time: j.CreatedAt, ID: j.ID}
+	}), nil
+}
+
+// CountJobs returns the number of jobs per kind and status.
+func (s *JobStore) CountJobs(ctx context.Context) ([]models.JobCount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT kind, status, COUNT(*)
+		FROM jobs
+		GROUP BY kind, status
+		ORDER BY kind, status;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []models.JobCount{}
+	for rows.Next() {
+		var c models.JobCount
+		if err := rows.Scan(&c.Kind, &c.Status, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan job count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job counts: %w", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"mabletask/api/logging"
+)
+
+// LeaderLock elects a single leader among replicas with a PostgreSQL session
+// advisory lock. The lock is held on a dedicated connection, so leadership
+// passes to another replica as soon as the leader's connection goes away.
+type LeaderLock struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewLeaderLock(db *sql.DB, key int64) *LeaderLock {
+	return &LeaderLock{db: db, key: key}
+}
+
+// IsLeader reports whether this instance holds the lock, trying to acquire it
+// when it does not.
+func (l *LeaderLock) IsLeader(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true
+		}
+		logging.Ctx(ctx).Warn().Msgf("Leader lock: lost connection holding lock %d", l.key)
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		logging.Ctx(ctx).Error().Err(err).Msg("Leader lock: failed to get a connection")
+		return false
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1);`, l.key).Scan(&acquired); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Msgf("Leader lock: failed to acquire lock %d", l.key)
+		conn.Close()
+		return false
+	}
+	if !acquired {
+		conn.Close()
+		return false
+	}
+	logging.Ctx(ctx).Info().Msgf("Leader lock: acquired lock %d", l.key)
+	l.conn = conn
+	return true
+}
+
+// Release gives up leadership, if held.
+func (l *LeaderLock) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return
+	}
+	if _, err := l.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1);`, l.key); err != nil {
+		log.Error().Err(err).Msgf("Leader lock: failed to release lock %d", l.key)
+	}
+	l.conn.Close()
+	l.conn = nil
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+const (
+	liveActiveWindow   = 5 * time.Minute
+	liveTopPagesWindow = 30 * time.Minute
+	liveTopPagesLimit  = 5
+	liveConversions    = 10
+)
+
+// GetLiveSummary returns the real-time overview as of now: active visitors,
+// event throughput, the most viewed pages of the last half hour and the
+// latest purchases.
+func (s *AnalyticsStore) GetLiveSummary(ctx context.Context, now time.Time, filters EventFilters) (*models.LiveSummary, error) {
+	summary := &models.LiveSummary{GeneratedAt: now}
+	filterClause, filterArgs := filters.clause()
+
+	minute := now.Truncate(time.Minute)
+	args := []interface{}{minute.Add(-time.Minute).UnixMilli(), minute.Add(-time.Millisecond).UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, now.Add(-liveActiveWindow).UnixMilli(), now.UnixMilli())
+	args = append(args, filterArgs...)
+	err := s.queryRow(ctx, `
+		SELECT
+			(SELECT `+sampledCount+` FROM analytics_events WHERE `+timeRangeClause+filterClause+`),
+			(SELECT uniqExact(`+visitorExpr+`) FROM analytics_events WHERE `+timeRangeClause+filterClause+`)
+	`, args...).Scan(&summary.EventsPerMinute, &summary.ActiveUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query live activity: %w", err)
+	}
+	summary.EventsPerSecond = float64(summary.EventsPerMinute) / 60
+
+	summary.TopPages, err = s.GetTopNPagePaths(ctx, now.Add(-liveTopPagesWindow), now, liveTopPagesLimit, filters)
+	if err != nil {
+		return nil, err
+	}
+	if summary.TopPages == nil {
+		summary.TopPages = []models.TopPathResult{}
+	}
+
+	summary.LatestConversions, err = s.latestConversions(ctx, now, filters)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// latestConversions returns the most recent purchases of the last day.
+func (s *AnalyticsStore) latestConversions(ctx context.Context, now time.Time, filters EventFilters) ([]models.Conversion, error) {
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{models.EventTypePurchase, now.Add(-24 * time.Hour).UnixMilli(), now.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, liveConversions)
+
+	rows, err := s.query(ctx, `
+		SELECT toString(event_id), `+visitorExpr+`, timestamp,
+			JSONExtractString(toString(event_data), 'orderId'),
+			JSONExtractFloat(toString(event_data), 'revenue'),
+			JSONExtractString(toString(event_data), 'currency')
+		FROM analytics_events
+		WHERE event_type = ? AND `+timeRangeClause+filterClause+`
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest conversions: %w", err)
+	}
+	defer rows.Close()
+
+	conversions := []models.Conversion{}
+	for rows.Next() {
+		var conv models.Conversion
+		if err := rows.Scan(&conv.EventID, &conv.VisitorID, &conv.Timestamp, &conv.OrderID, &conv.Revenue, &conv.Currency); err != nil {
+			return nil, fmt.Errorf("failed to scan conversion: %w", err)
+		}
+		conversions = append(conversions, conv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating conversions: %w", err)
+	}
+	return conversions, nil
+}
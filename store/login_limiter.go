@@ -0,0 +1,280 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLoginThrottled is wrapped by LoginLimiter when a client address made too
+// many failed login attempts.
+var ErrLoginThrottled = errors.New("too many failed login attempts")
+
+// ErrAccountLocked is wrapped by LoginLimiter when an account is temporarily
+// locked after repeated failed logins.
+var ErrAccountLocked = errors.New("account temporarily locked")
+
+// LoginBlockedError refuses a login attempt until RetryAfter has passed. It
+// wraps ErrLoginThrottled or ErrAccountLocked.
+type LoginBlockedError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("%v; retry after %s", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginBlockedError) Unwrap() error { return e.Err }
+
+// AttemptCounter counts events per key in windows that start with the first
+// event and reset when they end.
+type AttemptCounter interface {
+	// Hit counts an event and returns the count of the current window and
+	// when it ends.
+	Hit(ctx context.Context, key string, window time.Duration) (int64, time.Time, error)
+	// Peek returns the count of the current window and when it ends; zero when
+	// there is none.
+	Peek(ctx context.Context, key string) (int64, time.Time, error)
+	// Hold extends the current window to at least until.
+	Hold(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	// DeleteExpiredBefore removes windows that ended before cutoff.
+	DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// NewAttemptCounter counts in Redis when a client is given, and in PostgreSQL
+// otherwise, so that all replicas share the counts either way.
+func NewAttemptCounter(client *redis.Client, db *sql.DB) AttemptCounter {
+	if client != nil {
+		return &RedisAttemptCounter{client: client}
+	}
+	return &PostgresAttemptCounter{db: db}
+}
+
+// RedisAttemptCounter keeps counts in Redis keys that expire with their
+// window.
+type RedisAttemptCounter struct {
+	client *redis.Client
+}
+
+const attemptKeyPrefix = "attempts:"
+
+func (r *RedisAttemptCounter) Hit(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	key = attemptKeyPrefix + key
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, window)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count attempt: %w", err)
+	}
+	return incr.Val(), time.Now().Add(ttl.Val()), nil
+}
+
+func (r *RedisAttemptCounter) Peek(ctx context.Context, key string) (int64, time.Time, error) {
+	key = attemptKeyPrefix + key
+	pipe := r.client.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, time.Time{}, fmt.Errorf("failed to get attempts: %w", err)
+	}
+	count, err := get.Int64()
+	if err == redis.Nil || ttl.Val() <= 0 {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to parse attempts: %w", err)
+	}
+	return count, time.Now().Add(ttl.Val()), nil
+}
+
+func (r *RedisAttemptCounter) Hold(ctx context.Context, key string, until time.Time) error {
+	if err := r.client.PExpireAt(ctx, attemptKeyPrefix+key, until).Err(); err != nil {
+		return fmt.Errorf("failed to extend attempts window: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisAttemptCounter) Reset(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, attemptKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to reset attempts: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredBefore is a no-op: Redis expires the counts on its own.
+func (r *RedisAttemptCounter) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// PostgresAttemptCounter keeps counts in the login_attempts table.
+type PostgresAttemptCounter struct {
+	db *sql.DB
+}
+
+func (p *PostgresAttemptCounter) Hit(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	var (
+		count     int64
+		expiresAt time.Time
+	)
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO login_attempts (key, count, expires_at)
+		VALUES ($1, 1, CURRENT_TIMESTAMP + make_interval(secs => $2))
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN login_attempts.expires_at <= CURRENT_TIMESTAMP THEN 1 ELSE login_attempts.count + 1 END,
+			expires_at = CASE WHEN login_attempts.expires_at <= CURRENT_TIMESTAMP THEN EXCLUDED.expires_at ELSE login_attempts.expires_at END
+		RETURNING count, expires_at;
+	`, key, window.Seconds()).Scan(&count, &expiresAt)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count attempt: %w", err)
+	}
+	return count, expiresAt, nil
+}
+
+func (p *PostgresAttemptCounter) Peek(ctx context.Context, key string) (int64, time.Time, error) {
+	var (
+		count     int64
+		expiresAt time.Time
+	)
+	err := p.db.QueryRowContext(ctx, `
+		SELECT count, expires_at FROM login_attempts WHERE key = $1 AND expires_at > CURRENT_TIMESTAMP;
+	`, key).Scan(&count, &expiresAt)
+	if err == sql.ErrNoRows {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to get attempts: %w", err)
+	}
+	return count, expiresAt, nil
+}
+
+func (p *PostgresAttemptCounter) Hold(ctx context.Context, key string, until time.Time) error {
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE login_attempts SET expires_at = GREATEST(expires_at, $2) WHERE key = $1;
+	`, key, until)
+	if err != nil {
+		return fmt.Errorf("failed to extend attempts window: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresAttemptCounter) Reset(ctx context.Context, key string) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1;`, key); err != nil {
+		return fmt.Errorf("failed to reset attempts: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresAttemptCounter) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE expires_at < $1;`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune login attempts: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// LoginLimiter throttles failed logins per client address and locks accounts
+// after repeated failures. A limit of zero disables that check.
+type LoginLimiter struct {
+	counter AttemptCounter
+	// MaxIPFailures is how many failed logins an address may make per Window.
+	MaxIPFailures int64
+	// MaxAccountFailures is how many failed logins lock an account, counted
+	// per Window; the lock lasts Lockout.
+	MaxAccountFailures int64
+	Window             time.Duration
+	Lockout            time.Duration
+}
+
+func NewLoginLimiter(counter AttemptCounter, maxIPFailures, maxAccountFailures int64, window, lockout time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		counter:            counter,
+		MaxIPFailures:      maxIPFailures,
+		MaxAccountFailures: maxAccountFailures,
+		Window:             window,
+		Lockout:            lockout,
+	}
+}
+
+func loginIPKey(ip string) string { return "login:ip:" + ip }
+
+func loginAccountKey(email string) string {
+	return "login:account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func blocked(err error, until time.Time) error {
+	retryAfter := time.Until(until)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &LoginBlockedError{Err: err, RetryAfter: retryAfter}
+}
+
+// Check refuses a login attempt from ip for email with a *LoginBlockedError
+// while the address is throttled or the account locked.
+func (l *LoginLimiter) Check(ctx context.Context, ip, email string) error {
+	if l.MaxIPFailures > 0 {
+		count, until, err := l.counter.Peek(ctx, loginIPKey(ip))
+		if err != nil {
+			return err
+		}
+		if count >= l.MaxIPFailures {
+			return blocked(ErrLoginThrottled, until)
+		}
+	}
+	if l.MaxAccountFailures > 0 {
+		count, until, err := l.counter.Peek(ctx, loginAccountKey(email))
+		if err != nil {
+			return err
+		}
+		if count >= l.MaxAccountFailures {
+			return blocked(ErrAccountLocked, until)
+		}
+	}
+	return nil
+}
+
+// Fail counts a failed login. It returns a *LoginBlockedError when this
+// failure locks the account.
+func (l *LoginLimiter) Fail(ctx context.Context, ip, email string) error {
+	if l.MaxIPFailures > 0 {
+		if _, _, err := l.counter.Hit(ctx, loginIPKey(ip), l.Window); err != nil {
+			return err
+		}
+	}
+	if l.MaxAccountFailures > 0 {
+		key := loginAccountKey(email)
+		count, _, err := l.counter.Hit(ctx, key, l.Window)
+		if err != nil {
+			return err
+		}
+		if count >= l.MaxAccountFailures {
+			until := time.Now().Add(l.Lockout)
+			if err := l.counter.Hold(ctx, key, until); err != nil {
+				return err
+			}
+			return blocked(ErrAccountLocked, until)
+		}
+	}
+	return nil
+}
+
+// Succeed clears the failures of the account after a successful login.
+func (l *LoginLimiter) Succeed(ctx context.Context, email string) error {
+	if l.MaxAccountFailures <= 0 {
+		return nil
+	}
+	return l.counter.Reset(ctx, loginAccountKey(email))
+}
+
+// DeleteExpiredBefore removes counts whose window ended before cutoff.
+func (l *LoginLimiter) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return l.counter.DeleteExpiredBefore(ctx, cutoff)
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// outboundDestination returns the destination URL of an outbound_click event,
+// or "" for events of other types.
+func outboundDestination(event *models.AnalyticsEvent) string {
+	if event.EventType != models.EventTypeOutboundClick {
+		return ""
+	}
+	p, err := event.OutboundClickPayload()
+	if err != nil {
+		return ""
+	}
+	return p.URL
+}
+
+// GetTopOutboundDestinations returns the limit external destinations clicked
+// most in the range, by URL without query string or, with byDomain, by domain.
+func (s *AnalyticsStore) GetTopOutboundDestinations(ctx context.Context, byDomain bool, start, end time.Time, limit uint64, filters EventFilters) ([]models.OutboundDestination, error) {
+	if limit == 0 {
+		limit = 10
+	}
+	destination := "cutQueryString(destination_url)"
+	if byDomain {
+		destination = "domainWithoutWWW(destination_url)"
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s AS destination, `+sampledCount+` AS clicks, uniqExact(%s) AS visitors
+		FROM analytics_events
+		WHERE event_type = '%s' AND destination_url != '' AND %s%s
+		GROUP BY destination
+		ORDER BY clicks DESC, destination
+		LIMIT ?
+	`, destination, visitorExpr, models.EventTypeOutboundClick, timeRangeClause, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbound destinations: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.OutboundDestination{}
+	for rows.Next() {
+		var r models.OutboundDestination
+		if err := rows.Scan(&r.Destination, &r.Clicks, &r.Visitors); err != nil {
+			return nil, fmt.Errorf("failed to scan outbound destination: %w", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbound destinations: %w", err)
+	}
+	return results, nil
+}
+
+// GetOutboundClicksBySource returns the limit pages with the most outbound
+// clicks in the range, with the share of each page's viewers who clicked out.
+func (s *AnalyticsStore) GetOutboundClicksBySource(ctx context.Context, start, end time.Time, limit uint64, filters EventFilters) ([]models.OutboundSource, error) {
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT cutQueryString(page_path) AS path,
+		       countIf(event_type = '%[1]s') AS clicks,
+		       uniqExactIf(%[2]s, event_type = '%[1]s') AS visitors,
+		       uniqExactIf(%[2]s, event_type = '%[3]s') AS viewers
+		FROM analytics_events
+		WHERE event_type IN ('%[1]s', '%[3]s') AND page_path != '' AND %[4]s%[5]s
+		GROUP BY path
+		HAVING clicks > 0
+		ORDER BY clicks DESC, path
+		LIMIT ?
+	`, models.EventTypeOutboundClick, visitorExpr, models.EventTypePageView, timeRangeClause, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbound clicks by source: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.OutboundSource{}
+	for rows.Next() {
+		var r models.OutboundSource
+		if err := rows.Scan(&r.PagePath, &r.Clicks, &r.Visitors, &r.PageViewers); err != nil {
+			return nil, fmt.Errorf("failed to scan outbound source: %w", err)
+		}
+		if r.PageViewers > 0 {
+			r.ClickThroughRate = float64(r.Visitors) / float64(r.PageViewers)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbound sources: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+const (
+	// DefaultPageLimit is the page size of listings when none is requested.
+	DefaultPageLimit = 100
+	// MaxPageLimit caps the page size a caller may request.
+	MaxPageLimit = 1000
+)
+
+// Cursor is a keyset position in a listing ordered newest first by (Time, ID):
+// the next page starts after the row it names.
+type Cursor struct {
+	Time time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+// Encode returns the cursor as an opaque string for clients.
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a cursor returned by Encode.
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalid)
+	}
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Time.IsZero() || c.ID == "" {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalid)
+	}
+	return &c, nil
+}
+
+// PageRequest asks for up to Limit rows after the After cursor, or for the
+// first page when After is nil.
+type PageRequest struct {
+	Limit uint64
+	After *Cursor
+}
+
+func (p PageRequest) size() uint64 {
+	if p.Limit == 0 {
+		return DefaultPageLimit
+	}
+	return p.Limit
+}
+
+// keysetArgs returns the cursor time and ID as query arguments, both nil on
+// the first page, for conditions such as
+// ($1::timestamptz IS NULL OR (created_at, id) < ($1, $2)).
+func (p PageRequest) keysetArgs() (interface{}, interface{}) {
+	if p.After == nil {
+		return nil, nil
+	}
+	return p.After.Time, p.After.ID
+}
+
+// fetchLimit is the number of rows to query: one more than the page size, to
+// learn whether another page follows.
+func (p PageRequest) fetchLimit() uint64 {
+	return p.size() + 1
+}
+
+// newPage trims rows fetched with fetchLimit to the page size and sets the
+// cursor of the next page, if there is one.
+func newPage[T any](rows []T, p PageRequest, key func(T) Cursor) models.Page[T] {
+	page := models.Page[T]{Items: rows}
+	if uint64(len(rows)) > p.size() {
+		page.Items = rows[:p.size()]
+		page.NextCursor = key(page.Items[len(page.Items)-1]).Encode()
+	}
+	return page
+}
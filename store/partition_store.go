@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// maintenanceTables lists the tables partition maintenance may touch, with the
+// column used to decide partition age ("" when partitions cannot be aged).
+var maintenanceTables = map[string]string{
+	"analytics_events": "timestamp",
+	"query_log":        "timestamp",
+	"audience_members": "computed_at",
+	"user_traits":      "",
+	"user_groups":      "",
+	"suppressed_ids":   "",
+}
+
+var partitionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// PartitionStore runs partition maintenance on ClickHouse tables. Table names
+// are checked against maintenanceTables before being used in a statement.
+type PartitionStore struct {
+	ch *database.ClickHouseClient
+}
+
+func NewPartitionStore(chClient *database.ClickHouseClient) *PartitionStore {
+	return &PartitionStore{ch: chClient}
+}
+
+func checkMaintenanceTable(table string) error {
+	if _, ok := maintenanceTables[table]; !ok {
+		return fmt.Errorf("table %s: %w", table, ErrNotFound)
+	}
+	return nil
+}
+
+func checkPartitionIDs(ids []string) error {
+	for _, id := range ids {
+		if !partitionIDPattern.MatchString(id) {
+			return fmt.Errorf("partition id %q: %w", id, ErrInvalid)
+		}
+	}
+	return nil
+}
+
+// ListPartitions returns the table's active partitions followed by its
+// detached ones.
+func (s *PartitionStore) ListPartitions(ctx context.Context, table string) ([]models.Partition, error) {
+	if err := checkMaintenanceTable(table); err != nil {
+		return nil, err
+	}
+
+	partitions := []models.Partition{}
+	rows, err := s.ch.Conn.Query(ctx, `
+		SELECT partition_id, any(partition), count(), sum(rows), sum(bytes_on_disk)
+		FROM system.parts
+		WHERE active AND database = currentDatabase() AND table = ?
+		GROUP BY partition_id
+		ORDER BY partition_id
+	`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", table, err)
+	}
+	for rows.Next() {
+		var p models.Partition
+		if err := rows.Scan(&p.PartitionID, &p.Partition, &p.Parts, &p.Rows, &p.BytesOnDisk); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		partitions = append(partitions, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating partitions: %w", err)
+	}
+
+	rows, err = s.ch.Conn.Query(ctx, `
+		SELECT ifNull(partition_id, ''), count()
+		FROM system.detached_parts
+		WHERE database = currentDatabase() AND table = ?
+		GROUP BY partition_id
+		ORDER BY partition_id
+	`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list detached partitions of %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p := models.Partition{Detached: true}
+		if err := rows.Scan(&p.PartitionID, &p.Parts); err != nil {
+			return nil, fmt.Errorf("failed to scan detached partition: %w", err)
+		}
+		p.Partition = p.PartitionID
+		partitions = append(partitions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating detached partitions: %w", err)
+	}
+	return partitions, nil
+}
+
+// Optimize runs OPTIMIZE ... FINAL on each partition, or on the whole table
+// when no partitions are given.
+func (s *PartitionStore) Optimize(ctx context.Context, table string, partitionIDs []string) error {
+	if err := checkMaintenanceTable(table); err != nil {
+		return err
+	}
+	if err := checkPartitionIDs(partitionIDs); err != nil {
+		return err
+	}
+
+	if len(partitionIDs) == 0 {
+		if err := s.ch.Conn.Exec(ctx, fmt.Sprintf("OPTIMIZE TABLE %s FINAL", table)); err != nil {
+			return fmt.Errorf("failed to optimize %s: %w", table, err)
+		}
+		return nil
+	}
+	for _, id := range partitionIDs {
+		if err := s.ch.Conn.Exec(ctx, fmt.Sprintf("OPTIMIZE TABLE %s PARTITION ID '%s' FINAL", table, id)); err != nil {
+			return fmt.Errorf("failed to optimize partition %s of %s: %w", id, table, err)
+		}
+	}
+	return nil
+}
+
+// PartitionsOlderThan returns the active partitions whose newest row is before
+// the cutoff. Only tables with an age column support this.
+func (s *PartitionStore) PartitionsOlderThan(ctx context.Context, table string, cutoff time.Time) ([]string, error) {
+	if err := checkMaintenanceTable(table); err != nil {
+		return nil, err
+	}
+	column := maintenanceTables[table]
+	if column == "" {
+		return nil, fmt.Errorf("table %s has no time column to age partitions by: %w", table, ErrInvalid)
+	}
+
+	rows, err := s.ch.Conn.Query(ctx, fmt.Sprintf(`
+		SELECT _partition_id
+		FROM %s
+		GROUP BY _partition_id
+		HAVING max(%s) < fromUnixTimestamp64Milli(toInt64(?), 'UTC')
+		ORDER BY _partition_id
+	`, table, column), cutoff.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find old partitions of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan partition id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating partition ids: %w", err)
+	}
+	return ids, nil
+}
+
+// DropPartitions permanently removes the given partitions. It stops at the
+// first failure and returns the partitions dropped so far.
+func (s *PartitionStore) DropPartitions(ctx context.Context, table string, partitionIDs []string) ([]string, error) {
+	if err := checkMaintenanceTable(table); err != nil {
+		return nil, err
+	}
+	if err := checkPartitionIDs(partitionIDs); err != nil {
+		return nil, err
+	}
+
+	dropped := []string{}
+	for _, id := range partitionIDs {
+		if err := s.ch.Conn.Exec(ctx, fmt.Sprintf("ALTER TABLE %s DROP PARTITION ID '%s'", table, id)); err != nil {
+			return dropped, fmt.Errorf("failed to drop partition %s of %s: %w", id, table, err)
+		}
+		logging.Ctx(ctx).Warn().Msgf("Dropped partition %s of %s", id, table)
+		dropped = append(dropped, id)
+	}
+	return dropped, nil
+}
+
+// DetachPartition moves a partition to the table's detached directory, hiding
+// its data from queries until it is attached again.
+func (s *PartitionStore) DetachPartition(ctx context.Context, table, partitionID string) error {
+	if err := checkMaintenanceTable(table); err != nil {
+		return err
+	}
+	if err := checkPartitionIDs([]string{partitionID}); err != nil {
+		return err
+	}
+	if err := s.ch.Conn.Exec(ctx, fmt.Sprintf("ALTER TABLE %s DETACH PARTITION ID '%s'", table, partitionID)); err != nil {
+		return fmt.Errorf("failed to detach partition %s of %s: %w", partitionID, table, err)
+	}
+	return nil
+}
+
+func (s *PartitionStore) AttachPartition(ctx context.Context, table, partitionID string) error {
+	if err := checkMaintenanceTable(table); err != nil {
+		return err
+	}
+	if err := checkPartitionIDs([]string{partitionID}); err != nil {
+		return err
+	}
+	if err := s.ch.Conn.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION ID '%s'", table, partitionID)); err != nil {
+		return fmt.Errorf("failed to attach partition %s of %s: %w", partitionID, table, err)
+	}
+	return nil
+}
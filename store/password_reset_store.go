@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mabletask/api/utils"
+)
+
+// PasswordResetStore keeps the hashes of password reset tokens. A token can
+// be redeemed once, before it expires, and requesting a new one invalidates
+// the user's earlier tokens.
+type PasswordResetStore struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+// NewPasswordResetStore issues reset tokens valid for ttl.
+func NewPasswordResetStore(db *sql.DB, ttl time.Duration) *PasswordResetStore {
+	return &PasswordResetStore{db: db, ttl: ttl}
+}
+
+// CreateToken issues a reset token for the user and returns it with its
+// expiry.
+func (s *PasswordResetStore) CreateToken(ctx context.Context, userID int) (string, time.Time, error) {
+	token, err := utils.NewToken(utils.PasswordResetPrefix)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL;
+	`, userID); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+	var expiresAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP + make_interval(secs => $3))
+		RETURNING expires_at;
+	`, userID, utils.HashToken(token), s.ttl.Seconds()).Scan(&expiresAt)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store password reset token: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to commit password reset token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// ResetPassword redeems token and sets the user's password hash, returning
+// the user's id. Unknown, used or expired tokens give ErrRevoked.
+func (s *PasswordResetStore) ResetPassword(ctx context.Context, token string, hashedPassword []byte) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_resets SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id;
+	`, utils.HashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("password reset token: %w", ErrRevoked)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to redeem password reset token: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users SET hashed_password = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1;
+	`, userID, hashedPassword); err != nil {
+		return 0, fmt.Errorf("failed to update password of user %d: %w", userID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit password reset: %w", err)
+	}
+	return userID, nil
+}
+
+// DeleteExpiredBefore removes tokens that expired before cutoff.
+func (s *PasswordResetStore) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM password_resets WHERE expires_at < $1;`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune password reset tokens: %w", err)
+	}
+	return res.RowsAffected()
+}
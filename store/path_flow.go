@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// DefaultPathFlowDepth and MaxPathFlowDepth bound how many page views into
+// sessions GetPathFlow follows.
+const (
+	DefaultPathFlowDepth = 5
+	MaxPathFlowDepth     = 10
+)
+
+// GetPathFlow returns the most common page to page transitions of sessions,
+// for the first depth page views of each session and at most limit per step.
+// With from set, only transitions leaving that page are returned. Reloads
+// (a page followed by itself) are left out.
+func (s *AnalyticsStore) GetPathFlow(ctx context.Context, start, end time.Time, depth int, from string, limit uint64, filters EventFilters) ([]models.PathTransition, error) {
+	if depth < 1 || depth > MaxPathFlowDepth {
+		return nil, fmt.Errorf("%w: depth must be between 1 and %d", ErrInvalid, MaxPathFlowDepth)
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, depth)
+	fromClause := ""
+	if from != "" {
+		fromClause = " AND from_page = ?"
+		args = append(args, from)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT step, from_page, to_page, `+sampledCount+` AS transitions
+		FROM (
+			SELECT row_number() OVER w AS step,
+			       cutQueryString(page_path) AS from_page,
+			       leadInFrame(cutQueryString(page_path)) OVER w AS to_page,
+			       sample_rate
+			FROM analytics_events
+			WHERE %s%s
+			WINDOW w AS (PARTITION BY session_id ORDER BY timestamp ASC, event_id ASC ROWS BETWEEN CURRENT ROW AND 1 FOLLOWING)
+		)
+		WHERE to_page != '' AND to_page != from_page AND step <= ?%s
+		GROUP BY step, from_page, to_page
+		ORDER BY step ASC, transitions DESC, from_page, to_page
+		LIMIT ? BY step
+	`, sessionPageViews, filterClause, fromClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query path flow: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.PathTransition{}
+	for rows.Next() {
+		var r models.PathTransition
+		if err := rows.Scan(&r.Step, &r.From, &r.To, &r.Count); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for path flow")
+			continue
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for path flow: %w", err)
+	}
+
+	return results, nil
+}
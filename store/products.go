@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// productColumns holds line items as the parallel arrays of the
+// analytics_events products Nested column.
+type productColumns struct {
+	ids        []string
+	skus       []string
+	names      []string
+	prices     []float64
+	quantities []uint32
+	currencies []string
+}
+
+func newProductColumns(items []models.ProductLineItem) productColumns {
+	cols := productColumns{
+		ids:        make([]string, len(items)),
+		skus:       make([]string, len(items)),
+		names:      make([]string, len(items)),
+		prices:     make([]float64, len(items)),
+		quantities: make([]uint32, len(items)),
+		currencies: make([]string, len(items)),
+	}
+	for i, item := range items {
+		cols.ids[i] = item.ID
+		cols.skus[i] = item.SKU
+		cols.names[i] = item.Name
+		cols.prices[i] = item.Price
+		cols.quantities[i] = item.Quantity
+		cols.currencies[i] = item.Currency
+	}
+	return cols
+}
+
+func (cols productColumns) lineItems() []models.ProductLineItem {
+	if len(cols.ids) == 0 {
+		return nil
+	}
+	items := make([]models.ProductLineItem, len(cols.ids))
+	for i := range items {
+		items[i] = models.ProductLineItem{
+			ID:       cols.ids[i],
+			SKU:      cols.skus[i],
+			Name:     cols.names[i],
+			Price:    cols.prices[i],
+			Quantity: cols.quantities[i],
+			Currency: cols.currencies[i],
+		}
+	}
+	return items
+}
+
+// productSorts maps the orderings of GetTopProducts to their column.
+// Revenue adds up amounts across currencies, so it ranks products in stores
+// that sell in a single currency.
+var productSorts = map[string]string{
+	"views":      "views",
+	"addsToCart": "adds_to_cart",
+	"purchases":  "purchases",
+	"revenue":    "arraySum(revenue)",
+}
+
+// ProductSorts lists the orderings accepted by GetTopProducts.
+func ProductSorts() []string {
+	sorts := make([]string, 0, len(productSorts))
+	for name := range productSorts {
+		sorts = append(sorts, name)
+	}
+	sort.Strings(sorts)
+	return sorts
+}
+
+// GetTopProducts returns the limit products ranked by sortBy (views,
+// addsToCart, purchases or revenue) from the line items of product_view,
+// add_to_cart and purchase events.
+func (s *AnalyticsStore) GetTopProducts(ctx context.Context, sortBy string, start, end time.Time, limit uint64, filters EventFilters) ([]models.ProductStats, error) {
+	orderBy, ok := productSorts[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: sort must be one of %v", ErrInvalid, ProductSorts())
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{
+		models.EventTypeProductView, models.EventTypeAddToCart, models.EventTypePurchase,
+		models.EventTypePurchase, models.EventTypePurchase,
+		models.EventTypeProductView, models.EventTypeAddToCart, models.EventTypePurchase,
+		start.UnixMilli(), end.UnixMilli(),
+	}
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT product_id, argMax(name, last_seen) AS product_name,
+		       sum(view_count) AS views, sum(cart_units) AS adds_to_cart,
+		       sum(purchased_units) AS purchases, sum(order_count) AS orders,
+		       groupArrayIf(item_currency, order_count > 0) AS currencies,
+		       groupArrayIf(item_revenue, order_count > 0) AS revenue,
+		       groupArrayIf(order_count, order_count > 0) AS orders_by_currency
+		FROM (
+			SELECT if(p.id != '', p.id, p.sku) AS product_id,
+			       if(p.currency != '', p.currency, currency) AS item_currency,
+			       argMax(p.name, timestamp) AS name, max(timestamp) AS last_seen,
+			       countIf(event_type = ?) AS view_count,
+			       sumIf(p.quantity, event_type = ?) AS cart_units,
+			       sumIf(p.quantity, event_type = ?) AS purchased_units,
+			       sumIf(p.price * p.quantity, event_type = ?) AS item_revenue,
+			       uniqExactIf(event_id, event_type = ?) AS order_count
+			FROM analytics_events
+			ARRAY JOIN products AS p
+			WHERE event_type IN (?, ?, ?) AND %s%s
+			GROUP BY product_id, item_currency
+		)
+		GROUP BY product_id
+		ORDER BY %s DESC, product_id
+		LIMIT ?
+	`, timeRangeClause, filterClause, orderBy)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top products: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.ProductStats{}
+	for rows.Next() {
+		var (
+			r          models.ProductStats
+			currencies []string
+			revenue    []float64
+			orders     []uint64
+		)
+		if err := rows.Scan(&r.ProductID, &r.Name, &r.Views, &r.AddsToCart, &r.Purchases, &r.Orders, &currencies, &revenue, &orders); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for top products")
+			continue
+		}
+		r.Revenue = make([]models.RevenueAmount, len(currencies))
+		for i := range currencies {
+			r.Revenue[i] = models.RevenueAmount{Currency: currencies[i], Revenue: revenue[i], Purchases: orders[i]}
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for top products: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// ProjectSettingsStore keeps per-project (site) settings such as how long the
+// project's events are retained.
+type ProjectSettingsStore struct {
+	db *sql.DB
+	// DefaultMaxRetentionDays is the plan limit of projects without an
+	// override; 0 means retention is unlimited.
+	DefaultMaxRetentionDays int
+	// DefaultBaseCurrency is the currency revenue of projects without a
+	// choice is reported in.
+	DefaultBaseCurrency string
+	// DefaultEventTypeRetention holds the retention in days of event types
+	// in projects without a retention of their own for the type.
+	DefaultEventTypeRetention map[string]int
+}
+
+func NewProjectSettingsStore(db *sql.DB, defaultMaxRetentionDays int, defaultBaseCurrency string) *ProjectSettingsStore {
+	return &ProjectSettingsStore{db: db, DefaultMaxRetentionDays: defaultMaxRetentionDays, DefaultBaseCurrency: defaultBaseCurrency}
+}
+
+// effectiveRetention is the shorter of the chosen retention and the plan
+// limit, where 0 and nil mean unlimited.
+func effectiveRetention(days *int, max int) int {
+	if days == nil || (max > 0 && *days > max) {
+		return max
+	}
+	return *days
+}
+
+func (s *ProjectSettingsStore) scanSettings(row rowScanner, settings *models.ProjectSettings) error {
+	var retention, maxRetention, updatedBy sql.NullInt64
+	var baseCurrency sql.NullString
+	var updatedAt time.Time
+	if err := row.Scan(&settings.ProjectID, &retention, &maxRetention, &baseCurrency, &updatedBy, &updatedAt); err != nil {
+		return err
+	}
+	settings.BaseCurrency = s.DefaultBaseCurrency
+	if baseCurrency.Valid {
+		settings.BaseCurrency = baseCurrency.String
+	}
+	if retention.Valid {
+		days := int(retention.Int64)
+		settings.RetentionDays = &days
+	}
+	settings.MaxRetentionDays = s.DefaultMaxRetentionDays
+	if maxRetention.Valid {
+		settings.MaxRetentionDays = int(maxRetention.Int64)
+	}
+	if updatedBy.Valid {
+		id := int(updatedBy.Int64)
+		settings.UpdatedBy = &id
+	}
+	settings.UpdatedAt = &updatedAt
+	settings.EffectiveRetentionDays = effectiveRetention(settings.RetentionDays, settings.MaxRetentionDays)
+	return nil
+}
+
+const projectSettingsColumns = `project_id, retention_days, max_retention_days, base_currency, updated_by, updated_at`
+
+// GetSettings returns the project's settings, or the defaults when none were
+// saved.
+func (s *ProjectSettingsStore) GetSettings(ctx context.Context, projectID string) (*models.ProjectSettings, error) {
+	var settings models.ProjectSettings
+	err := s.scanSettings(s.db.QueryRowContext(ctx,
+		`SELECT `+projectSettingsColumns+` FROM project_settings WHERE project_id = $1;`, projectID), &settings)
+	if err == sql.ErrNoRows {
+		return &models.ProjectSettings{
+			ProjectID:              projectID,
+			MaxRetentionDays:       s.DefaultMaxRetentionDays,
+			EffectiveRetentionDays: s.DefaultMaxRetentionDays,
+			BaseCurrency:           s.DefaultBaseCurrency,
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings for project %s: %w", projectID, err)
+	}
+	return &settings, nil
+}
+
+// UpdateSettings replaces the settings a project chooses itself: how many days
+// its events are kept (nil keeps them up to the plan limit) and its base
+// currency ("" for the default). A retention longer than the limit wraps
+// ErrInvalid.
+func (s *ProjectSettingsStore) UpdateSettings(ctx context.Context, projectID string, req models.ProjectSettingsRequest, updatedBy int) (*models.ProjectSettings, error) {
+	current, err := s.GetSettings(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	days := req.RetentionDays
+	if days != nil && current.MaxRetentionDays > 0 && *days > current.MaxRetentionDays {
+		return nil, fmt.Errorf("retention of %d days exceeds the plan limit of %d days: %w", *days, current.MaxRetentionDays, ErrInvalid)
+	}
+	var baseCurrency interface{}
+	if req.BaseCurrency != "" {
+		baseCurrency = req.BaseCurrency
+	}
+
+	var updater interface{}
+	if updatedBy != 0 {
+		updater = updatedBy
+	}
+	var settings models.ProjectSettings
+	err = s.scanSettings(s.db.QueryRowContext(ctx, `
+		INSERT INTO project_settings (project_id, retention_days, base_currency, updated_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id) DO UPDATE
+		SET retention_days = EXCLUDED.retention_days,
+		    base_currency = EXCLUDED.base_currency,
+		    updated_by = EXCLUDED.updated_by,
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING `+projectSettingsColumns+`;
+	`, projectID, days, baseCurrency, updater), &settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update settings for project %s: %w", projectID, err)
+	}
+	return &settings, nil
+}
+
+// SetRetentionLimit sets the project's plan limit on retention; nil restores
+// DefaultMaxRetentionDays. A retention the project chose above the new limit
+// is kept but only enforced up to the limit.
+func (s *ProjectSettingsStore) SetRetentionLimit(ctx context.Context, projectID string, maxDays *int, updatedBy int) (*models.ProjectSettings, error) {
+	var updater interface{}
+	if updatedBy != 0 {
+		updater = updatedBy
+	}
+	var settings models.ProjectSettings
+	err := s.scanSettings(s.db.QueryRowContext(ctx, `
+		INSERT INTO project_settings (project_id, max_retention_days, updated_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (project_id) DO UPDATE
+		SET max_retention_days = EXCLUDED.max_retention_days,
+		    updated_by = EXCLUDED.updated_by,
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING `+projectSettingsColumns+`;
+	`, projectID, maxDays, updater), &settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set retention limit for project %s: %w", projectID, err)
+	}
+	return &settings, nil
+}
+
+// ListRetentions returns the effective retention in days of every project
+// with saved settings, 0 meaning unlimited. Other projects are retained for
+// DefaultMaxRetentionDays.
+func (s *ProjectSettingsStore) ListRetentions(ctx context.Context) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+projectSettingsColumns+` FROM project_settings;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project settings: %w", err)
+	}
+	defer rows.Close()
+
+	retentions := map[string]int{}
+	for rows.Next() {
+		var settings models.ProjectSettings
+		if err := s.scanSettings(rows, &settings); err != nil {
+			return nil, fmt.Errorf("failed to scan project settings: %w", err)
+		}
+		retentions[settings.ProjectID] = settings.EffectiveRetentionDays
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating project settings: %w", err)
+	}
+	return retentions, nil
+}
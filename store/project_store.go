@@ -0,0 +1,351 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lib/pq"
+
+	"mabletask/api/models"
+)
+
+// ProjectStore manages projects (sites) and their members in PostgreSQL. An
+// in-memory set of project IDs serves the ingestion path until the next
+// Refresh.
+type ProjectStore struct {
+	db *sql.DB
+
+	mu  sync.RWMutex
+	ids map[string]bool
+}
+
+func NewProjectStore(db *sql.DB) *ProjectStore {
+	return &ProjectStore{db: db, ids: map[string]bool{}}
+}
+
+// Refresh reloads the set of project IDs used by Exists.
+func (s *ProjectStore) Refresh(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM projects;`)
+	if err != nil {
+		return fmt.Errorf("failed to load projects: %w", err)
+	}
+	defer rows.Close()
+
+	ids := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to scan project: %w", err)
+		}
+		ids[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating projects: %w", err)
+	}
+
+	s.mu.Lock()
+	s.ids = ids
+	s.mu.Unlock()
+	return nil
+}
+
+// Exists reports whether the project is known as of the last Refresh or a
+// later change made through this store.
+func (s *ProjectStore) Exists(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ids[id]
+}
+
+func (s *ProjectStore) setKnown(id string, known bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if known {
+		s.ids[id] = true
+	} else {
+		delete(s.ids, id)
+	}
+}
+
+// projectColumns selects a project joined as p with the caller's membership
+// as m.
+const projectColumns = `p.id, p.name, p.domain, COALESCE(m.role, ''), p.created_by, p.created_at, p.updated_at`
+
+func scanProject(row rowScanner) (*models.Project, error) {
+	var project models.Project
+	var createdBy sql.NullInt64
+	if err := row.Scan(&project.ID, &project.Name, &project.Domain, &project.Role, &createdBy, &project.CreatedAt, &project.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		project.CreatedBy = &id
+	}
+	return &project, nil
+}
+
+// CreateProject creates the project with its creator as owner.
+func (s *ProjectStore) CreateProject(ctx context.Context, createdBy int, req models.ProjectRequest) (*models.Project, error) {
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var project models.Project
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO projects (id, name, domain, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, name, domain, created_at, updated_at;
+	`, req.ID, req.Name, req.Domain, creator).Scan(&project.ID, &project.Name, &project.Domain, &project.CreatedAt, &project.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, fmt.Errorf("project '%s': %w", req.ID, ErrAlreadyExists)
+		}
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+	if createdBy != 0 {
+		project.CreatedBy = &createdBy
+		project.Role = models.ProjectRoleOwner
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, $3);
+		`, project.ID, createdBy, models.ProjectRoleOwner); err != nil {
+			return nil, fmt.Errorf("failed to add project owner: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit project: %w", err)
+	}
+
+	s.setKnown(project.ID, true)
+	return &project, nil
+}
+
+// ListProjects returns the projects userID is a member of, or with all every
+// project.
+func (s *ProjectStore) ListProjects(ctx context.Context, userID int, all bool) ([]models.Project, error) {
+	join := "INNER JOIN"
+	if all {
+		join = "LEFT JOIN"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+projectColumns+`
+		FROM projects p
+		`+join+` project_members m ON m.project_id = p.id AND m.user_id = $1
+		ORDER BY p.id;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []models.Project
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		projects = append(projects, *project)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating projects: %w", err)
+	}
+	return projects, nil
+}
+
+// GetProject returns the project with userID's role in it.
+func (s *ProjectStore) GetProject(ctx context.Context, id string, userID int) (*models.Project, error) {
+	project, err := scanProject(s.db.QueryRowContext(ctx, `
+		SELECT `+projectColumns+`
+		FROM projects p
+		LEFT JOIN project_members m ON m.project_id = p.id AND m.user_id = $2
+		WHERE p.id = $1;
+	`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("project '%s': %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	return project, nil
+}
+
+// UpdateProject replaces the name and domain of a project; its ID is
+// immutable since events are stored under it.
+func (s *ProjectStore) UpdateProject(ctx context.Context, id string, userID int, req models.ProjectRequest) (*models.Project, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE projects SET name = $2, domain = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $1;
+	`, id, req.Name, req.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update project: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("project '%s': %w", id, ErrNotFound)
+	}
+	return s.GetProject(ctx, id, userID)
+}
+
+// DeleteProject removes the project and its memberships. Its events stay in
+// ClickHouse until they expire or are deleted.
+func (s *ProjectStore) DeleteProject(ctx context.Context, id string) error {
+	if id == models.DefaultProjectID {
+		return fmt.Errorf("%w: the default project cannot be deleted", ErrInvalid)
+	}
+	res, err := s.db.ExecContext(ctx, `DELETE FROM projects WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete project: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("project '%s': %w", id, ErrNotFound)
+	}
+	s.setKnown(id, false)
+	return nil
+}
+
+// MemberRole returns userID's role in the project, or "" if the user is not a
+// member.
+func (s *ProjectStore) MemberRole(ctx context.Context, projectID string, userID int) (string, error) {
+	var role string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT role FROM project_members WHERE project_id = $1 AND user_id = $2;
+	`, projectID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get membership of project %s: %w", projectID, err)
+	}
+	return role, nil
+}
+
+func (s *ProjectStore) ListMembers(ctx context.Context, projectID string) ([]models.ProjectMember, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.user_id, u.email, m.role, m.created_at
+		FROM project_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.project_id = $1
+		ORDER BY m.created_at, m.user_id;
+	`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members of project %s: %w", projectID, err)
+	}
+	defer rows.Close()
+
+	members := []models.ProjectMember{}
+	for rows.Next() {
+		var member models.ProjectMember
+		if err := rows.Scan(&member.UserID, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan project member: %w", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating project members: %w", err)
+	}
+	return members, nil
+}
+
+// SetMember adds the user to the project or changes their role. The last
+// owner cannot be demoted.
+func (s *ProjectStore) SetMember(ctx context.Context, projectID string, req models.ProjectMemberRequest) (*models.ProjectMember, error) {
+	if req.Role == "" {
+		req.Role = models.ProjectRoleMember
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if req.Role != models.ProjectRoleOwner {
+		if err := checkOtherOwner(ctx, tx, projectID, req.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	var member models.ProjectMember
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO project_members (project_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING user_id, (SELECT email FROM users WHERE id = $2), role, created_at;
+	`, projectID, req.UserID, req.Role).Scan(&member.UserID, &member.Email, &member.Role, &member.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			if pqErr.Constraint == "project_members_project_id_fkey" {
+				return nil, fmt.Errorf("project '%s': %w", projectID, ErrNotFound)
+			}
+			return nil, fmt.Errorf("%w: user %d does not exist", ErrInvalid, req.UserID)
+		}
+		return nil, fmt.Errorf("failed to set project member: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit project member: %w", err)
+	}
+	return &member, nil
+}
+
+// RemoveMember revokes the user's access to the project. The last owner
+// cannot be removed.
+func (s *ProjectStore) RemoveMember(ctx context.Context, projectID string, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkOtherOwner(ctx, tx, projectID, userID); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2;`, projectID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove project member: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("member %d of project '%s': %w", userID, projectID, ErrNotFound)
+	}
+	return tx.Commit()
+}
+
+// checkOtherOwner returns ErrInvalid if userID is the project's only owner,
+// locking the owners until the transaction ends.
+func checkOtherOwner(ctx context.Context, tx *sql.Tx, projectID string, userID int) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT user_id FROM project_members WHERE project_id = $1 AND role = 'owner' FOR UPDATE;
+	`, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get owners of project %s: %w", projectID, err)
+	}
+	defer rows.Close()
+
+	isOwner, others := false, 0
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to scan project owner: %w", err)
+		}
+		if id == userID {
+			isOwner = true
+		} else {
+			others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating project owners: %w", err)
+	}
+	if isOwner && others == 0 {
+		return fmt.Errorf("%w: a project needs at least one owner", ErrInvalid)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"mabletask/api/tracing"
+)
+
+// ErrQueryBusy is returned by read queries that could not start because the
+// instance is running as many queries as it allows and the wait queue is full
+// or the wait timed out. Callers should retry later.
+var ErrQueryBusy = errors.New("too many concurrent queries")
+
+type projectKey struct{}
+
+// WithProject returns a context carrying the project (site) a request acts
+// for, used to apply per-project query limits.
+func WithProject(ctx context.Context, projectID string) context.Context {
+	return context.WithValue(ctx, projectKey{}, projectID)
+}
+
+// ProjectFromContext returns the project set by WithProject, or "".
+func ProjectFromContext(ctx context.Context) string {
+	projectID, _ := ctx.Value(projectKey{}).(string)
+	return projectID
+}
+
+// QueryLimiter bounds the number of concurrent read queries of an instance,
+// and optionally of each project. Queries beyond the limit wait in a queue of
+// bounded length for at most the queue timeout.
+type QueryLimiter struct {
+	slots        chan struct{}
+	perProject   int
+	queueSize    int
+	queueTimeout time.Duration
+
+	mu       sync.Mutex
+	waiting  int
+	projects map[string]chan struct{}
+}
+
+// NewQueryLimiter allows concurrency queries at once, and perProject per
+// project when positive. At most queueSize queries wait for a slot, each for
+// at most queueTimeout.
+func NewQueryLimiter(concurrency, perProject, queueSize int, queueTimeout time.Duration) *QueryLimiter {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &QueryLimiter{
+		slots:        make(chan struct{}, concurrency),
+		perProject:   perProject,
+		queueSize:    queueSize,
+		queueTimeout: queueTimeout,
+		projects:     map[string]chan struct{}{},
+	}
+}
+
+// Acquire waits for a query slot for the project in ctx. The returned release
+// function must be called once the query's results have been read.
+func (l *QueryLimiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	sems := []chan struct{}{l.slots}
+	if sem := l.projectSlots(ProjectFromContext(ctx)); sem != nil {
+		sems = append([]chan struct{}{sem}, sems...)
+	}
+
+	// Fast path: free slots, no queueing.
+	held := 0
+	for _, sem := range sems {
+		if !tryAcquire(sem) {
+			break
+		}
+		held++
+	}
+	if held == len(sems) {
+		return l.releaser(sems), nil
+	}
+
+	l.mu.Lock()
+	if l.waiting >= l.queueSize {
+		l.mu.Unlock()
+		l.release(sems[:held])
+		return nil, fmt.Errorf("%w: queue full", ErrQueryBusy)
+	}
+	l.waiting++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	for _, sem := range sems[held:] {
+		select {
+		case sem <- struct{}{}:
+			held++
+		case <-timer.C:
+			l.release(sems[:held])
+			return nil, fmt.Errorf("%w: waited %s", ErrQueryBusy, l.queueTimeout)
+		case <-ctx.Done():
+			l.release(sems[:held])
+			return nil, ctx.Err()
+		}
+	}
+	return l.releaser(sems), nil
+}
+
+func (l *QueryLimiter) projectSlots(projectID string) chan struct{} {
+	if l.perProject <= 0 || projectID == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sem, ok := l.projects[projectID]
+	if !ok {
+		sem = make(chan struct{}, l.perProject)
+		l.projects[projectID] = sem
+	}
+	return sem
+}
+
+func tryAcquire(sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *QueryLimiter) release(sems []chan struct{}) {
+	for _, sem := range sems {
+		<-sem
+	}
+}
+
+func (l *QueryLimiter) releaser(sems []chan struct{}) func() {
+	var once sync.Once
+	return func() { once.Do(func() { l.release(sems) }) }
+}
+
+// limitedRows releases the query slot when the rows are closed.
+type limitedRows struct {
+	driver.Rows
+	release func()
+}
+
+func (r *limitedRows) Close() error {
+	defer r.release()
+	return r.Rows.Close()
+}
+
+// limitedRow releases the query slot once the row has been scanned.
+type limitedRow struct {
+	driver.Row
+	release func()
+}
+
+func (r *limitedRow) Scan(dest ...any) error {
+	defer r.release()
+	return r.Row.Scan(dest...)
+}
+
+func (r *limitedRow) ScanStruct(dest any) error {
+	defer r.release()
+	return r.Row.ScanStruct(dest)
+}
+
+// errRow is a driver.Row for a query that never ran.
+type errRow struct{ err error }
+
+func (r errRow) Err() error           { return r.err }
+func (r errRow) Scan(...any) error    { return r.err }
+func (r errRow) ScanStruct(any) error { return r.err }
+
+// acquire waits for a query slot in a span of its own, so time spent queueing
+// shows in a trace apart from the query's.
+func (s *AnalyticsStore) acquire(ctx context.Context) (func(), error) {
+	_, span := tracing.Start(ctx, "QueryLimiter.Acquire")
+	release, err := s.Limiter.Acquire(ctx)
+	tracing.End(span, err)
+	return release, err
+}
+
+// query runs a read query once the limiter grants a slot, holding the slot
+// until the rows are closed.
+func (s *AnalyticsStore) query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	release, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Conn.Query(ctx, query, args...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &limitedRows{Rows: rows, release: release}, nil
+}
+
+// queryRow is query for a single row, holding the slot until it is scanned.
+func (s *AnalyticsStore) queryRow(ctx context.Context, query string, args ...any) driver.Row {
+	release, err := s.acquire(ctx)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return &limitedRow{Row: s.DB.Conn.QueryRow(ctx, query, args...), release: release}
+}
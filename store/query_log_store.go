@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// QueryLogStore keeps the audit trail of stats queries in ClickHouse.
+type QueryLogStore struct {
+	ch *database.ClickHouseClient
+}
+
+func NewQueryLogStore(chClient *database.ClickHouseClient) *QueryLogStore {
+	return &QueryLogStore{ch: chClient}
+}
+
+// QueryLogFilter narrows ListQueries; zero values are ignored.
+type QueryLogFilter struct {
+	UserID   int
+	Endpoint string
+	Start    time.Time
+	End      time.Time
+	Limit    uint64
+}
+
+func (s *QueryLogStore) Record(ctx context.Context, entry models.QueryLogEntry) error {
+	err := s.ch.Conn.Exec(ctx, `
+		INSERT INTO query_log (timestamp, user_id, user_email, endpoint, params, status, duration_ms, rows_returned, ip_address)
+		VALUES (fromUnixTimestamp64Milli(toInt64(?), 'UTC'), ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.Timestamp.UnixMilli(), int64(entry.UserID), entry.UserEmail, entry.Endpoint, entry.Params,
+		uint16(entry.Status), entry.DurationMs, uint64(entry.RowsReturned), entry.IPAddress)
+	if err != nil {
+		return fmt.Errorf("failed to record query log entry: %w", err)
+	}
+	return nil
+}
+
+// ListQueries returns logged queries, newest first.
+func (s *QueryLogStore) ListQueries(ctx context.Context, f QueryLogFilter) ([]models.QueryLogEntry, error) {
+	query := `
+		SELECT timestamp, user_id, user_email, endpoint, params, status, duration_ms, rows_returned, ip_address
+		FROM query_log
+		WHERE ` + timeRangeClause
+	args := []interface{}{f.Start.UnixMilli(), f.End.UnixMilli()}
+	if f.UserID != 0 {
+		query += " AND user_id = ?"
+		args = append(args, int64(f.UserID))
+	}
+	if f.Endpoint != "" {
+		query += " AND endpoint = ?"
+		args = append(args, f.Endpoint)
+	}
+	query += " ORDER BY timestamp DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := s.ch.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query query log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.QueryLogEntry{}
+	for rows.Next() {
+		var (
+			e      models.QueryLogEntry
+			userID int64
+			status uint16
+			nRows  uint64
+		)
+		if err := rows.Scan(&e.Timestamp, &userID, &e.UserEmail, &e.Endpoint, &e.Params, &status, &e.DurationMs, &nRows, &e.IPAddress); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for query log")
+			continue
+		}
+		e.UserID, e.Status, e.RowsReturned = int(userID), int(status), int(nRows)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for query log: %w", err)
+	}
+	return entries, nil
+}
+
+// SummarizeQueries ranks user/endpoint pairs by total query time, surfacing
+// the callers that put the most load on the cluster.
+func (s *QueryLogStore) SummarizeQueries(ctx context.Context, start, end time.Time, limit uint64) ([]models.QueryLogSummary, error) {
+	rows, err := s.ch.Conn.Query(ctx, `
+		SELECT
+			user_id,
+			any(user_email) AS user_email,
+			endpoint,
+			count() AS queries,
+			sum(duration_ms) AS total_duration_ms,
+			avg(duration_ms) AS avg_duration_ms,
+			max(duration_ms) AS max_duration_ms,
+			sum(rows_returned) AS total_rows
+		FROM query_log
+		WHERE `+timeRangeClause+`
+		GROUP BY user_id, endpoint
+		ORDER BY total_duration_ms DESC
+		LIMIT ?
+	`, start.UnixMilli(), end.UnixMilli(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize query log: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.QueryLogSummary{}
+	for rows.Next() {
+		var (
+			r      models.QueryLogSummary
+			userID int64
+		)
+		if err := rows.Scan(&userID, &r.UserEmail, &r.Endpoint, &r.Queries, &r.TotalDurationMs, &r.AvgDurationMs, &r.MaxDurationMs, &r.TotalRows); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for query log summary")
+			continue
+		}
+		r.UserID = int(userID)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for query log summary: %w", err)
+	}
+	return results, nil
+}
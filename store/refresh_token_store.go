@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// RefreshTokenStore keeps the hashes of issued refresh tokens. Tokens are
+// single-use: each refresh replaces the token by a new one of the same
+// family, and reusing a replaced token revokes the family.
+type RefreshTokenStore struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+// NewRefreshTokenStore issues refresh tokens valid for ttl.
+func NewRefreshTokenStore(db *sql.DB, ttl time.Duration) *RefreshTokenStore {
+	return &RefreshTokenStore{db: db, ttl: ttl}
+}
+
+func (s *RefreshTokenStore) insertToken(ctx context.Context, q queryRower, userID int, familyID, userAgent, ip string) (int64, *models.RefreshToken, error) {
+	token, err := utils.NewToken(utils.RefreshTokenPrefix)
+	if err != nil {
+		return 0, nil, err
+	}
+	issued := &models.RefreshToken{Token: token}
+	var id int64
+	err = q.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP + make_interval(secs => $6))
+		RETURNING id, expires_at;
+	`, userID, familyID, utils.HashToken(token), userAgent, ip, s.ttl.Seconds()).Scan(&id, &issued.ExpiresAt)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return id, issued, nil
+}
+
+// Issue starts a new token family for the user, e.g. at login.
+func (s *RefreshTokenStore) Issue(ctx context.Context, userID int, userAgent, ip string) (*models.RefreshToken, error) {
+	_, issued, err := s.insertToken(ctx, s.db, userID, uuid.NewString(), userAgent, ip)
+	return issued, err
+}
+
+// Rotate redeems token for a new refresh token of the same family and returns
+// the user it belongs to. Unknown tokens give ErrNotFound; expired, revoked
+// or already redeemed tokens give ErrRevoked, and a redeemed token revokes
+// its whole family. Only for a redeemed token is the user returned along
+// with the error, so the reuse can be reported.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token, userAgent, ip string) (int, *models.RefreshToken, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		id         int64
+		userID     int
+		familyID   string
+		expiresAt  time.Time
+		revokedAt  sql.NullTime
+		replacedBy sql.NullInt64
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, expires_at, revoked_at, replaced_by
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE;
+	`, utils.HashToken(token)).Scan(&id, &userID, &familyID, &expiresAt, &revokedAt, &replacedBy)
+	if err == sql.ErrNoRows {
+		return 0, nil, fmt.Errorf("refresh token: %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if revokedAt.Valid {
+		if replacedBy.Valid {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+				WHERE family_id = $1 AND revoked_at IS NULL;
+			`, familyID); err != nil {
+				return 0, nil, fmt.Errorf("failed to revoke refresh token family: %w", err)
+			}
+			if err := tx.Commit(); err != nil {
+				return 0, nil, fmt.Errorf("failed to commit refresh token revocation: %w", err)
+			}
+			return userID, nil, fmt.Errorf("refresh token of user %d reused, family revoked: %w", userID, ErrRevoked)
+		}
+		return 0, nil, fmt.Errorf("refresh token: %w", ErrRevoked)
+	}
+	if !time.Now().Before(expiresAt) {
+		return 0, nil, fmt.Errorf("refresh token expired: %w", ErrRevoked)
+	}
+
+	newID, issued, err := s.insertToken(ctx, tx, userID, familyID, userAgent, ip)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $2 WHERE id = $1;
+	`, id, newID); err != nil {
+		return 0, nil, fmt.Errorf("failed to replace refresh token: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit refresh token rotation: %w", err)
+	}
+	return userID, issued, nil
+}
+
+// Revoke revokes the family of token, ending that session. Unknown tokens
+// are ignored.
+func (s *RefreshTokenStore) Revoke(ctx context.Context, token string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE revoked_at IS NULL
+		  AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1);
+	`, utils.HashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+// RevokeUser revokes every refresh token of the user, ending all their
+// sessions once their access tokens expire.
+func (s *RefreshTokenStore) RevokeUser(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL;
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// DeleteExpiredBefore removes tokens that expired before cutoff. Revoked and
+// replaced tokens are kept until they expire so that their reuse is detected.
+func (s *RefreshTokenStore) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1;`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune refresh tokens: %w", err)
+	}
+	return res.RowsAffected()
+}
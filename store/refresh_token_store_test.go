@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"mabletask/api/utils"
+)
+
+const testRefreshToken = "rt_0123456789abcdef0123456789abcdef"
+
+var refreshTokenColumns = []string{"id", "user_id", "family_id", "expires_at", "revoked_at", "replaced_by"}
+
+func newTestRefreshTokenStore(t *testing.T) (*RefreshTokenStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewRefreshTokenStore(db, time.Hour), mock
+}
+
+// expectTokenLookup expects the locked lookup of testRefreshToken, answered
+// with row (nil for no row).
+func expectTokenLookup(mock sqlmock.Sqlmock, row []driver.Value) {
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows(refreshTokenColumns)
+	if row != nil {
+		rows.AddRow(row...)
+	}
+	mock.ExpectQuery(`SELECT id, user_id, family_id, expires_at, revoked_at, replaced_by\s+FROM refresh_tokens`).
+		WithArgs(utils.HashToken(testRefreshToken)).
+		WillReturnRows(rows)
+}
+
+func TestRotateIssuesTokenOfSameFamily(t *testing.T) {
+	s, mock := newTestRefreshTokenStore(t)
+	expiresAt := time.Now().Add(time.Hour)
+	expectTokenLookup(mock, []driver.Value{int64(7), 42, "family-1", expiresAt, nil, nil})
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WithArgs(42, "family-1", sqlmock.AnyArg(), "agent", "203.0.113.9", time.Hour.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "expires_at"}).AddRow(int64(8), expiresAt))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP, replaced_by = \$2 WHERE id = \$1`).
+		WithArgs(int64(7), int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	userID, issued, err := s.Rotate(context.Background(), testRefreshToken, "agent", "203.0.113.9")
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if userID != 42 {
+		t.Errorf("user = %d, want 42", userID)
+	}
+	if issued == nil || issued.Token == "" || issued.Token == testRefreshToken {
+		t.Errorf("issued token = %+v, want a new token", issued)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRotateReusedTokenRevokesFamily(t *testing.T) {
+	s, mock := newTestRefreshTokenStore(t)
+	expectTokenLookup(mock, []driver.Value{int64(7), 42, "family-1", time.Now().Add(time.Hour), time.Now(), int64(8)})
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP\s+WHERE family_id = \$1 AND revoked_at IS NULL`).
+		WithArgs("family-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	userID, issued, err := s.Rotate(context.Background(), testRefreshToken, "agent", "203.0.113.9")
+	if !errors.Is(err, ErrRevoked) {
+		t.Fatalf("err = %v, want ErrRevoked", err)
+	}
+	if userID != 42 {
+		t.Errorf("user = %d, want 42 to report the reuse", userID)
+	}
+	if issued != nil {
+		t.Errorf("issued token %+v for a reused token", issued)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRotateExpiredToken(t *testing.T) {
+	s, mock := newTestRefreshTokenStore(t)
+	expectTokenLookup(mock, []driver.Value{int64(7), 42, "family-1", time.Now().Add(-time.Minute), nil, nil})
+	mock.ExpectRollback()
+
+	userID, issued, err := s.Rotate(context.Background(), testRefreshToken, "agent", "203.0.113.9")
+	if !errors.Is(err, ErrRevoked) {
+		t.Fatalf("err = %v, want ErrRevoked", err)
+	}
+	if userID != 0 || issued != nil {
+		t.Errorf("Rotate = %d, %+v; want no user or token", userID, issued)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRotateUnknownToken(t *testing.T) {
+	s, mock := newTestRefreshTokenStore(t)
+	expectTokenLookup(mock, nil)
+	mock.ExpectRollback()
+
+	userID, issued, err := s.Rotate(context.Background(), testRefreshToken, "agent", "203.0.113.9")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if userID != 0 || issued != nil {
+		t.Errorf("Rotate = %d, %+v; want no user or token", userID, issued)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"mabletask/api/models"
+)
+
+// eventTablePattern restricts the tables replays may create or write to, so
+// that names can be interpolated into statements.
+var eventTablePattern = regexp.MustCompile(`^analytics_events_[a-z0-9_]{1,48}$`)
+
+// ValidEventTable reports whether name may be used as a replay target table.
+func ValidEventTable(name string) bool {
+	return eventTablePattern.MatchString(name)
+}
+
+func replayRangeClause(projectID string, start, end time.Time) (string, []interface{}) {
+	clause := timeRangeClause
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	if projectID != "" {
+		clause += " AND project_id = ?"
+		args = append(args, projectID)
+	}
+	return clause, args
+}
+
+// ForEachEventInRange streams the events in [start, end], optionally limited to
+// a project, ordered by visitor and then by time so stateful enrichers such as
+// sessionization see each visitor's events contiguously.
+func (s *AnalyticsStore) ForEachEventInRange(ctx context.Context, projectID string, start, end time.Time, fn func(models.AnalyticsEvent) error) error {
+	clause, args := replayRangeClause(projectID, start, end)
+	query := `SELECT ` + eventColumns + ` FROM analytics_events WHERE ` + clause +
+		` ORDER BY if(user_id != '', user_id, anonymous_id), timestamp`
+
+	rows, err := s.DB.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query events in range: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan event in range: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating events in range: %w", err)
+	}
+	return nil
+}
+
+// CreateEventTable creates table with the same schema and engine as
+// analytics_events, if it does not exist yet.
+func (s *AnalyticsStore) CreateEventTable(ctx context.Context, table string) error {
+	if !ValidEventTable(table) {
+		return fmt.Errorf("%w: table %q", ErrInvalid, table)
+	}
+	if err := s.DB.Conn.Exec(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS analytics_events", table)); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+	return nil
+}
+
+// InsertEventsInto writes events to a table created by CreateEventTable. Unlike
+// InsertAnalyticsEvents it does not count towards ingestion statistics.
+func (s *AnalyticsStore) InsertEventsInto(ctx context.Context, table string, events []models.AnalyticsEvent) error {
+	if !ValidEventTable(table) {
+		return fmt.Errorf("%w: table %q", ErrInvalid, table)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return s.insertEvents(ctx, table, events)
+}
+
+// ReplaceEventRange deletes the events in [start, end] (optionally for one
+// project) from analytics_events and copies the rows of table in their place.
+// The delete mutation is waited for, but queries running between the two
+// statements may briefly see the range empty.
+func (s *AnalyticsStore) ReplaceEventRange(ctx context.Context, table, projectID string, start, end time.Time) error {
+	if !ValidEventTable(table) {
+		return fmt.Errorf("%w: table %q", ErrInvalid, table)
+	}
+	clause, args := replayRangeClause(projectID, start, end)
+
+	syncCtx := clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{"mutations_sync": 2}))
+	if err := s.DB.Conn.Exec(syncCtx, "ALTER TABLE analytics_events DELETE WHERE "+clause, args...); err != nil {
+		return fmt.Errorf("failed to delete events in range: %w", err)
+	}
+	if err := s.DB.Conn.Exec(ctx, fmt.Sprintf("INSERT INTO analytics_events SELECT * FROM %s", table)); err != nil {
+		return fmt.Errorf("failed to copy replayed events from %s: %w", table, err)
+	}
+	return nil
+}
+
+// DropEventTable removes a table created by CreateEventTable.
+func (s *AnalyticsStore) DropEventTable(ctx context.Context, table string) error {
+	if !ValidEventTable(table) {
+		return fmt.Errorf("%w: table %q", ErrInvalid, table)
+	}
+	if err := s.DB.Conn.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", table)); err != nil {
+		return fmt.Errorf("failed to drop table %s: %w", table, err)
+	}
+	return nil
+}
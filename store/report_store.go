@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"mabletask/api/models"
+)
+
+// ReportStore keeps users' saved report definitions. Every method is scoped to
+// the owning user and the project: other users' reports, and the user's reports
+// of other projects, are not found.
+type ReportStore struct {
+	db *sql.DB
+}
+
+func NewReportStore(db *sql.DB) *ReportStore {
+	return &ReportStore{db: db}
+}
+
+const reportColumns = `id, user_id, name, definition, created_at, updated_at`
+
+func scanReport(row rowScanner) (*models.Report, error) {
+	var (
+		report     models.Report
+		definition []byte
+	)
+	if err := row.Scan(&report.ID, &report.UserID, &report.Name, &definition, &report.CreatedAt, &report.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(definition, &report.Definition); err != nil {
+		return nil, fmt.Errorf("failed to decode definition for report %d: %w", report.ID, err)
+	}
+	return &report, nil
+}
+
+func (s *ReportStore) CreateReport(ctx context.Context, projectID string, userID int, req models.ReportRequest) (*models.Report, error) {
+	definition, err := json.Marshal(req.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report definition: %w", err)
+	}
+
+	report, err := scanReport(s.db.QueryRowContext(ctx, `
+		INSERT INTO reports (project_id, user_id, name, definition)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+reportColumns+`;
+	`, projectID, userID, req.Name, definition))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report: %w", err)
+	}
+	return report, nil
+}
+
+// ListReports returns the user's reports of the project, oldest first.
+func (s *ReportStore) ListReports(ctx context.Context, projectID string, userID int) ([]models.Report, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+reportColumns+` FROM reports WHERE user_id = $1 AND project_id = $2 ORDER BY id;`, userID, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		reports = append(reports, *report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reports: %w", err)
+	}
+	return reports, nil
+}
+
+func (s *ReportStore) GetReport(ctx context.Context, projectID string, userID, id int) (*models.Report, error) {
+	report, err := scanReport(s.db.QueryRowContext(ctx, `SELECT `+reportColumns+` FROM reports WHERE id = $1 AND user_id = $2 AND project_id = $3;`, id, userID, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("report %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report: %w", err)
+	}
+	return report, nil
+}
+
+func (s *ReportStore) UpdateReport(ctx context.Context, projectID string, userID, id int, req models.ReportRequest) (*models.Report, error) {
+	definition, err := json.Marshal(req.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report definition: %w", err)
+	}
+
+	report, err := scanReport(s.db.QueryRowContext(ctx, `
+		UPDATE reports
+		SET name = $4, definition = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND project_id = $3
+		RETURNING `+reportColumns+`;
+	`, id, userID, projectID, req.Name, definition))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("report %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update report: %w", err)
+	}
+	return report, nil
+}
+
+func (s *ReportStore) DeleteReport(ctx context.Context, projectID string, userID, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM reports WHERE id = $1 AND user_id = $2 AND project_id = $3;`, id, userID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete report: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("report %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// DefaultRetentionPeriods is how many periods after the cohort's own are
+// reported when the caller does not say.
+const DefaultRetentionPeriods = 8
+
+// GetRetention groups the visitors first seen in the range into cohorts by
+// the Day, Week or Month of their first event, and counts how many of each
+// cohort were active in each of the following periods, up to periods after
+// the cohort's own or the end of the range. First seen considers all earlier
+// events, so visitors active before the range belong to no cohort. Filters
+// restrict which events count, for first seen and for returning alike.
+func (s *AnalyticsStore) GetRetention(ctx context.Context, interval string, periods int, start, end time.Time, filters EventFilters) ([]models.RetentionCohort, error) {
+	switch interval {
+	case "Day", "Week", "Month":
+	default:
+		return nil, fmt.Errorf("%w: retention interval %q (one of Day, Week, Month)", ErrInvalid, interval)
+	}
+	if periods <= 0 {
+		periods = DefaultRetentionPeriods
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+	args = append(args, end.UnixMilli())
+	args = append(args, filterArgs...)
+	args = append(args, start.UnixMilli())
+
+	query := fmt.Sprintf(`
+		SELECT cohort, period, uniqExact(visitor) AS visitors
+		FROM (
+			SELECT %[1]s AS visitor, %[2]s AS period
+			FROM analytics_events
+			WHERE %[3]s%[4]s
+			GROUP BY visitor, period
+		) AS activity
+		INNER JOIN (
+			SELECT %[1]s AS visitor, toStartOf%[5]s(min(timestamp)) AS cohort
+			FROM analytics_events
+			WHERE timestamp <= fromUnixTimestamp64Milli(toInt64(?), 'UTC')%[4]s
+			GROUP BY visitor
+			HAVING min(timestamp) >= fromUnixTimestamp64Milli(toInt64(?), 'UTC')
+		) AS cohorts USING visitor
+		GROUP BY cohort, period
+		ORDER BY cohort, period
+	`, visitorExpr, timeBucket(interval), timeRangeClause, filterClause, interval)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention: %w", err)
+	}
+	defer rows.Close()
+
+	var cohorts []models.RetentionCohort
+	for rows.Next() {
+		var cohort, period time.Time
+		var visitors uint64
+		if err := rows.Scan(&cohort, &period, &visitors); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for retention")
+			continue
+		}
+		if len(cohorts) == 0 || !cohorts[len(cohorts)-1].Cohort.Equal(cohort) {
+			cohorts = append(cohorts, models.RetentionCohort{
+				Cohort:   cohort,
+				Retained: make([]uint64, periodsBetween(interval, cohort, end)+1),
+			})
+		}
+		current := &cohorts[len(cohorts)-1]
+		offset := periodsBetween(interval, cohort, period)
+		if offset == 0 {
+			current.Size = visitors
+		}
+		if offset < len(current.Retained) && offset <= periods {
+			current.Retained[offset] = visitors
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for retention: %w", err)
+	}
+
+	for i := range cohorts {
+		cohort := &cohorts[i]
+		if len(cohort.Retained) > periods+1 {
+			cohort.Retained = cohort.Retained[:periods+1]
+		}
+		cohort.Retention = make([]float64, len(cohort.Retained))
+		for j, retained := range cohort.Retained {
+			if cohort.Size > 0 {
+				cohort.Retention[j] = float64(retained) / float64(cohort.Size)
+			}
+		}
+	}
+	return cohorts, nil
+}
+
+// periodsBetween returns how many whole Day, Week or Month periods lie between
+// the period starting at from and the one containing to.
+func periodsBetween(interval string, from, to time.Time) int {
+	from, to = from.UTC(), to.UTC()
+	switch interval {
+	case "Month":
+		return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	case "Week":
+		return int(to.Sub(from).Hours()/24) / 7
+	default:
+		return int(to.Sub(from).Hours() / 24)
+	}
+}
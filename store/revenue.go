@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// revenueColumns holds the typed revenue columns of a purchase event, empty
+// for events of other types.
+type revenueColumns struct {
+	revenue  float64
+	currency string
+}
+
+func newRevenueColumns(event *models.AnalyticsEvent) revenueColumns {
+	if event.EventType != models.EventTypePurchase {
+		return revenueColumns{}
+	}
+	p, err := event.PurchasePayload()
+	if err != nil {
+		return revenueColumns{}
+	}
+	return revenueColumns{revenue: p.Revenue, currency: p.Currency}
+}
+
+// DailyRevenue is the revenue of one day in one original currency.
+type DailyRevenue struct {
+	Day time.Time
+	models.RevenueAmount
+}
+
+// GetDailyRevenue sums purchase revenue per UTC day and original currency.
+func (s *AnalyticsStore) GetDailyRevenue(ctx context.Context, start, end time.Time, filters EventFilters) ([]DailyRevenue, error) {
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT toDate(timestamp) AS day, currency, `+sampledSum("revenue")+`, `+sampledCount+`
+		FROM analytics_events
+		WHERE event_type = '%s' AND %s%s
+		GROUP BY day, currency
+		ORDER BY day, currency
+	`, models.EventTypePurchase, timeRangeClause, filterClause)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily revenue: %w", err)
+	}
+	defer rows.Close()
+
+	results := []DailyRevenue{}
+	for rows.Next() {
+		var r DailyRevenue
+		if err := rows.Scan(&r.Day, &r.Currency, &r.Revenue, &r.Purchases); err != nil {
+			return nil, fmt.Errorf("failed to scan daily revenue: %w", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating daily revenue: %w", err)
+	}
+	return results, nil
+}
+
+// RevenueReport converts daily revenue to base at each day's rates and sums
+// it per Day, Week or Month bucket, keeping the original amounts per currency.
+func RevenueReport(daily []DailyRevenue, rates *RateTable, base, interval string) (*models.RevenueReport, error) {
+	switch interval {
+	case "Day", "Week", "Month":
+	default:
+		return nil, fmt.Errorf("%w: interval %q must be Day, Week or Month", ErrInvalid, interval)
+	}
+
+	report := &models.RevenueReport{BaseCurrency: base, Interval: interval, Series: []models.RevenuePoint{}}
+	points := map[time.Time]*models.RevenuePoint{}
+	amounts := map[time.Time]map[string]*models.RevenueAmount{}
+	converted := map[time.Time]uint64{} // purchases included in Revenue
+	unconverted := map[string]bool{}
+	for _, d := range daily {
+		bucket := calendarBucket(d.Day, interval)
+		p, ok := points[bucket]
+		if !ok {
+			p = &models.RevenuePoint{Time: bucket}
+			points[bucket] = p
+			amounts[bucket] = map[string]*models.RevenueAmount{}
+		}
+		p.Purchases += d.Purchases
+		if amount, ok := rates.Convert(d.Revenue, d.Currency, base, d.Day); ok {
+			p.Revenue += amount
+			converted[bucket] += d.Purchases
+		} else {
+			unconverted[d.Currency] = true
+		}
+		a, ok := amounts[bucket][d.Currency]
+		if !ok {
+			a = &models.RevenueAmount{Currency: d.Currency}
+			amounts[bucket][d.Currency] = a
+		}
+		a.Revenue += d.Revenue
+		a.Purchases += d.Purchases
+	}
+
+	var totalConverted uint64
+	for bucket, p := range points {
+		if n := converted[bucket]; n > 0 {
+			p.AverageOrderValue = p.Revenue / float64(n)
+		}
+		report.TotalRevenue += p.Revenue
+		report.Purchases += p.Purchases
+		totalConverted += converted[bucket]
+		for _, a := range amounts[bucket] {
+			p.ByCurrency = append(p.ByCurrency, *a)
+		}
+		sort.Slice(p.ByCurrency, func(i, j int) bool { return p.ByCurrency[i].Currency < p.ByCurrency[j].Currency })
+		report.Series = append(report.Series, *p)
+	}
+	sort.Slice(report.Series, func(i, j int) bool { return report.Series[i].Time.Before(report.Series[j].Time) })
+	if totalConverted > 0 {
+		report.AverageOrderValue = report.TotalRevenue / float64(totalConverted)
+	}
+	for currency := range unconverted {
+		report.UnconvertedCurrencies = append(report.UnconvertedCurrencies, currency)
+	}
+	sort.Strings(report.UnconvertedCurrencies)
+	return report, nil
+}
+
+// Currencies returns the distinct currencies of daily revenue.
+func Currencies(daily []DailyRevenue) []string {
+	seen := map[string]bool{}
+	var currencies []string
+	for _, d := range daily {
+		if !seen[d.Currency] {
+			seen[d.Currency] = true
+			currencies = append(currencies, d.Currency)
+		}
+	}
+	return currencies
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sync"
+
+	"mabletask/api/models"
+)
+
+// SamplingStore manages per-project ingestion sampling rules. PostgreSQL
+// holds the rules; an in-memory copy serves the ingestion path.
+type SamplingStore struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	rates map[string]map[string]float64 // project_id -> event_type ("" for all) -> rate
+}
+
+func NewSamplingStore(db *sql.DB) *SamplingStore {
+	return &SamplingStore{db: db, rates: map[string]map[string]float64{}}
+}
+
+// Refresh reloads the rules used by Rate.
+func (s *SamplingStore) Refresh(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT project_id, event_type, sample_rate FROM sampling_rules;`)
+	if err != nil {
+		return fmt.Errorf("failed to load sampling rules: %w", err)
+	}
+	defer rows.Close()
+
+	rates := map[string]map[string]float64{}
+	for rows.Next() {
+		var (
+			projectID, eventType string
+			rate                 float64
+		)
+		if err := rows.Scan(&projectID, &eventType, &rate); err != nil {
+			return fmt.Errorf("failed to scan sampling rule: %w", err)
+		}
+		if rates[projectID] == nil {
+			rates[projectID] = map[string]float64{}
+		}
+		rates[projectID][eventType] = rate
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating sampling rules: %w", err)
+	}
+
+	s.mu.Lock()
+	s.rates = rates
+	s.mu.Unlock()
+	return nil
+}
+
+// Rate returns the share of the project's events of eventType that are kept:
+// the rate of the event type, else the project-wide rate, else 1.
+func (s *SamplingStore) Rate(projectID, eventType string) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rates := s.rates[projectID]
+	if rate, ok := rates[eventType]; ok {
+		return rate
+	}
+	if rate, ok := rates[""]; ok {
+		return rate
+	}
+	return 1
+}
+
+// Sample sets the SampleRate of an event of projectID and reports whether it
+// is kept. The decision is made per visitor, by anonymous, user or session ID,
+// so that sampled visitors keep their whole sessions; events without any are
+// sampled at random.
+func (s *SamplingStore) Sample(projectID string, event *models.AnalyticsEvent) bool {
+	event.SampleRate = s.Rate(projectID, event.EventType)
+	if event.SampleRate >= 1 {
+		return true
+	}
+
+	var key string
+	switch {
+	case event.AnonymousID != "":
+		key = "a:" + event.AnonymousID
+	case event.UserID != "":
+		key = "u:" + event.UserID
+	case event.SessionID != "":
+		key = "s:" + event.SessionID
+	default:
+		return rand.Float64() < event.SampleRate
+	}
+	h := fnv.New64a()
+	h.Write([]byte(projectID + ":" + key))
+	return float64(h.Sum64())/math.MaxUint64 < event.SampleRate
+}
+
+const samplingRuleColumns = `project_id, event_type, sample_rate, updated_by, updated_at`
+
+func scanSamplingRule(row rowScanner) (*models.SamplingRule, error) {
+	var (
+		rule      models.SamplingRule
+		updatedBy sql.NullInt64
+	)
+	if err := row.Scan(&rule.ProjectID, &rule.EventType, &rule.SampleRate, &updatedBy, &rule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if updatedBy.Valid {
+		id := int(updatedBy.Int64)
+		rule.UpdatedBy = &id
+	}
+	return &rule, nil
+}
+
+// ListRules returns the sampling rules of a project.
+func (s *SamplingStore) ListRules(ctx context.Context, projectID string) ([]models.SamplingRule, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+samplingRuleColumns+` FROM sampling_rules WHERE project_id = $1 ORDER BY event_type;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sampling rules of project %s: %w", projectID, err)
+	}
+	defer rows.Close()
+
+	rules := []models.SamplingRule{}
+	for rows.Next() {
+		rule, err := scanSamplingRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sampling rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sampling rules: %w", err)
+	}
+	return rules, nil
+}
+
+// SetRule creates or replaces the project's rule for req.EventType and
+// applies it on this instance right away; others pick it up on Refresh.
+func (s *SamplingStore) SetRule(ctx context.Context, projectID string, req models.SamplingRuleRequest, updatedBy int) (*models.SamplingRule, error) {
+	var updater interface{}
+	if updatedBy != 0 {
+		updater = updatedBy
+	}
+	rule, err := scanSamplingRule(s.db.QueryRowContext(ctx, `
+		INSERT INTO sampling_rules (project_id, event_type, sample_rate, updated_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id, event_type) DO UPDATE
+		SET sample_rate = EXCLUDED.sample_rate,
+		    updated_by = EXCLUDED.updated_by,
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING `+samplingRuleColumns+`;
+	`, projectID, req.EventType, req.SampleRate, updater))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set sampling rule for project %s: %w", projectID, err)
+	}
+
+	s.mu.Lock()
+	if s.rates[projectID] == nil {
+		s.rates[projectID] = map[string]float64{}
+	}
+	s.rates[projectID][rule.EventType] = rule.SampleRate
+	s.mu.Unlock()
+	return rule, nil
+}
+
+// DeleteRule removes the project's rule for eventType ("" for the
+// project-wide rule).
+func (s *SamplingStore) DeleteRule(ctx context.Context, projectID, eventType string) error {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM sampling_rules WHERE project_id = $1 AND event_type = $2;`, projectID, eventType)
+	if err != nil {
+		return fmt.Errorf("failed to delete sampling rule for project %s: %w", projectID, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	s.mu.Lock()
+	delete(s.rates[projectID], eventType)
+	s.mu.Unlock()
+	return nil
+}
+
+// sampledCount counts events scaled back up by their sample rate, in place
+// of count().
+const sampledCount = "toUInt64(round(sum(1 / sample_rate)))"
+
+// sampledSum sums expr over events scaled back up by their sample rate, in
+// place of sum(expr).
+func sampledSum(expr string) string {
+	return fmt.Sprintf("sum((%s) / sample_rate)", expr)
+}
+
+// sampledWeight aggregates the events of an entity such as a session, cart or
+// visitor into the weight that entity stands for. Visitors are sampled as a
+// whole, so an entity was kept at the largest sample rate among its events.
+// With different rates per event type this is an estimate.
+const sampledWeight = "min(1 / sample_rate)"
+
+// sampledWeightIf is sampledWeight over the events matching cond, 0 without
+// any.
+func sampledWeightIf(cond string) string {
+	return fmt.Sprintf("minIf(1 / sample_rate, %s)", cond)
+}
+
+// sampledSequenceWeight is the weight of an entity counted by a combination of
+// its events, such as a funnel's steps or a cart and its purchase: the events
+// are only all kept for visitors sampled at the smallest of their rates.
+const sampledSequenceWeight = "max(1 / sample_rate)"
+
+// weightedCount counts rows carrying a weight column computed with
+// sampledWeight, in place of count().
+const weightedCount = "toUInt64(round(sum(weight)))"
+
+// weightedCountIf counts the rows matching cond like weightedCount, in place
+// of countIf(cond).
+func weightedCountIf(cond string) string {
+	return fmt.Sprintf("toUInt64(round(sumIf(weight, %s)))", cond)
+}
+
+// sampleRate returns the sample_rate column of an event.
+func sampleRate(event *models.AnalyticsEvent) float64 {
+	if event.SampleRate <= 0 || event.SampleRate > 1 {
+		return 1
+	}
+	return event.SampleRate
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// ScheduleStore records the last run of each scheduled task.
+type ScheduleStore struct {
+	db *sql.DB
+}
+
+func NewScheduleStore(db *sql.DB) *ScheduleStore {
+	return &ScheduleStore{db: db}
+}
+
+func (s *ScheduleStore) RecordStart(ctx context.Context, name, instance string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedule_runs (name, instance, status, started_at, finished_at)
+		VALUES ($1, $2, 'running', CURRENT_TIMESTAMP, NULL)
+		ON CONFLICT (name) DO UPDATE
+		SET instance = EXCLUDED.instance, status = 'running', error = '',
+		    started_at = EXCLUDED.started_at, finished_at = NULL;
+	`, name, instance)
+	if err != nil {
+		return fmt.Errorf("failed to record start of %s: %w", name, err)
+	}
+	return nil
+}
+
+// RecordFinish stores the outcome of a run started with RecordStart.
+func (s *ScheduleStore) RecordFinish(ctx context.Context, name string, runErr error, duration time.Duration) error {
+	status, message, failed := models.ScheduleRunSucceeded, "", 0
+	if runErr != nil {
+		status, message, failed = models.ScheduleRunFailed, runErr.Error(), 1
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE schedule_runs
+		SET status = $2, error = $3, runs = runs + 1, failures = failures + $4,
+		    duration_ms = $5, finished_at = CURRENT_TIMESTAMP
+		WHERE name = $1;
+	`, name, status, message, failed, duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to record finish of %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListRuns returns the last run of every task that ran at least once, by name.
+func (s *ScheduleStore) ListRuns(ctx context.Context) (map[string]models.ScheduleRun, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, instance, status, error, runs, failures, duration_ms, started_at, finished_at
+		FROM schedule_runs;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := map[string]models.ScheduleRun{}
+	for rows.Next() {
+		var (
+			name       string
+			run        models.ScheduleRun
+			startedAt  sql.NullTime
+			finishedAt sql.NullTime
+		)
+		err := rows.Scan(&name, &run.Instance, &run.Status, &run.Error, &run.Runs, &run.Failures, &run.DurationMs, &startedAt, &finishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule run: %w", err)
+		}
+		if startedAt.Valid {
+			run.StartedAt = &startedAt.Time
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs[name] = run
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schedule runs: %w", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionStore maps opaque session ids to user ids. Sessions expire ttl after
+// they are created.
+type SessionStore interface {
+	Create(ctx context.Context, userID string) (string, error)
+	// Get returns the user of a session; unknown or expired sessions give
+	// ErrNotFound.
+	Get(ctx context.Context, sessionID string) (string, error)
+	Delete(ctx context.Context, sessionID string) error
+	// Prune removes sessions created before cutoff and returns how many were
+	// removed.
+	Prune(ctx context.Context, cutoff time.Time) (int64, error)
+	// Shared reports whether the sessions are visible to every instance.
+	Shared() bool
+}
+
+// NewSessionStore keeps sessions in Redis when a client is given, and in
+// process memory otherwise.
+func NewSessionStore(client *redis.Client, ttl time.Duration) SessionStore {
+	if client != nil {
+		return &RedisSessionStore{client: client, ttl: ttl}
+	}
+	return NewMemorySessionStore(ttl)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// RedisSessionStore keeps sessions in Redis, so they survive restarts and are
+// shared by all replicas. Redis expires them itself.
+type RedisSessionStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+const sessionKeyPrefix = "session:"
+
+func (s *RedisSessionStore) Create(ctx context.Context, userID string) (string, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	if err := s.client.Set(ctx, sessionKeyPrefix+id, userID, s.ttl).Err(); err != nil {
+		return "", fmt.Errorf("failed to store session: %w", err)
+	}
+	return id, nil
+}
+
+func (s *RedisSessionStore) Get(ctx context.Context, sessionID string) (string, error) {
+	userID, err := s.client.Get(ctx, sessionKeyPrefix+sessionID).Result()
+	if err == redis.Nil {
+		return "", fmt.Errorf("session: %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get session: %w", err)
+	}
+	return userID, nil
+}
+
+func (s *RedisSessionStore) Delete(ctx context.Context, sessionID string) error {
+	if err := s.client.Del(ctx, sessionKeyPrefix+sessionID).Err(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// Prune is a no-op: Redis expires sessions on its own.
+func (s *RedisSessionStore) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *RedisSessionStore) Shared() bool { return true }
+
+// MemorySessionStore keeps sessions in process memory. They are lost on
+// restart and each replica has its own, so it only suits development and
+// single-instance deployments.
+type MemorySessionStore struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	userID    string
+	createdAt time.Time
+}
+
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{ttl: ttl, sessions: map[string]memorySession{}}
+}
+
+func (s *MemorySessionStore) Create(ctx context.Context, userID string) (string, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.sessions[id] = memorySession{userID: userID, createdAt: time.Now()}
+	s.mu.Unlock()
+	return id, nil
+}
+
+func (s *MemorySessionStore) Get(ctx context.Context, sessionID string) (string, error) {
+	s.mu.Lock()
+	session, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !ok || time.Since(session.createdAt) >= s.ttl {
+		return "", fmt.Errorf("session: %w", ErrNotFound)
+	}
+	return session.userID, nil
+}
+
+func (s *MemorySessionStore) Delete(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	delete(s.sessions, sessionID)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemorySessionStore) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	for id, session := range s.sessions {
+		if session.createdAt.Before(cutoff) {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *MemorySessionStore) Shared() bool { return false }
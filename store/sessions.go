@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// sessionsSubquery aggregates the events in the range into one row per
+// session with its start, duration in milliseconds, event count and sampling
+// weight. Events without a session are left out.
+const sessionsSubquery = `
+			SELECT session_id,
+			       min(timestamp) AS started,
+			       dateDiff('millisecond', min(timestamp), max(timestamp)) AS duration_ms,
+			       ` + sampledCount + ` AS events,
+			       ` + sampledWeight + ` AS weight
+			FROM analytics_events
+			WHERE session_id != '' AND ` + timeRangeClause + `%s
+			GROUP BY session_id`
+
+// GetSessionsOverTime returns the number of sessions started in each interval
+// of the range, with their average duration and events per session.
+func (s *AnalyticsStore) GetSessionsOverTime(ctx context.Context, interval string, start, end time.Time, filters EventFilters) ([]models.SessionMetrics, error) {
+	if !utils.IsValidInterval(interval) {
+		return nil, fmt.Errorf("%w: interval %q", ErrInvalid, interval)
+	}
+
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT toStartOf%s(started) AS time_bucket, `+weightedCount+` AS sessions,
+		       avgWeighted(duration_ms, weight) / 1000 AS avg_duration, avgWeighted(events, weight) AS events_per_session
+		FROM (%s)
+		GROUP BY time_bucket
+		ORDER BY time_bucket ASC
+	`, interval, fmt.Sprintf(sessionsSubquery, filterClause))
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions over time: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.SessionMetrics
+	for rows.Next() {
+		var bucket time.Time
+		var m models.SessionMetrics
+		if err := rows.Scan(&bucket, &m.Sessions, &m.AvgDurationSeconds, &m.EventsPerSession); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Error scanning row for sessions over time")
+			continue
+		}
+		m.Time = &bucket
+		results = append(results, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows for sessions over time: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetSessionSummary returns the sessions with events in the range, their
+// average duration and events per session.
+func (s *AnalyticsStore) GetSessionSummary(ctx context.Context, start, end time.Time, filters EventFilters) (*models.SessionMetrics, error) {
+	filterClause, filterArgs := filters.clause()
+	args := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	args = append(args, filterArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT `+weightedCount+` AS sessions,
+		       ifNotFinite(avgWeighted(duration_ms, weight) / 1000, 0) AS avg_duration,
+		       ifNotFinite(avgWeighted(events, weight), 0) AS events_per_session
+		FROM (%s)
+	`, fmt.Sprintf(sessionsSubquery, filterClause))
+
+	var m models.SessionMetrics
+	if err := s.queryRow(ctx, query, args...).Scan(&m.Sessions, &m.AvgDurationSeconds, &m.EventsPerSession); err != nil {
+		return nil, fmt.Errorf("failed to query session summary: %w", err)
+	}
+	return &m, nil
+}
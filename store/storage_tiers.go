@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"mabletask/api/models"
+)
+
+type storageDisk struct {
+	diskType    string
+	free, total uint64
+}
+
+type storageVolume struct {
+	name  string
+	disks []string
+}
+
+// GetStorageTiers reports, for every MergeTree table in the current database,
+// how much of it lives on each volume and disk of its storage policy. Volumes
+// are listed in policy order, hot first; empty tiers are included.
+func (s *TableHealthStore) GetStorageTiers(ctx context.Context) ([]models.TableStorage, error) {
+	disks := map[string]storageDisk{}
+	rows, err := s.ch.Conn.Query(ctx, `SELECT name, toString(type), free_space, total_space FROM system.disks`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.disks: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		var d storageDisk
+		if err := rows.Scan(&name, &d.diskType, &d.free, &d.total); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan system.disks: %w", err)
+		}
+		disks[name] = d
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system.disks: %w", err)
+	}
+
+	policies := map[string][]storageVolume{}
+	rows, err = s.ch.Conn.Query(ctx, `
+		SELECT policy_name, volume_name, disks
+		FROM system.storage_policies
+		ORDER BY policy_name, volume_priority
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.storage_policies: %w", err)
+	}
+	for rows.Next() {
+		var policy string
+		var v storageVolume
+		if err := rows.Scan(&policy, &v.name, &v.disks); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan system.storage_policies: %w", err)
+		}
+		policies[policy] = append(policies[policy], v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system.storage_policies: %w", err)
+	}
+
+	type partStats struct{ parts, rows, bytes uint64 }
+	stats := map[[2]string]partStats{}
+	rows, err = s.ch.Conn.Query(ctx, `
+		SELECT table, disk_name, count(), sum(rows), sum(bytes_on_disk)
+		FROM system.parts
+		WHERE active AND database = currentDatabase()
+		GROUP BY table, disk_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.parts: %w", err)
+	}
+	for rows.Next() {
+		var table, disk string
+		var p partStats
+		if err := rows.Scan(&table, &disk, &p.parts, &p.rows, &p.bytes); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan system.parts: %w", err)
+		}
+		stats[[2]string{table, disk}] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system.parts: %w", err)
+	}
+
+	rows, err = s.ch.Conn.Query(ctx, `
+		SELECT name, storage_policy
+		FROM system.tables
+		WHERE database = currentDatabase() AND engine LIKE '%MergeTree'
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []models.TableStorage{}
+	for rows.Next() {
+		t := models.TableStorage{Tiers: []models.StorageTier{}}
+		if err := rows.Scan(&t.Table, &t.StoragePolicy); err != nil {
+			return nil, fmt.Errorf("failed to scan system.tables: %w", err)
+		}
+		for _, v := range policies[t.StoragePolicy] {
+			for _, disk := range v.disks {
+				p := stats[[2]string{t.Table, disk}]
+				d := disks[disk]
+				t.Tiers = append(t.Tiers, models.StorageTier{
+					Volume:      v.name,
+					Disk:        disk,
+					DiskType:    d.diskType,
+					Parts:       p.parts,
+					Rows:        p.rows,
+					BytesOnDisk: p.bytes,
+					FreeSpace:   d.free,
+					TotalSpace:  d.total,
+				})
+			}
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system.tables: %w", err)
+	}
+	return tables, nil
+}
@@ -0,0 +1,311 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"mabletask/api/database"
+	"mabletask/api/logging"
+	"mabletask/api/models"
+)
+
+// SuppressionStore manages the opt-out list. PostgreSQL holds the auditable
+// record, ClickHouse holds a mirror used to exclude suppressed subjects from
+// queries, and an in-memory cache serves lookups on the ingestion path.
+type SuppressionStore struct {
+	db *sql.DB
+	ch *database.ClickHouseClient
+
+	mu    sync.RWMutex
+	cache map[string]string // project_id + ":" + subject_type + ":" + subject_id -> mode
+}
+
+func NewSuppressionStore(db *sql.DB, chClient *database.ClickHouseClient) *SuppressionStore {
+	return &SuppressionStore{db: db, ch: chClient, cache: map[string]string{}}
+}
+
+func suppressionKey(projectID, subjectType, subjectID string) string {
+	return projectID + ":" + subjectType + ":" + subjectID
+}
+
+type suppressionSubject struct {
+	projectID, subjectType, subjectID string
+}
+
+// Refresh reloads the in-memory cache from the active suppressions in
+// PostgreSQL, then reconciles the ClickHouse mirror with them. Creating or
+// removing a suppression mirrors it only after committing, so a failed or
+// racing mirror write is repaired here.
+func (s *SuppressionStore) Refresh(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT project_id, subject_type, subject_id, mode
+		FROM suppressions
+		WHERE removed_at IS NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	cache := map[string]string{}
+	active := map[suppressionSubject]bool{}
+	for rows.Next() {
+		var sub suppressionSubject
+		var mode string
+		if err := rows.Scan(&sub.projectID, &sub.subjectType, &sub.subjectID, &mode); err != nil {
+			return fmt.Errorf("failed to scan suppression: %w", err)
+		}
+		cache[suppressionKey(sub.projectID, sub.subjectType, sub.subjectID)] = mode
+		active[sub] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating suppressions: %w", err)
+	}
+
+	s.mu.Lock()
+	s.cache = cache
+	s.mu.Unlock()
+
+	return s.reconcileMirror(ctx, active)
+}
+
+// reconcileMirror activates the subjects of active that are not active in the
+// ClickHouse mirror and deactivates those that no longer are.
+func (s *SuppressionStore) reconcileMirror(ctx context.Context, active map[suppressionSubject]bool) error {
+	rows, err := s.ch.Conn.Query(ctx, `
+		SELECT project_id, subject_type, subject_id
+		FROM suppressed_ids FINAL
+		WHERE active = 1
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load suppression mirror: %w", err)
+	}
+	defer rows.Close()
+
+	changes := map[suppressionSubject]bool{}
+	for sub := range active {
+		changes[sub] = true
+	}
+	for rows.Next() {
+		var sub suppressionSubject
+		if err := rows.Scan(&sub.projectID, &sub.subjectType, &sub.subjectID); err != nil {
+			return fmt.Errorf("failed to scan mirrored suppression: %w", err)
+		}
+		if active[sub] {
+			delete(changes, sub)
+		} else {
+			changes[sub] = false
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating mirrored suppressions: %w", err)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	batch, err := s.ch.Conn.PrepareBatch(ctx, `INSERT INTO suppressed_ids (project_id, subject_type, subject_id, active, updated_at)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare suppression mirror insert: %w", err)
+	}
+	defer batch.Abort()
+	updatedAt := time.Now().UTC()
+	for sub, isActive := range changes {
+		var activeFlag uint8
+		if isActive {
+			activeFlag = 1
+		}
+		if err := batch.Append(sub.projectID, sub.subjectType, sub.subjectID, activeFlag, updatedAt); err != nil {
+			return fmt.Errorf("failed to append mirrored suppression: %w", err)
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to reconcile suppression mirror: %w", err)
+	}
+	logging.Ctx(ctx).Info().Msgf("Reconciled %d suppressions with the ClickHouse mirror", len(changes))
+	return nil
+}
+
+// Lookup reports the suppression mode for an event's identifiers within its
+// project, checking the user ID before the anonymous ID.
+func (s *SuppressionStore) Lookup(projectID, userID, anonymousID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if userID != "" {
+		if mode, ok := s.cache[suppressionKey(projectID, models.SuppressionTypeUser, userID)]; ok {
+			return mode, true
+		}
+	}
+	if anonymousID != "" {
+		if mode, ok := s.cache[suppressionKey(projectID, models.SuppressionTypeAnonymous, anonymousID)]; ok {
+			return mode, true
+		}
+	}
+	return "", false
+}
+
+func (s *SuppressionStore) mirror(ctx context.Context, projectID, subjectType, subjectID string, active bool) error {
+	var activeFlag uint8
+	if active {
+		activeFlag = 1
+	}
+	err := s.ch.Conn.Exec(ctx, `
+		INSERT INTO suppressed_ids (project_id, subject_type, subject_id, active, updated_at)
+		VALUES (?, ?, ?, ?, fromUnixTimestamp64Milli(toInt64(?), 'UTC'))
+	`, projectID, subjectType, subjectID, activeFlag, time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to mirror suppression to ClickHouse: %w", err)
+	}
+	return nil
+}
+
+func (s *SuppressionStore) CreateSuppression(ctx context.Context, projectID string, createdBy int, req models.SuppressionRequest) (*models.Suppression, error) {
+	if req.Mode == "" {
+		req.Mode = models.SuppressionModeDrop
+	}
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	suppression := &models.Suppression{
+		Type:      req.Type,
+		SubjectID: req.SubjectID,
+		Mode:      req.Mode,
+		Reason:    req.Reason,
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO suppressions (project_id, subject_type, subject_id, mode, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at;
+	`, projectID, req.Type, req.SubjectID, req.Mode, req.Reason, creator).Scan(&suppression.ID, &suppression.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, fmt.Errorf("suppression for %s '%s': %w", req.Type, req.SubjectID, ErrAlreadyExists)
+		}
+		return nil, fmt.Errorf("failed to create suppression: %w", err)
+	}
+	if createdBy != 0 {
+		suppression.CreatedBy = &createdBy
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit suppression: %w", err)
+	}
+
+	s.mu.Lock()
+	s.cache[suppressionKey(projectID, req.Type, req.SubjectID)] = req.Mode
+	s.mu.Unlock()
+
+	if err := s.mirror(ctx, projectID, req.Type, req.SubjectID, true); err != nil {
+		logging.Ctx(ctx).Warn().Err(err).Msgf("Suppression %d not mirrored yet; the next refresh will", suppression.ID)
+	}
+
+	logging.Ctx(ctx).Info().Msgf("Suppression %d created for %s '%s' (mode=%s)", suppression.ID, req.Type, req.SubjectID, req.Mode)
+	return suppression, nil
+}
+
+func (s *SuppressionStore) ListSuppressions(ctx context.Context, projectID string, includeRemoved bool) ([]models.Suppression, error) {
+	query := `
+		SELECT id, subject_type, subject_id, mode, reason, created_by, created_at, removed_by, removed_at
+		FROM suppressions
+		WHERE project_id = $1
+	`
+	if !includeRemoved {
+		query += ` AND removed_at IS NULL`
+	}
+	query += ` ORDER BY id;`
+
+	rows, err := s.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	var suppressions []models.Suppression
+	for rows.Next() {
+		var (
+			sup       models.Suppression
+			createdBy sql.NullInt64
+			removedBy sql.NullInt64
+			removedAt sql.NullTime
+		)
+		if err := rows.Scan(&sup.ID, &sup.Type, &sup.SubjectID, &sup.Mode, &sup.Reason, &createdBy, &sup.CreatedAt, &removedBy, &removedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan suppression: %w", err)
+		}
+		if createdBy.Valid {
+			id := int(createdBy.Int64)
+			sup.CreatedBy = &id
+		}
+		if removedBy.Valid {
+			id := int(removedBy.Int64)
+			sup.RemovedBy = &id
+		}
+		if removedAt.Valid {
+			sup.RemovedAt = &removedAt.Time
+		}
+		suppressions = append(suppressions, sup)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating suppressions: %w", err)
+	}
+
+	return suppressions, nil
+}
+
+// RemoveSuppression lifts an active suppression, keeping the row for auditing.
+func (s *SuppressionStore) RemoveSuppression(ctx context.Context, projectID string, id, removedBy int) error {
+	var remover interface{}
+	if removedBy != 0 {
+		remover = removedBy
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var subjectType, subjectID string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE suppressions
+		SET removed_at = CURRENT_TIMESTAMP, removed_by = $3
+		WHERE id = $1 AND project_id = $2 AND removed_at IS NULL
+		RETURNING subject_type, subject_id;
+	`, id, projectID, remover).Scan(&subjectType, &subjectID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("suppression %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove suppression: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit suppression removal: %w", err)
+	}
+
+	s.mu.Lock()
+	delete(s.cache, suppressionKey(projectID, subjectType, subjectID))
+	s.mu.Unlock()
+
+	if err := s.mirror(ctx, projectID, subjectType, subjectID, false); err != nil {
+		logging.Ctx(ctx).Warn().Err(err).Msgf("Removal of suppression %d not mirrored yet; the next refresh will", id)
+	}
+
+	logging.Ctx(ctx).Info().Msgf("Suppression %d removed for %s '%s'", id, subjectType, subjectID)
+	return nil
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"mabletask/api/database"
+	"mabletask/api/models"
+)
+
+// Active parts per partition at which inserts start to suffer. ClickHouse
+// delays inserts at parts_to_delay_insert and rejects them at
+// parts_to_throw_insert; these thresholds warn well before either.
+const (
+	partsPerPartitionWarning  = 300
+	partsPerPartitionCritical = 1000
+	replicationDelayWarning   = 300 // seconds
+)
+
+// TableHealthStore reads storage health of the analytics tables from the
+// ClickHouse system tables.
+type TableHealthStore struct {
+	ch *database.ClickHouseClient
+}
+
+func NewTableHealthStore(chClient *database.ClickHouseClient) *TableHealthStore {
+	return &TableHealthStore{ch: chClient}
+}
+
+// GetHealth summarizes parts, disk usage per partition, merges, mutations and
+// replication for every table in the current database.
+func (s *TableHealthStore) GetHealth(ctx context.Context) (*models.ClickHouseHealth, error) {
+	tables := map[string]*models.TableHealth{}
+	table := func(name string) *models.TableHealth {
+		if t, ok := tables[name]; ok {
+			return t
+		}
+		t := &models.TableHealth{Table: name, Partitions: []models.PartitionUsage{}}
+		tables[name] = t
+		return t
+	}
+
+	rows, err := s.ch.Conn.Query(ctx, `
+		SELECT table, partition, count() AS parts, sum(rows), sum(bytes_on_disk)
+		FROM system.parts
+		WHERE active AND database = currentDatabase()
+		GROUP BY table, partition
+		ORDER BY table, partition
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.parts: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		var p models.PartitionUsage
+		if err := rows.Scan(&name, &p.Partition, &p.Parts, &p.Rows, &p.BytesOnDisk); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan system.parts: %w", err)
+		}
+		t := table(name)
+		t.Partitions = append(t.Partitions, p)
+		t.ActiveParts += p.Parts
+		t.Rows += p.Rows
+		t.BytesOnDisk += p.BytesOnDisk
+		if p.Parts > t.MaxPartsInPartition {
+			t.MaxPartsInPartition = p.Parts
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system.parts: %w", err)
+	}
+
+	rows, err = s.ch.Conn.Query(ctx, `
+		SELECT table, count(), max(elapsed)
+		FROM system.merges
+		WHERE database = currentDatabase()
+		GROUP BY table
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.merges: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		var merges uint64
+		var longest float64
+		if err := rows.Scan(&name, &merges, &longest); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan system.merges: %w", err)
+		}
+		t := table(name)
+		t.MergesInProgress, t.LongestMergeSeconds = merges, longest
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system.merges: %w", err)
+	}
+
+	rows, err = s.ch.Conn.Query(ctx, `
+		SELECT table, count()
+		FROM system.mutations
+		WHERE database = currentDatabase() AND NOT is_done
+		GROUP BY table
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.mutations: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		var pending uint64
+		if err := rows.Scan(&name, &pending); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan system.mutations: %w", err)
+		}
+		table(name).PendingMutations = pending
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system.mutations: %w", err)
+	}
+
+	rows, err = s.ch.Conn.Query(ctx, `
+		SELECT table, absolute_delay, queue_size, is_readonly
+		FROM system.replicas
+		WHERE database = currentDatabase()
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system.replicas: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		var delay uint64
+		var queue uint32
+		var readOnly uint8
+		if err := rows.Scan(&name, &delay, &queue, &readOnly); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan system.replicas: %w", err)
+		}
+		t := table(name)
+		t.ReplicationDelaySeconds, t.ReplicationQueueSize, t.ReadOnlyReplica = &delay, &queue, readOnly == 1
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating system.replicas: %w", err)
+	}
+
+	health := &models.ClickHouseHealth{Status: models.HealthStatusOK, Tables: make([]models.TableHealth, 0, len(tables))}
+	for _, t := range tables {
+		assessTableHealth(t)
+		if healthRank(t.Status) > healthRank(health.Status) {
+			health.Status = t.Status
+		}
+		health.Tables = append(health.Tables, *t)
+	}
+	sort.Slice(health.Tables, func(i, j int) bool { return health.Tables[i].Table < health.Tables[j].Table })
+	return health, nil
+}
+
+func assessTableHealth(t *models.TableHealth) {
+	t.Status = models.HealthStatusOK
+	raise := func(status, warning string) {
+		if healthRank(status) > healthRank(t.Status) {
+			t.Status = status
+		}
+		t.Warnings = append(t.Warnings, warning)
+	}
+
+	switch {
+	case t.MaxPartsInPartition >= partsPerPartitionCritical:
+		raise(models.HealthStatusCritical, fmt.Sprintf("%d active parts in one partition; inserts are being delayed or rejected", t.MaxPartsInPartition))
+	case t.MaxPartsInPartition >= partsPerPartitionWarning:
+		raise(models.HealthStatusWarning, fmt.Sprintf("%d active parts in one partition; merges are falling behind inserts", t.MaxPartsInPartition))
+	}
+	if t.ReadOnlyReplica {
+		raise(models.HealthStatusCritical, "replica is read-only")
+	}
+	if t.ReplicationDelaySeconds != nil && *t.ReplicationDelaySeconds >= replicationDelayWarning {
+		raise(models.HealthStatusWarning, fmt.Sprintf("replica is %d seconds behind", *t.ReplicationDelaySeconds))
+	}
+}
+
+func healthRank(status string) int {
+	switch status {
+	case models.HealthStatusCritical:
+		return 2
+	case models.HealthStatusWarning:
+		return 1
+	default:
+		return 0
+	}
+}
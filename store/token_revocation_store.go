@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenRevocationStore remembers access tokens revoked before they expire:
+// single tokens by their jti claim, and every token of a user issued before
+// a point in time. Entries are only kept as long as the tokens they revoke
+// could still be valid.
+type TokenRevocationStore interface {
+	// Revoke revokes the token with jti, which expires at expiresAt.
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeUser revokes the user's tokens issued before the given time.
+	RevokeUser(ctx context.Context, userID int, before time.Time) error
+	// IsRevoked reports whether the user's token with jti, issued at
+	// issuedAt, has been revoked.
+	IsRevoked(ctx context.Context, jti string, userID int, issuedAt time.Time) (bool, error)
+	// DeleteExpiredBefore removes entries no longer needed at cutoff.
+	DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// NewTokenRevocationStore keeps revocations in Redis when a client is given,
+// and in PostgreSQL otherwise. maxTokenTTL is the longest lifetime of an
+// access token, how long a user's revocation must be kept.
+func NewTokenRevocationStore(client *redis.Client, db *sql.DB, maxTokenTTL time.Duration) TokenRevocationStore {
+	if client != nil {
+		return &RedisTokenRevocationStore{client: client, maxTokenTTL: maxTokenTTL}
+	}
+	return &PostgresTokenRevocationStore{db: db, maxTokenTTL: maxTokenTTL}
+}
+
+// RedisTokenRevocationStore keeps revocations in Redis keys that expire with
+// the tokens they revoke.
+type RedisTokenRevocationStore struct {
+	client      *redis.Client
+	maxTokenTTL time.Duration
+}
+
+const (
+	revokedTokenKeyPrefix = "revoked:token:"
+	revokedUserKeyPrefix  = "revoked:user:"
+)
+
+func (r *RedisTokenRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.client.Set(ctx, revokedTokenKeyPrefix+jti, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisTokenRevocationStore) RevokeUser(ctx context.Context, userID int, before time.Time) error {
+	key := revokedUserKeyPrefix + strconv.Itoa(userID)
+	if err := r.client.Set(ctx, key, before.Unix(), r.maxTokenTTL).Err(); err != nil {
+		return fmt.Errorf("failed to revoke tokens of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+func (r *RedisTokenRevocationStore) IsRevoked(ctx context.Context, jti string, userID int, issuedAt time.Time) (bool, error) {
+	pipe := r.client.Pipeline()
+	token := pipe.Exists(ctx, revokedTokenKeyPrefix+jti)
+	user := pipe.Get(ctx, revokedUserKeyPrefix+strconv.Itoa(userID))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if jti != "" && token.Val() > 0 {
+		return true, nil
+	}
+	before, err := user.Int64()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to parse user token revocation: %w", err)
+	}
+	return issuedAt.Unix() < before, nil
+}
+
+// DeleteExpiredBefore is a no-op: Redis expires revocations on its own.
+func (r *RedisTokenRevocationStore) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// PostgresTokenRevocationStore keeps revocations in the revoked_tokens and
+// user_token_revocations tables.
+type PostgresTokenRevocationStore struct {
+	db          *sql.DB
+	maxTokenTTL time.Duration
+}
+
+func (p *PostgresTokenRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING;
+	`, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresTokenRevocationStore) RevokeUser(ctx context.Context, userID int, before time.Time) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO user_token_revocations (user_id, revoked_before, expires_at)
+		VALUES ($1, $2, $2::timestamptz + make_interval(secs => $3))
+		ON CONFLICT (user_id) DO UPDATE SET
+			revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before),
+			expires_at = GREATEST(user_token_revocations.expires_at, EXCLUDED.expires_at);
+	`, userID, before.Truncate(time.Second), p.maxTokenTTL.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to revoke tokens of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+func (p *PostgresTokenRevocationStore) IsRevoked(ctx context.Context, jti string, userID int, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := p.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		    OR EXISTS (SELECT 1 FROM user_token_revocations WHERE user_id = $2 AND revoked_before > $3);
+	`, jti, userID, issuedAt).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+func (p *PostgresTokenRevocationStore) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	tokens, err := p.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1;`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune revoked tokens: %w", err)
+	}
+	users, err := p.db.ExecContext(ctx, `DELETE FROM user_token_revocations WHERE expires_at < $1;`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune user token revocations: %w", err)
+	}
+	n, _ := tokens.RowsAffected()
+	m, _ := users.RowsAffected()
+	return n + m, nil
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"mabletask/api/tracing"
+)
+
+// startPostgresSpan starts the span of a store method backed by PostgreSQL.
+// ClickHouse queries get theirs from the connection itself.
+func startPostgresSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, append(attrs, semconv.DBSystemNamePostgreSQL)...)
+}
+
+// endSpan ends the span of a store method. Missing records, conflicts and
+// invalid requests are answers rather than failures: they are recorded as the
+// span's outcome, and only other errors mark it failed. Error messages, which
+// may hold emails, are kept off the span for them.
+func endSpan(span trace.Span, err error) {
+	var outcome string
+	switch {
+	case errors.Is(err, ErrNotFound):
+		outcome = "not_found"
+	case errors.Is(err, ErrAlreadyExists):
+		outcome = "already_exists"
+	case errors.Is(err, ErrInvalid):
+		outcome = "invalid"
+	}
+	if outcome != "" {
+		span.SetAttributes(attribute.String("store.outcome", outcome))
+		err = nil
+	}
+	tracing.End(span, err)
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/models"
+)
+
+type TraitsStore struct {
+	DB *database.ClickHouseClient
+}
+
+func NewTraitsStore(chClient *database.ClickHouseClient) *TraitsStore {
+	return &TraitsStore{DB: chClient}
+}
+
+// userTraitsSelect merges the per-trait rows of user_traits into one row per
+// user: the email, plan and company columns, the custom traits as a JSON
+// object and the time of the latest identify call. Callers append their
+// WHERE clause before userTraitsGroupBy.
+const userTraitsSelect = `
+	SELECT project_id, user_id,
+		anyIf(value, NOT custom AND name = 'email') AS email,
+		anyIf(value, NOT custom AND name = 'plan') AS plan,
+		anyIf(value, NOT custom AND name = 'company') AS company,
+		concat('{', arrayStringConcat(arraySort(groupArrayIf(concat(toJSONString(name), ':', value), custom)), ','), '}') AS traits,
+		max(updated_at) AS updated_at
+	FROM user_traits FINAL
+`
+
+const userTraitsGroupBy = ` GROUP BY project_id, user_id`
+
+// currentUserTraits is the merged traits of every user, for use as a subquery.
+const currentUserTraits = `(` + userTraitsSelect + userTraitsGroupBy + `)`
+
+// GetUserTraits returns the latest traits for a user of the project, or nil
+// if the user was never identified.
+func (s *TraitsStore) GetUserTraits(ctx context.Context, projectID, userID string) (*models.UserTraits, error) {
+	query := userTraitsSelect + ` WHERE project_id = ? AND user_id = ?` + userTraitsGroupBy
+	rows, err := s.DB.Conn.Query(ctx, query, projectID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user traits: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var (
+		traits    models.UserTraits
+		project   string
+		rawTraits string
+	)
+	if err := rows.Scan(&project, &traits.UserID, &traits.Email, &traits.Plan, &traits.Company, &rawTraits, &traits.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to scan user traits: %w", err)
+	}
+	traits.Traits = json.RawMessage(rawTraits)
+	return &traits, nil
+}
+
+// UpsertUserTraits merges the identify payload into the user's existing traits.
+// Each trait is its own row, replaced by the newest write of that trait, so
+// concurrent identify calls for a user merge in ClickHouse instead of
+// overwriting each other. Empty fields keep their previous values. A row
+// without a name records the call itself, so users identified without traits
+// are known too.
+func (s *TraitsStore) UpsertUserTraits(ctx context.Context, projectID string, req models.IdentifyRequest) (*models.UserTraits, error) {
+	updatedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	batch, err := s.DB.Conn.PrepareBatch(ctx, `INSERT INTO user_traits (project_id, user_id, custom, name, value, updated_at)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare traits insert: %w", err)
+	}
+	defer batch.Abort()
+
+	appendTrait := func(custom bool, name, value string) error {
+		if err := batch.Append(projectID, req.UserID, custom, name, value, updatedAt); err != nil {
+			return fmt.Errorf("failed to append trait %s: %w", name, err)
+		}
+		return nil
+	}
+	if err := appendTrait(false, "", ""); err != nil {
+		return nil, err
+	}
+	for _, field := range []struct{ name, value string }{
+		{"email", req.Email},
+		{"plan", req.Plan},
+		{"company", req.Company},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if err := appendTrait(false, field.name, field.value); err != nil {
+			return nil, err
+		}
+	}
+	names := make([]string, 0, len(req.Traits))
+	for name := range req.Traits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := json.Marshal(req.Traits[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode trait %s: %w", name, err)
+		}
+		if err := appendTrait(true, name, string(value)); err != nil {
+			return nil, err
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return nil, fmt.Errorf("failed to insert user traits: %w", err)
+	}
+
+	return s.GetUserTraits(ctx, projectID, req.UserID)
+}
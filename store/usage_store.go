@@ -0,0 +1,317 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"mabletask/api/database"
+	"mabletask/api/models"
+)
+
+// ErrQuotaExceeded is returned by ReserveEvents when accepting the events would
+// take the project over its monthly ingestion quota.
+var ErrQuotaExceeded = errors.New("monthly event quota exceeded")
+
+// UsageStore meters ingested events and stats queries per project and month,
+// and enforces monthly ingestion quotas.
+type UsageStore struct {
+	db *sql.DB
+	ch *database.ClickHouseClient
+	// DefaultQuota applies to projects without an override; 0 means unlimited.
+	DefaultQuota int64
+	// SoftQuotaPercent is the share of the quota above which usage is reported
+	// with a warning status.
+	SoftQuotaPercent float64
+}
+
+func NewUsageStore(db *sql.DB, chClient *database.ClickHouseClient, defaultQuota int64, softQuotaPercent float64) *UsageStore {
+	return &UsageStore{db: db, ch: chClient, DefaultQuota: defaultQuota, SoftQuotaPercent: softQuotaPercent}
+}
+
+// usagePeriod returns the first day of t's month in UTC.
+func usagePeriod(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// GetQuota returns the project's monthly event quota (0 = unlimited).
+func (s *UsageStore) GetQuota(ctx context.Context, projectID string) (int64, error) {
+	var quota int64
+	err := s.db.QueryRowContext(ctx, `SELECT monthly_event_quota FROM project_quotas WHERE project_id = $1;`, projectID).Scan(&quota)
+	if err == sql.ErrNoRows {
+		return s.DefaultQuota, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get quota for project %s: %w", projectID, err)
+	}
+	return quota, nil
+}
+
+// ReserveEvents adds n events to the project's usage for the current month and
+// returns the new total and the quota. When the quota would be exceeded nothing
+// is counted and ErrQuotaExceeded is returned together with the current total.
+func (s *UsageStore) ReserveEvents(ctx context.Context, projectID string, n int) (used, quota int64, err error) {
+	quota, err = s.GetQuota(ctx, projectID)
+	if err != nil {
+		return 0, 0, err
+	}
+	period := usagePeriod(time.Now())
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO usage_counters (project_id, period, events_ingested)
+		SELECT $1::VARCHAR, $2::DATE, $3::BIGINT
+		WHERE $4::BIGINT = 0 OR $3::BIGINT <= $4::BIGINT
+		ON CONFLICT (project_id, period) DO UPDATE
+		SET events_ingested = usage_counters.events_ingested + EXCLUDED.events_ingested,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE $4 = 0 OR usage_counters.events_ingested + EXCLUDED.events_ingested <= $4
+		RETURNING events_ingested;
+	`, projectID, period, int64(n), quota).Scan(&used)
+	if err == sql.ErrNoRows {
+		if err := s.db.QueryRowContext(ctx, `
+			SELECT COALESCE((SELECT events_ingested FROM usage_counters WHERE project_id = $1 AND period = $2), 0);
+		`, projectID, period).Scan(&used); err != nil {
+			return 0, quota, fmt.Errorf("failed to read usage for project %s: %w", projectID, err)
+		}
+		return used, quota, fmt.Errorf("project %s: %w", projectID, ErrQuotaExceeded)
+	}
+	if err != nil {
+		return 0, quota, fmt.Errorf("failed to record usage for project %s: %w", projectID, err)
+	}
+	return used, quota, nil
+}
+
+// ReleaseEvents returns n previously reserved events, e.g. when the insert
+// they were reserved for failed.
+func (s *UsageStore) ReleaseEvents(ctx context.Context, projectID string, n int) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE usage_counters
+		SET events_ingested = GREATEST(events_ingested - $3, 0), updated_at = CURRENT_TIMESTAMP
+		WHERE project_id = $1 AND period = $2;
+	`, projectID, usagePeriod(time.Now()), int64(n))
+	if err != nil {
+		return fmt.Errorf("failed to release usage for project %s: %w", projectID, err)
+	}
+	return nil
+}
+
+// RecordQuery counts one stats query against the project.
+func (s *UsageStore) RecordQuery(ctx context.Context, projectID string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO usage_counters (project_id, period, queries)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (project_id, period) DO UPDATE
+		SET queries = usage_counters.queries + 1, updated_at = CURRENT_TIMESTAMP;
+	`, projectID, usagePeriod(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to record query for project %s: %w", projectID, err)
+	}
+	return nil
+}
+
+// Status classifies usage against a quota.
+func (s *UsageStore) Status(used, quota int64) (percent float64, status string) {
+	if quota <= 0 {
+		return 0, models.UsageStatusOK
+	}
+	percent = float64(used) / float64(quota) * 100
+	switch {
+	case used >= quota:
+		return percent, models.UsageStatusExceeded
+	case percent >= s.SoftQuotaPercent:
+		return percent, models.UsageStatusWarning
+	default:
+		return percent, models.UsageStatusOK
+	}
+}
+
+// GetUsage returns the current month's usage for a project together with the
+// previous months of history, newest first.
+func (s *UsageStore) GetUsage(ctx context.Context, projectID string, months int) (*models.Usage, error) {
+	quota, err := s.GetQuota(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	current := usagePeriod(time.Now())
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT period, events_ingested, queries
+		FROM usage_counters
+		WHERE project_id = $1 AND period > $2 AND period <= $3
+		ORDER BY period DESC;
+	`, projectID, current.AddDate(0, -months, 0), current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage for project %s: %w", projectID, err)
+	}
+	defer rows.Close()
+
+	usage := &models.Usage{
+		ProjectID:         projectID,
+		UsagePeriod:       models.UsagePeriod{Period: current.Format("2006-01")},
+		MonthlyEventQuota: quota,
+		History:           []models.UsagePeriod{},
+	}
+	for rows.Next() {
+		var period time.Time
+		var p models.UsagePeriod
+		if err := rows.Scan(&period, &p.EventsIngested, &p.Queries); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		p.Period = period.Format("2006-01")
+		if p.Period == usage.Period {
+			usage.UsagePeriod = p
+		}
+		usage.History = append(usage.History, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage: %w", err)
+	}
+
+	usage.PercentUsed, usage.Status = s.Status(usage.EventsIngested, quota)
+	return usage, nil
+}
+
+func (s *UsageStore) SetQuota(ctx context.Context, projectID string, quota int64, updatedBy int) (*models.ProjectQuota, error) {
+	var updater interface{}
+	if updatedBy != 0 {
+		updater = updatedBy
+	}
+
+	q := models.ProjectQuota{ProjectID: projectID, MonthlyEventQuota: quota}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO project_quotas (project_id, monthly_event_quota, updated_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (project_id) DO UPDATE
+		SET monthly_event_quota = EXCLUDED.monthly_event_quota,
+		    updated_by = EXCLUDED.updated_by,
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at;
+	`, projectID, quota, updater).Scan(&q.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set quota for project %s: %w", projectID, err)
+	}
+	if updatedBy != 0 {
+		q.UpdatedBy = &updatedBy
+	}
+	return &q, nil
+}
+
+func (s *UsageStore) ListQuotas(ctx context.Context) ([]models.ProjectQuota, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT project_id, monthly_event_quota, updated_by, updated_at
+		FROM project_quotas
+		ORDER BY project_id;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quotas: %w", err)
+	}
+	defer rows.Close()
+
+	quotas := []models.ProjectQuota{}
+	for rows.Next() {
+		var q models.ProjectQuota
+		var updatedBy sql.NullInt64
+		if err := rows.Scan(&q.ProjectID, &q.MonthlyEventQuota, &updatedBy, &q.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan quota: %w", err)
+		}
+		if updatedBy.Valid {
+			id := int(updatedBy.Int64)
+			q.UpdatedBy = &id
+		}
+		quotas = append(quotas, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating quotas: %w", err)
+	}
+	return quotas, nil
+}
+
+// ParseUsagePeriod parses a YYYY-MM billing period.
+func ParseUsagePeriod(s string) (time.Time, error) {
+	period, err := time.Parse("2006-01", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid period %q, expected YYYY-MM: %w", s, err)
+	}
+	return period, nil
+}
+
+// GetBillingUsage returns the usage of every project for the month starting at
+// period, ordered by project ID.
+func (s *UsageStore) GetBillingUsage(ctx context.Context, period time.Time) ([]models.BillingUsage, error) {
+	period = usagePeriod(period)
+	label := period.Format("2006-01")
+	byProject := map[string]*models.BillingUsage{}
+	line := func(projectID string) *models.BillingUsage {
+		if b, ok := byProject[projectID]; ok {
+			return b
+		}
+		b := &models.BillingUsage{Period: label, ProjectID: projectID}
+		byProject[projectID] = b
+		return b
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT project_id, events_ingested, queries
+		FROM usage_counters
+		WHERE period = $1;
+	`, period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get billing usage: %w", err)
+	}
+	for rows.Next() {
+		var projectID string
+		var events, queries int64
+		if err := rows.Scan(&projectID, &events, &queries); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan billing usage: %w", err)
+		}
+		b := line(projectID)
+		b.EventsIngested, b.Queries = events, queries
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating billing usage: %w", err)
+	}
+
+	var totalBytes, totalRows uint64
+	err = s.ch.Conn.QueryRow(ctx, `
+		SELECT sum(bytes_on_disk), sum(rows)
+		FROM system.parts
+		WHERE active AND database = currentDatabase() AND table = 'analytics_events'
+	`).Scan(&totalBytes, &totalRows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analytics_events size: %w", err)
+	}
+
+	stored, err := s.ch.Conn.Query(ctx, `SELECT project_id, count() FROM analytics_events GROUP BY project_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count stored events per project: %w", err)
+	}
+	defer stored.Close()
+	for stored.Next() {
+		var projectID string
+		var count uint64
+		if err := stored.Scan(&projectID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan stored events: %w", err)
+		}
+		b := line(projectID)
+		b.StoredEvents = count
+		if totalRows > 0 {
+			b.StorageBytesEstimate = uint64(float64(totalBytes) * float64(count) / float64(totalRows))
+		}
+	}
+	if err := stored.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stored events: %w", err)
+	}
+
+	results := make([]models.BillingUsage, 0, len(byProject))
+	for _, b := range byProject {
+		results = append(results, *b)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ProjectID < results[j].ProjectID })
+	return results, nil
+}
@@ -3,9 +3,14 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
 
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+
+	"mabletask/api/logging"
 	"mabletask/api/models"
 )
 
@@ -17,51 +22,255 @@ func NewUserStore(db *sql.DB) *UserStore {
 	return &UserStore{db: db}
 }
 
-func (s *UserStore) CreateUser(ctx context.Context, email string, hashedPassword []byte) (*models.User, error) {
+func (s *UserStore) CreateUser(ctx context.Context, email string, hashedPassword []byte) (_ *models.User, err error) {
+	ctx, span := startPostgresSpan(ctx, "UserStore.CreateUser")
+	defer func() { endSpan(span, err) }()
+
 	user := &models.User{}
 	query := `
 		INSERT INTO users (email, hashed_password)
 		VALUES ($1, $2)
-		RETURNING id, email, created_at, updated_at;
+		RETURNING id, email, is_admin, created_at, updated_at;
 	`
-	err := s.db.QueryRowContext(ctx, query, email, hashedPassword).Scan(
+	err = s.db.QueryRowContext(ctx, query, email, hashedPassword).Scan(
 		&user.ID,
 		&user.Email,
+		&user.IsAdmin,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 	if err != nil {
 		if err.Error() == `pq: duplicate key value violates unique constraint "idx_users_email"` ||
 			err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"` {
-			return nil, fmt.Errorf("user with email '%s' already exists", email)
+			return nil, fmt.Errorf("user with email '%s' %w", email, ErrAlreadyExists)
 		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	log.Printf("User created in DB: ID=%d, Email=%s", user.ID, user.Email)
+	logging.Ctx(ctx).Info().Int("user_id", user.ID).Msg("User created in DB")
 	return user, nil
 }
 
-func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (_ *models.User, err error) {
+	ctx, span := startPostgresSpan(ctx, "UserStore.GetUserByEmail")
+	defer func() { endSpan(span, err) }()
+
 	user := &models.User{}
 	query := `
-		SELECT id, email, hashed_password, created_at, updated_at
+		SELECT id, email, hashed_password, is_admin, created_at, updated_at
 		FROM users
 		WHERE email = $1;
 	`
-	err := s.db.QueryRowContext(ctx, query, email).Scan(
+	err = s.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.Email,
 		&user.HashedPassword,
+		&user.IsAdmin,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user with email '%s' not found", email)
+			return nil, fmt.Errorf("user with email '%s' %w", email, ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 
 	return user, nil
 }
+
+func (s *UserStore) GetUserByID(ctx context.Context, id int) (_ *models.User, err error) {
+	ctx, span := startPostgresSpan(ctx, "UserStore.GetUserByID", attribute.Int("user.id", id))
+	defer func() { endSpan(span, err) }()
+
+	user := &models.User{}
+	query := `
+		SELECT id, email, hashed_password, is_admin, created_at, updated_at
+		FROM users
+		WHERE id = $1;
+	`
+	err = s.db.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.Email,
+		&user.HashedPassword,
+		&user.IsAdmin,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	return user, nil
+}
+
+// ListUsers returns a page of users, newest first.
+func (s *UserStore) ListUsers(ctx context.Context, page PageRequest) (_ models.Page[models.User], err error) {
+	ctx, span := startPostgresSpan(ctx, "UserStore.ListUsers")
+	defer func() { endSpan(span, err) }()
+
+	afterTime, afterID := page.keysetArgs()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, email, is_admin, created_at, updated_at
+		FROM users
+		WHERE $1::timestamptz IS NULL OR (created_at, id) < ($1, $2::integer)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3;
+	`, afterTime, afterID, page.fetchLimit())
+	if err != nil {
+		return models.Page[models.User]{}, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return models.Page[models.User]{}, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Page[models.User]{}, fmt.Errorf("error iterating users: %w", err)
+	}
+	return newPage(users, page, func(u models.User) Cursor {
+		return Cursor{Time: u.CreatedAt, ID: strconv.Itoa(u.ID)}
+	}), nil
+}
+
+// GetOrCreateOAuthUser returns the user linked to the provider account. An
+// account not linked yet is linked to the user with its email, or to a new
+// user without a password when there is none; the caller must have checked
+// that the provider verified the email.
+func (s *UserStore) GetOrCreateOAuthUser(ctx context.Context, provider, subject, email string) (_ *models.User, err error) {
+	ctx, span := startPostgresSpan(ctx, "UserStore.GetOrCreateOAuthUser", attribute.String("oauth.provider", provider))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE user_identities SET last_login_at = CURRENT_TIMESTAMP
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_id;
+	`, provider, subject).Scan(&userID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE LOWER(email) = LOWER($1) ORDER BY id LIMIT 1;`, email).Scan(&userID)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRowContext(ctx, `INSERT INTO users (email) VALUES ($1) RETURNING id;`, email).Scan(&userID)
+			if err == nil {
+				logging.Ctx(ctx).Info().Int("user_id", userID).Str("provider", provider).Msg("User created in DB via OAuth")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find or create user for %s account: %w", provider, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4);
+		`, provider, subject, userID, email); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return nil, fmt.Errorf("%s account %s: %w", provider, subject, ErrAlreadyExists)
+			}
+			return nil, fmt.Errorf("failed to link %s account: %w", provider, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get %s account: %w", provider, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit %s sign-in: %w", provider, err)
+	}
+	return s.GetUserByID(ctx, userID)
+}
+
+// UpdateEmail sets the user's email. An email used by another user gives
+// ErrAlreadyExists.
+func (s *UserStore) UpdateEmail(ctx context.Context, id int, email string) (err error) {
+	ctx, span := startPostgresSpan(ctx, "UserStore.UpdateEmail", attribute.Int("user.id", id))
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE users SET email = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1;
+	`, id, email)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("user with email '%s': %w", email, ErrAlreadyExists)
+		}
+		return fmt.Errorf("failed to update email of user %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// UpdatePassword sets the user's password hash.
+func (s *UserStore) UpdatePassword(ctx context.Context, id int, hashedPassword []byte) (err error) {
+	ctx, span := startPostgresSpan(ctx, "UserStore.UpdatePassword", attribute.Int("user.id", id))
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE users SET hashed_password = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1;
+	`, id, hashedPassword)
+	if err != nil {
+		return fmt.Errorf("failed to update password of user %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteUser deletes the user along with their sessions, reports and project
+// memberships; records they created are kept without a creator. A user who
+// is the only owner of a project with other members gives ErrInvalid, so a
+// project is never left without an owner to manage it.
+func (s *UserStore) DeleteUser(ctx context.Context, id int) (err error) {
+	ctx, span := startPostgresSpan(ctx, "UserStore.DeleteUser", attribute.Int("user.id", id))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var projectID string
+	err = tx.QueryRowContext(ctx, `
+		SELECT m.project_id
+		FROM project_members m
+		WHERE m.user_id = $1 AND m.role = 'owner'
+		  AND NOT EXISTS (SELECT 1 FROM project_members o WHERE o.project_id = m.project_id AND o.user_id <> $1 AND o.role = 'owner')
+		  AND EXISTS (SELECT 1 FROM project_members o WHERE o.project_id = m.project_id AND o.user_id <> $1)
+		ORDER BY m.project_id
+		LIMIT 1;
+	`, id).Scan(&projectID)
+	if err == nil {
+		return fmt.Errorf("%w: user %d is the only owner of project '%s'", ErrInvalid, id, projectID)
+	}
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check project ownership of user %d: %w", id, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %d: %w", id, ErrNotFound)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit deletion of user %d: %w", id, err)
+	}
+	logging.Ctx(ctx).Info().Int("user_id", id).Msg("User deleted from DB")
+	return nil
+}
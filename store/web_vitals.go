@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mabletask/api/models"
+)
+
+// webVitalColumns holds the typed web vital columns of an event, empty for
+// events of other types.
+type webVitalColumns struct {
+	name   string
+	value  float64
+	rating string
+}
+
+func newWebVitalColumns(event *models.AnalyticsEvent) webVitalColumns {
+	if event.EventType != models.EventTypeWebVital {
+		return webVitalColumns{}
+	}
+	p, err := event.WebVitalPayload()
+	if err != nil {
+		return webVitalColumns{}
+	}
+	return webVitalColumns{name: p.Name, value: p.Value, rating: p.Rating}
+}
+
+// GetWebVitals returns the 50th, 75th and 95th percentile of each web vital
+// per page path (without query string) and device type, for the limit pages
+// with the most samples. metric restricts the report to one metric when set.
+func (s *AnalyticsStore) GetWebVitals(ctx context.Context, metric string, start, end time.Time, limit uint64, filters EventFilters) ([]models.WebVitalStats, error) {
+	if limit == 0 {
+		limit = 10
+	}
+
+	filterClause, filterArgs := filters.clause()
+	metricClause := ""
+	var metricArgs []interface{}
+	if metric != "" {
+		metricClause = " AND web_vital_name = ?"
+		metricArgs = []interface{}{metric}
+	}
+
+	// The top pages are ranked over the same samples as the report.
+	where := fmt.Sprintf("event_type = '%s' AND web_vital_name != '' AND %s%s%s",
+		models.EventTypeWebVital, timeRangeClause, filterClause, metricClause)
+	whereArgs := []interface{}{start.UnixMilli(), end.UnixMilli()}
+	whereArgs = append(whereArgs, filterArgs...)
+	whereArgs = append(whereArgs, metricArgs...)
+
+	args := append([]interface{}{}, whereArgs...)
+	args = append(args, whereArgs...)
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT cutQueryString(page_path) AS path, device_type, web_vital_name, count() AS samples,
+		       quantiles(0.5, 0.75, 0.95)(web_vital_value) AS q
+		FROM analytics_events
+		WHERE %[1]s AND path IN (
+			SELECT cutQueryString(page_path) FROM analytics_events WHERE %[1]s
+			GROUP BY cutQueryString(page_path) ORDER BY count() DESC LIMIT ?
+		)
+		GROUP BY path, device_type, web_vital_name
+		ORDER BY path, device_type, web_vital_name
+	`, where)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query web vitals: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.WebVitalStats{}
+	for rows.Next() {
+		var r models.WebVitalStats
+		var q []float64
+		if err := rows.Scan(&r.PagePath, &r.DeviceType, &r.Metric, &r.Samples, &q); err != nil {
+			return nil, fmt.Errorf("failed to scan web vitals: %w", err)
+		}
+		if len(q) == 3 {
+			r.P50, r.P75, r.P95 = q[0], q[1], q[2]
+		}
+		r.Rating = models.RateWebVital(r.Metric, r.P75)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating web vitals: %w", err)
+	}
+	return results, nil
+}
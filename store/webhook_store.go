@@ -0,0 +1,432 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"mabletask/api/logging"
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// WebhookStore manages outbound webhooks and their deliveries in PostgreSQL.
+// An in-memory copy of the enabled webhooks' filters serves the ingestion
+// path until the next Refresh.
+type WebhookStore struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	hooks map[string]map[string][]int // project_id -> event_type -> webhook IDs
+}
+
+func NewWebhookStore(db *sql.DB) *WebhookStore {
+	return &WebhookStore{db: db, hooks: map[string]map[string][]int{}}
+}
+
+const webhookColumns = `id, project_id, name, url, event_types, enabled, created_by, created_at, updated_at`
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var (
+		hook      models.Webhook
+		createdBy sql.NullInt64
+	)
+	if err := row.Scan(&hook.ID, &hook.ProjectID, &hook.Name, &hook.URL, pq.Array(&hook.EventTypes), &hook.Enabled,
+		&createdBy, &hook.CreatedAt, &hook.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		hook.CreatedBy = &id
+	}
+	return &hook, nil
+}
+
+// Refresh reloads the enabled webhooks used by QueueDeliveries.
+func (s *WebhookStore) Refresh(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, project_id, event_types FROM webhooks WHERE enabled;`)
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := map[string]map[string][]int{}
+	for rows.Next() {
+		var (
+			id         int
+			projectID  string
+			eventTypes []string
+		)
+		if err := rows.Scan(&id, &projectID, pq.Array(&eventTypes)); err != nil {
+			return fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		if hooks[projectID] == nil {
+			hooks[projectID] = map[string][]int{}
+		}
+		for _, eventType := range eventTypes {
+			hooks[projectID][eventType] = append(hooks[projectID][eventType], id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating webhooks: %w", err)
+	}
+
+	s.mu.Lock()
+	s.hooks = hooks
+	s.mu.Unlock()
+	return nil
+}
+
+// reload applies a change to the webhooks on this instance right away; other
+// instances pick it up at their next Refresh.
+func (s *WebhookStore) reload(ctx context.Context) {
+	if err := s.Refresh(ctx); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Msg("Webhooks: failed to reload")
+	}
+}
+
+// matching returns the enabled webhooks of projectID subscribed to eventType.
+func (s *WebhookStore) matching(projectID, eventType string) []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hooks[projectID][eventType]
+}
+
+// QueueDeliveries adds a pending delivery of each event to every enabled
+// webhook of its project subscribed to its type, and returns how many were
+// queued.
+func (s *WebhookStore) QueueDeliveries(ctx context.Context, events []models.AnalyticsEvent) (int, error) {
+	type delivery struct {
+		webhookID int
+		event     *models.AnalyticsEvent
+	}
+	var deliveries []delivery
+	for i := range events {
+		for _, id := range s.matching(events[i].ProjectID, events[i].EventType) {
+			deliveries = append(deliveries, delivery{id, &events[i]})
+		}
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin webhook deliveries: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4);
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare webhook deliveries: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, d := range deliveries {
+		payload, err := json.Marshal(d.event)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode event %s: %w", d.event.EventID, err)
+		}
+		if _, err := stmt.ExecContext(ctx, d.webhookID, d.event.EventID, d.event.EventType, payload); err != nil {
+			return 0, fmt.Errorf("failed to queue delivery of event %s to webhook %d: %w", d.event.EventID, d.webhookID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit webhook deliveries: %w", err)
+	}
+	return len(deliveries), nil
+}
+
+// CreateWebhook registers a webhook for the project with a new secret. The
+// returned webhook is the only one carrying the secret.
+func (s *WebhookStore) CreateWebhook(ctx context.Context, projectID string, createdBy int, req models.WebhookRequest) (*models.Webhook, error) {
+	secret, err := utils.NewToken(utils.WebhookSecretPrefix)
+	if err != nil {
+		return nil, err
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+
+	hook, err := scanWebhook(s.db.QueryRowContext(ctx, `
+		INSERT INTO webhooks (project_id, name, url, secret, event_types, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+webhookColumns+`;
+	`, projectID, req.Name, req.URL, secret, pq.Array(req.EventTypes), enabled, creator))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	hook.Secret = secret
+	s.reload(ctx)
+	return hook, nil
+}
+
+// ListWebhooks returns the project's webhooks without their secrets.
+func (s *WebhookStore) ListWebhooks(ctx context.Context, projectID string) ([]models.Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE project_id = $1 ORDER BY id;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		hooks = append(hooks, *hook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+func (s *WebhookStore) GetWebhook(ctx context.Context, projectID string, id int) (*models.Webhook, error) {
+	hook, err := scanWebhook(s.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1 AND project_id = $2;`, id, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return hook, nil
+}
+
+// UpdateWebhook replaces the URL, name, event types and enabled flag of a
+// webhook of the project. Its secret is kept.
+func (s *WebhookStore) UpdateWebhook(ctx context.Context, projectID string, id int, req models.WebhookRequest) (*models.Webhook, error) {
+	enabled := req.Enabled == nil || *req.Enabled
+	hook, err := scanWebhook(s.db.QueryRowContext(ctx, `
+		UPDATE webhooks
+		SET name = $3, url = $4, event_types = $5, enabled = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+webhookColumns+`;
+	`, id, projectID, req.Name, req.URL, pq.Array(req.EventTypes), enabled))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	s.reload(ctx)
+	return hook, nil
+}
+
+// RotateSecret gives a webhook of the project a new secret and returns it
+// with the webhook. Deliveries are signed with the new secret from then on,
+// retries of earlier ones included.
+func (s *WebhookStore) RotateSecret(ctx context.Context, projectID string, id int) (*models.Webhook, error) {
+	secret, err := utils.NewToken(utils.WebhookSecretPrefix)
+	if err != nil {
+		return nil, err
+	}
+	hook, err := scanWebhook(s.db.QueryRowContext(ctx, `
+		UPDATE webhooks SET secret = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+webhookColumns+`;
+	`, id, projectID, secret))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+	hook.Secret = secret
+	return hook, nil
+}
+
+// DeleteWebhook removes a webhook of the project with its deliveries.
+func (s *WebhookStore) DeleteWebhook(ctx context.Context, projectID string, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND project_id = $2;`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("webhook %d: %w", id, ErrNotFound)
+	}
+	s.reload(ctx)
+	return nil
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, status, attempts, next_attempt_at, response_status, last_error, created_at, completed_at`
+
+func scanWebhookDelivery(row rowScanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	var (
+		d              models.WebhookDelivery
+		nextAttemptAt  sql.NullTime
+		responseStatus sql.NullInt64
+		completedAt    sql.NullTime
+	)
+	dest := append([]interface{}{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+		&nextAttemptAt, &responseStatus, &d.LastError, &d.CreatedAt, &completedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if nextAttemptAt.Valid && d.Status == models.WebhookDeliveryPending {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		d.ResponseStatus = &status
+	}
+	if completedAt.Valid {
+		d.CompletedAt = &completedAt.Time
+	}
+	return &d, nil
+}
+
+// ListDeliveries returns the deliveries of a webhook of the project, newest
+// first, optionally only those with status.
+func (s *WebhookStore) ListDeliveries(ctx context.Context, projectID string, webhookID int, status string, limit uint64) ([]models.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`, payload
+		FROM webhook_deliveries
+		WHERE webhook_id = (SELECT id FROM webhooks WHERE id = $1 AND project_id = $2)
+		  AND ($3 = '' OR status = $3)
+		ORDER BY id DESC
+		LIMIT $4;
+	`, webhookID, projectID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var payload []byte
+		d, err := scanWebhookDelivery(rows, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RedeliverDelivery puts a delivery of a webhook of the project back in the
+// queue, due now, with a fresh set of attempts.
+func (s *WebhookStore) RedeliverDelivery(ctx context.Context, projectID string, webhookID int, id int64) (*models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(s.db.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, completed_at = NULL
+		WHERE id = $1 AND webhook_id = (SELECT id FROM webhooks WHERE id = $2 AND project_id = $3)
+		RETURNING `+webhookDeliveryColumns+`;
+	`, id, webhookID, projectID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook delivery %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+// DueDelivery is a delivery claimed for sending, with what is needed to send
+// it.
+type DueDelivery struct {
+	models.WebhookDelivery
+	ProjectID string
+	URL       string
+	Secret    string
+}
+
+// ClaimDeliveries returns up to limit pending deliveries of enabled webhooks
+// that are due, pushing their next attempt lease into the future so no other
+// instance claims them while they are being sent.
+func (s *WebhookStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET next_attempt_at = CURRENT_TIMESTAMP + $2::BIGINT * INTERVAL '1 millisecond'
+			WHERE id IN (
+				SELECT d.id FROM webhook_deliveries d
+				JOIN webhooks w ON w.id = d.webhook_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= CURRENT_TIMESTAMP AND w.enabled
+				ORDER BY d.next_attempt_at
+				FOR UPDATE OF d SKIP LOCKED
+				LIMIT $1
+			)
+			RETURNING `+webhookDeliveryColumns+`, payload
+		)
+		SELECT c.*, w.project_id, w.url, w.secret
+		FROM claimed c
+		JOIN webhooks w ON w.id = c.webhook_id
+		ORDER BY c.id;
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var due []DueDelivery
+	for rows.Next() {
+		var (
+			dd      DueDelivery
+			payload []byte
+		)
+		d, err := scanWebhookDelivery(rows, &payload, &dd.ProjectID, &dd.URL, &dd.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		dd.WebhookDelivery = *d
+		due = append(due, dd)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return due, nil
+}
+
+// RecordAttempt stores the outcome of sending a delivery. A failed attempt
+// is retried at retryAt, or marks the delivery failed when retryAt is nil.
+func (s *WebhookStore) RecordAttempt(ctx context.Context, id int64, responseStatus int, sendErr error, retryAt *time.Time) error {
+	var code interface{}
+	if responseStatus != 0 {
+		code = responseStatus
+	}
+	status, lastError, done := models.WebhookDeliveryDelivered, "", true
+	if sendErr != nil {
+		status, lastError = models.WebhookDeliveryFailed, sendErr.Error()
+		if retryAt != nil {
+			status, done = models.WebhookDeliveryPending, false
+		}
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, response_status = $3, last_error = $4,
+		    next_attempt_at = COALESCE($5, next_attempt_at),
+		    completed_at = CASE WHEN $6 THEN CURRENT_TIMESTAMP END
+		WHERE id = $1;
+	`, id, status, code, lastError, retryAt, done)
+	if err != nil {
+		return fmt.Errorf("failed to record attempt of webhook delivery %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteDeliveriesBefore deletes delivered and failed deliveries completed
+// before cutoff.
+func (s *WebhookStore) DeleteDeliveriesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE status <> 'pending' AND completed_at < $1;`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	return res.RowsAffected()
+}
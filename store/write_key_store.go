@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"mabletask/api/models"
+	"mabletask/api/utils"
+)
+
+// WriteKeyStore manages the per-project write keys of ingestion. PostgreSQL
+// holds the key hashes; an in-memory copy of the usable ones serves the
+// ingestion path until the next Refresh, so a key revoked on another instance
+// stops working there within the refresh interval.
+type WriteKeyStore struct {
+	db *sql.DB
+
+	mu   sync.RWMutex
+	keys map[string]activeWriteKey // key hash -> key
+}
+
+type activeWriteKey struct {
+	projectID string
+	expiresAt *time.Time
+}
+
+func NewWriteKeyStore(db *sql.DB) *WriteKeyStore {
+	return &WriteKeyStore{db: db, keys: map[string]activeWriteKey{}}
+}
+
+// writeKeyPrefixLen is how much of a key is kept in clear to identify it.
+const writeKeyPrefixLen = 10
+
+const writeKeyColumns = `id, project_id, name, prefix, created_by, created_at, expires_at, revoked_at`
+
+func scanWriteKey(row rowScanner) (*models.WriteKey, error) {
+	var (
+		key                  models.WriteKey
+		createdBy            sql.NullInt64
+		expiresAt, revokedAt sql.NullTime
+	)
+	if err := row.Scan(&key.ID, &key.ProjectID, &key.Name, &key.Prefix, &createdBy, &key.CreatedAt, &expiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		key.CreatedBy = &id
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// Refresh reloads the usable write keys.
+func (s *WriteKeyStore) Refresh(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT key_hash, project_id, expires_at
+		FROM write_keys
+		WHERE revoked_at IS NULL AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load write keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := map[string]activeWriteKey{}
+	for rows.Next() {
+		var (
+			hash, projectID string
+			expiresAt       sql.NullTime
+		)
+		if err := rows.Scan(&hash, &projectID, &expiresAt); err != nil {
+			return fmt.Errorf("failed to scan write key: %w", err)
+		}
+		key := activeWriteKey{projectID: projectID}
+		if expiresAt.Valid {
+			key.expiresAt = &expiresAt.Time
+		}
+		keys[hash] = key
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating write keys: %w", err)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+// Resolve returns the project of a usable write key.
+func (s *WriteKeyStore) Resolve(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	s.mu.RLock()
+	active, ok := s.keys[utils.HashToken(key)]
+	s.mu.RUnlock()
+	if !ok || (active.expiresAt != nil && !time.Now().Before(*active.expiresAt)) {
+		return "", false
+	}
+	return active.projectID, true
+}
+
+func (s *WriteKeyStore) cache(hash string, key activeWriteKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[hash] = key
+}
+
+func (s *WriteKeyStore) uncache(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, hash)
+}
+
+// createKey inserts a new key for the project and returns it with its secret.
+func createKey(ctx context.Context, q queryRower, projectID string, createdBy int, name string) (*models.WriteKey, string, error) {
+	secret, err := utils.NewToken(utils.WriteKeyPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+	var creator interface{}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+	hash := utils.HashToken(secret)
+
+	key, err := scanWriteKey(q.QueryRowContext(ctx, `
+		INSERT INTO write_keys (project_id, name, prefix, key_hash, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+writeKeyColumns+`;
+	`, projectID, name, secret[:writeKeyPrefixLen], hash, creator))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create write key: %w", err)
+	}
+	key.Key = secret
+	return key, hash, nil
+}
+
+// CreateKey issues a new write key for the project. The returned key is the
+// only copy of the secret.
+func (s *WriteKeyStore) CreateKey(ctx context.Context, projectID string, createdBy int, req models.WriteKeyRequest) (*models.WriteKey, error) {
+	key, hash, err := createKey(ctx, s.db, projectID, createdBy, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	s.cache(hash, activeWriteKey{projectID: projectID})
+	return key, nil
+}
+
+// ListKeys returns the project's write keys, revoked ones included, without
+// their secrets.
+func (s *WriteKeyStore) ListKeys(ctx context.Context, projectID string) ([]models.WriteKey, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+writeKeyColumns+` FROM write_keys WHERE project_id = $1 ORDER BY id;`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list write keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.WriteKey{}
+	for rows.Next() {
+		key, err := scanWriteKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan write key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating write keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RotateKey replaces a usable key of the project by a new key of the same
+// name. The old key expires after grace, or at once if grace is zero.
+func (s *WriteKeyStore) RotateKey(ctx context.Context, projectID string, id int, grace time.Duration, createdBy int) (*models.WriteKey, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var name, oldHash string
+	var expiresAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		UPDATE write_keys
+		SET expires_at = LEAST(COALESCE(expires_at, 'infinity'), CURRENT_TIMESTAMP + make_interval(secs => $3))
+		WHERE id = $1 AND project_id = $2 AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		RETURNING name, key_hash, expires_at;
+	`, id, projectID, grace.Seconds()).Scan(&name, &oldHash, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("write key %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire write key: %w", err)
+	}
+
+	key, hash, err := createKey(ctx, tx, projectID, createdBy, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit write key rotation: %w", err)
+	}
+
+	if grace > 0 {
+		s.cache(oldHash, activeWriteKey{projectID: projectID, expiresAt: &expiresAt})
+	} else {
+		s.uncache(oldHash)
+	}
+	s.cache(hash, activeWriteKey{projectID: projectID})
+	return key, nil
+}
+
+// RevokeKey disables a write key of the project immediately.
+func (s *WriteKeyStore) RevokeKey(ctx context.Context, projectID string, id int) (*models.WriteKey, error) {
+	var hash string
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE write_keys SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND project_id = $2 AND revoked_at IS NULL
+		RETURNING key_hash;
+	`, id, projectID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("write key %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke write key: %w", err)
+	}
+	s.uncache(hash)
+
+	key, err := scanWriteKey(s.db.QueryRowContext(ctx, `SELECT `+writeKeyColumns+` FROM write_keys WHERE id = $1;`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get write key: %w", err)
+	}
+	return key, nil
+}
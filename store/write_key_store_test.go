@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"mabletask/api/utils"
+)
+
+const (
+	testWriteKey        = "wk_0123456789abcdef0123456789abcdef"
+	testExpiredWriteKey = "wk_fedcba9876543210fedcba9876543210"
+)
+
+// newTestWriteKeyStore returns a store loaded with testWriteKey of project
+// "shop" and testExpiredWriteKey, which expired after it was loaded.
+func newTestWriteKeyStore(t *testing.T) (*WriteKeyStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mock.ExpectQuery(`SELECT key_hash, project_id, expires_at\s+FROM write_keys`).
+		WillReturnRows(sqlmock.NewRows([]string{"key_hash", "project_id", "expires_at"}).
+			AddRow(utils.HashToken(testWriteKey), "shop", nil).
+			AddRow(utils.HashToken(testExpiredWriteKey), "shop", time.Now().Add(-time.Minute)))
+	s := NewWriteKeyStore(db)
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	return s, mock
+}
+
+func TestResolveWriteKey(t *testing.T) {
+	s, _ := newTestWriteKeyStore(t)
+
+	if projectID, ok := s.Resolve(testWriteKey); !ok || projectID != "shop" {
+		t.Errorf("Resolve(valid) = %q, %v; want shop, true", projectID, ok)
+	}
+	for name, key := range map[string]string{
+		"missing": "",
+		"unknown": "wk_00000000000000000000000000000000",
+		"expired": testExpiredWriteKey,
+	} {
+		if projectID, ok := s.Resolve(key); ok {
+			t.Errorf("Resolve(%s) = %q, true; want rejected", name, projectID)
+		}
+	}
+}
+
+func TestResolveRevokedWriteKey(t *testing.T) {
+	s, mock := newTestWriteKeyStore(t)
+	mock.ExpectQuery(`UPDATE write_keys SET revoked_at = CURRENT_TIMESTAMP`).
+		WithArgs(3, "shop").
+		WillReturnRows(sqlmock.NewRows([]string{"key_hash"}).AddRow(utils.HashToken(testWriteKey)))
+	mock.ExpectQuery(`SELECT ` + writeKeyColumns + ` FROM write_keys WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "prefix", "created_by", "created_at", "expires_at", "revoked_at"}).
+			AddRow(3, "shop", "site", testWriteKey[:writeKeyPrefixLen], nil, time.Now(), nil, time.Now()))
+
+	if _, err := s.RevokeKey(context.Background(), "shop", 3); err != nil {
+		t.Fatalf("RevokeKey: %v", err)
+	}
+	if projectID, ok := s.Resolve(testWriteKey); ok {
+		t.Errorf("Resolve(revoked) = %q, true; want rejected", projectID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
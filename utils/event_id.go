@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewEventID returns a UUIDv7 whose embedded timestamp is the event time t, so
+// that IDs sort with the events they name, even when importing history.
+func NewEventID(t time.Time) string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.New().String()
+	}
+	// The first 48 bits of a UUIDv7 are the Unix time in milliseconds.
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+	copy(id[:6], ms[2:])
+	return id.String()
+}
+
+// IsEventID reports whether id is a UUIDv7 and therefore acceptable as a
+// client-supplied event ID.
+func IsEventID(id string) bool {
+	u, err := uuid.Parse(id)
+	return err == nil && u.Version() == 7 && u.Variant() == uuid.RFC4122
+}
@@ -1,5 +1,14 @@
 package utils
 
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
 func IsValidInterval(interval string) bool {
 	switch interval {
 	case "Minute", "Hour", "Day", "Week", "Month", "Quarter", "Year":
@@ -9,3 +18,59 @@ func IsValidInterval(interval string) bool {
 	}
 }
 
+var projectIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// IsValidProjectID reports whether id is usable as a project (site) ID.
+func IsValidProjectID(id string) bool {
+	return projectIDPattern.MatchString(id)
+}
+
+// GetEnvDuration reads a Go duration (e.g. "24h", "90s") from the environment,
+// falling back to def when the variable is unset or malformed.
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Invalid %s %q, using default %s", key, raw, def)
+		return def
+	}
+	return d
+}
+
+// GetEnvInt64 reads an integer from the environment, falling back to def when
+// the variable is unset or malformed.
+func GetEnvInt64(key string, def int64) int64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Invalid %s %q, using default %d", key, raw, def)
+		return def
+	}
+	return n
+}
+
+// CorrectClientTimestamp maps a client-reported event time onto the server clock.
+// The skew between the client's sentAt and the server's receivedAt is added to
+// the event time; results that fall outside the acceptance window (or in the
+// future) are rejected and receivedAt is returned with ok=false.
+func CorrectClientTimestamp(clientTime, sentAt, receivedAt time.Time, window time.Duration) (time.Time, bool) {
+	var skew time.Duration
+	if !sentAt.IsZero() {
+		skew = receivedAt.Sub(sentAt)
+	}
+
+	corrected := clientTime.Add(skew)
+	if corrected.After(receivedAt) {
+		corrected = receivedAt
+	}
+	if receivedAt.Sub(corrected) > window {
+		return receivedAt, false
+	}
+	return corrected.UTC(), true
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+)
+
+// Modes of IP_ANONYMIZE.
+const (
+	IPAnonymizeNone     = "none"
+	IPAnonymizeTruncate = "truncate"
+	IPAnonymizeHash     = "hash"
+)
+
+// IPAnonymizer rewrites client IPs before they are stored. Truncation zeroes
+// the last octet of IPv4 and the last 80 bits of IPv6 addresses; hashing
+// replaces the address with a keyed SHA-256 hash, so events of one address
+// can still be told apart from others without revealing it. A nil
+// IPAnonymizer keeps addresses as they are.
+type IPAnonymizer struct {
+	mode string
+	salt []byte
+}
+
+// IPAnonymizerFromEnv reads IP_ANONYMIZE (none, truncate or hash) and, for
+// hash, the IP_HASH_SALT key. It returns nil for none.
+func IPAnonymizerFromEnv() (*IPAnonymizer, error) {
+	switch mode := os.Getenv("IP_ANONYMIZE"); mode {
+	case "", IPAnonymizeNone:
+		return nil, nil
+	case IPAnonymizeTruncate:
+		return &IPAnonymizer{mode: mode}, nil
+	case IPAnonymizeHash:
+		salt := os.Getenv("IP_HASH_SALT")
+		if salt == "" {
+			return nil, errors.New("IP_HASH_SALT is required when IP_ANONYMIZE is hash")
+		}
+		return &IPAnonymizer{mode: mode, salt: []byte(salt)}, nil
+	default:
+		return nil, fmt.Errorf("unknown IP_ANONYMIZE %q; use none, truncate or hash", mode)
+	}
+}
+
+// Anonymize returns the address to store for ip. Addresses that do not parse
+// are dropped when truncating, as they cannot be truncated safely.
+func (a *IPAnonymizer) Anonymize(ip string) string {
+	if a == nil || ip == "" {
+		return ip
+	}
+	if a.mode == IPAnonymizeHash {
+		mac := hmac.New(sha256.New, a.salt)
+		mac.Write([]byte(ip))
+		return hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.WithZone("").Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.Addr().String()
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWK is a public key in JSON Web Key format, as published at
+// /.well-known/jwks.json.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// verificationKey is a public key tokens may be signed with.
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+	jwk    JWK
+}
+
+var (
+	// signingMethod and signingKey sign new tokens; signingKeyID is set as
+	// their kid header. Without a private key tokens are signed with HS256
+	// and jwtSecret.
+	signingMethod jwt.SigningMethod = jwt.SigningMethodHS256
+	signingKey    interface{}       = jwtSecret
+	signingKeyID  string
+	// verificationKeys are the public keys accepted by ValidateJWT, by kid.
+	verificationKeys = map[string]verificationKey{}
+)
+
+// LoadSigningKeys configures token signing from the environment:
+//
+//   - JWT_SECRET_KEY is the HS256 secret. HS256 tokens are accepted as long as
+//     it is set, so sessions survive a switch to asymmetric signing.
+//   - JWT_PRIVATE_KEY_FILE is a PEM RSA or Ed25519 private key. When set, new
+//     tokens are signed with it using RS256 or EdDSA.
+//   - JWT_PUBLIC_KEY_FILES lists further PEM public keys, comma-separated,
+//     that tokens are still accepted from, e.g. the previous key during a
+//     rotation.
+//
+// Public keys are identified by the kid header, the RFC 7638 thumbprint of
+// the key.
+func LoadSigningKeys() error {
+	jwtSecret = []byte(os.Getenv("JWT_SECRET_KEY"))
+	signingMethod, signingKey, signingKeyID = jwt.SigningMethodHS256, jwtSecret, ""
+	verificationKeys = map[string]verificationKey{}
+
+	if path := os.Getenv("JWT_PRIVATE_KEY_FILE"); path != "" {
+		private, err := readPrivateKey(path)
+		if err != nil {
+			return err
+		}
+		var public crypto.PublicKey
+		switch k := private.(type) {
+		case *rsa.PrivateKey:
+			signingMethod, public = jwt.SigningMethodRS256, &k.PublicKey
+		case ed25519.PrivateKey:
+			signingMethod, public = jwt.SigningMethodEdDSA, k.Public()
+		default:
+			return fmt.Errorf("JWT_PRIVATE_KEY_FILE: unsupported key type %T; use an RSA or Ed25519 key", private)
+		}
+		key, err := newVerificationKey(public)
+		if err != nil {
+			return err
+		}
+		signingKey, signingKeyID = private, key.jwk.Kid
+		verificationKeys[key.jwk.Kid] = key
+	}
+
+	for _, path := range strings.Split(os.Getenv("JWT_PUBLIC_KEY_FILES"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		public, err := readPublicKey(path)
+		if err != nil {
+			return err
+		}
+		key, err := newVerificationKey(public)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		verificationKeys[key.jwk.Kid] = key
+	}
+	return nil
+}
+
+// JWKS returns the public keys tokens may be signed with. It is empty when
+// only HS256 is used.
+func JWKS() []JWK {
+	keys := make([]JWK, 0, len(verificationKeys))
+	for _, key := range verificationKeys {
+		keys = append(keys, key.jwk)
+	}
+	// The signing key first, then the others in a stable order.
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i].Kid == signingKeyID) != (keys[j].Kid == signingKeyID) {
+			return keys[i].Kid == signingKeyID
+		}
+		return keys[i].Kid < keys[j].Kid
+	})
+	return keys
+}
+
+// signToken signs claims with the configured key.
+func signToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(signingMethod, claims)
+	if signingKeyID != "" {
+		token.Header["kid"] = signingKeyID
+	}
+	return token.SignedString(signingKey)
+}
+
+// verificationKeyFor picks the key to check a token with: the HS256 secret,
+// or the public key named by its kid, whose algorithm the token must use.
+func verificationKeyFor(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(jwtSecret) == 0 {
+			return nil, fmt.Errorf("HS256 tokens are not accepted")
+		}
+		return jwtSecret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := verificationKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.key, nil
+}
+
+func newVerificationKey(public crypto.PublicKey) (verificationKey, error) {
+	var (
+		key   = verificationKey{key: public}
+		thumb string
+	)
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		n := base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		key.method = jwt.SigningMethodRS256
+		key.jwk = JWK{Kty: "RSA", Alg: "RS256", N: n, E: e}
+		thumb = `{"e":"` + e + `","kty":"RSA","n":"` + n + `"}`
+	case ed25519.PublicKey:
+		x := base64.RawURLEncoding.EncodeToString(k)
+		key.method = jwt.SigningMethodEdDSA
+		key.jwk = JWK{Kty: "OKP", Alg: "EdDSA", Crv: "Ed25519", X: x}
+		thumb = `{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`
+	default:
+		return key, fmt.Errorf("unsupported public key type %T; use an RSA or Ed25519 key", public)
+	}
+	sum := sha256.Sum256([]byte(thumb))
+	key.jwk.Kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	key.jwk.Use = "sig"
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
@@ -8,33 +8,44 @@ import (
 	"mabletask/api/models"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID  int    `json:"user_id"`
+	Email   string `json:"email"`
+	IsAdmin bool   `json:"is_admin,omitempty"`
+	// ImpersonatorID is the admin a support impersonation token was issued
+	// to; 0 for regular tokens.
+	ImpersonatorID int `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// jwtSecret is the HS256 secret, set by LoadSigningKeys.
 var jwtSecret = []byte(os.Getenv("JWT_SECRET_KEY"))
 
+// AccessTokenTTL is how long access tokens are valid. Sessions outlive it by
+// exchanging a refresh token at /api/refresh.
+var AccessTokenTTL = GetEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+
 func GenerateJWT(user *models.User) (string, error) {
-	expirationTime := time.Now().Add(1 * time.Hour)
+	expirationTime := time.Now().Add(AccessTokenTTL)
 
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+		UserID:  user.ID,
+		Email:   user.Email,
+		IsAdmin: user.IsAdmin,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "mabletask-api",
 			Subject:   fmt.Sprintf("%d", user.ID),
+			ID:        uuid.NewString(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := signToken(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -42,15 +53,39 @@ func GenerateJWT(user *models.User) (string, error) {
 	return tokenString, nil
 }
 
+// GenerateImpersonationJWT issues a token acting as user on behalf of the admin
+// impersonatorID, valid for ttl. It never carries admin rights, even for an
+// admin user.
+func GenerateImpersonationJWT(user *models.User, impersonatorID int, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expirationTime := now.Add(ttl)
+
+	claims := &Claims{
+		UserID:         user.ID,
+		Email:          user.Email,
+		ImpersonatorID: impersonatorID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "mabletask-api",
+			Subject:   fmt.Sprintf("%d", user.ID),
+			ID:        uuid.NewString(),
+		},
+	}
+
+	tokenString, err := signToken(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, expirationTime, nil
+}
+
 func ValidateJWT(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKeyFor)
 
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
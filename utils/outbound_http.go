@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned (wrapped) by OutboundClient when a request
+// would connect to a non-public address, and by CheckOutboundURL.
+var ErrForbiddenAddress = errors.New("destination address is not public")
+
+// nonPublicPrefixes are ranges netip.Addr.IsPrivate does not cover: "this
+// network", which Linux routes to the host, carrier-grade NAT (RFC 6598),
+// benchmarking networks (RFC 2544), and the NAT64 prefix (RFC 6052), which
+// embeds an IPv4 address that a NAT64 gateway would connect to.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// PublicAddress reports whether addr is a globally routable unicast address,
+// i.e. not loopback, private, link-local, multicast or unspecified.
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckOutboundURL rejects user-supplied URLs that are not http(s) or name a
+// host that is plainly not public: localhost or a non-public IP literal. Hosts
+// resolving to non-public addresses are caught by OutboundClient instead.
+func CheckOutboundURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenAddress, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrForbiddenAddress, u.Scheme)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, u.Host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !PublicAddress(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+	}
+	return nil
+}
+
+// OutboundTimeout bounds each request of OutboundClient.
+const OutboundTimeout = 10 * time.Second
+
+// OutboundClient sends every request to a URL supplied by users: audience
+// exports, webhook deliveries, destinations and alert notifications.
+var OutboundClient = newOutboundClient(OutboundTimeout)
+
+// newOutboundClient returns an HTTP client that only connects to public
+// addresses, checked on the resolved IP when dialing so a hostname cannot
+// point it at internal services. It ignores proxy settings and does not
+// follow redirects: a 3xx is returned to the caller like any other response.
+func newOutboundClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+			}
+			if !PublicAddress(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"198.18.0.1", false},
+		{"198.19.255.254", false},
+		{"198.20.0.1", true},
+		{"::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b:1::a9fe:a9fe", true},
+	}
+	for _, tt := range tests {
+		if got := PublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("PublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOutboundURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/hook", true},
+		{"http://93.184.216.34/hook", true},
+		{"ftp://example.com/hook", false},
+		{"http://localhost:8080/hook", false},
+		{"http://api.localhost/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://198.18.0.1/hook", false},
+		{"http://[64:ff9b::a9fe:a9fe]/hook", false},
+	}
+	for _, tt := range tests {
+		err := CheckOutboundURL(tt.url)
+		if tt.allowed && err != nil {
+			t.Errorf("CheckOutboundURL(%s) = %v, want allowed", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckOutboundURL(%s) = %v, want ErrForbiddenAddress", tt.url, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+import "math"
+
+// TwoProportionZTest compares the conversion rates x1/n1 and x2/n2 with a
+// pooled two-proportion z-test. It returns the z-score of the second rate
+// against the first and the two-sided p-value; ok is false when either group
+// is empty or nobody (or everybody) converted, where the test is undefined.
+func TwoProportionZTest(x1, n1, x2, n2 uint64) (z, p float64, ok bool) {
+	if n1 == 0 || n2 == 0 {
+		return 0, 0, false
+	}
+	p1 := float64(x1) / float64(n1)
+	p2 := float64(x2) / float64(n2)
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 0, 0, false
+	}
+	z = (p2 - p1) / se
+	return z, math.Erfc(math.Abs(z) / math.Sqrt2), true
+}
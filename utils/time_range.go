@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RangePresets lists the names accepted by RangePreset.
+var RangePresets = []string{"today", "yesterday", "last_7d", "last_30d", "this_month", "last_month"}
+
+// RangePreset resolves a relative range name to an inclusive [start, end]
+// range. Day and month boundaries are taken in now's location, so callers pass
+// the current time in the caller's timezone. The last_Nd presets cover the
+// last N calendar days including today. Returned times are in UTC.
+func RangePreset(name string, now time.Time) (time.Time, time.Time, bool) {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	thisMonth := time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+
+	var start, end time.Time
+	switch name {
+	case "today":
+		start, end = today, now
+	case "yesterday":
+		start, end = today.AddDate(0, 0, -1), today.Add(-time.Millisecond)
+	case "last_7d":
+		start, end = today.AddDate(0, 0, -6), now
+	case "last_30d":
+		start, end = today.AddDate(0, 0, -29), now
+	case "this_month":
+		start, end = thisMonth, now
+	case "last_month":
+		start, end = thisMonth.AddDate(0, -1, 0), thisMonth.Add(-time.Millisecond)
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+	return start.UTC(), end.UTC(), true
+}
+
+// epochMillisThreshold separates Unix seconds from Unix milliseconds: second
+// values stay below it until the year 5138, millisecond values pass it in 1973.
+const epochMillisThreshold = 100_000_000_000
+
+// ParseTimeParam parses a time query parameter given as RFC3339, Unix epoch
+// seconds or milliseconds, or a plain YYYY-MM-DD date in loc. With endOfDay, a
+// plain date resolves to the last millisecond of that day instead of midnight,
+// so that it can close an inclusive range. The result is in UTC.
+func ParseTimeParam(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if n >= epochMillisThreshold || n <= -epochMillisThreshold {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, loc); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Millisecond)
+		}
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 (e.g., 2006-01-02T15:04:05Z), Unix seconds or milliseconds, or YYYY-MM-DD", value)
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Prefixes of the opaque secrets the API issues, so they are recognizable,
+// e.g. in leaked source code.
+const (
+	WriteKeyPrefix      = "wk_"
+	RefreshTokenPrefix  = "rt_"
+	PasswordResetPrefix = "pr_"
+	EmailChangePrefix   = "ev_"
+	WebhookSecretPrefix = "whsec_"
+)
+
+// NewToken returns a random opaque secret starting with prefix.
+func NewToken(prefix string) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 of token, as stored in place of the
+// token itself.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}